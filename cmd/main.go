@@ -10,15 +10,22 @@
 package main
 
 import (
+	"context"
 	"go.uber.org/zap"
 	"os"
 	"pet/config"
 	"pet/internal/database"
 	"pet/internal/logger"
+	"pet/internal/mailer"
+	"pet/internal/metrics"
+	"pet/internal/middleware"
+	"pet/internal/password"
 	"pet/internal/repository"
 	"pet/internal/server"
 	"pet/internal/service"
+	"pet/internal/tracing"
 	"runtime/debug"
+	"time"
 )
 
 func main() {
@@ -41,6 +48,45 @@ func main() {
 		}
 	}()
 
+	// трассировка: спаны HTTP, сервисов и SQL выгружаются в config.TraceExporter
+	shutdownTracing, err := tracing.Init(context.Background(), config.TraceExporter, config.TraceFile)
+	if err != nil {
+		log.Error(
+			"cannot configure tracing",
+			zap.Error(err),
+			zap.String("component", "main"),
+			zap.String("event", "tracing_init"),
+		)
+		os.Exit(1)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := shutdownTracing(ctx)
+		if err != nil {
+			log.Warn(
+				"cannot flush traces",
+				zap.Error(err),
+				zap.String("component", "main"),
+				zap.String("event", "tracing_shutdown"),
+			)
+		}
+	}()
+
+	if config.PasswordPolicy.DenylistFile != "" {
+		err := password.LoadDenylistFile(config.PasswordPolicy.DenylistFile)
+		if err != nil {
+			log.Error(
+				"cannot load password denylist",
+				zap.Error(err),
+				zap.String("component", "main"),
+				zap.String("event", "password_policy"),
+			)
+			os.Exit(1)
+		}
+	}
+
 	// Подключаемся к локальной БД
 	dbName := "usersdb"
 	dbUsers, err := database.ConnectDB(dbName, log)
@@ -57,7 +103,76 @@ func main() {
 	}
 	defer dbUsers.Close()
 
+	err = database.Migrate(dbUsers, log)
+	if err != nil {
+		log.Error(
+			"cannot apply migrations",
+			zap.Error(err),
+			zap.String("db_name", dbName),
+			zap.String("component", "database"),
+			zap.String("event", "migrate"),
+		)
+		os.Exit(1)
+	}
+
+	// метрики пула соединений (sql.DB.Stats) для /metrics
+	err = metrics.RegisterDB(dbUsers, dbName)
+	if err != nil {
+		log.Warn(
+			"cannot register database metrics",
+			zap.Error(err),
+			zap.String("component", "main"),
+			zap.String("event", "metrics"),
+		)
+	}
+
 	repo := repository.NewUserRepository(dbUsers, log)
+
+	// лимиты запросов: в памяти процесса или в Postgres, чтобы несколько экземпляров делили квоты
+	var limiterStore middleware.LimiterStore = middleware.NewMemoryLimiterStore()
+	if config.RateLimitStore == "postgres" {
+		limiterStore = repository.NewRateLimitStore(dbUsers, log)
+	}
+	limiterStore.Start()
+	defer limiterStore.Stop()
+	middleware.InitLimiterStore(limiterStore)
+
+	// первый администратор назначается через ADMIN_EMAIL, дальше роли меняются через /admin/users/{id}/role
+	if cfg.AdminEmail != "" {
+		err = repo.SetUserRoleByEmail(context.Background(), cfg.AdminEmail, middleware.RoleAdmin)
+		if err != nil {
+			log.Warn(
+				"cannot assign admin role",
+				zap.Error(err),
+				zap.String("user.email", cfg.AdminEmail),
+				zap.String("component", "main"),
+				zap.String("event", "bootstrap_admin"),
+			)
+		}
+	}
+	// провайдер, который сейчас недоступен, просто не появится среди способов входа
+	err = server.InitOIDCProviders(context.Background(), cfg.OIDCProviders, log)
+	if err != nil {
+		log.Warn(
+			"cannot configure oidc provider",
+			zap.Error(err),
+			zap.String("component", "main"),
+			zap.String("event", "oidc_init"),
+		)
+	}
+
+	m, err := mailer.New(cfg.Mail)
+	if err != nil {
+		log.Error(
+			"cannot configure mailer",
+			zap.Error(err),
+			zap.String("component", "main"),
+			zap.String("event", "mailer_init"),
+		)
+		os.Exit(1)
+	}
+	server.InitMailer(m)
+
 	srv := service.NewUserService(repo, log)
 	_ = srv
 
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"github.com/joho/godotenv"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -13,12 +16,235 @@ var (
 	JWTSecret       = []byte("super-secret-key")
 	AccessTokenTTL  = 15 * time.Minute
 	RefreshTokenTTL = 1200 * time.Hour
+	MFATokenTTL     = 5 * time.Minute // время жизни токена между вводом пароля и вводом TOTP-кода
+
+	ImpersonationTokenTTL = 10 * time.Minute // время жизни токена администратора, действующего от имени пользователя
+
+	UserStatusCacheTTL = 30 * time.Second // сколько Auth может не перечитывать статус аккаунта из БД
+)
+
+// настройки защиты входа от перебора паролей (см. service.LoginGuard)
+var (
+	LoginBackoffAfter     = 3                // после скольких неудач по e-mail включается задержка
+	LoginMaxFailures      = 5                // после скольких неудач по e-mail аккаунт блокируется
+	LoginMaxFailuresPerIP = 50               // после скольких неудач с одного IP он блокируется
+	LoginLockoutDuration  = 15 * time.Minute // длительность блокировки и окно подсчёта неудач
+	LoginBackoffBase      = time.Second      // первая задержка, дальше удваивается
+	LoginBackoffMax       = time.Minute      // максимальная задержка между попытками
+)
+
+// настройки хеширования паролей (см. пакет password)
+var (
+	PasswordAlgorithm = "argon2id" // алгоритм для новых хешей: argon2id или bcrypt
+	Argon2Memory      = 64 * 1024  // память argon2id в КиБ
+	Argon2Iterations  = 3
+	Argon2Parallelism = 2
+	BcryptCost        = 12
+)
+
+// PasswordPolicyConfig — требования к паролю при регистрации и смене пароля
+type PasswordPolicyConfig struct {
+	MinLength     int
+	MaxLength     int // 0 — без ограничения
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	DenylistFile  string // дополнительный список запрещённых паролей, по одному на строку
+}
+
+// PasswordPolicy — текущая политика паролей
+var PasswordPolicy = PasswordPolicyConfig{
+	MinLength:    8,
+	MaxLength:    128,
+	RequireLower: true,
+	RequireDigit: true,
+}
+
+// настройки входа по ссылке из письма (magic link)
+var (
+	MagicLinkEnabled      = false
+	MagicLinkURL          = "http://localhost:3000/login/magic" // страница клиента, которая отправит токен в POST /login/magic/redeem
+	MagicLinkTTL          = 15 * time.Minute
+	MagicLinkMaxPerWindow = 3         // сколько писем можно запросить на один e-mail за окно
+	MagicLinkWindow       = time.Hour // окно ограничения частоты писем
+)
+
+// настройки регистрации и приглашений
+var (
+	RegistrationOpen = true                           // false — зарегистрироваться можно только по приглашению
+	InvitationURL    = "http://localhost:3000/invite" // страница клиента, которая отправит токен в POST /invitations/accept
+	InvitationTTL    = 7 * 24 * time.Hour
+)
+
+// настройки ключей доступа WebAuthn (passkeys)
+var (
+	WebAuthnRPID       = "localhost"                       // домен, к которому привязываются ключи (без схемы и порта)
+	WebAuthnRPName     = "Users API"                       // название сервиса, которое видит пользователь
+	WebAuthnOrigins    = []string{"http://localhost:8080"} // разрешённые origin клиентов
+	PasskeyCeremonyTTL = 5 * time.Minute                   // сколько ждать ответа аутентификатора между begin и finish
+)
+
+// RateLimit — лимит запросов: Rate в секунду в среднем и Burst подряд (он же RateLimit-Limit)
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// RateLimitPolicy — лимиты группы маршрутов по типу клиента. Незаданный (нулевой) лимит
+// берётся из группы default
+type RateLimitPolicy struct {
+	Anonymous RateLimit            // без аутентификации — по IP
+	User      RateLimit            // пользователь с JWT — по sub
+	APIKey    RateLimit            // по API-ключу
+	Roles     map[string]RateLimit // вместо User для пользователей с этими ролями
+}
+
+// группы маршрутов для RateLimits
+const (
+	RateLimitGroupDefault = "default"
+	RateLimitGroupAuth    = "auth" // вход и регистрация: строже, чтобы затруднить перебор
+
+	// RateLimitGroupCredentials — лимит по IP на запросы к защищённым маршрутам до проверки токена
+	// или API-ключа (см. middleware.RateLimitByIP). Задаётся только Anonymous; он выше лимитов
+	// пользователей, чтобы не мешать клиентам за общим IP
+	RateLimitGroupCredentials = "credentials"
+)
+
+// настройки ограничения частоты запросов (см. middleware.RateLimit)
+var (
+	RateLimitEnabled = true
+	RateLimitStore   = "memory" // memory — свои квоты у каждого экземпляра, postgres — общие для всех
+
+	RateLimits = map[string]RateLimitPolicy{
+		RateLimitGroupDefault: {
+			Anonymous: RateLimit{Rate: 5, Burst: 10},
+			User:      RateLimit{Rate: 10, Burst: 20},
+			APIKey:    RateLimit{Rate: 20, Burst: 40},
+			Roles:     map[string]RateLimit{"admin": {Rate: 50, Burst: 100}},
+		},
+		RateLimitGroupAuth: {
+			Anonymous: RateLimit{Rate: 0.2, Burst: 5}, // 5 попыток подряд, дальше одна в 5 секунд
+		},
+		RateLimitGroupCredentials: {
+			Anonymous: RateLimit{Rate: 100, Burst: 200},
+		},
+	}
+)
+
+// TrustedProxies - подсети балансировщиков и прокси, которым можно верить в X-Forwarded-For,
+// Forwarded и X-Real-IP. Пусто — IP клиента всегда берётся из адреса соединения
+var TrustedProxies []string
+
+// CorsPolicy — политика CORS: с каких сайтов (origin) браузер может обращаться к API.
+// В AllowedOrigins допускаются шаблоны поддоменов вида "https://*.example.com" (без самого example.com)
+type CorsPolicy struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"` // заголовки ответа, которые видит JavaScript
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age"` // сколько секунд браузер кеширует ответ на предзапрос; 0 — CorsDefaultMaxAge
+}
+
+// значения по умолчанию для незаданных полей CorsPolicy
+var (
+	CorsDefaultMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	CorsDefaultHeaders = []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID", "Idempotency-Key"}
+	CorsDefaultExposed = []string{"ETag", "RateLimit-Limit", "RateLimit-Remaining", "Retry-After", "X-Request-ID", "Idempotent-Replayed"}
+	CorsDefaultMaxAge  = 600
 )
 
+// CorsProfiles — встроенные политики CORS по окружению (APP_ENV). В prod источники не разрешены,
+// пока не заданы в CORS_CONFIG_FILE или CORS_ALLOWED_ORIGINS
+var CorsProfiles = map[string]CorsPolicy{
+	"dev": {
+		AllowedOrigins:   []string{"http://localhost:3000", "http://127.0.0.1:3000"},
+		AllowCredentials: true,
+	},
+	"prod": {
+		AllowCredentials: true,
+	},
+}
+
+// Cors — политика CORS, загруженная при старте (см. LoadCorsPolicy)
+var Cors CorsPolicy
+
+// таймауты HTTP-сервера (см. server.runServer). WriteTimeout должен быть больше сроков запросов,
+// иначе соединение закроется раньше, чем клиент получит ответ 504
+var (
+	HTTPReadHeaderTimeout = 5 * time.Second
+	HTTPReadTimeout       = 15 * time.Second
+	HTTPWriteTimeout      = 30 * time.Second
+	HTTPIdleTimeout       = 60 * time.Second
+)
+
+// сроки обработки запросов (см. middleware.Deadline)
+var (
+	RequestTimeout = 10 * time.Second // срок по умолчанию
+
+	// RouteTimeouts — сроки отдельных маршрутов, ключ — "<МЕТОД> <шаблон пути>", например "POST /login"
+	RouteTimeouts = map[string]time.Duration{}
+)
+
+// доступ к метрикам Prometheus (/metrics). С MetricsAddr метрики отдаются отдельным сервером
+// на этом адресе (например, ":9090" во внутренней сети), иначе — на основном порту,
+// но только с заголовком "Authorization: Bearer <MetricsToken>". Без обоих /metrics нет
+var (
+	MetricsAddr  string
+	MetricsToken string
+)
+
+// трассировка OpenTelemetry (см. пакет tracing). TraceExporter: none — трассы не выгружаются
+// (ID трасс всё равно есть в логах и передаются дальше), otlp — в коллектор по OTLP/HTTP
+// (адрес в стандартной OTEL_EXPORTER_OTLP_ENDPOINT), file — построчно в JSON в TraceFile
+var (
+	TraceExporter = "none"
+	TraceFile     = "tmp/traces.json"
+)
+
+// IdempotencyTTL - сколько хранится ответ на запрос с Idempotency-Key (см. middleware.Idempotency)
+var IdempotencyTTL = 24 * time.Hour
+
+// MaxRequestBodySize - наибольший размер тела JSON-запроса в байтах, больше — 413
+var MaxRequestBodySize int64 = 1 << 20
+
+// HandleReservationTTL - сколько старое имя пользователя после смены закреплено за прежним владельцем
+var HandleReservationTTL = 30 * 24 * time.Hour
+
+// TOTPIssuer - название сервиса, которое видит пользователь в приложении-аутентификаторе
+var TOTPIssuer = "Users API"
+
 // Config хранит настройки приложения, включая настройки базы данных и логгера
 type Config struct { // единая точка загрузки
 	PostgresDSN string       // Строка подключения к PostgreSQL
 	Logger      LoggerConfig // Настройки логгера
+	AdminEmail  string       // E-mail пользователя, которому при старте назначается роль admin (необязательно)
+
+	OIDCProviders []OIDCProviderConfig // Внешние провайдеры входа (OpenID Connect)
+	Mail          MailConfig           // Отправка писем
+}
+
+// MailConfig хранит настройки отправки писем
+type MailConfig struct {
+	Driver       string // MAIL_DRIVER: smtp или file (по умолчанию file — письма пишутся в каталог Dir)
+	Dir          string // MAIL_DIR: каталог для писем драйвера file
+	SMTPAddr     string // MAIL_SMTP_ADDR: host:port
+	SMTPUsername string // MAIL_SMTP_USERNAME
+	SMTPPassword string // MAIL_SMTP_PASSWORD
+	From         string // MAIL_FROM: адрес отправителя
+}
+
+// OIDCProviderConfig хранит настройки одного OIDC-провайдера.
+// Задаётся через OIDC_PROVIDERS=corp,google и переменные OIDC_<ИМЯ>_*
+type OIDCProviderConfig struct {
+	Name          string   // Имя в URL: /auth/oidc/{name}/login
+	IssuerURL     string   // OIDC_<ИМЯ>_ISSUER: по нему ищется /.well-known/openid-configuration
+	ClientID      string   // OIDC_<ИМЯ>_CLIENT_ID
+	ClientSecret  string   // OIDC_<ИМЯ>_CLIENT_SECRET (необязательно для публичных клиентов)
+	RedirectURL   string   // OIDC_<ИМЯ>_REDIRECT_URL: адрес /auth/oidc/{name}/callback этого API
+	Scopes        []string // OIDC_<ИМЯ>_SCOPES через пробел, по умолчанию "openid email profile"
+	AutoProvision bool     // OIDC_<ИМЯ>_AUTO_PROVISION: создавать пользователя при первом входе (по умолчанию true)
 }
 
 // LoggerConfig хранит конфигурацию логгера: уровень, среду выполнения и вывод стека ошибок
@@ -71,8 +297,126 @@ func LoadConfig() *Config {
 		boolLogWithStack = true
 	}
 
+	// необязательные настройки защиты входа, по умолчанию используются значения выше
+	LoginBackoffAfter = intFromEnv("LOGIN_BACKOFF_AFTER", LoginBackoffAfter)
+	LoginMaxFailures = intFromEnv("LOGIN_MAX_FAILURES", LoginMaxFailures)
+	LoginMaxFailuresPerIP = intFromEnv("LOGIN_MAX_FAILURES_PER_IP", LoginMaxFailuresPerIP)
+	LoginLockoutDuration = durationFromEnv("LOGIN_LOCKOUT_DURATION", LoginLockoutDuration)
+	LoginBackoffBase = durationFromEnv("LOGIN_BACKOFF_BASE", LoginBackoffBase)
+	LoginBackoffMax = durationFromEnv("LOGIN_BACKOFF_MAX", LoginBackoffMax)
+
+	// необязательные настройки паролей
+	PasswordAlgorithm = os.Getenv("PASSWORD_ALGORITHM")
+	if PasswordAlgorithm == "" {
+		PasswordAlgorithm = "argon2id"
+	}
+	if PasswordAlgorithm != "argon2id" && PasswordAlgorithm != "bcrypt" {
+		log.Fatalf("Invalid PASSWORD_ALGORITHM: %s (must be argon2id or bcrypt)", PasswordAlgorithm)
+	}
+	BcryptCost = intFromEnv("BCRYPT_COST", BcryptCost)
+	Argon2Memory = intFromEnv("ARGON2_MEMORY_KIB", Argon2Memory)
+	Argon2Iterations = intFromEnv("ARGON2_ITERATIONS", Argon2Iterations)
+	PasswordPolicy.MinLength = intFromEnv("PASSWORD_MIN_LENGTH", PasswordPolicy.MinLength)
+	PasswordPolicy.MaxLength = intFromEnv("PASSWORD_MAX_LENGTH", PasswordPolicy.MaxLength)
+	PasswordPolicy.RequireUpper = boolFromEnv("PASSWORD_REQUIRE_UPPER", PasswordPolicy.RequireUpper)
+	PasswordPolicy.RequireLower = boolFromEnv("PASSWORD_REQUIRE_LOWER", PasswordPolicy.RequireLower)
+	PasswordPolicy.RequireDigit = boolFromEnv("PASSWORD_REQUIRE_DIGIT", PasswordPolicy.RequireDigit)
+	PasswordPolicy.RequireSymbol = boolFromEnv("PASSWORD_REQUIRE_SYMBOL", PasswordPolicy.RequireSymbol)
+	PasswordPolicy.DenylistFile = os.Getenv("PASSWORD_DENYLIST_FILE")
+
+	// необязательные настройки входа по ссылке
+	MagicLinkEnabled = boolFromEnv("MAGIC_LINK_ENABLED", MagicLinkEnabled)
+	if url := os.Getenv("MAGIC_LINK_URL"); url != "" {
+		MagicLinkURL = url
+	}
+	MagicLinkTTL = durationFromEnv("MAGIC_LINK_TTL", MagicLinkTTL)
+	MagicLinkMaxPerWindow = intFromEnv("MAGIC_LINK_MAX_PER_WINDOW", MagicLinkMaxPerWindow)
+	MagicLinkWindow = durationFromEnv("MAGIC_LINK_WINDOW", MagicLinkWindow)
+
+	// необязательные настройки регистрации
+	RegistrationOpen = boolFromEnv("REGISTRATION_OPEN", RegistrationOpen)
+	if url := os.Getenv("INVITATION_URL"); url != "" {
+		InvitationURL = url
+	}
+	InvitationTTL = durationFromEnv("INVITATION_TTL", InvitationTTL)
+
+	HandleReservationTTL = durationFromEnv("HANDLE_RESERVATION_TTL", HandleReservationTTL)
+	IdempotencyTTL = durationFromEnv("IDEMPOTENCY_TTL", IdempotencyTTL)
+	MaxRequestBodySize = int64(intFromEnv("MAX_REQUEST_BODY_SIZE", int(MaxRequestBodySize)))
+
+	// необязательные таймауты сервера и сроки запросов
+	HTTPReadHeaderTimeout = durationFromEnv("HTTP_READ_HEADER_TIMEOUT", HTTPReadHeaderTimeout)
+	HTTPReadTimeout = durationFromEnv("HTTP_READ_TIMEOUT", HTTPReadTimeout)
+	HTTPWriteTimeout = durationFromEnv("HTTP_WRITE_TIMEOUT", HTTPWriteTimeout)
+	HTTPIdleTimeout = durationFromEnv("HTTP_IDLE_TIMEOUT", HTTPIdleTimeout)
+	RequestTimeout = durationFromEnv("REQUEST_TIMEOUT", RequestTimeout)
+	loadRouteTimeouts()
+
+	MetricsAddr = os.Getenv("METRICS_ADDR")
+	MetricsToken = os.Getenv("METRICS_TOKEN")
+
+	if exporter := os.Getenv("TRACE_EXPORTER"); exporter != "" {
+		TraceExporter = exporter
+	}
+	if TraceExporter != "none" && TraceExporter != "otlp" && TraceExporter != "file" {
+		log.Fatalf("Invalid TRACE_EXPORTER: %s (must be none, otlp or file)", TraceExporter)
+	}
+	if file := os.Getenv("TRACE_FILE"); file != "" {
+		TraceFile = file
+	}
+
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		TrustedProxies = strings.Split(proxies, ",")
+	}
+
+	// необязательные настройки лимитов запросов
+	RateLimitEnabled = boolFromEnv("RATE_LIMIT_ENABLED", RateLimitEnabled)
+	if store := os.Getenv("RATE_LIMIT_STORE"); store != "" {
+		RateLimitStore = store
+	}
+	if RateLimitStore != "memory" && RateLimitStore != "postgres" {
+		log.Fatalf("Invalid RATE_LIMIT_STORE: %s (must be memory or postgres)", RateLimitStore)
+	}
+	loadRateLimits()
+
+	Cors, err = LoadCorsPolicy(inputAppEnv)
+	if err != nil {
+		log.Fatalf("Invalid CORS settings: %v", err)
+	}
+
+	// необязательные настройки ключей доступа
+	if rpID := os.Getenv("WEBAUTHN_RP_ID"); rpID != "" {
+		WebAuthnRPID = rpID
+	}
+	if rpName := os.Getenv("WEBAUTHN_RP_NAME"); rpName != "" {
+		WebAuthnRPName = rpName
+	}
+	if origins := os.Getenv("WEBAUTHN_ORIGINS"); origins != "" {
+		WebAuthnOrigins = strings.Split(origins, ",")
+	}
+
+	mailDir := os.Getenv("MAIL_DIR")
+	if mailDir == "" {
+		mailDir = "tmp/mail"
+	}
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "no-reply@localhost"
+	}
+
 	cfg := Config{
 		PostgresDSN: inputPostgresDSN,
+		AdminEmail:  os.Getenv("ADMIN_EMAIL"),
+
+		OIDCProviders: loadOIDCProviders(),
+		Mail: MailConfig{
+			Driver:       os.Getenv("MAIL_DRIVER"),
+			Dir:          mailDir,
+			SMTPAddr:     os.Getenv("MAIL_SMTP_ADDR"),
+			SMTPUsername: os.Getenv("MAIL_SMTP_USERNAME"),
+			SMTPPassword: os.Getenv("MAIL_SMTP_PASSWORD"),
+			From:         mailFrom,
+		},
 		Logger: LoggerConfig{
 			AppEnv:       inputAppEnv,
 			LogLevel:     inputLogLevel,
@@ -82,3 +426,273 @@ func LoadConfig() *Config {
 
 	return &cfg
 }
+
+// intFromEnv читает необязательное целое из переменной окружения
+func intFromEnv(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Fatalf("Invalid %s: %s (must be a positive integer)", name, value)
+	}
+	return n
+}
+
+// boolFromEnv читает необязательный флаг true/false из переменной окружения
+func boolFromEnv(name string, def bool) bool {
+	value := strings.ToLower(os.Getenv(name))
+	switch value {
+	case "":
+		return def
+	case "true":
+		return true
+	case "false":
+		return false
+	default:
+		log.Fatalf("Invalid %s: %s (must be true or false)", name, value)
+		return def
+	}
+}
+
+// durationFromEnv читает необязательную длительность ("15m", "1h") из переменной окружения
+func durationFromEnv(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Fatalf("Invalid %s: %s (must be a positive duration, e.g. 15m)", name, value)
+	}
+	return d
+}
+
+// loadOIDCProviders читает настройки OIDC-провайдеров. Без OIDC_PROVIDERS вход через провайдеров выключен
+func loadOIDCProviders() []OIDCProviderConfig {
+	var providers []OIDCProviderConfig
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		provider := OIDCProviderConfig{
+			Name:          name,
+			IssuerURL:     os.Getenv(prefix + "ISSUER"),
+			ClientID:      os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret:  os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:   os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:        strings.Fields(os.Getenv(prefix + "SCOPES")),
+			AutoProvision: strings.ToLower(os.Getenv(prefix+"AUTO_PROVISION")) != "false",
+		}
+		if provider.IssuerURL == "" || provider.ClientID == "" || provider.RedirectURL == "" {
+			log.Fatalf("OIDC provider %s: %sISSUER, %sCLIENT_ID and %sREDIRECT_URL must be set", name, prefix, prefix, prefix)
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers
+}
+
+// loadRateLimits переопределяет лимиты из переменных RATE_LIMIT_<ГРУППА>_<КЛИЕНТ>="<в секунду>:<burst>",
+// где клиент — ANONYMOUS, USER, API_KEY или ROLE_<РОЛЬ>. Например: RATE_LIMIT_AUTH_ANONYMOUS=0.2:5
+func loadRateLimits() {
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		rest, ok := strings.CutPrefix(name, "RATE_LIMIT_")
+		if !ok || rest == "ENABLED" || rest == "STORE" {
+			continue
+		}
+
+		limit, err := parseRateLimit(value)
+		if err != nil {
+			log.Fatalf("Invalid %s: %s (must be <rate>:<burst>, e.g. 5:10)", name, value)
+		}
+
+		var group, role string
+		var set func(p *RateLimitPolicy)
+		switch {
+		case strings.HasSuffix(rest, "_ANONYMOUS"):
+			group = strings.TrimSuffix(rest, "_ANONYMOUS")
+			set = func(p *RateLimitPolicy) { p.Anonymous = limit }
+		case strings.HasSuffix(rest, "_API_KEY"):
+			group = strings.TrimSuffix(rest, "_API_KEY")
+			set = func(p *RateLimitPolicy) { p.APIKey = limit }
+		case strings.HasSuffix(rest, "_USER"):
+			group = strings.TrimSuffix(rest, "_USER")
+			set = func(p *RateLimitPolicy) { p.User = limit }
+		case strings.Contains(rest, "_ROLE_"):
+			group, role, _ = strings.Cut(rest, "_ROLE_")
+			role = strings.ToLower(role)
+			set = func(p *RateLimitPolicy) {
+				roles := make(map[string]RateLimit, len(p.Roles)+1)
+				for k, v := range p.Roles {
+					roles[k] = v
+				}
+				roles[role] = limit
+				p.Roles = roles
+			}
+		default:
+			log.Fatalf("Invalid %s: unknown client type (must end with _ANONYMOUS, _USER, _API_KEY or _ROLE_<ROLE>)", name)
+		}
+
+		group = strings.ToLower(group)
+		policy := RateLimits[group]
+		set(&policy)
+		RateLimits[group] = policy
+	}
+}
+
+// loadRouteTimeouts читает сроки маршрутов из ROUTE_TIMEOUTS="POST /login=5s,GET /users=2s"
+// и проверяет, что все сроки меньше HTTPWriteTimeout
+func loadRouteTimeouts() {
+	for _, item := range splitList(os.Getenv("ROUTE_TIMEOUTS")) {
+		route, value, ok := strings.Cut(item, "=")
+		method, path, okRoute := strings.Cut(strings.TrimSpace(route), " ")
+		timeout, err := time.ParseDuration(strings.TrimSpace(value))
+		if !ok || !okRoute || err != nil || timeout <= 0 {
+			log.Fatalf("Invalid ROUTE_TIMEOUTS entry: %s (must be \"<METHOD> <path>=<duration>\", e.g. \"POST /login=5s\")", item)
+		}
+		RouteTimeouts[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = timeout
+	}
+
+	for route, timeout := range RouteTimeouts {
+		if timeout >= HTTPWriteTimeout {
+			log.Fatalf("Invalid timeout for %s: %s (must be less than HTTP_WRITE_TIMEOUT %s)", route, timeout, HTTPWriteTimeout)
+		}
+	}
+	if RequestTimeout >= HTTPWriteTimeout {
+		log.Fatalf("Invalid REQUEST_TIMEOUT: %s (must be less than HTTP_WRITE_TIMEOUT %s)", RequestTimeout, HTTPWriteTimeout)
+	}
+}
+
+// parseRateLimit разбирает "<в секунду>:<burst>"
+func parseRateLimit(value string) (RateLimit, error) {
+	rateStr, burstStr, ok := strings.Cut(value, ":")
+	if !ok {
+		return RateLimit{}, strconv.ErrSyntax
+	}
+
+	rate, err := strconv.ParseFloat(rateStr, 64)
+	if err != nil || rate <= 0 {
+		return RateLimit{}, strconv.ErrSyntax
+	}
+	burst, err := strconv.Atoi(burstStr)
+	if err != nil || burst <= 0 {
+		return RateLimit{}, strconv.ErrSyntax
+	}
+	return RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+// LoadCorsPolicy собирает политику CORS для окружения env: встроенный профиль из CorsProfiles,
+// вместо него — профиль из JSON-файла CORS_CONFIG_FILE ({"dev": {...}, "prod": {...}}),
+// поверх — переменные CORS_ALLOWED_ORIGINS, CORS_ALLOWED_METHODS, CORS_ALLOWED_HEADERS,
+// CORS_EXPOSED_HEADERS (через запятую), CORS_ALLOW_CREDENTIALS и CORS_MAX_AGE.
+// Не завершает программу при ошибке
+func LoadCorsPolicy(env string) (CorsPolicy, error) {
+	policy, err := loadCorsFile(env)
+	if err != nil {
+		return CorsPolicy{}, fmt.Errorf("config/LoadCorsPolicy: %w", err)
+	}
+
+	for name, field := range map[string]*[]string{
+		"CORS_ALLOWED_ORIGINS": &policy.AllowedOrigins,
+		"CORS_ALLOWED_METHODS": &policy.AllowedMethods,
+		"CORS_ALLOWED_HEADERS": &policy.AllowedHeaders,
+		"CORS_EXPOSED_HEADERS": &policy.ExposedHeaders,
+	} {
+		if value := os.Getenv(name); value != "" {
+			*field = splitList(value)
+		}
+	}
+
+	if value := os.Getenv("CORS_ALLOW_CREDENTIALS"); value != "" {
+		credentials, err := strconv.ParseBool(value)
+		if err != nil {
+			return CorsPolicy{}, fmt.Errorf("config/LoadCorsPolicy: invalid CORS_ALLOW_CREDENTIALS %q (must be true or false)", value)
+		}
+		policy.AllowCredentials = credentials
+	}
+	if value := os.Getenv("CORS_MAX_AGE"); value != "" {
+		maxAge, err := strconv.Atoi(value)
+		if err != nil || maxAge <= 0 {
+			return CorsPolicy{}, fmt.Errorf("config/LoadCorsPolicy: invalid CORS_MAX_AGE %q (must be a positive number of seconds)", value)
+		}
+		policy.MaxAge = maxAge
+	}
+
+	return withCorsDefaults(policy), nil
+}
+
+// ReloadCorsPolicy перечитывает политику CORS для окружения env из файла CORS_CONFIG_FILE
+// (для перезагрузки по SIGHUP). Переменные CORS_* не учитываются: окружение запущенного процесса
+// не меняется, и заданные при старте переменные навсегда перекрыли бы правки файла.
+// Без CORS_CONFIG_FILE перечитывать нечего — возвращается ошибка
+func ReloadCorsPolicy(env string) (CorsPolicy, error) {
+	if os.Getenv("CORS_CONFIG_FILE") == "" {
+		return CorsPolicy{}, fmt.Errorf("config/ReloadCorsPolicy: CORS_CONFIG_FILE is not set")
+	}
+
+	policy, err := loadCorsFile(env)
+	if err != nil {
+		return CorsPolicy{}, fmt.Errorf("config/ReloadCorsPolicy: %w", err)
+	}
+	return withCorsDefaults(policy), nil
+}
+
+// loadCorsFile возвращает профиль env из файла CORS_CONFIG_FILE, если он там есть, иначе встроенный
+func loadCorsFile(env string) (CorsPolicy, error) {
+	policy := CorsProfiles[env]
+
+	if file := os.Getenv("CORS_CONFIG_FILE"); file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return CorsPolicy{}, err
+		}
+
+		var profiles map[string]CorsPolicy
+		err = json.Unmarshal(data, &profiles)
+		if err != nil {
+			return CorsPolicy{}, fmt.Errorf("%s: %w", file, err)
+		}
+		if profile, ok := profiles[env]; ok {
+			policy = profile
+		}
+	}
+	return policy, nil
+}
+
+// withCorsDefaults заполняет незаданные поля политики значениями по умолчанию
+func withCorsDefaults(policy CorsPolicy) CorsPolicy {
+	if len(policy.AllowedMethods) == 0 {
+		policy.AllowedMethods = CorsDefaultMethods
+	}
+	if len(policy.AllowedHeaders) == 0 {
+		policy.AllowedHeaders = CorsDefaultHeaders
+	}
+	if len(policy.ExposedHeaders) == 0 {
+		policy.ExposedHeaders = CorsDefaultExposed
+	}
+	if policy.MaxAge == 0 {
+		policy.MaxAge = CorsDefaultMaxAge
+	}
+	return policy
+}
+
+// splitList разбирает список через запятую, пропуская пустые элементы
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
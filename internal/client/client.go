@@ -9,8 +9,8 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"pet/internal/model"
-	"strconv"
 	"time"
 )
 
@@ -29,22 +29,25 @@ func Run(l *log.Logger) {
 		return
 	}
 
-	// Отправка POST-запроса и обработка ответа сервера
+	// Отправка POST-запроса и обработка ответа сервера.
+	// Сервер присваивает пользователю публичный ID — по нему адресуются все следующие запросы
 	resp, err := sendPostNewUser(bytesNewUser)
 	if err != nil {
 		errorsLogger.Println(err)
 		return
 	}
-	if resp != nil {
-		defer resp.Body.Close()
-		err = outputRespBody(resp.Body, "POST")
-		if err != nil {
-			errorsLogger.Println(err)
-			return
-		}
-	} else {
+	if resp == nil {
 		fmt.Println("Тело ответа POST-запроса вернулось пустым")
+		return
+	}
+	defer resp.Body.Close()
+
+	createdUser, err := outputRespBody(resp.Body, "POST")
+	if err != nil {
+		errorsLogger.Println(err)
+		return
 	}
+	userID := createdUser.PublicID
 
 	// Отправка GET-запроса и обработка ответа сервера
 	err = sendGetUsers()
@@ -54,13 +57,15 @@ func Run(l *log.Logger) {
 	}
 
 	// Отправка PUT-запроса и обработка ответа сервера
-	bytesPutUser, err := marshalUser(model.UpdatedUserPut)
+	putUser := model.UpdatedUserPut
+	putUser.PublicID = userID
+	bytesPutUser, err := marshalUser(putUser)
 	if err != nil {
 		errorsLogger.Println(err)
 		return
 	}
 
-	resp, err = sendPutUser(bytesPutUser, model.UpdatedUserPut.ID)
+	resp, err = sendPutUser(bytesPutUser, userID)
 	if err != nil {
 		errorsLogger.Println(err)
 		return
@@ -68,7 +73,7 @@ func Run(l *log.Logger) {
 	if resp != nil {
 		defer resp.Body.Close()
 
-		err = outputRespBody(resp.Body, "PUT")
+		_, err = outputRespBody(resp.Body, "PUT")
 		if err != nil {
 			errorsLogger.Println(err)
 			return
@@ -78,12 +83,14 @@ func Run(l *log.Logger) {
 	}
 
 	// Отправка PATCH-запроса и обработка ответа сервера
-	patchUpdatedUser, err := json.Marshal(model.UpdatedUserPatch)
+	patchUser := model.UpdatedUserPatch
+	patchUser.PublicID = userID
+	patchUpdatedUser, err := json.Marshal(patchUser)
 	if err != nil {
 		errorsLogger.Println(err)
 		return
 	}
-	resp, err = sendPatchUser(patchUpdatedUser, model.UpdatedUserPatch.ID)
+	resp, err = sendPatchUser(patchUpdatedUser, userID)
 	if err != nil {
 		errorsLogger.Println(err)
 		return
@@ -91,7 +98,7 @@ func Run(l *log.Logger) {
 	if resp != nil {
 		defer resp.Body.Close()
 
-		err = outputRespBody(resp.Body, "PATCH")
+		_, err = outputRespBody(resp.Body, "PATCH")
 		if err != nil {
 			errorsLogger.Println(err)
 			return
@@ -100,21 +107,26 @@ func Run(l *log.Logger) {
 		fmt.Println("Тело ответа PATCH-запроса вернулось пустым")
 	}
 
+	// Отправка GET-запроса с ID и обработка ответа сервера
+	getUser, err := sendGetUser(userID)
+	if err != nil {
+		errorsLogger.Println(err)
+		return
+	}
+	// дальнейшая работа с полученным пользователем
+	fmt.Printf("Получен пользователь ID %s:\n", getUser.PublicID)
+	fmt.Printf("Имя: %s\nВозраст: %d\nE-mail: %s\n", getUser.Name, getUser.Age, getUser.Email)
+
 	// Отправка DELETE-запроса и обработка ответа сервера
-	resp, err = sendDeleteUser(model.DeletedUser.ID)
+	resp, err = sendDeleteUser(userID)
 	if err != nil {
 		errorsLogger.Println(err)
+		return
 	}
 	if resp != nil {
 		defer resp.Body.Close()
 	}
-	fmt.Printf("Пользователь с ID %d удален из БД\n", model.DeletedUser.ID)
-
-	// Отправка PUT-запроса с ID и обработка ответа сервера
-	getUser, err := sendGetUser(model.GetUserByID.ID)
-	// дальнейшая работа с полученным пользователем
-	fmt.Printf("Получен пользователь ID %d:\n", getUser.ID)
-	fmt.Printf("Имя: %s\nВозраст: %d\nE-mail: %s\n", getUser.Name, getUser.Age, getUser.Email)
+	fmt.Printf("Пользователь с ID %s удален из БД\n", userID)
 }
 
 func createUser() model.User {
@@ -124,16 +136,16 @@ func createUser() model.User {
 }
 
 // Функция декодирования и вывода в консоль тела ответа
-func outputRespBody(body io.ReadCloser, method string) error {
+func outputRespBody(body io.ReadCloser, method string) (model.User, error) {
 	defer body.Close()
 
 	updatedUser, err := unmarshalUser(body)
 	if err != nil {
-		return fmt.Errorf("[CLIENT] ошибка при декодировании тела ответа из JSON: %v", err)
+		return model.User{}, fmt.Errorf("[CLIENT] ошибка при декодировании тела ответа из JSON: %v", err)
 	}
-	fmt.Printf("Методом %v обновлены данные пользователя с ID %d:\n", method, updatedUser.ID)
+	fmt.Printf("Методом %v обновлены данные пользователя с ID %s:\n", method, updatedUser.PublicID)
 	fmt.Printf("Имя: %s\nВозраст: %d\nE-mail: %s\n", updatedUser.Name, updatedUser.Age, updatedUser.Email)
-	return nil
+	return updatedUser, nil
 }
 
 // Функция декодирования из JSON
@@ -209,13 +221,13 @@ func sendGetUsers() error {
 }
 
 // Функция обновления пользователя в БД
-func sendPutUser(bytesUpdatedUser []byte, ID int) (*http.Response, error) {
+func sendPutUser(bytesUpdatedUser []byte, ID string) (*http.Response, error) {
 	timeout := 5 * time.Second
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	url := "http://localhost:8080/users/" + strconv.Itoa(ID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewBuffer(bytesUpdatedUser))
+	urlID := "http://localhost:8080/users/" + url.PathEscape(ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, urlID, bytes.NewBuffer(bytesUpdatedUser))
 	if err != nil {
 		return nil, fmt.Errorf("[CLIENT] ошибка при создании PUT-запроса: %w", err)
 	}
@@ -248,12 +260,12 @@ func unmarshalUser(body io.Reader) (model.User, error) {
 }
 
 // Функция частичного обновления пользователя в БД
-func sendPatchUser(bytesUpdatedUser []byte, ID int) (*http.Response, error) {
+func sendPatchUser(bytesUpdatedUser []byte, ID string) (*http.Response, error) {
 	timeout := 5 * time.Second
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	urlID := "http://localhost:8080" + "/users/" + strconv.Itoa(ID)
+	urlID := "http://localhost:8080" + "/users/" + url.PathEscape(ID)
 	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, urlID, bytes.NewBuffer(bytesUpdatedUser))
 	if err != nil {
 		return nil, fmt.Errorf("[CLIENT] ошибка при создании запроса: %w", err)
@@ -277,12 +289,12 @@ func sendPatchUser(bytesUpdatedUser []byte, ID int) (*http.Response, error) {
 }
 
 // Функция удаления пользователя в БД
-func sendDeleteUser(ID int) (*http.Response, error) {
+func sendDeleteUser(ID string) (*http.Response, error) {
 	timeout := 5 * time.Second
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	urlID := "http://localhost:8080" + "/users/" + strconv.Itoa(ID)
+	urlID := "http://localhost:8080" + "/users/" + url.PathEscape(ID)
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, urlID, nil)
 	if err != nil {
 		return nil, fmt.Errorf("[CLIENT] ошибка при создании запроса: %w", err)
@@ -305,13 +317,13 @@ func sendDeleteUser(ID int) (*http.Response, error) {
 }
 
 // Функция получения пользователя из БД по ID
-func sendGetUser(ID int) (*model.User, error) {
+func sendGetUser(ID string) (*model.User, error) {
 	timeout := 5 * time.Second
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	url := "http://localhost:8080" + "/users/" + strconv.Itoa(ID)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	urlID := "http://localhost:8080" + "/users/" + url.PathEscape(ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlID, nil)
 	if err != nil {
 		return nil, fmt.Errorf("[CLIENT] ошибка при создании GET-запроса %w", err)
 	}
@@ -0,0 +1,98 @@
+package database
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"go.uber.org/zap"
+	"io/fs"
+	"sort"
+)
+
+// migrationsFS - SQL-миграции, встроенные в бинарник.
+// Файлы применяются по порядку имени: 0001_*.sql, 0002_*.sql и т.д.
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Migrate применяет к БД все ещё не применённые миграции.
+// Список применённых версий хранится в таблице schema_migrations,
+// каждая миграция выполняется в отдельной транзакции.
+func Migrate(db *sql.DB, log *zap.Logger) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+	       version TEXT PRIMARY KEY,
+	       applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)
+`)
+	if err != nil {
+		return fmt.Errorf("database/Migrate: create schema_migrations: %w", err)
+	}
+
+	files, err := fs.Glob(migrationsFS, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("database/Migrate: %w", err)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		version := file[len("migrations/"):]
+
+		var exists bool
+		err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("database/Migrate: check %s: %w", version, err)
+		}
+		if exists {
+			continue
+		}
+
+		query, err := migrationsFS.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("database/Migrate: read %s: %w", version, err)
+		}
+
+		err = applyMigration(db, version, string(query))
+		if err != nil {
+			log.Error("migration failed",
+				zap.Error(err),
+				zap.String("version", version),
+				zap.String("component", "database"),
+				zap.String("operation", "Migrate"))
+
+			return fmt.Errorf("database/Migrate: apply %s: %w", version, err)
+		}
+
+		log.Info("migration applied",
+			zap.String("version", version),
+			zap.String("component", "database"),
+			zap.String("operation", "Migrate"))
+	}
+
+	return nil
+}
+
+// applyMigration выполняет одну миграцию и помечает её применённой в одной транзакции
+func applyMigration(db *sql.DB, version string, query string) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	_, err = tx.Exec(query)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
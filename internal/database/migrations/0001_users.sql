@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS users (
+       id SERIAL PRIMARY KEY,
+       name TEXT NOT NULL,
+       age INT NOT NULL,
+       email TEXT UNIQUE NOT NULL
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'guest';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS balance NUMERIC(14, 2) NOT NULL DEFAULT 0 CHECK (balance >= 0);
@@ -0,0 +1,21 @@
+-- TOTP-аутентификатор пользователя: секрет хранится до подтверждения кодом,
+-- enabled становится true только после успешного подтверждения.
+-- last_used_step — номер последнего принятого шага (unix-время / 30): код действует несколько шагов,
+-- и без него его можно было бы использовать повторно, пока он не истёк
+CREATE TABLE IF NOT EXISTS user_totp (
+       user_id INT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+       secret TEXT NOT NULL,
+       enabled BOOLEAN NOT NULL DEFAULT false,
+       created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       confirmed_at TIMESTAMPTZ,
+       last_used_step BIGINT NOT NULL DEFAULT 0
+);
+
+-- одноразовые коды восстановления, хранятся только в виде SHA-256
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+       id SERIAL PRIMARY KEY,
+       user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+       code_hash TEXT NOT NULL,
+       used_at TIMESTAMPTZ,
+       UNIQUE (user_id, code_hash)
+);
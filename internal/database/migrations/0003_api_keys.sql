@@ -0,0 +1,13 @@
+-- API-ключи для сервисного доступа. Сам ключ не хранится — только префикс для поиска и SHA-256 хеш
+CREATE TABLE IF NOT EXISTS api_keys (
+       id SERIAL PRIMARY KEY,
+       name TEXT NOT NULL,
+       prefix TEXT UNIQUE NOT NULL,
+       key_hash TEXT NOT NULL,
+       scopes TEXT NOT NULL DEFAULT '', -- скоупы через пробел: "users:read transfers:write"
+       created_by INT REFERENCES users (id) ON DELETE SET NULL,
+       created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       expires_at TIMESTAMPTZ,
+       last_used_at TIMESTAMPTZ,
+       revoked_at TIMESTAMPTZ
+);
@@ -0,0 +1,5 @@
+-- роли хранятся в users.role, права ролей и их наследование описаны в коде (middleware/roles.go)
+UPDATE users SET role = 'guest' WHERE role NOT IN ('guest', 'editor', 'admin');
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('guest', 'editor', 'admin'));
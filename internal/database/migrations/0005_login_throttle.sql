@@ -0,0 +1,20 @@
+-- счётчики неудачных входов. key — "email:<email>" или "ip:<ip>", строки для несуществующих
+-- e-mail тоже создаются, чтобы поведение не выдавало, есть ли такой аккаунт
+CREATE TABLE IF NOT EXISTS login_throttle (
+       key TEXT PRIMARY KEY,
+       failures INT NOT NULL DEFAULT 0,
+       last_failure_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       blocked_until TIMESTAMPTZ, -- до этого момента попытки входа отклоняются (back-off или блокировка)
+       locked BOOLEAN NOT NULL DEFAULT false
+);
+
+-- IP, с которых были неудачные попытки входа в аккаунт (email_key — ключ счётчика e-mail).
+-- Когда администратор снимает блокировку аккаунта, счётчики этих IP тоже сбрасываются
+CREATE TABLE IF NOT EXISTS login_throttle_ips (
+       email_key TEXT NOT NULL,
+       ip_key TEXT NOT NULL,
+       last_failure_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       PRIMARY KEY (email_key, ip_key)
+);
+
+CREATE INDEX IF NOT EXISTS login_throttle_ips_last_failure_at_idx ON login_throttle_ips (last_failure_at);
@@ -0,0 +1,16 @@
+-- Сессии: одна запись на каждый вход. Сессия — это семейство refresh-токенов:
+-- при обновлении токена меняется только refresh_jti, повторное использование старого токена отзывает сессию
+CREATE TABLE IF NOT EXISTS sessions (
+       id SERIAL PRIMARY KEY,
+       user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+       refresh_jti TEXT NOT NULL, -- идентификатор последнего выданного refresh-токена семейства
+       device TEXT NOT NULL DEFAULT '',
+       user_agent TEXT NOT NULL DEFAULT '',
+       ip TEXT NOT NULL DEFAULT '',
+       created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       expires_at TIMESTAMPTZ NOT NULL,
+       revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
@@ -0,0 +1,13 @@
+-- Внешние учётные записи (OIDC), привязанные к пользователям. subject уникален только внутри провайдера
+CREATE TABLE IF NOT EXISTS user_identities (
+       id SERIAL PRIMARY KEY,
+       user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+       provider TEXT NOT NULL,
+       subject TEXT NOT NULL,
+       email TEXT NOT NULL DEFAULT '',
+       created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       last_login_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       UNIQUE (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id);
@@ -0,0 +1,20 @@
+-- Одноразовые ссылки для входа по e-mail. Сама ссылка — подписанный JWT, здесь хранится только его jti,
+-- чтобы погасить ссылку при первом использовании
+CREATE TABLE IF NOT EXISTS magic_links (
+       jti TEXT PRIMARY KEY,
+       user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+       created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       expires_at TIMESTAMPTZ NOT NULL,
+       used_at TIMESTAMPTZ
+);
+
+-- Запросы ссылок для входа по e-mail — для ограничения числа писем на один адрес (скользящее окно
+-- MAGIC_LINK_WINDOW). Учитываются и незарегистрированные адреса: иначе по 429 можно было бы узнать,
+-- есть ли аккаунт. Строки старше окна удаляются при следующем запросе
+CREATE TABLE IF NOT EXISTS magic_link_requests (
+       email TEXT NOT NULL,
+       requested_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS magic_link_requests_email_idx ON magic_link_requests (email, requested_at);
+CREATE INDEX IF NOT EXISTS magic_link_requests_requested_at_idx ON magic_link_requests (requested_at);
@@ -0,0 +1,28 @@
+-- Ключи доступа (WebAuthn passkeys). У пользователя их может быть несколько, по одному на устройство
+CREATE TABLE IF NOT EXISTS passkeys (
+       id SERIAL PRIMARY KEY,
+       user_id INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+       credential_id BYTEA NOT NULL UNIQUE,
+       public_key BYTEA NOT NULL, -- открытый ключ в формате COSE
+       attestation_type TEXT NOT NULL DEFAULT '',
+       aaguid BYTEA,
+       sign_count BIGINT NOT NULL DEFAULT 0, -- счётчик подписей аутентификатора, помогает заметить клон ключа
+       transports TEXT NOT NULL DEFAULT '', -- через запятую: usb, nfc, ble, internal, hybrid
+       backup_eligible BOOLEAN NOT NULL DEFAULT false,
+       backup_state BOOLEAN NOT NULL DEFAULT false,
+       name TEXT NOT NULL DEFAULT '',
+       created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS passkeys_user_id_idx ON passkeys (user_id);
+
+-- Незавершённые церемонии WebAuthn (challenge между begin и finish). Запись удаляется при finish,
+-- поэтому один challenge нельзя использовать дважды
+CREATE TABLE IF NOT EXISTS passkey_ceremonies (
+       id TEXT PRIMARY KEY,
+       kind TEXT NOT NULL, -- register или login
+       user_id INT REFERENCES users (id) ON DELETE CASCADE, -- NULL для входа: пользователь ещё неизвестен
+       data TEXT NOT NULL, -- webauthn.SessionData в JSON
+       expires_at TIMESTAMPTZ NOT NULL
+);
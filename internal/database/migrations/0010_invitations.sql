@@ -0,0 +1,16 @@
+-- Приглашения от администраторов. В письме уходит токен, здесь хранится только его SHA-256.
+-- Роль назначается заранее и достаётся пользователю при принятии приглашения
+CREATE TABLE IF NOT EXISTS invitations (
+       id SERIAL PRIMARY KEY,
+       email TEXT NOT NULL,
+       role TEXT NOT NULL,
+       token_hash TEXT NOT NULL UNIQUE,
+       invited_by INT REFERENCES users (id) ON DELETE SET NULL,
+       created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+       expires_at TIMESTAMPTZ NOT NULL,
+       accepted_at TIMESTAMPTZ,
+       accepted_user_id INT REFERENCES users (id) ON DELETE SET NULL,
+       revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS invitations_email_idx ON invitations (lower(email));
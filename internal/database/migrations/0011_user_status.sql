@@ -0,0 +1,7 @@
+-- Статус аккаунта. Допустимые переходы проверяются в service.AccountStatusService,
+-- здесь — только допустимые значения. Существующие пользователи считаются активными
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active'
+      CHECK (status IN ('pending', 'active', 'suspended', 'locked', 'closed'));
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS status_changed_by INT REFERENCES users (id) ON DELETE SET NULL;
@@ -0,0 +1,47 @@
+-- Публичный ID пользователя: UUIDv7 (RFC 9562) — непрозрачный, не раскрывает число пользователей
+-- и порядок регистрации по соседним ID, но сортируется по времени создания и хорошо ложится в индекс.
+-- Используется в URL, токенах и ответах API. Целочисленный id остаётся внутренним ключом для связей
+CREATE OR REPLACE FUNCTION uuid_generate_v7() RETURNS uuid AS $$
+    -- первые 48 бит — миллисекунды Unix-времени, версия 7 ставится поверх версии 4 (биты 52 и 53)
+    SELECT encode(
+        set_bit(
+            set_bit(
+                overlay(uuid_send(gen_random_uuid())
+                        PLACING substring(int8send(floor(extract(epoch FROM clock_timestamp()) * 1000)::bigint) FROM 3)
+                        FROM 1 FOR 6),
+                52, 1),
+            53, 1),
+        'hex')::uuid;
+$$ LANGUAGE sql VOLATILE;
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS public_id UUID;
+
+-- существующие пользователи получают ID в порядке регистрации
+DO $$
+DECLARE
+    row_id INT;
+BEGIN
+    FOR row_id IN SELECT id FROM users WHERE public_id IS NULL ORDER BY id LOOP
+        UPDATE users SET public_id = uuid_generate_v7() WHERE id = row_id;
+    END LOOP;
+END;
+$$;
+
+ALTER TABLE users ALTER COLUMN public_id SET DEFAULT uuid_generate_v7();
+ALTER TABLE users ALTER COLUMN public_id SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_public_id_key ON users (public_id);
+
+-- публичный ID неизменяем: на него ссылаются выданные токены и внешние системы
+CREATE OR REPLACE FUNCTION users_public_id_immutable() RETURNS trigger AS $$
+BEGIN
+    IF NEW.public_id IS DISTINCT FROM OLD.public_id THEN
+        RAISE EXCEPTION 'users.public_id is immutable';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS users_public_id_immutable ON users;
+CREATE TRIGGER users_public_id_immutable
+    BEFORE UPDATE OF public_id ON users
+    FOR EACH ROW EXECUTE FUNCTION users_public_id_immutable();
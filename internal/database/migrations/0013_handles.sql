@@ -0,0 +1,16 @@
+-- Имя пользователя (handle) — по нему пользователя находят для переводов и входа.
+-- Уникально без учёта регистра, хранится в том виде, в каком его задал пользователь.
+-- Существующие пользователи и те, кто не выбрал имя, получают случайное user_xxxxxxxxxxxx
+ALTER TABLE users ADD COLUMN IF NOT EXISTS handle TEXT;
+UPDATE users SET handle = 'user_' || substr(md5(random()::text || id::text), 1, 12) WHERE handle IS NULL;
+ALTER TABLE users ALTER COLUMN handle SET DEFAULT ('user_' || substr(md5(random()::text), 1, 12));
+ALTER TABLE users ALTER COLUMN handle SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_handle_lower_key ON users (lower(handle));
+
+-- После смены имени старое закреплено за прежним владельцем до reserved_until,
+-- чтобы его не занял кто-то другой и переводы по старому имени не ушли чужому человеку
+CREATE TABLE IF NOT EXISTS handle_reservations (
+    handle         TEXT PRIMARY KEY, -- в нижнем регистре
+    user_id        INT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reserved_until TIMESTAMPTZ NOT NULL
+);
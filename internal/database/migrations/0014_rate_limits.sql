@@ -0,0 +1,8 @@
+-- Состояние лимитов запросов (GCRA) для RATE_LIMIT_STORE=postgres: квоты общие для всех экземпляров сервера.
+-- tat — теоретическое время, к которому клиент «израсходует» выданные запросы; строка с tat в прошлом
+-- ничем не отличается от отсутствующей и удаляется фоновой очисткой.
+-- UNLOGGED: после сбоя таблица очищается — для лимитов это допустимо, зато запись дешевле
+CREATE UNLOGGED TABLE IF NOT EXISTS rate_limits (
+    key TEXT PRIMARY KEY,
+    tat TIMESTAMPTZ NOT NULL
+);
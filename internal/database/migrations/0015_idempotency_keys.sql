@@ -0,0 +1,16 @@
+-- Ответы на POST и PATCH с заголовком Idempotency-Key (см. middleware.Idempotency): повтор того же
+-- запроса получает сохранённый ответ вместо повторного выполнения.
+-- scope — кто отправил запрос (пользователь, API-ключ или anonymous): одинаковые ключи разных клиентов не пересекаются.
+-- Пока запрос выполняется, status_code пуст, а locked_until защищает ключ от параллельных повторов;
+-- если сервер упал, не сохранив ответ, после locked_until ключ можно занять снова
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope        TEXT NOT NULL,
+    key          TEXT NOT NULL,
+    fingerprint  TEXT NOT NULL, -- sha256 метода, пути и тела запроса
+    status_code  INT,
+    headers      JSONB,
+    body         BYTEA,
+    locked_until TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, key)
+);
@@ -3,11 +3,13 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"go.uber.org/zap"
 )
 
-// ConnectDB - функция получения строки с настройками для подключения к БД
+// ConnectDB - функция получения строки с настройками для подключения к БД.
+// SQL-запросы в рамках трассы записываются спанами (см. queryTracer)
 func ConnectDB(dbName string, log *zap.Logger) (*sql.DB, error) {
 
 	if dbName == "" {
@@ -21,7 +23,7 @@ func ConnectDB(dbName string, log *zap.Logger) (*sql.DB, error) {
 
 	connStr := fmt.Sprintf("host=localhost port=5432 user=user password=newpassword dbname=%s sslmode=disable", dbName)
 
-	db, err := sql.Open("pgx", connStr)
+	connConfig, err := pgx.ParseConfig(connStr)
 	if err != nil {
 		log.Error("open db error",
 			zap.Error(err),
@@ -31,6 +33,9 @@ func ConnectDB(dbName string, log *zap.Logger) (*sql.DB, error) {
 
 		return nil, fmt.Errorf("database/ConnectDB: open db error: %w", err)
 	}
+	connConfig.Tracer = queryTracer{}
+
+	db := stdlib.OpenDB(*connConfig)
 
 	err = db.Ping()
 	if err != nil {
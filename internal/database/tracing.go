@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"github.com/jackc/pgx/v5"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"pet/internal/tracing"
+	"strings"
+)
+
+// queryTracer — pgx.QueryTracer, который открывает клиентский спан на каждый SQL-запрос.
+// Запросы вне трассы (миграции, фоновая очистка) не трассируются, чтобы не плодить трассы из одного спана.
+// Текст запроса пишется в спан как есть: значения передаются параметрами и в него не попадают
+type queryTracer struct{}
+
+// TraceQueryStart начинает спан запроса
+func (queryTracer) TraceQueryStart(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+
+	operation := queryOperation(data.SQL)
+	ctx, _ = tracing.Tracer().Start(ctx, "SQL "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBOperationName(operation),
+			semconv.DBQueryText(data.SQL),
+		),
+	)
+	return ctx
+}
+
+// TraceQueryEnd завершает спан запроса, отмечая ошибку
+func (queryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	tracing.RecordError(span, data.Err)
+	span.End()
+}
+
+// queryOperation возвращает первое слово запроса (SELECT, INSERT, WITH...) для имени спана
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
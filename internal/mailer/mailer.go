@@ -0,0 +1,112 @@
+// Package mailer отправляет письма пользователям (ссылки для входа, приглашения и т.п.)
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"pet/config"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Message — простое текстовое письмо
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New создаёт отправителя по настройкам: "smtp" — настоящая отправка, "file" — запись писем в каталог
+func New(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		if cfg.SMTPAddr == "" || cfg.From == "" {
+			return nil, fmt.Errorf("mailer.New: MAIL_SMTP_ADDR and MAIL_FROM must be set for smtp driver")
+		}
+		return &SMTPMailer{Addr: cfg.SMTPAddr, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword, From: cfg.From}, nil
+	case "file", "":
+		return &FileMailer{Dir: cfg.Dir, From: cfg.From}, nil
+	default:
+		return nil, fmt.Errorf("mailer.New: unknown driver %q", cfg.Driver)
+	}
+}
+
+// format собирает письмо в формате RFC 5322
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// SMTPMailer отправляет письма через SMTP-сервер (PLAIN-аутентификация, если задан Username)
+type SMTPMailer struct {
+	Addr     string // host:port
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, err := net.SplitHostPort(m.Addr)
+		if err != nil {
+			return fmt.Errorf("mailer.SMTPMailer.Send: %w", err)
+		}
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+
+	err := smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, format(m.From, msg))
+	if err != nil {
+		return fmt.Errorf("mailer.SMTPMailer.Send: %w", err)
+	}
+	return nil
+}
+
+// FileMailer — замена SMTP для локальной разработки и тестов: каждое письмо пишется в отдельный .eml файл
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+var fileCounter atomic.Int64
+
+// unsafeFileChars — всё, что нельзя оставлять в имени файла
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]`)
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	err := os.MkdirAll(m.Dir, 0o700)
+	if err != nil {
+		return fmt.Errorf("mailer.FileMailer.Send: %w", err)
+	}
+
+	// имя сортируется по времени отправки: <unix nano>-<счётчик>-<получатель>.eml
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + "-" + strconv.FormatInt(fileCounter.Add(1), 10) +
+		"-" + unsafeFileChars.ReplaceAllString(msg.To, "_") + ".eml"
+
+	err = os.WriteFile(filepath.Join(m.Dir, name), format(m.From, msg), 0o600)
+	if err != nil {
+		return fmt.Errorf("mailer.FileMailer.Send: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package metrics — метрики приложения в формате Prometheus.
+// Все метрики регистрируются в собственном реестре Registry, а не в глобальном prometheus.DefaultRegisterer,
+// чтобы в /metrics не попадали метрики сторонних библиотек
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+)
+
+const namespace = "users_api"
+
+// Registry — реестр метрик приложения
+var Registry = prometheus.NewRegistry()
+
+// HTTP-метрики (см. middleware.Metrics)
+var (
+	// HTTPRequestDuration — время обработки запросов. route — шаблон маршрута mux ("/users/{id}"),
+	// а не путь запроса: иначе каждый ID порождал бы отдельный временной ряд
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Duration of HTTP requests by route template, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// HTTPRequestsInFlight — запросы, которые обрабатываются прямо сейчас
+	HTTPRequestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of HTTP requests currently being served by route template.",
+	}, []string{"method", "route"})
+
+	// RateLimitRejections — запросы, отклонённые с 429 (см. middleware.RateLimit)
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the rate limiter by route group.",
+	}, []string{"group"})
+)
+
+// бизнес-метрики
+var (
+	// Registrations — созданные пользователи по способу: register, invitation, oidc, admin
+	Registrations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "registrations_total",
+		Help:      "Created user accounts by source.",
+	}, []string{"source"})
+
+	// Logins — успешные входы по способу: password, mfa, magic_link, passkey, oidc
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "logins_total",
+		Help:      "Successful logins by method.",
+	}, []string{"method"})
+
+	// LoginFailures — неудачные попытки входа (неверный пароль, TOTP-код или неизвестный пользователь)
+	LoginFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Failed login attempts.",
+	})
+
+	// Transfers — переводы по результату: success или failure
+	Transfers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_total",
+		Help:      "Balance transfers by result.",
+	}, []string{"result"})
+
+	// TransferVolume — сумма успешных переводов
+	TransferVolume = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfer_volume_total",
+		Help:      "Total amount of successful balance transfers.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		RateLimitRejections,
+		Registrations,
+		Logins,
+		LoginFailures,
+		Transfers,
+		TransferVolume,
+	)
+}
+
+// RegisterDB добавляет метрики пула соединений (sql.DB.Stats) базы dbName
+func RegisterDB(db *sql.DB, dbName string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// Handler отдаёт метрики в текстовом формате Prometheus
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,142 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/model"
+	"strings"
+	"time"
+)
+
+// APIKeyPrefix - с этого префикса начинается любой API-ключ, так Auth отличает его от JWT.
+// Полный формат ключа: uak_<prefix>_<secret>
+const APIKeyPrefix = "uak_"
+
+const apiKeyContextKey contextKey = "apiKey"
+
+// APIKeyStore - хранилище API-ключей, которое нужно middleware Auth
+type APIKeyStore interface {
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (model.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int) error
+}
+
+var apiKeyStore APIKeyStore
+
+// InitAPIKeyStore задаёт хранилище, в котором Auth ищет API-ключи.
+// Пока хранилище не задано, API-ключи отклоняются.
+func InitAPIKeyStore(store APIKeyStore) {
+	apiKeyStore = store
+}
+
+// GenerateAPIKey генерирует новый API-ключ.
+// Возвращает полный ключ (показывается один раз), публичный префикс для поиска и хеш для хранения.
+func GenerateAPIKey() (key string, prefix string, hash string, err error) {
+	prefixBytes := make([]byte, 4)
+	secretBytes := make([]byte, 24)
+
+	_, err = rand.Read(prefixBytes)
+	if err != nil {
+		return "", "", "", err
+	}
+	_, err = rand.Read(secretBytes)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	key = APIKeyPrefix + prefix + "_" + hex.EncodeToString(secretBytes)
+
+	return key, prefix, HashAPIKey(key), nil
+}
+
+// HashAPIKey возвращает SHA-256 ключа. Ключ случайный и длинный, поэтому медленный хеш не нужен
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAPIKey проверяет ключ: формат, наличие в хранилище, хеш, отзыв и срок действия
+func authenticateAPIKey(ctx context.Context, raw string) (model.APIKey, error) {
+	if apiKeyStore == nil {
+		return model.APIKey{}, fmt.Errorf("api key store is not initialized")
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(raw, APIKeyPrefix), "_", 2)
+	if !strings.HasPrefix(raw, APIKeyPrefix) || len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return model.APIKey{}, fmt.Errorf("malformed api key")
+	}
+
+	key, err := apiKeyStore.GetAPIKeyByPrefix(ctx, parts[0])
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(HashAPIKey(raw)), []byte(key.KeyHash)) != 1 {
+		return model.APIKey{}, fmt.Errorf("api key hash mismatch")
+	}
+
+	if key.RevokedAt != nil {
+		return model.APIKey{}, fmt.Errorf("api key revoked")
+	}
+
+	if key.ExpiresAt != nil && time.Now().After(*key.ExpiresAt) {
+		return model.APIKey{}, fmt.Errorf("api key expired")
+	}
+
+	return key, nil
+}
+
+// serveWithAPIKey аутентифицирует запрос по API-ключу и кладёт ключ в контекст
+func serveWithAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, raw string) {
+	log := LoggerFromContext(r.Context())
+
+	key, err := authenticateAPIKey(r.Context(), raw)
+	if err != nil {
+		log.Error("api key authentication error",
+			zap.Error(err),
+			zap.String("component", "middleware"),
+			zap.String("event", "auth"),
+		)
+
+		http.Error(w, "access denied", http.StatusUnauthorized)
+		return
+	}
+
+	// last_used_at — вспомогательная информация, ошибка записи не должна ломать запрос
+	_ = apiKeyStore.TouchAPIKey(r.Context(), key.ID)
+
+	ctx := context.WithValue(r.Context(), apiKeyContextKey, key)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// GetAPIKeyFromContext — возвращает API-ключ, если запрос аутентифицирован ключом, а не JWT
+func GetAPIKeyFromContext(r *http.Request) (model.APIKey, bool) {
+	key, ok := r.Context().Value(apiKeyContextKey).(model.APIKey)
+	return key, ok
+}
+
+// RejectAPIKeys — middleware для ручек, доступных только пользователям с JWT.
+// Скоупы API-ключей проверяются в Require так же, как права ролей
+// (например, выпуск API-ключей: ключ не должен выпускать другие ключи)
+func RejectAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := GetAPIKeyFromContext(r)
+		if ok {
+			LoggerFromContext(r.Context()).Error("api key is not allowed here",
+				zap.String("component", "middleware"),
+				zap.String("event", "permission_checking"),
+				zap.Int("api_key.id", key.ID),
+			)
+
+			http.Error(w, "access denied", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -14,14 +14,34 @@ import (
 // чтобы безопасно использовать контекст без риска "переписать" чужие значения
 type contextKey string
 
-const userIDKey contextKey = "userID"
+const (
+	userIDKey   contextKey = "userID"
+	publicIDKey contextKey = "publicID"
+	roleKey     contextKey = "role"
+)
+
+// Типы JWT-токенов (claim "typ"). В middleware Auth принимается только access-токен
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeMFA     = "mfa"        // промежуточный токен: пароль проверен, ожидается TOTP-код
+	TokenTypeOIDC    = "oidc_state" // state, nonce и PKCE-verifier незавершённого входа через OIDC
+	TokenTypeMagic   = "magic"      // одноразовая ссылка для входа из письма
+)
 
-// Auth — middleware-функция для аутентификации по заголовку Authorization
+// Auth — middleware-функция для аутентификации по заголовку Authorization.
+// Принимает JWT access-токен или API-ключ (в "Authorization: Bearer uak_..." или в X-API-Key)
 func Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		log := LoggerFromContext(r.Context())
 
+		apiKey := r.Header.Get("X-API-Key")
+		if apiKey != "" {
+			serveWithAPIKey(w, r, next, apiKey)
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 
 		// Простейшая проверка на наличие токена в формате Bearer ...
@@ -38,6 +58,11 @@ func Auth(next http.Handler) http.Handler {
 		// удаляет Bearer из записи с токеном
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 
+		if strings.HasPrefix(tokenString, APIKeyPrefix) {
+			serveWithAPIKey(w, r, next, tokenString)
+			return
+		}
+
 		// надо распарсить и проверить только access-токен
 		claims := jwt.MapClaims{}
 		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
@@ -60,9 +85,10 @@ func Auth(next http.Handler) http.Handler {
 			return
 		}
 
-		sub, ok := claims["sub"] // subject — ID пользователя (обязательное поле)
-		if !ok {
-			log.Error("claims token error",
+		// refresh- и MFA-токены не дают доступа к API
+		if claims["typ"] != TokenTypeAccess {
+			log.Error("wrong token type",
+				zap.Any("typ", claims["typ"]),
 				zap.String("component", "middleware"),
 				zap.String("event", "auth"),
 			)
@@ -71,11 +97,32 @@ func Auth(next http.Handler) http.Handler {
 			return
 		}
 
-		//TODO: переделать ID на тип "строка"
+		// sub — публичный ID пользователя (обязательное поле)
+		publicID, ok := claims["sub"].(string)
+		if !ok || publicID == "" {
+			log.Error("invalid sub-field",
+				zap.String("component", "middleware"),
+				zap.String("event", "auth"),
+			)
+			http.Error(w, "access denied", http.StatusUnauthorized)
+			return
+		}
 
-		userIDFloat, ok := sub.(float64)
+		// sid — сессия, из которой выдан токен. Отозванная сессия сразу лишает доступа
+		sessionIDFloat, ok := claims["sid"].(float64)
 		if !ok {
-			log.Error("invalid sub-field",
+			log.Error("invalid sid-field",
+				zap.String("component", "middleware"),
+				zap.String("event", "auth"),
+			)
+			http.Error(w, "access denied", http.StatusUnauthorized)
+			return
+		}
+
+		err = checkSession(r.Context(), int(sessionIDFloat))
+		if err != nil {
+			log.Error("session check error",
+				zap.Error(err),
 				zap.String("component", "middleware"),
 				zap.String("event", "auth"),
 			)
@@ -83,11 +130,50 @@ func Auth(next http.Handler) http.Handler {
 			return
 		}
 
-		role, _ := claims["role"]
+		// статус проверяется при каждом запросе: приостановленный аккаунт теряет доступ, не дожидаясь истечения токена.
+		// Заодно публичный ID превращается во внутренний, с которым работают хендлеры
+		userID, ok := checkUserStatus(w, r, publicID)
+		if !ok {
+			return
+		}
+
+		role, _ := claims["role"].(string)
+
+		// Добавляем userID, роль и сессию в context
+		ctx := context.WithValue(r.Context(), userIDKey, userID)
+		ctx = context.WithValue(ctx, publicIDKey, publicID)
+		ctx = context.WithValue(ctx, roleKey, role)
+		ctx = context.WithValue(ctx, sessionIDKey, int(sessionIDFloat))
+
+		// act — администратор, который действует от имени пользователя (имперсонация)
+		if act, ok := claims["act"]; ok {
+			actMap, _ := act.(map[string]any)
+			actorPublicID, _ := actMap["sub"].(string)
+			actorID, _, err := userStatus(r.Context(), actorPublicID)
+			if actorPublicID == "" || err != nil {
+				log.Error("invalid act-field",
+					zap.Error(err),
+					zap.String("component", "middleware"),
+					zap.String("event", "auth"),
+				)
+				http.Error(w, "access denied", http.StatusUnauthorized)
+				return
+			}
+			ctx = context.WithValue(ctx, actorIDKey, actorID)
+
+			// все дальнейшие логи запроса содержат обе личности
+			impersonationLog := log.With(
+				zap.Int("user.id", userID),
+				zap.Int("actor.id", actorID),
+				zap.Bool("impersonated", true),
+			)
+			ctx = context.WithValue(ctx, ctxKeyLogger{}, impersonationLog)
 
-		// Добавляем userID в context
-		ctx := context.WithValue(r.Context(), userIDKey, int(userIDFloat))
-		ctx = context.WithValue(ctx, "role", role)
+			impersonationLog.Info("impersonated request",
+				zap.String("component", "middleware"),
+				zap.String("event", "ImpersonatedRequest"),
+			)
+		}
 
 		// Передаём дальше с новым контекстом
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -100,3 +186,16 @@ func GetUserIDFromContext(r *http.Request) (int, bool) {
 	userID, ok := val.(int)
 	return userID, ok
 }
+
+// GetPublicIDFromContext — извлекает публичный ID пользователя из context.Context.
+// Есть только у запросов с access-токеном: у API-ключей пользователя нет
+func GetPublicIDFromContext(r *http.Request) (string, bool) {
+	publicID, ok := r.Context().Value(publicIDKey).(string)
+	return publicID, ok
+}
+
+// GetRoleFromContext — извлекает роль пользователя из context.Context ("" если роли нет)
+func GetRoleFromContext(r *http.Request) string {
+	role, _ := r.Context().Value(roleKey).(string)
+	return role
+}
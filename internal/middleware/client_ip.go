@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const clientIPKey contextKey = "clientIP"
+
+// ClientIPResolver определяет IP клиента с учётом доверенных прокси (балансировщиков).
+// Заголовки X-Forwarded-For, Forwarded и X-Real-IP учитываются, только если запрос пришёл
+// от доверенного прокси: иначе любой клиент мог бы подставить в них чужой адрес
+type ClientIPResolver struct {
+	trusted []netip.Prefix
+}
+
+// NewClientIPResolver создаёт ClientIPResolver. proxies — подсети ("10.0.0.0/8") или отдельные адреса.
+// Без доверенных прокси IP клиента всегда берётся из RemoteAddr
+func NewClientIPResolver(proxies []string) (*ClientIPResolver, error) {
+	resolver := &ClientIPResolver{}
+
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				return nil, fmt.Errorf("middleware/NewClientIPResolver: invalid trusted proxy %q: %w", proxy, err)
+			}
+			addr = addr.Unmap()
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		resolver.trusted = append(resolver.trusted, prefix.Masked())
+	}
+
+	return resolver, nil
+}
+
+// isTrusted - адрес принадлежит доверенному прокси
+func (c *ClientIPResolver) isTrusted(addr netip.Addr) bool {
+	for _, prefix := range c.trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve возвращает IP клиента. Цепочка адресов из Forwarded (RFC 7239), иначе из X-Forwarded-For,
+// просматривается справа налево: каждый доверенный прокси дописывает адрес, от которого получил запрос,
+// поэтому первый недоверенный адрес справа — клиент. Левее него адреса мог подделать сам клиент.
+// X-Real-IP используется, только если цепочек нет
+func (c *ClientIPResolver) Resolve(r *http.Request) string {
+	remote, ok := parseRemoteAddr(r.RemoteAddr)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !c.isTrusted(remote) {
+		return remote.String()
+	}
+
+	chain, found := forwardedChain(r.Header.Values("Forwarded"))
+	if !found {
+		chain, found = xForwardedForChain(r.Header.Values("X-Forwarded-For"))
+	}
+	if !found {
+		if realIP, ok := parseForwardedAddr(r.Header.Get("X-Real-IP")); ok {
+			return realIP.String()
+		}
+		return remote.String()
+	}
+
+	// client — последний адрес, которому можно верить: его сообщил доверенный прокси
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		addr, ok := parseForwardedAddr(chain[i])
+		if !ok {
+			// "unknown", обфусцированный идентификатор или мусор: дальше цепочке верить нельзя
+			break
+		}
+		client = addr
+		if !c.isTrusted(addr) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// forwardedChain извлекает значения for= из заголовков Forwarded (RFC 7239) в порядке прохождения прокси
+func forwardedChain(values []string) ([]string, bool) {
+	var chain []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					chain = append(chain, strings.Trim(val, `"`))
+				}
+			}
+		}
+	}
+	return chain, len(chain) > 0
+}
+
+// xForwardedForChain собирает адреса из всех заголовков X-Forwarded-For
+func xForwardedForChain(values []string) ([]string, bool) {
+	var chain []string
+	for _, value := range values {
+		for _, addr := range strings.Split(value, ",") {
+			chain = append(chain, strings.TrimSpace(addr))
+		}
+	}
+	return chain, len(chain) > 0
+}
+
+// parseForwardedAddr разбирает адрес из заголовка: "192.0.2.1", "192.0.2.1:4711", "2001:db8::1"
+// или "[2001:db8::1]:4711"
+func parseForwardedAddr(value string) (netip.Addr, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return netip.Addr{}, false
+	}
+
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(value, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// parseRemoteAddr разбирает RemoteAddr вида "IP:порт"
+func parseRemoteAddr(remoteAddr string) (netip.Addr, bool) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+// ClientIP - middleware, которое определяет IP клиента и кладёт его в контекст (см. GetClientIP).
+// Ставится снаружи WithLogger, чтобы IP попал в логгер запроса
+func ClientIP(resolver *ClientIPResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientIPKey, resolver.Resolve(r))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetClientIP возвращает IP клиента, определённый middleware ClientIP.
+// Без него (например, в тестах) — IP из RemoteAddr
+func GetClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+
+	if addr, ok := parseRemoteAddr(r.RemoteAddr); ok {
+		return addr.String()
+	}
+	return r.RemoteAddr
+}
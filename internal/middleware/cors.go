@@ -1,18 +1,147 @@
 package middleware
 
 import (
+	"fmt"
 	"github.com/rs/cors"
 	"net/http"
+	"pet/config"
+	"strings"
+	"sync/atomic"
 )
 
-func Cors() func(handler http.Handler) http.Handler {
-	return cors.New(cors.Options{
-		AllowedOrigins: []string{
-			"http://localhost:3000",
-			"https://example.com",
-			"https://anotherdomain.com"},
-		AllowedMethods:   []string{http.MethodGet, "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
-		AllowCredentials: true,
-	}).Handler
+// CorsHandler — обработка CORS с политикой, которую можно заменить через Reload без перезапуска сервера.
+// Запросы, уже начатые со старой политикой, дорабатывают с ней
+type CorsHandler struct {
+	current atomic.Pointer[cors.Cors]
+}
+
+// NewCors создаёт CorsHandler с политикой policy (см. config.LoadCorsPolicy)
+func NewCors(policy config.CorsPolicy) (*CorsHandler, error) {
+	c := &CorsHandler{}
+
+	err := c.Reload(policy)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload заменяет политику. Если новая политика неверна, остаётся прежняя
+func (c *CorsHandler) Reload(policy config.CorsPolicy) error {
+	origins, err := newOriginMatcher(policy.AllowedOrigins)
+	if err != nil {
+		return fmt.Errorf("middleware/CorsHandler.Reload: %w", err)
+	}
+	if origins.any && policy.AllowCredentials {
+		// браузер не примет "*" вместе с куками, а отражать любой origin с куками небезопасно
+		return fmt.Errorf("middleware/CorsHandler.Reload: origin \"*\" cannot be used with credentials")
+	}
+
+	c.current.Store(cors.New(cors.Options{
+		AllowOriginFunc:  origins.match,
+		AllowedMethods:   policy.AllowedMethods,
+		AllowedHeaders:   policy.AllowedHeaders,
+		ExposedHeaders:   policy.ExposedHeaders,
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           policy.MaxAge,
+	}))
+	return nil
+}
+
+// Handler — middleware: отвечает на предзапросы браузера и добавляет заголовки CORS к ответам
+func (c *CorsHandler) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.current.Load().ServeHTTP(w, r, next.ServeHTTP)
+	})
+}
+
+// originMatcher — разрешённые источники: точные ("https://app.example.com"),
+// шаблоны поддоменов ("https://*.example.com") или любой ("*")
+type originMatcher struct {
+	any       bool
+	exact     map[string]bool
+	wildcards []wildcardOrigin
+}
+
+// wildcardOrigin — шаблон "scheme://*.suffix"; suffix включает порт, если он указан
+type wildcardOrigin struct {
+	scheme string
+	suffix string // ".example.com" или ".example.com:8443"
+}
+
+// newOriginMatcher разбирает список источников. "*" допускается только в начале имени хоста
+func newOriginMatcher(origins []string) (*originMatcher, error) {
+	m := &originMatcher{exact: make(map[string]bool)}
+
+	for _, origin := range origins {
+		origin = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+		if origin == "*" {
+			m.any = true
+			continue
+		}
+
+		scheme, host, ok := strings.Cut(origin, "://")
+		if !ok || scheme == "" || host == "" {
+			return nil, fmt.Errorf("invalid origin %q (must be scheme://host[:port])", origin)
+		}
+
+		if suffix, ok := strings.CutPrefix(host, "*"); ok {
+			if !strings.HasPrefix(suffix, ".") || len(suffix) < 2 || strings.Contains(suffix, "*") {
+				return nil, fmt.Errorf("invalid origin pattern %q (must be scheme://*.domain[:port])", origin)
+			}
+			m.wildcards = append(m.wildcards, wildcardOrigin{scheme: scheme, suffix: suffix})
+			continue
+		}
+		if strings.Contains(host, "*") {
+			return nil, fmt.Errorf("invalid origin pattern %q (\"*\" is only allowed as the first label)", origin)
+		}
+		m.exact[origin] = true
+	}
+
+	return m, nil
+}
+
+// match проверяет заголовок Origin запроса
+func (m *originMatcher) match(origin string) bool {
+	if m.any {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+	if m.exact[origin] {
+		return true
+	}
+
+	scheme, host, ok := strings.Cut(origin, "://")
+	if !ok {
+		return false
+	}
+	for _, w := range m.wildcards {
+		if scheme != w.scheme {
+			continue
+		}
+		sub, ok := strings.CutSuffix(host, w.suffix)
+		if ok && isSubdomain(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubdomain — непустая часть имени хоста перед доменом шаблона: метки из букв, цифр и дефисов
+func isSubdomain(sub string) bool {
+	if sub == "" {
+		return false
+	}
+	for _, label := range strings.Split(sub, ".") {
+		if label == "" {
+			return false
+		}
+		for _, ch := range label {
+			if (ch < 'a' || ch > 'z') && (ch < '0' || ch > '9') && ch != '-' {
+				return false
+			}
+		}
+	}
+	return true
 }
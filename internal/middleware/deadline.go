@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/config"
+	"sync"
+	"time"
+)
+
+// Deadline — middleware, которое ограничивает время обработки запроса сроком маршрута
+// (config.RouteTimeouts, иначе config.RequestTimeout). Срок кладётся в контекст запроса
+// и через него доходит до запросов к БД. Не уложившийся запрос получает 504 с JSON-телом,
+// запрос, отменённый до срока (клиент ушёл, сервер останавливается), — 503.
+// Ставится через router.Use: шаблон маршрута известен только после сопоставления
+func Deadline(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := routeTimeout(r)
+		if timeout <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &deadlineWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+				}
+			}()
+			next.ServeHTTP(tw, r.WithContext(ctx))
+			tw.finish()
+			close(done)
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p) // передаём панику в Recoverer
+		case <-done:
+		case <-ctx.Done():
+		}
+
+		// законченный ответ отправляется, даже если срок истёк одновременно с ним: 504 на уже выполненную
+		// операцию (например, перевод) клиент повторил бы
+		if !tw.abandon(ctx.Err()) {
+			tw.flushTo(w)
+			return
+		}
+
+		log := LoggerFromContext(r.Context())
+		status, body := http.StatusGatewayTimeout, `{"error":"request timed out","timeout":"`+timeout.String()+`"}`
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			status, body = http.StatusServiceUnavailable, `{"error":"request canceled"}`
+		}
+
+		log.Warn("request deadline exceeded",
+			zap.Duration("timeout", timeout),
+			zap.Error(ctx.Err()),
+			zap.String("component", "middleware"),
+			zap.String("event", "deadline"),
+		)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	})
+}
+
+// routeTimeout возвращает срок для маршрута запроса
+func routeTimeout(r *http.Request) time.Duration {
+	if template, ok := routeTemplate(r); ok {
+		if timeout, ok := config.RouteTimeouts[r.Method+" "+template]; ok {
+			return timeout
+		}
+	}
+	return config.RequestTimeout
+}
+
+// deadlineWriter копит ответ обработчика в памяти: пока срок не истёк, неизвестно,
+// отправлять его или 504. После abandon запись в него отбрасывается
+type deadlineWriter struct {
+	mu        sync.Mutex
+	header    http.Header
+	body      bytes.Buffer
+	status    int
+	finished  bool // обработчик вернул управление, ответ полный
+	abandoned bool
+}
+
+// Header возвращает заголовки ответа обработчика
+func (tw *deadlineWriter) Header() http.Header {
+	return tw.header
+}
+
+// Write дописывает тело ответа
+func (tw *deadlineWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.abandoned {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(b)
+}
+
+// WriteHeader запоминает статус; повторные вызовы игнорируются, как у http.ResponseWriter
+func (tw *deadlineWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.abandoned || tw.status != 0 {
+		return
+	}
+	tw.status = code
+}
+
+// flushTo отправляет накопленный ответ клиенту
+func (tw *deadlineWriter) flushTo(w http.ResponseWriter) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	dst := w.Header()
+	for key, values := range tw.header {
+		dst[key] = values
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	w.WriteHeader(tw.status)
+	_, _ = w.Write(tw.body.Bytes())
+}
+
+// finish отмечает, что обработчик закончил ответ
+func (tw *deadlineWriter) finish() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	tw.finished = true
+}
+
+// abandon отбрасывает ответ обработчика, чтобы клиент получил 503 или 504; err — ошибка контекста запроса.
+// false — ответ надо отправить: обработчик его закончил, и это не ошибка 5xx из-за истёкшего срока
+// (например, запрос к БД, прерванный сроком)
+func (tw *deadlineWriter) abandon(err error) bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.finished && (!errors.Is(err, context.DeadlineExceeded) || tw.status < http.StatusInternalServerError) {
+		return false
+	}
+	tw.abandoned = true
+	return true
+}
@@ -7,8 +7,8 @@ func DefaultHeaders() func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-			// Устанавливаем системные заголовки безопасности и поведения по умолчанию
-			w.Header().Set("Content-Type", "application/json")  // Все ответы — JSON
+			// Устанавливаем системные заголовки безопасности и поведения по умолчанию.
+			// Content-Type ставит тот, кто пишет тело: формат ответа зависит от Accept (см. render.Write)
 			w.Header().Set("X-Frame-Options", "DENY")           // Защита от Clickjacking
 			w.Header().Set("X-Content-Type-Options", "nosniff") // Отключить MIME-sniffing
 			w.Header().Set("Referrer-Policy", "no-referrer")    // Не отправлять Referer
@@ -0,0 +1,276 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"pet/config"
+	"pet/internal/model"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IdempotencyStore - хранилище ответов на запросы с Idempotency-Key.
+// AcquireIdempotencyKey занимает ключ клиента scope на время lock или возвращает уже сохранённую запись,
+// SaveIdempotentResponse сохраняет ответ, ReleaseIdempotencyKey освобождает ключ без ответа
+type IdempotencyStore interface {
+	AcquireIdempotencyKey(ctx context.Context, scope string, key string, fingerprint string, lock time.Duration, ttl time.Duration) (model.IdempotentResponse, bool, error)
+	SaveIdempotentResponse(ctx context.Context, scope string, key string, response model.IdempotentResponse) error
+	ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error
+}
+
+var idempotencyStore IdempotencyStore
+
+// InitIdempotencyStore задаёт хранилище, в котором Idempotency хранит ответы.
+// Пока хранилище не задано, Idempotency-Key не учитывается.
+func InitIdempotencyStore(store IdempotencyStore) {
+	idempotencyStore = store
+}
+
+const (
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyPollInterval - как часто повтор проверяет, завершился ли запрос с тем же ключом
+	idempotencyPollInterval = 50 * time.Millisecond
+)
+
+// idempotencySkippedHeaders - заголовки, которые относятся к конкретному запросу и не повторяются
+// в сохранённом ответе. Cookie (токены сессии) не хранятся вовсе
+var idempotencySkippedHeaders = []string{
+	"Set-Cookie", "X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "Retry-After",
+}
+
+// Idempotency — middleware для POST и PATCH с заголовком Idempotency-Key: повтор запроса с тем же ключом
+// получает сохранённый ответ (статус, заголовки, тело и Idempotent-Replayed: true) вместо повторного выполнения.
+// Ключ действует в пределах клиента (пользователь или API-ключ) и config.IdempotencyTTL; у анонимных
+// запросов клиента не различить, и ключ не учитывается (для них — IdempotencyByEmail).
+// Тот же ключ с другим запросом (метод, путь, тело) — 422.
+// Повтор, пришедший, пока первый запрос ещё выполняется, ждёт его ответа. Ответы 5xx не сохраняются:
+// повтор выполнится заново.
+// Тело ответа хранится в БД открытым текстом, поэтому middleware подключается к маршрутам по одному
+// и только к тем, чьи ответы не содержат секретов (токенов, ключей, секретов TOTP). Ставится после Auth
+func Idempotency(next http.Handler) http.Handler {
+	return idempotency(next, func(r *http.Request, _ []byte) (string, bool) {
+		return idempotencyScope(r)
+	})
+}
+
+// IdempotencyByEmail — Idempotency для анонимных маршрутов, где клиента определяет e-mail из тела запроса
+// (POST /register): ключ действует в пределах этого e-mail. Повтор регистрации с тем же ключом получает
+// сохранённый ответ, а не ошибку о занятом e-mail; запрос с тем же ключом, но другим e-mail выполняется
+// отдельно и чужого ответа не получит. Без e-mail в теле ключ не учитывается
+func IdempotencyByEmail(next http.Handler) http.Handler {
+	return idempotency(next, emailIdempotencyScope)
+}
+
+// idempotency — общая реализация Idempotency; scopeOf определяет клиента по запросу и его телу
+func idempotency(next http.Handler, scopeOf func(r *http.Request, body []byte) (string, bool)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || idempotencyStore == nil || (r.Method != http.MethodPost && r.Method != http.MethodPatch) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		log := LoggerFromContext(r.Context())
+
+		if len(key) > maxIdempotencyKeyLength || !isVisibleASCII(key) {
+			writeIdempotencyError(w, http.StatusBadRequest, `{"error":"invalid Idempotency-Key"}`)
+			return
+		}
+
+		// тело читается целиком для отпечатка и возвращается обработчику; больше лимита — 413 в readJSON
+		body, err := io.ReadAll(io.LimitReader(r.Body, config.MaxRequestBodySize+1))
+		if err != nil {
+			writeIdempotencyError(w, http.StatusBadRequest, `{"error":"failed to read request body"}`)
+			return
+		}
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+
+		scope, ok := scopeOf(r, body)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		fingerprint := requestFingerprint(r, body)
+
+		for {
+			stored, acquired, err := idempotencyStore.AcquireIdempotencyKey(r.Context(), scope, key, fingerprint,
+				config.HTTPWriteTimeout, config.IdempotencyTTL)
+			if err != nil {
+				// недоступное хранилище не должно останавливать API — выполняем запрос как без ключа
+				log.Error("idempotency store error",
+					zap.Error(err),
+					zap.String("component", "middleware"),
+					zap.String("event", "idempotency"),
+				)
+
+				next.ServeHTTP(w, r)
+				return
+			}
+			if acquired {
+				break
+			}
+
+			if stored.Fingerprint != fingerprint {
+				log.Warn("idempotency key reused with a different request",
+					zap.String("idempotency.key", key),
+					zap.String("component", "middleware"),
+					zap.String("event", "idempotency"),
+				)
+
+				writeIdempotencyError(w, http.StatusUnprocessableEntity,
+					`{"error":"idempotency key reused with a different request"}`)
+				return
+			}
+
+			if stored.StatusCode != 0 {
+				replayIdempotentResponse(w, stored)
+				return
+			}
+
+			// запрос с тем же ключом ещё выполняется — ждём его ответа
+			select {
+			case <-r.Context().Done():
+				writeIdempotencyError(w, http.StatusConflict,
+					`{"error":"request with this idempotency key is in progress"}`)
+				return
+			case <-time.After(idempotencyPollInterval):
+			}
+		}
+
+		iw := &idempotencyWriter{ResponseWriter: w}
+		saved := false
+
+		// ключ освобождается и при панике обработчика; срок запроса уже мог истечь, поэтому контекст без отмены
+		storeCtx := context.WithoutCancel(r.Context())
+		defer func() {
+			if saved {
+				return
+			}
+			err := idempotencyStore.ReleaseIdempotencyKey(storeCtx, scope, key)
+			if err != nil {
+				log.Error("failed to release idempotency key",
+					zap.Error(err),
+					zap.String("component", "middleware"),
+					zap.String("event", "idempotency"),
+				)
+			}
+		}()
+
+		next.ServeHTTP(iw, r)
+
+		if iw.status == 0 {
+			iw.WriteHeader(http.StatusOK)
+		}
+		if iw.status >= http.StatusInternalServerError {
+			return
+		}
+
+		err = idempotencyStore.SaveIdempotentResponse(storeCtx, scope, key, model.IdempotentResponse{
+			Fingerprint: fingerprint,
+			StatusCode:  iw.status,
+			Header:      iw.header,
+			Body:        iw.body.Bytes(),
+		})
+		if err != nil {
+			log.Error("failed to save idempotent response",
+				zap.Error(err),
+				zap.String("component", "middleware"),
+				zap.String("event", "idempotency"),
+			)
+			return
+		}
+		saved = true
+	})
+}
+
+// idempotencyScope - клиент, в пределах которого действует ключ: API-ключ или пользователь.
+// false — запрос анонимный: общий для всех анонимных клиентов ключ смешал бы их ответы,
+// а IP не годится — мобильный клиент может повторить запрос уже из другой сети
+func idempotencyScope(r *http.Request) (string, bool) {
+	if key, ok := GetAPIKeyFromContext(r); ok {
+		return "apikey:" + strconv.Itoa(key.ID), true
+	}
+	if publicID, ok := GetPublicIDFromContext(r); ok {
+		return "user:" + publicID, true
+	}
+	return "", false
+}
+
+// emailIdempotencyScope - клиент анонимного запроса по e-mail из JSON-тела (регистр и пробелы не учитываются).
+// В БД попадает только хеш e-mail
+func emailIdempotencyScope(_ *http.Request, body []byte) (string, bool) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	err := json.Unmarshal(body, &req)
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if err != nil || email == "" {
+		return "", false
+	}
+
+	hash := sha256.Sum256([]byte(email))
+	return "email:" + hex.EncodeToString(hash[:]), true
+}
+
+// requestFingerprint - sha256 метода, пути и тела запроса
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// replayIdempotentResponse отдаёт сохранённый ответ
+func replayIdempotentResponse(w http.ResponseWriter, stored model.IdempotentResponse) {
+	for name, values := range stored.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(stored.StatusCode)
+	_, _ = w.Write(stored.Body)
+}
+
+func writeIdempotencyError(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "application/json") // заголовок, что ответ будет в JSON
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(body))
+}
+
+// idempotencyWriter передаёт ответ клиенту и одновременно запоминает его для сохранения
+type idempotencyWriter struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+// WriteHeader запоминает статус и заголовки ответа на момент отправки
+func (iw *idempotencyWriter) WriteHeader(code int) {
+	if iw.status != 0 {
+		return
+	}
+	iw.status = code
+	iw.header = iw.ResponseWriter.Header().Clone()
+	for _, name := range idempotencySkippedHeaders {
+		iw.header.Del(name)
+	}
+	iw.ResponseWriter.WriteHeader(code)
+}
+
+// Write запоминает тело ответа
+func (iw *idempotencyWriter) Write(b []byte) (int, error) {
+	if iw.status == 0 {
+		iw.WriteHeader(http.StatusOK)
+	}
+	iw.body.Write(b)
+	return iw.ResponseWriter.Write(b)
+}
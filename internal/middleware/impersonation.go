@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"go.uber.org/zap"
+	"net/http"
+)
+
+// actorIDKey — ID администратора, который действует от имени пользователя (claim "act")
+const actorIDKey contextKey = "actorID"
+
+// GetActorIDFromContext — возвращает ID настоящего автора запроса, если идёт имперсонация.
+// Эффективный пользователь при этом по-прежнему в GetUserIDFromContext
+func GetActorIDFromContext(r *http.Request) (int, bool) {
+	id, ok := r.Context().Value(actorIDKey).(int)
+	return id, ok
+}
+
+// IsImpersonating сообщает, что запрос сделан администратором от имени пользователя
+func IsImpersonating(r *http.Request) bool {
+	_, ok := GetActorIDFromContext(r)
+	return ok
+}
+
+// ForbidImpersonation — middleware для чувствительных операций (переводы, смена пароля и т.п.),
+// которые нельзя выполнять от имени пользователя. Должно стоять после Auth
+func ForbidImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actorID, ok := GetActorIDFromContext(r)
+		if ok {
+			userID, _ := GetUserIDFromContext(r)
+
+			LoggerFromContext(r.Context()).Warn("operation is not allowed while impersonating",
+				zap.String("component", "middleware"),
+				zap.String("event", "permission_checking"),
+				zap.Int("user.id", userID),
+				zap.Int("actor.id", actorID),
+			)
+
+			http.Error(w, "not allowed while impersonating", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
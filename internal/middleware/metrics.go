@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"pet/internal/metrics"
+	"strconv"
+	"time"
+)
+
+// Metrics — middleware метрик HTTP (см. пакет metrics): время обработки и число запросов в обработке
+// по шаблону маршрута. Ставится через router.Use первым, чтобы учитывать и время остальных middleware
+// маршрута; запросы без маршрута (404, 405) не учитываются
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := routeTemplate(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		inFlight := metrics.HTTPRequestsInFlight.WithLabelValues(r.Method, route)
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		lrw := &midLogResponseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		next.ServeHTTP(lrw, r)
+
+		metrics.HTTPRequestDuration.
+			WithLabelValues(r.Method, route, strconv.Itoa(lrw.statusCode)).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
+// routeTemplate возвращает шаблон маршрута mux, с которым сопоставлен запрос ("/users/{id}")
+func routeTemplate(r *http.Request) (string, bool) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", false
+	}
+
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return "", false
+	}
+	return template, true
+}
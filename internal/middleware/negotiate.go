@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/render"
+)
+
+// Negotiate — middleware, которое отвечает 406, если клиент в Accept не принимает ни одного
+// формата из render. Проверка до обработчика: иначе запрос на изменение успел бы выполниться,
+// а клиент получил бы только 406. Какой именно формат подходит ответу, решает render.Write:
+// CSV — только для списков, остальные ответы клиенту, принимающему только CSV, отдаются в JSON
+func Negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !render.Acceptable(r.Header.Get("Accept")) {
+			LoggerFromContext(r.Context()).Info("not acceptable",
+				zap.String("http.accept", r.Header.Get("Accept")),
+				zap.String("component", "middleware"),
+				zap.String("event", "negotiate"),
+			)
+
+			render.WriteNotAcceptable(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
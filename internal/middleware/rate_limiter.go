@@ -1,71 +1,94 @@
 package middleware
 
 import (
+	"context"
 	"go.uber.org/zap"
-	"golang.org/x/time/rate"
-	"net"
+	"math"
 	"net/http"
+	"pet/config"
+	"pet/internal/metrics"
+	"pet/internal/model"
+	"strconv"
 	"sync"
 	"time"
 )
 
-// задают лимит: 5 запросов в секунду, burst (максимальный "взрыв") — 10
-const (
-	reqPerSecond = 5
-	burstSize    = 10
-)
-
-// client - структура для хранения лимитеров по IP
-type client struct {
-	limiter  *rate.Limiter // ограничитель запросов
-	lastSeen time.Time     // время последнего запроса
+// LimiterStore - хранилище состояния лимитов запросов. Allow атомарно учитывает один запрос
+// клиента key и решает, пропустить ли его при лимите limit.
+// Start запускает фоновую очистку устаревших записей, Stop останавливает её и дожидается завершения
+type LimiterStore interface {
+	Allow(ctx context.Context, key string, limit config.RateLimit) (model.RateLimitResult, error)
+	Start()
+	Stop()
 }
 
-// Эта карта (map) хранит лимитеры (rate.Limiter) по IP-адресу пользователя
-// Карты не потокобезопасны в Go.
-var clients = make(map[string]*client)
+var limiterStore LimiterStore
 
-// Одновременная запись/чтение без синхронизации вызовет панику: concurrent map writes.
-// sync.Mutex используется для блокировки доступа к карте, пока в нее пишут или читают
-var mu sync.Mutex
+// InitLimiterStore задаёт хранилище, в котором RateLimit считает запросы.
+// Пока хранилище не задано, запросы не ограничиваются.
+func InitLimiterStore(store LimiterStore) {
+	limiterStore = store
+}
 
-// RateLimiterMiddleware — middleware-функция для ограничения запросов
-func RateLimiterMiddleware() func(http.Handler) http.Handler {
-	// запускаем фоновую очистку старых IP
-	go cleanupClients()
+// RateLimit — middleware ограничения запросов для группы маршрутов group (см. config.RateLimits).
+// Ставится после Auth: аутентифицированный клиент ограничивается по sub из JWT (с учётом роли)
+// или по API-ключу, анонимный — по IP. В ответ добавляются RateLimit-Limit и RateLimit-Remaining,
+// при превышении — 429 с Retry-After
+func RateLimit(group string) func(http.Handler) http.Handler {
+	return rateLimit(group, func(r *http.Request) (string, config.RateLimit) {
+		return rateLimitIdentity(r, group)
+	})
+}
 
+// RateLimitByIP — ограничение запросов группы group только по IP, с лимитом анонимных клиентов.
+// Ставится перед Auth: иначе запросы с неверными токенами и API-ключами получали бы 401 без всякого
+// лимита (и каждый — с поиском ключа или сессии в БД), то есть перебор учётных данных был бы не ограничен
+func RateLimitByIP(group string) func(http.Handler) http.Handler {
+	return rateLimit(group, func(r *http.Request) (string, config.RateLimit) {
+		return "ip:" + GetClientIP(r), rateLimitFor(group, func(p config.RateLimitPolicy) config.RateLimit {
+			return p.Anonymous
+		})
+	})
+}
+
+// rateLimit — общая часть RateLimit и RateLimitByIP; identify определяет клиента и его лимит
+func rateLimit(group string, identify func(r *http.Request) (string, config.RateLimit)) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !config.RateLimitEnabled || limiterStore == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
 
 			log := LoggerFromContext(r.Context())
 
-			// r.RemoteAddr - это поле из http.Request,
-			//IP-адрес клиента + порт, с которого он обратился к серверу.
-			// строка вида "IP:порт", например,"192.168.1.42:54321"
-			// net.SplitHostPort разделяет эту строку на части: IP и порт
-			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			identity, limit := identify(r)
 
+			result, err := limiterStore.Allow(r.Context(), group+"|"+identity, limit)
 			if err != nil {
-				log.Error("unable to parse IP",
+				// недоступное хранилище лимитов не должно останавливать весь API — пропускаем запрос
+				log.Error("rate limit store error",
 					zap.Error(err),
 					zap.String("component", "middleware"),
 					zap.String("event", "rate_limiter"),
 				)
 
-				http.Error(w, "internal server error", http.StatusInternalServerError)
+				next.ServeHTTP(w, r)
 				return
 			}
 
-			// регулирует, сколько запросов в секунду (или минуту) можно сделать с этого IP.
-			limiter := getLimiter(ip)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
 
-			// Allow - основной метод, проверяющий, можно ли сейчас выполнить запрос, согласно лимиту?
-			if !limiter.Allow() { // если лимит исчерпан на текущий момент, то
+			if !result.Allowed { // если лимит исчерпан на текущий момент, то
+				w.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(result.RetryAfter.Seconds())), 1)))
+				metrics.RateLimitRejections.WithLabelValues(group).Inc()
 
-				log.Error("too many requests",
+				log.Warn("too many requests",
 					zap.String("component", "middleware"),
 					zap.String("event", "rate_limiter"),
-					zap.String("ip", ip),
+					zap.String("rate_limit.group", group),
+					zap.String("rate_limit.identity", identity),
 				)
 
 				w.Header().Set("Content-Type", "application/json") // заголовок, что ответ будет в JSON
@@ -79,35 +102,146 @@ func RateLimiterMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
-// очистка старых IP-лимитеров (например, раз в минуту), чтобы не держать их лимитеры в памяти
-func cleanupClients() {
-	for {
-		time.Sleep(time.Minute)
+// rateLimitIdentity определяет клиента и его лимит в группе: API-ключ, пользователь (роль) или IP
+func rateLimitIdentity(r *http.Request, group string) (string, config.RateLimit) {
+	if key, ok := GetAPIKeyFromContext(r); ok {
+		return "apikey:" + strconv.Itoa(key.ID), rateLimitFor(group, func(p config.RateLimitPolicy) config.RateLimit {
+			return p.APIKey
+		})
+	}
 
-		mu.Lock()
-		for ip, c := range clients {
-			if time.Since(c.lastSeen) > 3*time.Minute {
-				delete(clients, ip)
+	if publicID, ok := GetPublicIDFromContext(r); ok {
+		role := GetRoleFromContext(r)
+		return "user:" + publicID, rateLimitFor(group, func(p config.RateLimitPolicy) config.RateLimit {
+			if limit, ok := p.Roles[role]; ok {
+				return limit
 			}
+			return p.User
+		})
+	}
+
+	// IP с учётом доверенных прокси: иначе за балансировщиком все клиенты делили бы один лимит
+	return "ip:" + GetClientIP(r), rateLimitFor(group, func(p config.RateLimitPolicy) config.RateLimit {
+		return p.Anonymous
+	})
+}
+
+// rateLimitFor выбирает лимит из группы, затем из группы default; для аутентифицированных
+// клиентов без своего лимита используется лимит анонимных
+func rateLimitFor(group string, pick func(config.RateLimitPolicy) config.RateLimit) config.RateLimit {
+	for _, name := range []string{group, config.RateLimitGroupDefault} {
+		policy := config.RateLimits[name]
+		if limit := pick(policy); limit.Burst > 0 {
+			return limit
+		}
+		if policy.Anonymous.Burst > 0 {
+			return policy.Anonymous
+		}
+	}
+	return config.RateLimit{Rate: 5, Burst: 10}
+}
+
+// gcra — GCRA (generic cell rate algorithm): вместо счётчика хранится одно время tat — когда клиент
+// «израсходует» уже выданные запросы. Каждый запрос сдвигает tat на interval = 1/Rate,
+// запрос пропускается, если tat уходит в будущее не дальше чем на Burst интервалов
+func gcra(tat time.Time, now time.Time, limit config.RateLimit) (time.Time, model.RateLimitResult) {
+	interval := time.Duration(float64(time.Second) / limit.Rate)
+	tolerance := interval * time.Duration(limit.Burst)
+
+	if tat.Before(now) {
+		tat = now
+	}
+	newTAT := tat.Add(interval)
+	allowAt := newTAT.Add(-tolerance)
+
+	if now.Before(allowAt) {
+		return tat, model.RateLimitResult{
+			Limit:      limit.Burst,
+			Remaining:  0,
+			RetryAfter: allowAt.Sub(now),
 		}
-		mu.Unlock()
 	}
+
+	return newTAT, model.RateLimitResult{
+		Allowed:   true,
+		Limit:     limit.Burst,
+		Remaining: int(now.Sub(allowAt) / interval),
+	}
+}
+
+// MemoryLimiterStore - лимиты в памяти процесса: у каждого экземпляра сервера свои квоты
+type MemoryLimiterStore struct {
+	// карты не потокобезопасны в Go: одновременная запись без блокировки вызовет панику
+	mu   sync.Mutex
+	tats map[string]time.Time
+
+	cleanupInterval time.Duration
+	started         chan struct{}
+	stop            chan struct{}
+	done            chan struct{}
+	startOnce       sync.Once
+	stopOnce        sync.Once
 }
 
-// getLimiter возвращает лимитер для IP (создает, если нет)
-func getLimiter(ip string) *rate.Limiter {
-	mu.Lock()
-	defer mu.Unlock()
-
-	user, exists := clients[ip]
-	if !exists { // если юзера нет в базе
-		// создаем новый лимитер: requestsPerSecond - частота, burstSize - "всплеск"
-		limiter := rate.NewLimiter(rate.Limit(reqPerSecond), burstSize)
-		clients[ip] = &client{limiter, time.Now()}
-		return limiter
+// NewMemoryLimiterStore создаёт хранилище лимитов в памяти. Очистка запускается через Start
+func NewMemoryLimiterStore() *MemoryLimiterStore {
+	return &MemoryLimiterStore{
+		tats:            make(map[string]time.Time),
+		cleanupInterval: time.Minute,
+		started:         make(chan struct{}),
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
 	}
+}
+
+// Allow учитывает запрос клиента key
+func (s *MemoryLimiterStore) Allow(_ context.Context, key string, limit config.RateLimit) (model.RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	// если юзер есть в базе, просто обновляем время последнего запроса
-	user.lastSeen = time.Now()
-	return user.limiter
+	tat, result := gcra(s.tats[key], time.Now(), limit)
+	s.tats[key] = tat
+	return result, nil
+}
+
+// Start запускает фоновую очистку клиентов, которые уже восстановили весь лимит
+func (s *MemoryLimiterStore) Start() {
+	s.startOnce.Do(func() {
+		close(s.started)
+
+		go func() {
+			defer close(s.done)
+
+			ticker := time.NewTicker(s.cleanupInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-s.stop:
+					return
+				case now := <-ticker.C:
+					s.mu.Lock()
+					for key, tat := range s.tats {
+						if tat.Before(now) {
+							delete(s.tats, key)
+						}
+					}
+					s.mu.Unlock()
+				}
+			}
+		}()
+	})
+}
+
+// Stop останавливает очистку и дожидается её завершения. Повторный вызов ничего не делает
+func (s *MemoryLimiterStore) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+
+		select {
+		case <-s.started:
+			<-s.done
+		default:
+		}
+	})
 }
@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 	"net/http"
+	"slices"
+	"strings"
 )
 
 const (
@@ -11,44 +14,133 @@ const (
 	RoleEditor = "editor"
 )
 
-func AdminOnly(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-		log := LoggerFromContext(r.Context())
-
-		role, ok := r.Context().Value("role").(string)
-		if !ok || role != RoleAdmin {
-			log.Error("invalid role",
-				zap.String("component", "middleware"),
-				zap.String("event", "role_checking"),
-				zap.String("required_role", RoleAdmin),
-				zap.String("got_role", role),
-			)
-
-			http.Error(w, "access denied", http.StatusForbidden)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
-func EditorOnly(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-
-		log := LoggerFromContext(r.Context())
-
-		role, ok := r.Context().Value("role").(string)
-		if !ok || role != RoleEditor {
-			log.Error("invalid role",
-				zap.String("component", "middleware"),
-				zap.String("event", "role_checking"),
-				zap.String("required_role", RoleAdmin),
-				zap.String("got_role", role),
-			)
-
-			http.Error(w, "access denied", http.StatusForbidden)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+// Права доступа. Они же используются как скоупы API-ключей
+const (
+	PermUsersRead        = "users:read"
+	PermUsersWrite       = "users:write"
+	PermUsersDelete      = "users:delete"
+	PermTransfersWrite   = "transfers:write"
+	PermRolesManage      = "roles:manage"
+	PermAPIKeysManage    = "api_keys:manage"
+	PermLoginsUnlock     = "logins:unlock"
+	PermSessionsManage   = "sessions:manage"
+	PermUsersImpersonate = "users:impersonate"
+	PermUsersInvite      = "users:invite"
+	PermUsersSuspend     = "users:suspend"
+
+	// PermTransfersAny — скоуп API-ключа для переводов с любого счёта (from_id). Ролям не выдаётся:
+	// пользователь с JWT переводит только со своего счёта, а ключи выпускает только администратор
+	PermTransfersAny = "transfers:any"
+)
+
+// roleParents - наследование ролей: admin ⊃ editor ⊃ guest
+var roleParents = map[string]string{
+	RoleAdmin:  RoleEditor,
+	RoleEditor: RoleGuest,
+}
+
+// rolePermissions - собственные права каждой роли (без унаследованных)
+var rolePermissions = map[string][]string{
+	RoleGuest:  {PermUsersRead, PermTransfersWrite},
+	RoleEditor: {PermUsersWrite},
+	RoleAdmin:  {PermUsersDelete, PermRolesManage, PermAPIKeysManage, PermLoginsUnlock, PermSessionsManage, PermUsersImpersonate, PermUsersInvite, PermUsersSuspend},
+}
+
+// Roles - все роли от младшей к старшей
+var Roles = []string{RoleGuest, RoleEditor, RoleAdmin}
+
+// IsValidRole проверяет, что роль известна
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleParent возвращает роль, от которой наследуются права ("" для младшей роли)
+func RoleParent(role string) string {
+	return roleParents[role]
+}
+
+// RolePermissions возвращает все права роли с учётом наследования
+func RolePermissions(role string) []string {
+	var perms []string
+	for r := role; r != ""; r = roleParents[r] {
+		perms = append(perms, rolePermissions[r]...)
+	}
+	return perms
+}
+
+// RoleHasPermission проверяет, есть ли у роли право (с учётом наследования)
+func RoleHasPermission(role string, permission string) bool {
+	return slices.Contains(RolePermissions(role), permission)
+}
+
+// Require возвращает middleware, которое пропускает запрос только при наличии права.
+// Должно стоять после Auth: для JWT право берётся из роли, для API-ключа — из его скоупов.
+func Require(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			log := LoggerFromContext(r.Context())
+
+			key, isAPIKey := GetAPIKeyFromContext(r)
+			if isAPIKey {
+				if !slices.Contains(key.Scopes, permission) {
+					log.Error("api key scope missing",
+						zap.String("component", "middleware"),
+						zap.String("event", "permission_checking"),
+						zap.String("required_permission", permission),
+						zap.Int("api_key.id", key.ID),
+					)
+
+					http.Error(w, "access denied", http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			_, authenticated := GetUserIDFromContext(r)
+			if !authenticated {
+				log.Error("request is not authenticated",
+					zap.String("component", "middleware"),
+					zap.String("event", "permission_checking"),
+					zap.String("required_permission", permission),
+				)
+
+				http.Error(w, "access denied", http.StatusUnauthorized)
+				return
+			}
+
+			role := GetRoleFromContext(r)
+			if !RoleHasPermission(role, permission) {
+				log.Error("permission denied",
+					zap.String("component", "middleware"),
+					zap.String("event", "permission_checking"),
+					zap.String("required_permission", permission),
+					zap.String("got_role", role),
+				)
+
+				http.Error(w, "access denied", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSelfOr — как Require, но дополнительно пропускает пользователя к его собственному ресурсу:
+// если {id} в URL совпадает с публичным ID из токена, право не требуется
+func RequireSelfOr(permission string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		guarded := Require(permission)(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			publicID, ok := GetPublicIDFromContext(r)
+			if ok && strings.EqualFold(mux.Vars(r)["id"], publicID) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			guarded.ServeHTTP(w, r)
+		})
+	}
 }
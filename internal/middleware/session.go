@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+const sessionIDKey contextKey = "sessionID"
+
+// SessionStore - хранилище сессий, которое нужно middleware Auth для проверки отзыва
+type SessionStore interface {
+	IsSessionActive(ctx context.Context, id int) (bool, error)
+}
+
+var sessionStore SessionStore
+
+// InitSessionStore задаёт хранилище, в котором Auth проверяет сессию access-токена.
+// Пока хранилище не задано, access-токены отклоняются.
+func InitSessionStore(store SessionStore) {
+	sessionStore = store
+}
+
+// checkSession проверяет, что сессия, к которой привязан токен, не отозвана
+func checkSession(ctx context.Context, id int) error {
+	if sessionStore == nil {
+		return fmt.Errorf("session store is not initialized")
+	}
+
+	active, err := sessionStore.IsSessionActive(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return fmt.Errorf("session %d is revoked or expired", id)
+	}
+	return nil
+}
+
+// GetSessionIDFromContext — извлекает ID сессии, к которой привязан access-токен
+func GetSessionIDFromContext(r *http.Request) (int, bool) {
+	id, ok := r.Context().Value(sessionIDKey).(int)
+	return id, ok
+}
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/config"
+	"pet/internal/model"
+	"sync"
+	"time"
+)
+
+// UserStatusStore - хранилище, из которого Auth по публичному ID из токена узнаёт внутренний ID и статус аккаунта
+type UserStatusStore interface {
+	GetUserStatus(ctx context.Context, publicID string) (int, string, error)
+}
+
+// cachedStatus - внутренний ID, статус аккаунта и время, до которого их можно не перечитывать
+type cachedStatus struct {
+	id        int
+	status    string
+	expiresAt time.Time
+}
+
+var (
+	userStatusStore UserStatusStore
+	statusMu        sync.Mutex
+	statusCache     = map[string]cachedStatus{} // ключ — публичный ID
+	statusSweptAt   time.Time                   // когда из statusCache последний раз удалялись истёкшие записи
+)
+
+// InitUserStatusStore задаёт хранилище статусов и очищает кеш.
+// Пока хранилище не задано, access-токены отклоняются.
+func InitUserStatusStore(store UserStatusStore) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	userStatusStore = store
+	statusCache = map[string]cachedStatus{}
+}
+
+// InvalidateUserStatus убирает статус из кеша. Вызывается после смены статуса,
+// чтобы на этом экземпляре приложения она подействовала сразу, а не через config.UserStatusCacheTTL
+func InvalidateUserStatus(publicID string) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+
+	delete(statusCache, publicID)
+}
+
+// userStatus возвращает внутренний ID и статус аккаунта, по возможности из кеша
+func userStatus(ctx context.Context, publicID string) (int, string, error) {
+	statusMu.Lock()
+	cached, ok := statusCache[publicID]
+	store := userStatusStore
+	statusMu.Unlock()
+
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.id, cached.status, nil
+	}
+	if store == nil {
+		return 0, "", fmt.Errorf("user status store is not initialized")
+	}
+
+	id, status, err := store.GetUserStatus(ctx, publicID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	now := time.Now()
+	statusMu.Lock()
+	// истёкшие записи удаляются не чаще раза за config.UserStatusCacheTTL: в кеше остаются только
+	// пользователи, обращавшиеся к API за последний TTL, а не все, кто когда-либо входил
+	if now.Sub(statusSweptAt) >= config.UserStatusCacheTTL {
+		for key, entry := range statusCache {
+			if !now.Before(entry.expiresAt) {
+				delete(statusCache, key)
+			}
+		}
+		statusSweptAt = now
+	}
+	statusCache[publicID] = cachedStatus{id: id, status: status, expiresAt: now.Add(config.UserStatusCacheTTL)}
+	statusMu.Unlock()
+
+	return id, status, nil
+}
+
+// checkUserStatus возвращает внутренний ID пользователя с публичным ID publicID.
+// Отвечает 403, если аккаунт не активен, и 401, если пользователя нет или статус узнать не удалось
+func checkUserStatus(w http.ResponseWriter, r *http.Request, publicID string) (int, bool) {
+	id, status, err := userStatus(r.Context(), publicID)
+	if err != nil {
+		LoggerFromContext(r.Context()).Error("user status check error",
+			zap.Error(err),
+			zap.String("user.public_id", publicID),
+			zap.String("component", "middleware"),
+			zap.String("event", "auth"),
+		)
+		http.Error(w, "access denied", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	if status != model.UserStatusActive {
+		LoggerFromContext(r.Context()).Warn("inactive account",
+			zap.Int("user.id", id),
+			zap.String("user.status", status),
+			zap.String("component", "middleware"),
+			zap.String("event", "auth"),
+		)
+		http.Error(w, "account is "+status, http.StatusForbidden)
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"pet/internal/tracing"
+)
+
+// Tracing — middleware, которое открывает серверный спан на каждый HTTP-запрос.
+// Если клиент или прокси передал traceparent, спан продолжает его трассу.
+// Ставится снаружи WithLogger, чтобы trace.id и span.id попали в логгер запроса
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		// имя уточняется шаблоном маршрута в TraceRoute, когда mux сопоставит запрос
+		ctx, span := tracing.Tracer().Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		lrw := &midLogResponseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		next.ServeHTTP(lrw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(lrw.statusCode))
+		// 4xx — ошибка клиента, а не сервера: по семантическим соглашениям статус спана не меняется
+		if lrw.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", lrw.statusCode))
+		}
+	})
+}
+
+// TraceRoute — middleware маршрутов (router.Use): называет спан запроса по шаблону маршрута
+// ("GET /users/{id}"), чтобы спаны одного маршрута с разными ID группировались вместе
+func TraceRoute(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := routeTemplate(r); ok {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"net/http"
 )
@@ -10,20 +11,40 @@ import (
 // ctxKeyLogger - неэкспортируемый ключ для передачи логгера в контекст
 type ctxKeyLogger struct{}
 
+// maxRequestIDLength - X-Request-ID длиннее этого заменяется своим: он повторяется в ответе и в логах
+const maxRequestIDLength = 128
+
 // WithLogger - middleware-функция, которая кладет context в запрос и передает его дальше
-// Она передает ID для трассировки, метод, путь и IP-адрес клиента, но с портом!
+// Она передает ID запроса (X-Request-ID, который возвращается клиенту в ответе), ID трассы и спана
+// (см. Tracing), метод, путь, IP клиента (с учётом доверенных прокси, см. ClientIP)
+// и адрес соединения с портом
 func WithLogger(baseLog *zap.Logger) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(wr http.ResponseWriter, req *http.Request) {
 
 			reqID := generateRequestID(req)
+			wr.Header().Set("X-Request-ID", reqID)
 
-			reqLogger := baseLog.With(
-				zap.String("trace.id", reqID),
+			fields := []zap.Field{
+				zap.String("request.id", reqID),
 				zap.String("http.method", req.Method),
 				zap.String("url.path", req.URL.Path),
+				zap.String("client.ip", GetClientIP(req)),
 				zap.String("client.address", req.RemoteAddr),
-			)
+			}
+
+			// без Tracing (например, в тестах) ID трассы — ID запроса, как раньше
+			spanCtx := trace.SpanContextFromContext(req.Context())
+			if spanCtx.IsValid() {
+				fields = append(fields,
+					zap.String("trace.id", spanCtx.TraceID().String()),
+					zap.String("span.id", spanCtx.SpanID().String()),
+				)
+			} else {
+				fields = append(fields, zap.String("trace.id", reqID))
+			}
+
+			reqLogger := baseLog.With(fields...)
 
 			ctx := context.WithValue(req.Context(), ctxKeyLogger{}, reqLogger)
 
@@ -32,14 +53,31 @@ func WithLogger(baseLog *zap.Logger) func(http.Handler) http.Handler {
 	}
 }
 
+// generateRequestID берёт X-Request-ID клиента или прокси, а если его нет или он
+// подозрительный (слишком длинный, с управляющими символами), создаёт новый UUID
 func generateRequestID(req *http.Request) string {
 	reqID := req.Header.Get("X-Request-ID")
-	if reqID != "" {
+	if reqID != "" && validRequestID(reqID) {
 		return reqID
 	}
 	return uuid.New().String()
 }
 
+// validRequestID - только видимые ASCII-символы и не длиннее maxRequestIDLength
+func validRequestID(reqID string) bool {
+	return len(reqID) <= maxRequestIDLength && isVisibleASCII(reqID)
+}
+
+// isVisibleASCII - в значении заголовка нет пробелов, управляющих и не-ASCII символов
+func isVisibleASCII(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] <= ' ' || value[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
 func LoggerFromContext(ctx context.Context) *zap.Logger {
 	logger, ok := ctx.Value(ctxKeyLogger{}).(*zap.Logger)
 	if ok {
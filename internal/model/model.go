@@ -1,43 +1,86 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type User struct {
-	ID             int     `json:"id"`
+	ID             int     `json:"-"`      // внутренний ключ для связей в БД, наружу не отдаётся
+	PublicID       string  `json:"id"`     // публичный UUIDv7: используется в URL, токенах и ответах
+	Handle         string  `json:"handle"` // уникальное без учёта регистра имя; меняется только через PUT /me/handle
 	Name           string  `json:"name" validate:"required"`
 	Age            int     `json:"age" validate:"gte=0,lte=130"`
-	Email          string  `json:"email" validate:"required, email"`
+	Email          string  `json:"email" validate:"required,email"`
 	Role           string  `json:"role"`
-	HashedPassword string  // не указывать json:"..." — не придет снаружи
+	Status         string  `json:"status"` // pending, active, suspended, locked или closed; меняется только через AccountStatusService
+	HashedPassword string  `json:"-"`      // никогда не приходит снаружи и не отдаётся в ответах
 	Balance        float64 `json:"balance" validate:"min=0"`
 }
 
 // PartialUser — используется для PATCH-запросов
 type PartialUser struct {
-	ID             int      `json:"id"`
-	Name           *string  `json:"name,omitempty" validate:"required"`
-	Age            *int     `json:"age,omitempty" validate:"gte=0,lte=130"`
-	Email          *string  `json:"email,omitempty" validate:"required, email"`
-	HashedPassword *string  // не указывать json:"..." — не придет снаружи
-	Balance        *float64 `json:"balance" validate:"min=0"`
+	ID             int      `json:"-"`
+	PublicID       string   `json:"id"`
+	Name           *string  `json:"name,omitempty" validate:"omitempty,min=1"`
+	Age            *int     `json:"age,omitempty" validate:"omitempty,gte=0,lte=130"`
+	Email          *string  `json:"email,omitempty" validate:"omitempty,email"`
+	HashedPassword *string  `json:"-"` // никогда не приходит снаружи и не отдаётся в ответах
+	Balance        *float64 `json:"balance" validate:"omitempty,min=0"`
 }
 
 type RegisterRequest struct {
 	Name     string `json:"name" validate:"required"`
 	Age      int    `json:"age" validate:"gte=0,lte=130"`
-	Email    string `json:"email" validate:"required, email"`
-	Password string `json:"password" validate:"required, min=8"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"` // требования к паролю — в password.Validate
+	Handle   string `json:"handle,omitempty"`             // необязательно; без него выдаётся случайное user_xxx
+}
+
+// CreateUserRequest — POST /users: администратор создаёт пользователя сразу с паролем
+type CreateUserRequest struct {
+	Name     string `json:"name" validate:"required"`
+	Age      int    `json:"age" validate:"gte=0,lte=130"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"` // требования к паролю — в password.Validate
+	Handle   string `json:"handle,omitempty"`             // необязательно; без него выдаётся случайное user_xxx
+}
+
+// UpdateProfileRequest — PATCH /me: пользователь меняет только свои имя, возраст и e-mail
+type UpdateProfileRequest struct {
+	Name  *string `json:"name,omitempty" validate:"omitempty,min=1"`
+	Age   *int    `json:"age,omitempty" validate:"omitempty,gte=0,lte=130"`
+	Email *string `json:"email,omitempty" validate:"omitempty,email"`
+}
+
+// ChangePasswordRequest — смена пароля с повторной проверкой текущего
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// DeleteAccountRequest — удаление своего аккаунта с подтверждением паролем
+type DeleteAccountRequest struct {
+	Password string `json:"password" validate:"required"`
 }
 
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required, email"`
+	Email    string `json:"email,omitempty" validate:"required_without=Login,omitempty,email"`
+	Login    string `json:"login,omitempty" validate:"required_without=Email"` // имя пользователя или e-mail
 	Password string `json:"password" validate:"required"`
 }
 
+// ChangeHandleRequest — PUT /me/handle: смена имени пользователя
+type ChangeHandleRequest struct {
+	Handle string `json:"handle" validate:"required"`
+}
+
+// UpdatedUserPut — тестовые данные для PUT-запроса. Публичный ID подставляется из ответа на POST
 var UpdatedUserPut = User{
-	ID: 2, Name: "Marina", Age: 23, Email: "marina@gmail.com"}
+	Name: "Marina", Age: 23, Email: "marina@gmail.com"}
 
 // UpdatedUserPatch — тестовые данные для PATCH-запроса
 var UpdatedUserPatch = PartialUser{
-	ID:    1,
 	Email: StrPtr("new@gmail.com"),
 }
 
@@ -46,6 +89,253 @@ func StrPtr(s string) *string {
 	return &s
 }
 
-var DeletedUser = User{ID: 1}
+// TOTP — состояние TOTP-аутентификатора пользователя
+type TOTP struct {
+	UserID  int
+	Secret  string
+	Enabled bool
+}
+
+// TOTPEnrollResponse — данные для подключения приложения-аутентификатора
+type TOTPEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`     // otpauth://totp/... для ручного ввода или генерации QR
+	QRCode string `json:"qr_code"` // PNG с QR-кодом в base64
+}
+
+// MFACodeRequest — код из приложения-аутентификатора
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// MFALoginRequest — второй шаг входа: MFA-токен из /login и код (TOTP или код восстановления)
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+// RecoveryCodesResponse — коды восстановления, показываются пользователю один раз
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// APIKey — именованный API-ключ для сервисного доступа со списком разрешённых скоупов
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  *int       `json:"-"`
+	CreatorID  string     `json:"created_by,omitempty"` // публичный ID администратора, выпустившего ключ
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	KeyHash    string     `json:"-"` // SHA-256 полного ключа, наружу не отдаётся
+}
+
+// Session — сессия пользователя (один вход с одного устройства), она же семейство refresh-токенов
+type Session struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Device     string     `json:"device"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	Current    bool       `json:"current"` // сессия, из которой сделан запрос
+	RefreshJTI string     `json:"-"`       // ID последнего refresh-токена семейства
+}
+
+// RevokeSessionsResponse — результат отзыва нескольких сессий
+type RevokeSessionsResponse struct {
+	Revoked int64 `json:"revoked"`
+}
+
+// ExternalIdentity — пользователь внешнего провайдера (OIDC) по данным проверенного ID-токена
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string // claim "sub": постоянный ID пользователя у провайдера
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// ImpersonateRequest — запрос администратора на вход от имени пользователя
+type ImpersonateRequest struct {
+	Reason string `json:"reason" validate:"required,min=3,max=500"` // попадает в журнал аудита
+}
+
+// ImpersonationResponse — короткоживущий access-токен от имени пользователя (без refresh-токена)
+type ImpersonationResponse struct {
+	AccessToken string    `json:"access-token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	UserID      string    `json:"user_id"`  // публичный ID пользователя
+	ActorID     string    `json:"actor_id"` // публичный ID администратора
+}
+
+// MagicLinkRequest — запрос ссылки для входа по e-mail
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// RedeemMagicLinkRequest — токен из ссылки для входа
+type RedeemMagicLinkRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// CreateAPIKeyRequest — запрос администратора на выпуск API-ключа
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,oneof=users:read users:write users:delete transfers:write transfers:any"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// CreateAPIKeyResponse — выпущенный ключ. Поле key показывается только один раз
+type CreateAPIKeyResponse struct {
+	Key    string `json:"key"`
+	APIKey APIKey `json:"api_key"`
+}
+
+// TransferRequest — перевод средств. FromID обязателен только для API-ключей,
+// для пользователя отправителем всегда считается он сам.
+// Получатель задаётся через To (имя пользователя, e-mail или публичный ID) либо через ToID
+type TransferRequest struct {
+	FromID string  `json:"from_id,omitempty" validate:"omitempty,uuid"` // публичные ID пользователей
+	ToID   string  `json:"to_id,omitempty" validate:"required_without=To,omitempty,uuid"`
+	To     string  `json:"to,omitempty" validate:"required_without=ToID"`
+	Amount float64 `json:"amount" validate:"required,gt=0"`
+}
+
+// RoleInfo — роль и её права с учётом наследования
+type RoleInfo struct {
+	Name        string   `json:"name"`
+	Inherits    string   `json:"inherits,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// SetRoleRequest — запрос администратора на назначение роли пользователю
+type SetRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=guest editor admin"`
+}
+
+// LoginThrottle — счётчик неудачных попыток входа по e-mail или IP
+type LoginThrottle struct {
+	Key          string
+	Failures     int
+	BlockedUntil *time.Time
+	Locked       bool
+}
+
+// RateLimitResult — решение лимитера по одному запросу
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int           // сколько запросов можно сделать подряд (RateLimit-Limit)
+	Remaining  int           // сколько осталось прямо сейчас (RateLimit-Remaining)
+	RetryAfter time.Duration // через сколько повторить, если запрос отклонён
+}
+
+// IdempotentResponse — запись о запросе с Idempotency-Key. StatusCode = 0 — запрос ещё выполняется
+type IdempotentResponse struct {
+	Fingerprint string
+	StatusCode  int
+	Header      map[string][]string
+	Body        []byte
+}
+
+// Passkey — ключ доступа WebAuthn, зарегистрированный пользователем
+type Passkey struct {
+	ID              int        `json:"id"`
+	UserID          int        `json:"-"`
+	Name            string     `json:"name"`
+	CredentialID    []byte     `json:"credential_id"` // в JSON — base64
+	AAGUID          []byte     `json:"aaguid,omitempty"`
+	SignCount       uint32     `json:"sign_count"`
+	Transports      []string   `json:"transports"`
+	BackupEligible  bool       `json:"backup_eligible"`
+	BackupState     bool       `json:"backup_state"` // ключ синхронизирован в облако (iCloud, Google и т.п.)
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	PublicKey       []byte     `json:"-"`
+	AttestationType string     `json:"-"`
+}
 
-var GetUserByID = User{ID: 3}
+// PasskeyBeginResponse — параметры церемонии WebAuthn для navigator.credentials.create/get.
+// ceremony_id нужно вернуть в запросе finish
+type PasskeyBeginResponse struct {
+	CeremonyID string `json:"ceremony_id"`
+	Options    any    `json:"options"`
+}
+
+// PasskeyRegisterFinishRequest — ответ аутентификатора на navigator.credentials.create
+type PasskeyRegisterFinishRequest struct {
+	CeremonyID string          `json:"ceremony_id" validate:"required"`
+	Name       string          `json:"name" validate:"max=64"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+// PasskeyLoginFinishRequest — ответ аутентификатора на navigator.credentials.get
+type PasskeyLoginFinishRequest struct {
+	CeremonyID string          `json:"ceremony_id" validate:"required"`
+	Credential json.RawMessage `json:"credential" validate:"required"`
+}
+
+// RenamePasskeyRequest — новое название ключа доступа
+type RenamePasskeyRequest struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+// Статусы приглашения
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationRevoked  = "revoked"
+	InvitationExpired  = "expired"
+)
+
+// Invitation — приглашение администратора зарегистрироваться с заранее назначенной ролью
+type Invitation struct {
+	ID             int        `json:"id"`
+	Email          string     `json:"email"`
+	Role           string     `json:"role"`
+	Status         string     `json:"status"` // pending, accepted, revoked или expired
+	InvitedBy      *int       `json:"-"`
+	InviterID      string     `json:"invited_by,omitempty"` // публичный ID пригласившего администратора
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AcceptedAt     *time.Time `json:"accepted_at,omitempty"`
+	AcceptedUserID *int       `json:"-"`
+	AcceptedUser   string     `json:"accepted_user_id,omitempty"` // публичный ID зарегистрированного пользователя
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	TokenHash      string     `json:"-"` // SHA-256 токена из письма, наружу не отдаётся
+}
+
+// CreateInvitationRequest — запрос администратора на приглашение
+type CreateInvitationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=guest editor admin"`
+}
+
+// AcceptInvitationRequest — данные приглашённого: e-mail и роль берутся из приглашения
+type AcceptInvitationRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Name     string `json:"name" validate:"required"`
+	Age      int    `json:"age" validate:"gte=0,lte=130"`
+	Password string `json:"password" validate:"required"` // требования к паролю — в password.Validate
+}
+
+// Статусы аккаунта. Войти и пользоваться API может только active
+const (
+	UserStatusPending   = "pending"   // аккаунт создан, но ещё не подтверждён
+	UserStatusActive    = "active"    // обычное состояние
+	UserStatusSuspended = "suspended" // приостановлен администратором
+	UserStatusLocked    = "locked"    // заблокирован администратором по соображениям безопасности
+	UserStatusClosed    = "closed"    // закрыт навсегда
+)
+
+// ChangeStatusRequest — причина смены статуса аккаунта администратором
+type ChangeStatusRequest struct {
+	Reason string `json:"reason" validate:"required,min=3,max=500"`
+}
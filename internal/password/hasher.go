@@ -0,0 +1,216 @@
+// Package password хеширует и проверяет пароли пользователей.
+//
+// Хеш хранится в закодированном виде с тегом алгоритма ("$argon2id$..." или "$2a$..." для bcrypt),
+// поэтому алгоритм и его параметры можно менять без миграции: старые хеши продолжают проверяться,
+// а при следующем успешном входе пересчитываются текущим алгоритмом (см. Verify).
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"pet/config"
+	"strings"
+)
+
+// ErrUnknownAlgorithm — хеш не распознан ни одним из алгоритмов (например, пароль не задан)
+var ErrUnknownAlgorithm = errors.New("unknown password hash algorithm")
+
+// Hasher — один алгоритм хеширования паролей
+type Hasher interface {
+	// ID — имя алгоритма: "argon2id" или "bcrypt"
+	ID() string
+	// Hash возвращает закодированный хеш с тегом алгоритма и параметрами
+	Hash(password string) (string, error)
+	// Matches сообщает, что хеш закодирован этим алгоритмом
+	Matches(encoded string) bool
+	// Verify проверяет пароль по закодированному хешу
+	Verify(password string, encoded string) (bool, error)
+	// NeedsRehash сообщает, что хеш посчитан с устаревшими (более слабыми) параметрами
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2idHasher — argon2id в формате PHC: $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type Argon2idHasher struct {
+	Memory      uint32 // память в КиБ
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+func (h Argon2idHasher) ID() string { return "argon2id" }
+
+func (h Argon2idHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2idHasher) Verify(password string, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory < h.Memory || params.Iterations < h.Iterations || params.Parallelism < h.Parallelism ||
+		uint32(len(salt)) < h.SaltLength || uint32(len(key)) < h.KeyLength
+}
+
+// decodeArgon2id разбирает хеш argon2id на параметры, соль и ключ
+func decodeArgon2id(encoded string) (Argon2idHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("malformed argon2id hash")
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("unsupported argon2id version: %s", parts[2])
+	}
+
+	var params Argon2idHasher
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("malformed argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("malformed argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return Argon2idHasher{}, nil, nil, fmt.Errorf("malformed argon2id key: %w", err)
+	}
+
+	return params, salt, key, nil
+}
+
+// BcryptHasher — bcrypt. Тег алгоритма и cost уже есть в стандартном формате ($2a$10$...)
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) ID() string { return "bcrypt" }
+
+func (h BcryptHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h BcryptHasher) Verify(password string, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost < h.Cost
+}
+
+var (
+	current   Hasher   // алгоритм для новых хешей
+	known     []Hasher // все алгоритмы, хеши которых умеем проверять
+	dummyHash string   // хеш для проверки-пустышки, см. VerifyDummy
+)
+
+func init() {
+	Init()
+}
+
+// Init настраивает алгоритмы по значениям из config (PasswordAlgorithm, Argon2*, BcryptCost).
+// Вызывается при старте сервера после загрузки конфигурации
+func Init() {
+	argon := Argon2idHasher{
+		Memory:      uint32(config.Argon2Memory),
+		Iterations:  uint32(config.Argon2Iterations),
+		Parallelism: uint8(config.Argon2Parallelism),
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+	bcr := BcryptHasher{Cost: config.BcryptCost}
+
+	known = []Hasher{argon, bcr}
+	current = argon
+	if config.PasswordAlgorithm == bcr.ID() {
+		current = bcr
+	}
+
+	dummyHash, _ = current.Hash("dummy-password-for-timing")
+}
+
+// Algorithm возвращает имя алгоритма, которым хешируются новые пароли
+func Algorithm() string {
+	return current.ID()
+}
+
+// Hash хеширует пароль текущим алгоритмом
+func Hash(password string) (string, error) {
+	return current.Hash(password)
+}
+
+// Verify проверяет пароль по хешу любого поддерживаемого алгоритма.
+// needsRehash = true, если пароль верный, но хеш посчитан другим алгоритмом
+// или с устаревшими параметрами — тогда его стоит пересчитать через Hash и сохранить.
+func Verify(password string, encoded string) (ok bool, needsRehash bool, err error) {
+	for _, h := range known {
+		if !h.Matches(encoded) {
+			continue
+		}
+
+		ok, err = h.Verify(password, encoded)
+		if err != nil || !ok {
+			return false, false, err
+		}
+		return true, h.ID() != current.ID() || h.NeedsRehash(encoded), nil
+	}
+
+	return false, false, ErrUnknownAlgorithm
+}
+
+// VerifyDummy тратит на проверку столько же времени, сколько настоящая проверка,
+// чтобы по времени ответа нельзя было понять, что пользователя не существует
+func VerifyDummy(password string) {
+	_, _, _ = Verify(password, dummyHash)
+}
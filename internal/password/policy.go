@@ -0,0 +1,118 @@
+package password
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"os"
+	"pet/config"
+	"strings"
+	"unicode"
+)
+
+// commonPasswords — встроенный список самых распространённых паролей.
+// Дополнительный список можно задать файлом config.PasswordPolicy.DenylistFile
+//
+//go:embed denylist.txt
+var commonPasswords string
+
+var denylist = map[string]struct{}{}
+
+func init() {
+	addToDenylist(commonPasswords)
+}
+
+func addToDenylist(list string) {
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word != "" && !strings.HasPrefix(word, "#") {
+			denylist[word] = struct{}{}
+		}
+	}
+}
+
+// LoadDenylistFile добавляет к запрещённым паролям слова из файла (по одному на строку)
+func LoadDenylistFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("password.LoadDenylistFile: %w", err)
+	}
+	addToDenylist(string(data))
+	return nil
+}
+
+// bcryptMaxBytes — наибольшая длина пароля, которую принимает bcrypt
+const bcryptMaxBytes = 72
+
+// PolicyError — пароль не соответствует политике. Violations можно показать пользователю
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password policy violation: " + strings.Join(e.Violations, "; ")
+}
+
+// Validate проверяет пароль по политике config.PasswordPolicy.
+// userInputs — данные пользователя (e-mail, имя), которые нельзя использовать как пароль
+func Validate(password string, userInputs ...string) error {
+	policy := config.PasswordPolicy
+	var violations []string
+
+	length := len([]rune(password))
+	if length < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", policy.MinLength))
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d characters long", policy.MaxLength))
+	}
+	// bcrypt не хеширует пароли длиннее 72 байт: такой пароль иначе прошёл бы политику и дал 500
+	if current.ID() == "bcrypt" && len(password) > bcryptMaxBytes {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", bcryptMaxBytes))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		default:
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, "must contain a digit")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	lower := strings.ToLower(password)
+	if _, denied := denylist[lower]; denied {
+		violations = append(violations, "is too common")
+	}
+	for _, input := range userInputs {
+		input = strings.ToLower(strings.TrimSpace(input))
+		local, _, _ := strings.Cut(input, "@")
+		if input != "" && (lower == input || lower == local) {
+			violations = append(violations, "must not match your e-mail or name")
+			break
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package render
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// encodeJSON — как json.NewEncoder(w).Encode, которым ответы кодировались раньше
+func encodeJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+// encodeMsgpack кодирует v в MessagePack с именами полей из тегов json
+func encodeMsgpack(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	return enc.Encode(v)
+}
+
+// generic переводит v в то, что получилось бы после JSON: map[string]any, []any, json.Number, string, bool или nil.
+// Так XML и CSV учитывают теги json (имена, omitempty, "-") и MarshalJSON так же, как JSON-ответы
+func generic(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var out any
+	err = dec.Decode(&out)
+	return out, err
+}
+
+// encodeXML кодирует v в XML: корневой элемент <response>, поля объекта — элементы с именами
+// из тегов json (по алфавиту), элементы списка — <item>
+func encodeXML(w io.Writer, v any) error {
+	value, err := generic(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	err = writeXMLElement(enc, "response", value)
+	if err != nil {
+		return err
+	}
+	err = enc.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func writeXMLElement(enc *xml.Encoder, name string, value any) error {
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+	err := enc.EncodeToken(start)
+	if err != nil {
+		return err
+	}
+
+	switch value := value.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			err = writeXMLElement(enc, key, value[key])
+			if err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, item := range value {
+			err = writeXMLElement(enc, "item", item)
+			if err != nil {
+				return err
+			}
+		}
+	case nil:
+		// пустой элемент
+	default:
+		err = enc.EncodeToken(xml.CharData(scalarString(value)))
+		if err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// xmlName заменяет символы, недопустимые в имени XML-элемента, на "_"
+func xmlName(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		valid := r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') ||
+			(i > 0 && (r == '-' || r == '.' || (r >= '0' && r <= '9')))
+		if valid {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// encodeCSV кодирует список в CSV. Столбцы — поля элемента в порядке объявления (имена из тегов json),
+// вложенные объекты и списки записываются в ячейку как JSON. Список не из объектов — один столбец value
+func encodeCSV(w io.Writer, v any) error {
+	value, err := generic(v)
+	if err != nil {
+		return err
+	}
+	rows, _ := value.([]any) // nil-срез кодируется в JSON как null — это пустой список
+
+	columns := csvColumns(reflect.TypeOf(v).Elem())
+
+	cw := csv.NewWriter(w)
+	if columns == nil {
+		err = cw.Write([]string{"value"})
+		for _, row := range rows {
+			if err != nil {
+				break
+			}
+			err = cw.Write([]string{csvCell(row)})
+		}
+	} else {
+		err = cw.Write(columns)
+		for _, row := range rows {
+			if err != nil {
+				break
+			}
+			fields, _ := row.(map[string]any)
+			record := make([]string, len(columns))
+			for i, column := range columns {
+				record[i] = csvCell(fields[column])
+			}
+			err = cw.Write(record)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvColumns возвращает имена полей структуры t из тегов json с учётом встроенных структур.
+// nil — t не структура
+func csvColumns(t reflect.Type) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	columns := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := csvColumns(field.Type)
+			if embedded != nil {
+				columns = append(columns, embedded...)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if !slices.Contains(columns, name) {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
+
+// csvCell форматирует значение ячейки. Строки, которые табличный редактор принял бы за формулу,
+// начинаются с апострофа (защита от CSV-инъекций: имена пользователей задают сами пользователи)
+func csvCell(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+			return "'" + value
+		}
+		return value
+	case map[string]any, []any:
+		data, _ := json.Marshal(value)
+		return string(data)
+	default:
+		return scalarString(value)
+	}
+}
+
+// scalarString форматирует строку, число или bool из generic
+func scalarString(value any) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,190 @@
+// Package render — ответы API в формате, который клиент запросил в заголовке Accept:
+// JSON (по умолчанию), XML, MessagePack и CSV (только для списков).
+// Имена полей во всех форматах берутся из тегов json, как в JSON-ответах
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// format — формат ответа
+type format struct {
+	mediaType   string   // имя в Accept
+	aliases     []string // другие имена того же формата в Accept
+	contentType string   // заголовок Content-Type ответа
+	listOnly    bool     // только для списков (CSV)
+	encode      func(w io.Writer, v any) error
+}
+
+// formats — поддерживаемые форматы. При одинаковом q в Accept выбирается тот, что раньше в списке
+var formats = []format{
+	{mediaType: "application/json", contentType: "application/json", encode: encodeJSON},
+	{mediaType: "application/xml", aliases: []string{"text/xml"}, contentType: "application/xml; charset=utf-8", encode: encodeXML},
+	{mediaType: "application/msgpack", aliases: []string{"application/x-msgpack", "application/vnd.msgpack"},
+		contentType: "application/msgpack", encode: encodeMsgpack},
+	{mediaType: "text/csv", contentType: "text/csv; charset=utf-8", listOnly: true, encode: encodeCSV},
+}
+
+// MediaTypes возвращает поддерживаемые форматы ответа (для 406 и документации)
+func MediaTypes() []string {
+	types := make([]string, 0, len(formats))
+	for _, f := range formats {
+		types = append(types, f.mediaType)
+	}
+	return types
+}
+
+// mediaRange — один элемент заголовка Accept: "text/*;q=0.5"
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept разбирает заголовок Accept. Элементы с неверным q пропускаются
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok || typ == "" || subtype == "" {
+			continue
+		}
+
+		q := 1.0
+		valid := true
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(strings.TrimSpace(key), "q") {
+				parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+				if err != nil || parsed < 0 || parsed > 1 {
+					valid = false
+					break
+				}
+				q = parsed
+			}
+		}
+		if valid {
+			ranges = append(ranges, mediaRange{typ: strings.TrimSpace(typ), subtype: strings.TrimSpace(subtype), q: q})
+		}
+	}
+	return ranges
+}
+
+// quality возвращает q для типа mediaType по самому точному подходящему диапазону (RFC 9110, 12.5.1):
+// "text/csv" точнее "text/*", а тот точнее "*/*". 0 — тип не принимается
+func quality(ranges []mediaRange, mediaType string) float64 {
+	typ, subtype, _ := strings.Cut(mediaType, "/")
+
+	best, specificity := 0.0, -1
+	for _, mr := range ranges {
+		var s int
+		switch {
+		case mr.typ == typ && mr.subtype == subtype:
+			s = 2
+		case mr.typ == typ && mr.subtype == "*":
+			s = 1
+		case mr.typ == "*" && mr.subtype == "*":
+			s = 0
+		default:
+			continue
+		}
+		if s > specificity {
+			best, specificity = mr.q, s
+		}
+	}
+	return best
+}
+
+// negotiate выбирает формат для ответа. list — значение является списком (для CSV).
+// Без Accept — JSON
+func negotiate(accept string, list bool) (format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return formats[0], true
+	}
+	ranges := parseAccept(accept)
+
+	var chosen format
+	bestQ := 0.0
+	for _, f := range formats {
+		if f.listOnly && !list {
+			continue
+		}
+		q := quality(ranges, f.mediaType)
+		for _, alias := range f.aliases {
+			q = max(q, quality(ranges, alias))
+		}
+		if q > bestQ {
+			chosen, bestQ = f, q
+		}
+	}
+	return chosen, bestQ > 0
+}
+
+// Acceptable сообщает, принимает ли клиент хоть один из форматов (CSV считается — он годится для списков)
+func Acceptable(accept string) bool {
+	_, ok := negotiate(accept, true)
+	return ok
+}
+
+// isList — значение кодируется списком: срез или массив (кроме []byte)
+func isList(v any) bool {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return false
+	}
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8
+}
+
+// Write кодирует v в формате из Accept и отправляет его со статусом status.
+// Если клиент не принимает ни одного формата, отвечает 406; ответы об ошибках (status >= 400) в этом
+// случае отдаются в JSON, чтобы клиент всё же узнал, что пошло не так. Ответ, который не является
+// списком, клиенту, принимающему только CSV, отдаётся в JSON: запрос уже выполнен (Negotiate пропускает
+// CSV для любого маршрута), и 406 скрыл бы его результат.
+// Тело кодируется целиком до отправки: при ошибке кодирования клиент получит 500, а не обрывок
+func Write(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	w.Header().Add("Vary", "Accept")
+
+	accept := r.Header.Get("Accept")
+	f, ok := negotiate(accept, isList(v))
+	if !ok {
+		if status < http.StatusBadRequest && !Acceptable(accept) {
+			WriteNotAcceptable(w)
+			return nil
+		}
+		f = formats[0]
+	}
+
+	var buf bytes.Buffer
+	err := f.encode(&buf, v)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return fmt.Errorf("render/Write: %s: %w", f.mediaType, err)
+	}
+
+	w.Header().Set("Content-Type", f.contentType)
+	w.WriteHeader(status)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("render/Write: %w", err)
+	}
+	return nil
+}
+
+// WriteNotAcceptable отвечает 406 со списком поддерживаемых форматов (в JSON: другие клиент не принимает)
+func WriteNotAcceptable(w http.ResponseWriter) {
+	var buf bytes.Buffer
+	_ = encodeJSON(&buf, map[string]any{
+		"error":     "not acceptable",
+		"available": MediaTypes(),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotAcceptable)
+	_, _ = w.Write(buf.Bytes())
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/internal/model"
+	"strings"
+)
+
+// apiKeyColumns - общий список колонок для выборки API-ключей. Автор ключа отдаётся наружу по публичному ID
+const apiKeyColumns = `id, name, prefix, key_hash, scopes, created_by,
+	(SELECT u.public_id FROM users u WHERE u.id = api_keys.created_by),
+	created_at, expires_at, last_used_at, revoked_at`
+
+// scanAPIKey - сканирует строку с колонками apiKeyColumns в model.APIKey
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (model.APIKey, error) {
+	var key model.APIKey
+	var scopes string
+	var createdBy sql.NullInt64
+	var creatorID sql.NullString
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.KeyHash, &scopes, &createdBy, &creatorID,
+		&key.CreatedAt, &expiresAt, &lastUsedAt, &revokedAt)
+	if err != nil {
+		return model.APIKey{}, err
+	}
+
+	key.Scopes = strings.Fields(scopes)
+	if createdBy.Valid {
+		id := int(createdBy.Int64)
+		key.CreatedBy = &id
+	}
+	key.CreatorID = creatorID.String
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return key, nil
+}
+
+// CreateAPIKey сохраняет новый API-ключ (только префикс и хеш) и возвращает его из БД
+func (r *UserRepository) CreateAPIKey(ctx context.Context, key model.APIKey) (model.APIKey, error) {
+	query := `
+	INSERT INTO api_keys (name, prefix, key_hash, scopes, created_by, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING ` + apiKeyColumns
+
+	row := r.db.QueryRowContext(ctx, query,
+		key.Name,
+		key.Prefix,
+		key.KeyHash,
+		strings.Join(key.Scopes, " "),
+		key.CreatedBy,
+		key.ExpiresAt)
+
+	created, err := scanAPIKey(row)
+	if err != nil {
+		r.log.Error("failed to insert api key",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "CreateAPIKey"))
+
+		return model.APIKey{}, fmt.Errorf("repository/CreateAPIKey: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetAPIKeyByPrefix находит API-ключ по его публичному префиксу
+func (r *UserRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (model.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE prefix = $1`
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, prefix))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to scan api key",
+				zap.Error(err),
+				zap.String("component", "repository"),
+				zap.String("event", "GetAPIKeyByPrefix"))
+		}
+
+		return model.APIKey{}, fmt.Errorf("repository/GetAPIKeyByPrefix: %w", err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys возвращает все API-ключи, включая отозванные
+func (r *UserRepository) ListAPIKeys(ctx context.Context) ([]model.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys ORDER BY id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		r.log.Error("failed to execute SELECT api_keys",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "ListAPIKeys"))
+
+		return nil, fmt.Errorf("repository/ListAPIKeys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []model.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository/ListAPIKeys: %w", err)
+		}
+		keys = append(keys, key)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("repository/ListAPIKeys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey отзывает API-ключ. Повторный отзыв возвращает ошибку с sql.ErrNoRows
+func (r *UserRepository) RevokeAPIKey(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		r.log.Error("failed to revoke api key",
+			zap.Error(err),
+			zap.Int("api_key.id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "RevokeAPIKey"))
+
+		return fmt.Errorf("repository/RevokeAPIKey: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("repository/RevokeAPIKey: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// TouchAPIKey обновляет время последнего использования ключа.
+// Чтобы не писать в БД на каждый запрос, значение обновляется не чаще раза в минуту.
+func (r *UserRepository) TouchAPIKey(ctx context.Context, id int) error {
+	query := `
+	UPDATE api_keys
+	SET last_used_at = now()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')
+`
+	_, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.log.Warn("failed to update api key last_used_at",
+			zap.Error(err),
+			zap.Int("api_key.id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "TouchAPIKey"))
+
+		return fmt.Errorf("repository/TouchAPIKey: %w", err)
+	}
+	return nil
+}
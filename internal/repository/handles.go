@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+	"pet/internal/model"
+	"time"
+)
+
+// ErrHandleTaken — имя занято другим пользователем или закреплено за прежним владельцем
+var ErrHandleTaken = errors.New("handle already taken")
+
+// handleTakenQuery - занято ли имя $1 для пользователя $2: есть у другого пользователя
+// или после смены закреплено за другим пользователем и срок ещё не истёк
+const handleTakenQuery = `SELECT
+	EXISTS (SELECT 1 FROM users WHERE lower(handle) = lower($1) AND id <> $2)
+	OR EXISTS (SELECT 1 FROM handle_reservations WHERE handle = lower($1) AND user_id <> $2 AND reserved_until > now())`
+
+// isHandleConflict - нарушен уникальный индекс имени (гонка двух запросов, прошедших проверку EXISTS)
+func isHandleConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "users_handle_lower_key"
+}
+
+// HandleAvailable проверяет, может ли пользователь userID занять имя (0 — для нового пользователя)
+func (r *UserRepository) HandleAvailable(ctx context.Context, handle string, userID int) (bool, error) {
+	var taken bool
+	err := r.db.QueryRowContext(ctx, handleTakenQuery, handle, userID).Scan(&taken)
+	if err != nil {
+		r.log.Error("failed to check handle",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "HandleAvailable"))
+
+		return false, fmt.Errorf("repository/HandleAvailable: %w", err)
+	}
+	return !taken, nil
+}
+
+// GetUserByHandle получает пользователя по имени без учёта регистра.
+// Если пользователя нет — ошибка с sql.ErrNoRows
+func (r *UserRepository) GetUserByHandle(ctx context.Context, handle string) (model.User, error) {
+	query := `
+	SELECT id, public_id, handle, name, age, email, role, status, password
+	FROM users
+	WHERE lower(handle) = lower($1)
+`
+	var user model.User
+	err := r.db.QueryRowContext(ctx, query, handle).
+		Scan(&user.ID, &user.PublicID, &user.Handle, &user.Name, &user.Age, &user.Email, &user.Role, &user.Status, &user.HashedPassword)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to get user by handle",
+				zap.Error(err),
+				zap.String("component", "repository"),
+				zap.String("event", "GetUserByHandle"))
+		}
+
+		return model.User{}, fmt.Errorf("repository/GetUserByHandle: %w", err)
+	}
+	return user, nil
+}
+
+// ChangeHandle в одной транзакции меняет имя пользователя и закрепляет старое за ним до reservedUntil.
+// Свою же закреплённую запись новое имя снимает. Если имя занято — ErrHandleTaken
+func (r *UserRepository) ChangeHandle(ctx context.Context, userID int, handle string, reservedUntil time.Time) (model.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/ChangeHandle: %w", err)
+	}
+	defer tx.Rollback()
+
+	// FOR UPDATE: две одновременные смены имени одного пользователя не потеряют закрепление старого
+	var old string
+	err = tx.QueryRowContext(ctx, "SELECT handle FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&old)
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/ChangeHandle: %w", err)
+	}
+
+	var taken bool
+	err = tx.QueryRowContext(ctx, handleTakenQuery, handle, userID).Scan(&taken)
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/ChangeHandle: %w", err)
+	}
+	if taken {
+		return model.User{}, fmt.Errorf("repository/ChangeHandle: %w", ErrHandleTaken)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET handle = $2 WHERE id = $1", userID, handle)
+	if err != nil {
+		if isHandleConflict(err) {
+			return model.User{}, fmt.Errorf("repository/ChangeHandle: %w", ErrHandleTaken)
+		}
+
+		r.log.Error("failed to update handle",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "ChangeHandle"))
+
+		return model.User{}, fmt.Errorf("repository/ChangeHandle: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO handle_reservations (handle, user_id, reserved_until)
+	VALUES (lower($1), $2, $3)
+	ON CONFLICT (handle) DO UPDATE SET user_id = EXCLUDED.user_id, reserved_until = EXCLUDED.reserved_until
+`, old, userID, reservedUntil)
+	if err != nil {
+		r.log.Error("failed to reserve old handle",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "ChangeHandle"))
+
+		return model.User{}, fmt.Errorf("repository/ChangeHandle: %w", err)
+	}
+
+	// смена только регистра оставляет имя за пользователем — закреплять его не нужно
+	_, err = tx.ExecContext(ctx, "DELETE FROM handle_reservations WHERE handle = lower($1)", handle)
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/ChangeHandle: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/ChangeHandle: %w", err)
+	}
+
+	user, err := r.GetUserByID(ctx, userID)
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/ChangeHandle: %w", err)
+	}
+	user.HashedPassword = ""
+	return user, nil
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/internal/model"
+	"time"
+)
+
+// acquireIdempotencyKeyQuery занимает ключ: новый, истёкший или брошенный (запрос не завершился к locked_until).
+// Занятый ключ не меняется — тогда запрос не возвращает строк
+const acquireIdempotencyKeyQuery = `
+INSERT INTO idempotency_keys AS ik (scope, key, fingerprint, locked_until, expires_at)
+VALUES ($1, $2, $3, now() + make_interval(secs => $4), now() + make_interval(secs => $5))
+ON CONFLICT (scope, key) DO UPDATE
+SET fingerprint = EXCLUDED.fingerprint,
+    status_code = NULL,
+    headers = NULL,
+    body = NULL,
+    locked_until = EXCLUDED.locked_until,
+    expires_at = EXCLUDED.expires_at
+WHERE ik.expires_at <= now() OR (ik.status_code IS NULL AND ik.locked_until <= now())
+RETURNING true
+`
+
+// AcquireIdempotencyKey занимает ключ key клиента scope для запроса с отпечатком fingerprint
+// на время lock (пока запрос выполняется) и хранит ответ ttl. true — ключ занят этим запросом.
+// false — ключ уже занят: возвращается сохранённая запись (StatusCode = 0, если запрос ещё выполняется).
+// Заодно удаляются истёкшие ключи
+func (r *UserRepository) AcquireIdempotencyKey(ctx context.Context, scope string, key string, fingerprint string, lock time.Duration, ttl time.Duration) (model.IdempotentResponse, bool, error) {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= now()")
+	if err != nil {
+		r.log.Warn("failed to delete expired idempotency keys",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "AcquireIdempotencyKey"))
+	}
+
+	var acquired bool
+	err = r.db.QueryRowContext(ctx, acquireIdempotencyKeyQuery, scope, key, fingerprint, lock.Seconds(), ttl.Seconds()).Scan(&acquired)
+	if err == nil {
+		return model.IdempotentResponse{}, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		r.log.Error("failed to acquire idempotency key",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "AcquireIdempotencyKey"))
+
+		return model.IdempotentResponse{}, false, fmt.Errorf("repository/AcquireIdempotencyKey: %w", err)
+	}
+
+	var stored model.IdempotentResponse
+	var statusCode sql.NullInt64
+	var headers []byte
+	query := `SELECT fingerprint, status_code, headers, body FROM idempotency_keys WHERE scope = $1 AND key = $2`
+	err = r.db.QueryRowContext(ctx, query, scope, key).Scan(&stored.Fingerprint, &statusCode, &headers, &stored.Body)
+	if errors.Is(err, sql.ErrNoRows) {
+		// ключ освободили между запросами: пусть вызывающий попробует снова, как с выполняющимся запросом
+		return model.IdempotentResponse{Fingerprint: fingerprint}, false, nil
+	}
+	if err != nil {
+		r.log.Error("failed to get idempotency key",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "AcquireIdempotencyKey"))
+
+		return model.IdempotentResponse{}, false, fmt.Errorf("repository/AcquireIdempotencyKey: %w", err)
+	}
+
+	stored.StatusCode = int(statusCode.Int64)
+	if len(headers) > 0 {
+		err = json.Unmarshal(headers, &stored.Header)
+		if err != nil {
+			return model.IdempotentResponse{}, false, fmt.Errorf("repository/AcquireIdempotencyKey: %w", err)
+		}
+	}
+	return stored, false, nil
+}
+
+// SaveIdempotentResponse сохраняет ответ на запрос, занявший ключ
+func (r *UserRepository) SaveIdempotentResponse(ctx context.Context, scope string, key string, response model.IdempotentResponse) error {
+	headers, err := json.Marshal(response.Header)
+	if err != nil {
+		return fmt.Errorf("repository/SaveIdempotentResponse: %w", err)
+	}
+
+	query := `
+	UPDATE idempotency_keys SET status_code = $3, headers = $4, body = $5
+	WHERE scope = $1 AND key = $2 AND status_code IS NULL
+`
+	_, err = r.db.ExecContext(ctx, query, scope, key, response.StatusCode, string(headers), response.Body)
+	if err != nil {
+		r.log.Error("failed to save idempotent response",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "SaveIdempotentResponse"))
+
+		return fmt.Errorf("repository/SaveIdempotentResponse: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey освобождает ключ, ответ на который не сохраняется: повтор выполнится заново
+func (r *UserRepository) ReleaseIdempotencyKey(ctx context.Context, scope string, key string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM idempotency_keys WHERE scope = $1 AND key = $2 AND status_code IS NULL", scope, key)
+	if err != nil {
+		r.log.Error("failed to release idempotency key",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "ReleaseIdempotencyKey"))
+
+		return fmt.Errorf("repository/ReleaseIdempotencyKey: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/internal/model"
+)
+
+// GetUserByIdentity находит пользователя, к которому привязана внешняя учётная запись
+func (r *UserRepository) GetUserByIdentity(ctx context.Context, provider string, subject string) (model.User, error) {
+	var userID int
+	err := r.db.QueryRowContext(ctx, "SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2", provider, subject).Scan(&userID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to select user identity",
+				zap.Error(err),
+				zap.String("identity.provider", provider),
+				zap.String("component", "repository"),
+				zap.String("event", "GetUserByIdentity"))
+		}
+
+		return model.User{}, fmt.Errorf("repository/GetUserByIdentity: %w", err)
+	}
+
+	return r.GetUserByID(ctx, userID)
+}
+
+// LinkIdentity привязывает внешнюю учётную запись к пользователю или,
+// если она уже привязана, обновляет e-mail и время последнего входа
+func (r *UserRepository) LinkIdentity(ctx context.Context, userID int, identity model.ExternalIdentity) error {
+	query := `
+	INSERT INTO user_identities (user_id, provider, subject, email)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (provider, subject) DO UPDATE
+	SET email = EXCLUDED.email, last_login_at = now()
+`
+	_, err := r.db.ExecContext(ctx, query, userID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		r.log.Error("failed to link identity",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("identity.provider", identity.Provider),
+			zap.String("component", "repository"),
+			zap.String("event", "LinkIdentity"))
+
+		return fmt.Errorf("repository/LinkIdentity: %w", err)
+	}
+	return nil
+}
+
+// CreateUserWithIdentity создаёт пользователя без пароля и сразу привязывает к нему внешнюю учётную запись.
+// Войти такой пользователь может только через провайдера, пока не задаст пароль
+func (r *UserRepository) CreateUserWithIdentity(ctx context.Context, user model.User, identity model.ExternalIdentity) (model.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/CreateUserWithIdentity: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, "INSERT INTO users (name, age, email) VALUES ($1, $2, $3) RETURNING id",
+		user.Name, user.Age, user.Email).Scan(&id)
+	if err != nil {
+		r.log.Error("failed to insert provisioned user",
+			zap.Error(err),
+			zap.String("identity.provider", identity.Provider),
+			zap.String("component", "repository"),
+			zap.String("event", "CreateUserWithIdentity"))
+
+		return model.User{}, fmt.Errorf("repository/CreateUserWithIdentity: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)",
+		id, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		r.log.Error("failed to insert user identity",
+			zap.Error(err),
+			zap.String("identity.provider", identity.Provider),
+			zap.String("component", "repository"),
+			zap.String("event", "CreateUserWithIdentity"))
+
+		return model.User{}, fmt.Errorf("repository/CreateUserWithIdentity: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/CreateUserWithIdentity: %w", err)
+	}
+
+	return r.GetUserByID(ctx, id)
+}
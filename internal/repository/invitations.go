@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/internal/model"
+	"time"
+)
+
+// ErrEmailTaken — пользователь с таким e-mail уже зарегистрирован
+var ErrEmailTaken = errors.New("email already registered")
+
+// invitationColumns - общий список колонок для выборки приглашений. Пользователи отдаются наружу по публичным ID
+const invitationColumns = `id, email, role, token_hash, invited_by,
+	(SELECT u.public_id FROM users u WHERE u.id = invitations.invited_by),
+	created_at, expires_at, accepted_at, accepted_user_id,
+	(SELECT u.public_id FROM users u WHERE u.id = invitations.accepted_user_id),
+	revoked_at`
+
+// pendingInvitation - условие действующего приглашения
+const pendingInvitation = `accepted_at IS NULL AND revoked_at IS NULL AND expires_at > now()`
+
+// scanInvitation - сканирует строку с колонками invitationColumns в model.Invitation и вычисляет статус
+func scanInvitation(row interface{ Scan(dest ...any) error }) (model.Invitation, error) {
+	var invitation model.Invitation
+	var invitedBy, acceptedUserID sql.NullInt64
+	var inviterID, acceptedUser sql.NullString
+	var acceptedAt, revokedAt sql.NullTime
+
+	err := row.Scan(&invitation.ID, &invitation.Email, &invitation.Role, &invitation.TokenHash, &invitedBy, &inviterID,
+		&invitation.CreatedAt, &invitation.ExpiresAt, &acceptedAt, &acceptedUserID, &acceptedUser, &revokedAt)
+	if err != nil {
+		return model.Invitation{}, err
+	}
+
+	if invitedBy.Valid {
+		id := int(invitedBy.Int64)
+		invitation.InvitedBy = &id
+	}
+	if acceptedUserID.Valid {
+		id := int(acceptedUserID.Int64)
+		invitation.AcceptedUserID = &id
+	}
+	invitation.InviterID = inviterID.String
+	invitation.AcceptedUser = acceptedUser.String
+	if acceptedAt.Valid {
+		invitation.AcceptedAt = &acceptedAt.Time
+	}
+	if revokedAt.Valid {
+		invitation.RevokedAt = &revokedAt.Time
+	}
+
+	switch {
+	case invitation.AcceptedAt != nil:
+		invitation.Status = model.InvitationAccepted
+	case invitation.RevokedAt != nil:
+		invitation.Status = model.InvitationRevoked
+	case !invitation.ExpiresAt.After(time.Now()):
+		invitation.Status = model.InvitationExpired
+	default:
+		invitation.Status = model.InvitationPending
+	}
+
+	return invitation, nil
+}
+
+// CreateInvitation сохраняет приглашение. Прежние действующие приглашения на тот же e-mail отзываются,
+// чтобы по старой ссылке нельзя было получить другую роль
+func (r *UserRepository) CreateInvitation(ctx context.Context, invitation model.Invitation) (model.Invitation, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.Invitation{}, fmt.Errorf("repository/CreateInvitation: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `UPDATE invitations SET revoked_at = now() WHERE lower(email) = lower($1) AND `+pendingInvitation,
+		invitation.Email)
+	if err != nil {
+		r.log.Error("failed to revoke previous invitations",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "CreateInvitation"))
+
+		return model.Invitation{}, fmt.Errorf("repository/CreateInvitation: %w", err)
+	}
+
+	query := `
+	INSERT INTO invitations (email, role, token_hash, invited_by, expires_at)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING ` + invitationColumns
+
+	created, err := scanInvitation(tx.QueryRowContext(ctx, query,
+		invitation.Email,
+		invitation.Role,
+		invitation.TokenHash,
+		invitation.InvitedBy,
+		invitation.ExpiresAt))
+	if err != nil {
+		r.log.Error("failed to insert invitation",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "CreateInvitation"))
+
+		return model.Invitation{}, fmt.Errorf("repository/CreateInvitation: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return model.Invitation{}, fmt.Errorf("repository/CreateInvitation: %w", err)
+	}
+
+	return created, nil
+}
+
+// ListInvitations возвращает все приглашения, новые первыми
+func (r *UserRepository) ListInvitations(ctx context.Context) ([]model.Invitation, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+invitationColumns+` FROM invitations ORDER BY created_at DESC, id DESC`)
+	if err != nil {
+		r.log.Error("failed to execute SELECT invitations",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "ListInvitations"))
+
+		return nil, fmt.Errorf("repository/ListInvitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []model.Invitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository/ListInvitations: %w", err)
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("repository/ListInvitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// GetPendingInvitation возвращает действующее приглашение по хешу токена.
+// Если приглашения нет, оно принято, отозвано или истекло — ошибка с sql.ErrNoRows
+func (r *UserRepository) GetPendingInvitation(ctx context.Context, tokenHash string) (model.Invitation, error) {
+	query := `SELECT ` + invitationColumns + ` FROM invitations WHERE token_hash = $1 AND ` + pendingInvitation
+
+	invitation, err := scanInvitation(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to scan invitation",
+				zap.Error(err),
+				zap.String("component", "repository"),
+				zap.String("event", "GetPendingInvitation"))
+		}
+
+		return model.Invitation{}, fmt.Errorf("repository/GetPendingInvitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// RevokeInvitation отзывает действующее приглашение. Если его нет или оно уже не действует — ошибка с sql.ErrNoRows
+func (r *UserRepository) RevokeInvitation(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, `UPDATE invitations SET revoked_at = now() WHERE id = $1 AND `+pendingInvitation, id)
+	if err != nil {
+		r.log.Error("failed to revoke invitation",
+			zap.Error(err),
+			zap.Int("invitation.id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "RevokeInvitation"))
+
+		return fmt.Errorf("repository/RevokeInvitation: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("repository/RevokeInvitation: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// AcceptInvitation в одной транзакции создаёт пользователя с e-mail и ролью из приглашения и гасит приглашение.
+// Если приглашение уже не действует — ошибка с sql.ErrNoRows, если e-mail занят — ErrEmailTaken
+func (r *UserRepository) AcceptInvitation(ctx context.Context, tokenHash string, user model.User) (model.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/AcceptInvitation: %w", err)
+	}
+	defer tx.Rollback()
+
+	// FOR UPDATE: два одновременных запроса с одним токеном не создадут двух пользователей
+	query := `SELECT ` + invitationColumns + ` FROM invitations WHERE token_hash = $1 AND ` + pendingInvitation + ` FOR UPDATE`
+	invitation, err := scanInvitation(tx.QueryRowContext(ctx, query, tokenHash))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to lock invitation",
+				zap.Error(err),
+				zap.String("component", "repository"),
+				zap.String("event", "AcceptInvitation"))
+		}
+
+		return model.User{}, fmt.Errorf("repository/AcceptInvitation: %w", err)
+	}
+
+	var taken bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE email = $1)", invitation.Email).Scan(&taken)
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/AcceptInvitation: %w", err)
+	}
+	if taken {
+		return model.User{}, fmt.Errorf("repository/AcceptInvitation: %w", ErrEmailTaken)
+	}
+
+	var id int
+	err = tx.QueryRowContext(ctx, "INSERT INTO users (name, age, email, password, role) VALUES ($1, $2, $3, $4, $5) RETURNING id",
+		user.Name, user.Age, invitation.Email, user.HashedPassword, invitation.Role).Scan(&id)
+	if err != nil {
+		r.log.Error("failed to insert invited user",
+			zap.Error(err),
+			zap.Int("invitation.id", invitation.ID),
+			zap.String("component", "repository"),
+			zap.String("event", "AcceptInvitation"))
+
+		return model.User{}, fmt.Errorf("repository/AcceptInvitation: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE invitations SET accepted_at = now(), accepted_user_id = $2 WHERE id = $1", invitation.ID, id)
+	if err != nil {
+		r.log.Error("failed to mark invitation accepted",
+			zap.Error(err),
+			zap.Int("invitation.id", invitation.ID),
+			zap.String("component", "repository"),
+			zap.String("event", "AcceptInvitation"))
+
+		return model.User{}, fmt.Errorf("repository/AcceptInvitation: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/AcceptInvitation: %w", err)
+	}
+
+	created, err := r.GetUserByID(ctx, id)
+	if err != nil {
+		return model.User{}, fmt.Errorf("repository/AcceptInvitation: %w", err)
+	}
+	created.HashedPassword = ""
+	return created, nil
+}
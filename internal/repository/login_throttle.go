@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/internal/model"
+	"time"
+)
+
+// GetLoginThrottle возвращает счётчик неудачных входов. Если записи нет — пустой счётчик без ошибки
+func (r *UserRepository) GetLoginThrottle(ctx context.Context, key string) (model.LoginThrottle, error) {
+	query := `
+	SELECT key, failures, blocked_until, locked
+	FROM login_throttle
+	WHERE key = $1
+`
+	throttle := model.LoginThrottle{Key: key}
+	var blockedUntil sql.NullTime
+
+	err := r.db.QueryRowContext(ctx, query, key).Scan(&throttle.Key, &throttle.Failures, &blockedUntil, &throttle.Locked)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return throttle, nil
+		}
+
+		r.log.Error("failed to scan login throttle",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "GetLoginThrottle"))
+
+		return model.LoginThrottle{}, fmt.Errorf("repository/GetLoginThrottle: %w", err)
+	}
+
+	if blockedUntil.Valid {
+		throttle.BlockedUntil = &blockedUntil.Time
+	}
+	return throttle, nil
+}
+
+// IncrementLoginFailures атомарно увеличивает счётчик неудачных входов и возвращает новое значение.
+// Если последняя неудача была раньше, чем window назад, счёт начинается заново.
+func (r *UserRepository) IncrementLoginFailures(ctx context.Context, key string, window time.Duration) (int, error) {
+	query := `
+	INSERT INTO login_throttle (key, failures, last_failure_at)
+	VALUES ($1, 1, now())
+	ON CONFLICT (key) DO UPDATE
+	SET failures = CASE
+	        WHEN login_throttle.last_failure_at < now() - make_interval(secs => $2) THEN 1
+	        ELSE login_throttle.failures + 1
+	    END,
+	    last_failure_at = now(),
+	    locked = CASE
+	        WHEN login_throttle.last_failure_at < now() - make_interval(secs => $2) THEN false
+	        ELSE login_throttle.locked
+	    END
+	RETURNING failures
+`
+	var failures int
+	err := r.db.QueryRowContext(ctx, query, key, window.Seconds()).Scan(&failures)
+	if err != nil {
+		r.log.Error("failed to increment login failures",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "IncrementLoginFailures"))
+
+		return 0, fmt.Errorf("repository/IncrementLoginFailures: %w", err)
+	}
+
+	return failures, nil
+}
+
+// BlockLogin запрещает попытки входа до blockedUntil. locked=true означает блокировку, а не back-off
+func (r *UserRepository) BlockLogin(ctx context.Context, key string, blockedUntil time.Time, locked bool) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE login_throttle SET blocked_until = $1, locked = $2 WHERE key = $3", blockedUntil, locked, key)
+	if err != nil {
+		r.log.Error("failed to block login",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "BlockLogin"))
+
+		return fmt.Errorf("repository/BlockLogin: %w", err)
+	}
+	return nil
+}
+
+// ResetLoginThrottle удаляет счётчик: после успешного входа или разблокировки администратором.
+// Возвращает true, если счётчик существовал и был заблокирован.
+func (r *UserRepository) ResetLoginThrottle(ctx context.Context, key string) (bool, error) {
+	var locked bool
+	err := r.db.QueryRowContext(ctx, "DELETE FROM login_throttle WHERE key = $1 RETURNING locked", key).Scan(&locked)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+
+		r.log.Error("failed to reset login throttle",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "ResetLoginThrottle"))
+
+		return false, fmt.Errorf("repository/ResetLoginThrottle: %w", err)
+	}
+	return locked, nil
+}
+
+// RecordLoginFailureIP запоминает, что с IP ipKey была неудачная попытка входа в аккаунт emailKey.
+// Записи старше window удаляются: счётчики IP к этому времени уже сброшены
+func (r *UserRepository) RecordLoginFailureIP(ctx context.Context, emailKey string, ipKey string, window time.Duration) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM login_throttle_ips WHERE last_failure_at < now() - make_interval(secs => $1)", window.Seconds())
+	if err != nil {
+		r.log.Warn("failed to delete old login failure IPs",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "RecordLoginFailureIP"))
+	}
+
+	query := `
+	INSERT INTO login_throttle_ips (email_key, ip_key)
+	VALUES ($1, $2)
+	ON CONFLICT (email_key, ip_key) DO UPDATE SET last_failure_at = now()
+`
+	_, err = r.db.ExecContext(ctx, query, emailKey, ipKey)
+	if err != nil {
+		r.log.Error("failed to record login failure IP",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "RecordLoginFailureIP"))
+
+		return fmt.Errorf("repository/RecordLoginFailureIP: %w", err)
+	}
+	return nil
+}
+
+// TakeLoginFailureIPs удаляет и возвращает ключи IP, с которых за последние window были неудачные
+// попытки входа в аккаунт emailKey
+func (r *UserRepository) TakeLoginFailureIPs(ctx context.Context, emailKey string, window time.Duration) ([]string, error) {
+	query := `
+	DELETE FROM login_throttle_ips
+	WHERE email_key = $1
+	RETURNING ip_key, last_failure_at >= now() - make_interval(secs => $2)
+`
+	rows, err := r.db.QueryContext(ctx, query, emailKey, window.Seconds())
+	if err != nil {
+		r.log.Error("failed to delete login failure IPs",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "TakeLoginFailureIPs"))
+
+		return nil, fmt.Errorf("repository/TakeLoginFailureIPs: %w", err)
+	}
+	defer rows.Close()
+
+	var ipKeys []string
+	for rows.Next() {
+		var ipKey string
+		var recent bool
+		err = rows.Scan(&ipKey, &recent)
+		if err != nil {
+			return nil, fmt.Errorf("repository/TakeLoginFailureIPs: %w", err)
+		}
+		if recent {
+			ipKeys = append(ipKeys, ipKey)
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("repository/TakeLoginFailureIPs: %w", err)
+	}
+	return ipKeys, nil
+}
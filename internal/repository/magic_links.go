@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+)
+
+// CreateMagicLink сохраняет jti выданной ссылки для входа
+func (r *UserRepository) CreateMagicLink(ctx context.Context, jti string, userID int, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, "INSERT INTO magic_links (jti, user_id, expires_at) VALUES ($1, $2, $3)", jti, userID, expiresAt)
+	if err != nil {
+		r.log.Error("failed to insert magic link",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "CreateMagicLink"))
+
+		return fmt.Errorf("repository/CreateMagicLink: %w", err)
+	}
+	return nil
+}
+
+// UseMagicLink гасит ссылку. false — ссылки нет, она истекла, уже использована или выдана другому пользователю
+func (r *UserRepository) UseMagicLink(ctx context.Context, jti string, userID int) (bool, error) {
+	query := `
+	UPDATE magic_links
+	SET used_at = now()
+	WHERE jti = $1 AND user_id = $2 AND used_at IS NULL AND expires_at > now()
+	RETURNING jti
+`
+	var used string
+	err := r.db.QueryRowContext(ctx, query, jti, userID).Scan(&used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		r.log.Error("failed to use magic link",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "UseMagicLink"))
+
+		return false, fmt.Errorf("repository/UseMagicLink: %w", err)
+	}
+	return true, nil
+}
+
+// RecordMagicLinkRequest учитывает запрос ссылки на email, если за последние window их было меньше limit.
+// false — лимит исчерпан, запрос не учитывается; retryAfter — когда в окне освободится место.
+// Запросы на один e-mail учитываются по очереди (advisory-блокировка), чтобы параллельные не обошли лимит
+func (r *UserRepository) RecordMagicLinkRequest(ctx context.Context, email string, limit int, window time.Duration) (bool, time.Duration, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("repository/RecordMagicLinkRequest: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext('magic_link:' || $1))", email)
+	if err != nil {
+		r.log.Error("failed to lock magic link requests",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "RecordMagicLinkRequest"))
+
+		return false, 0, fmt.Errorf("repository/RecordMagicLinkRequest: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM magic_link_requests WHERE requested_at <= now() - make_interval(secs => $1)", window.Seconds())
+	if err != nil {
+		r.log.Error("failed to delete old magic link requests",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "RecordMagicLinkRequest"))
+
+		return false, 0, fmt.Errorf("repository/RecordMagicLinkRequest: %w", err)
+	}
+
+	query := `
+	SELECT count(*), EXTRACT(EPOCH FROM min(requested_at) + make_interval(secs => $2) - now())
+	FROM magic_link_requests
+	WHERE email = $1
+`
+	var count int
+	var retryAfter sql.NullFloat64
+	err = tx.QueryRowContext(ctx, query, email, window.Seconds()).Scan(&count, &retryAfter)
+	if err != nil {
+		r.log.Error("failed to count magic link requests",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "RecordMagicLinkRequest"))
+
+		return false, 0, fmt.Errorf("repository/RecordMagicLinkRequest: %w", err)
+	}
+
+	if count >= limit {
+		return false, time.Duration(retryAfter.Float64 * float64(time.Second)), nil
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO magic_link_requests (email) VALUES ($1)", email)
+	if err != nil {
+		r.log.Error("failed to record magic link request",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "RecordMagicLinkRequest"))
+
+		return false, 0, fmt.Errorf("repository/RecordMagicLinkRequest: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, 0, fmt.Errorf("repository/RecordMagicLinkRequest: %w", err)
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/internal/model"
+)
+
+// ErrTOTPAlreadyEnabled — у пользователя уже подключён и подтверждён TOTP
+var ErrTOTPAlreadyEnabled = errors.New("totp already enabled")
+
+// SaveTOTPSecret сохраняет новый (ещё не подтверждённый) TOTP-секрет пользователя.
+// Если у пользователя уже включён TOTP, секрет не перезаписывается.
+func (r *UserRepository) SaveTOTPSecret(ctx context.Context, userID int, secret string) error {
+	query := `
+	INSERT INTO user_totp (user_id, secret)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE
+	SET secret = EXCLUDED.secret, created_at = now()
+	WHERE user_totp.enabled = false
+`
+	result, err := r.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		r.log.Error("failed to save totp secret",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "SaveTOTPSecret"))
+
+		return fmt.Errorf("repository/SaveTOTPSecret: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("repository/SaveTOTPSecret: %w", ErrTOTPAlreadyEnabled)
+	}
+	return nil
+}
+
+// GetTOTP получает TOTP-аутентификатор пользователя.
+// Если аутентификатор не подключался, возвращает ошибку, оборачивающую sql.ErrNoRows.
+func (r *UserRepository) GetTOTP(ctx context.Context, userID int) (model.TOTP, error) {
+	query := `
+	SELECT user_id, secret, enabled
+	FROM user_totp
+	WHERE user_id = $1
+`
+	var totp model.TOTP
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&totp.UserID, &totp.Secret, &totp.Enabled)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to scan totp",
+				zap.Error(err),
+				zap.Int("user.id", userID),
+				zap.String("component", "repository"),
+				zap.String("event", "GetTOTP"))
+		}
+
+		return model.TOTP{}, fmt.Errorf("repository/GetTOTP: %w", err)
+	}
+
+	return totp, nil
+}
+
+// EnableTOTP включает TOTP после подтверждения кодом и заменяет коды восстановления.
+// Принимает уже захешированные коды восстановления.
+func (r *UserRepository) EnableTOTP(ctx context.Context, userID int, recoveryCodeHashes []string) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("repository/EnableTOTP: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	result, err := tx.ExecContext(ctx, "UPDATE user_totp SET enabled = true, confirmed_at = now() WHERE user_id = $1 AND enabled = false", userID)
+	if err != nil {
+		r.log.Error("failed to enable totp",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "EnableTOTP"))
+
+		return fmt.Errorf("repository/EnableTOTP: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		err = ErrTOTPAlreadyEnabled
+		return fmt.Errorf("repository/EnableTOTP: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM mfa_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("repository/EnableTOTP: %w", err)
+	}
+
+	for _, hash := range recoveryCodeHashes {
+		_, err = tx.ExecContext(ctx, "INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, hash)
+		if err != nil {
+			r.log.Error("failed to insert recovery code",
+				zap.Error(err),
+				zap.Int("user.id", userID),
+				zap.String("component", "repository"),
+				zap.String("event", "EnableTOTP"))
+
+			return fmt.Errorf("repository/EnableTOTP: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("repository/EnableTOTP: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode помечает код восстановления использованным.
+// Возвращает false, если код не найден или уже был использован.
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	query := `
+	UPDATE mfa_recovery_codes
+	SET used_at = now()
+	WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+`
+	result, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		r.log.Error("failed to use recovery code",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "UseRecoveryCode"))
+
+		return false, fmt.Errorf("repository/UseRecoveryCode: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+// UseTOTPStep отмечает шаг TOTP step как использованный. false — принят уже этот или более поздний шаг:
+// код повторяется, и его нельзя принимать второй раз
+func (r *UserRepository) UseTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, "UPDATE user_totp SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2", userID, step)
+	if err != nil {
+		r.log.Error("failed to use totp step",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "UseTOTPStep"))
+
+		return false, fmt.Errorf("repository/UseTOTPStep: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/internal/model"
+	"strings"
+	"time"
+)
+
+// ErrPasskeyExists — ключ с таким credential ID уже зарегистрирован
+var ErrPasskeyExists = errors.New("passkey already registered")
+
+// passkeyColumns - общий список колонок для выборки ключей доступа
+const passkeyColumns = `id, user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports,
+	backup_eligible, backup_state, name, created_at, last_used_at`
+
+// scanPasskey - сканирует строку с колонками passkeyColumns в model.Passkey
+func scanPasskey(row interface{ Scan(dest ...any) error }) (model.Passkey, error) {
+	var passkey model.Passkey
+	var signCount int64
+	var transports string
+	var lastUsedAt sql.NullTime
+
+	err := row.Scan(&passkey.ID, &passkey.UserID, &passkey.CredentialID, &passkey.PublicKey, &passkey.AttestationType,
+		&passkey.AAGUID, &signCount, &transports, &passkey.BackupEligible, &passkey.BackupState, &passkey.Name,
+		&passkey.CreatedAt, &lastUsedAt)
+	if err != nil {
+		return model.Passkey{}, err
+	}
+
+	passkey.SignCount = uint32(signCount)
+	passkey.Transports = []string{}
+	if transports != "" {
+		passkey.Transports = strings.Split(transports, ",")
+	}
+	if lastUsedAt.Valid {
+		passkey.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return passkey, nil
+}
+
+// CreatePasskey сохраняет новый ключ доступа. Если credential ID уже занят — ErrPasskeyExists
+func (r *UserRepository) CreatePasskey(ctx context.Context, passkey model.Passkey) (model.Passkey, error) {
+	query := `
+	INSERT INTO passkeys (user_id, credential_id, public_key, attestation_type, aaguid, sign_count, transports,
+		backup_eligible, backup_state, name)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	ON CONFLICT (credential_id) DO NOTHING
+	RETURNING ` + passkeyColumns
+
+	created, err := scanPasskey(r.db.QueryRowContext(ctx, query,
+		passkey.UserID,
+		passkey.CredentialID,
+		passkey.PublicKey,
+		passkey.AttestationType,
+		passkey.AAGUID,
+		int64(passkey.SignCount),
+		strings.Join(passkey.Transports, ","),
+		passkey.BackupEligible,
+		passkey.BackupState,
+		passkey.Name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Passkey{}, fmt.Errorf("repository/CreatePasskey: %w", ErrPasskeyExists)
+	}
+	if err != nil {
+		r.log.Error("failed to insert passkey",
+			zap.Error(err),
+			zap.Int("user.id", passkey.UserID),
+			zap.String("component", "repository"),
+			zap.String("event", "CreatePasskey"))
+
+		return model.Passkey{}, fmt.Errorf("repository/CreatePasskey: %w", err)
+	}
+
+	return created, nil
+}
+
+// ListPasskeys возвращает ключи доступа пользователя, новые первыми
+func (r *UserRepository) ListPasskeys(ctx context.Context, userID int) ([]model.Passkey, error) {
+	query := `SELECT ` + passkeyColumns + ` FROM passkeys WHERE user_id = $1 ORDER BY created_at DESC, id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		r.log.Error("failed to execute SELECT passkeys",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "ListPasskeys"))
+
+		return nil, fmt.Errorf("repository/ListPasskeys: %w", err)
+	}
+	defer rows.Close()
+
+	passkeys := []model.Passkey{}
+	for rows.Next() {
+		passkey, err := scanPasskey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository/ListPasskeys: %w", err)
+		}
+		passkeys = append(passkeys, passkey)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("repository/ListPasskeys: %w", err)
+	}
+
+	return passkeys, nil
+}
+
+// UpdatePasskeyUsage сохраняет новый счётчик подписей и флаг резервной копии после успешного входа
+func (r *UserRepository) UpdatePasskeyUsage(ctx context.Context, id int, signCount uint32, backupState bool) error {
+	query := `UPDATE passkeys SET sign_count = $2, backup_state = $3, last_used_at = now() WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query, id, int64(signCount), backupState)
+	if err != nil {
+		r.log.Error("failed to update passkey usage",
+			zap.Error(err),
+			zap.Int("passkey.id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "UpdatePasskeyUsage"))
+
+		return fmt.Errorf("repository/UpdatePasskeyUsage: %w", err)
+	}
+	return nil
+}
+
+// RenamePasskey меняет название ключа пользователя. Если ключа нет — ошибка с sql.ErrNoRows
+func (r *UserRepository) RenamePasskey(ctx context.Context, userID int, id int, name string) (model.Passkey, error) {
+	query := `UPDATE passkeys SET name = $3 WHERE id = $1 AND user_id = $2 RETURNING ` + passkeyColumns
+
+	passkey, err := scanPasskey(r.db.QueryRowContext(ctx, query, id, userID, name))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to rename passkey",
+				zap.Error(err),
+				zap.Int("passkey.id", id),
+				zap.String("component", "repository"),
+				zap.String("event", "RenamePasskey"))
+		}
+
+		return model.Passkey{}, fmt.Errorf("repository/RenamePasskey: %w", err)
+	}
+
+	return passkey, nil
+}
+
+// DeletePasskey удаляет ключ пользователя. Если ключа нет — ошибка с sql.ErrNoRows
+func (r *UserRepository) DeletePasskey(ctx context.Context, userID int, id int) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM passkeys WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		r.log.Error("failed to delete passkey",
+			zap.Error(err),
+			zap.Int("passkey.id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "DeletePasskey"))
+
+		return fmt.Errorf("repository/DeletePasskey: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("repository/DeletePasskey: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// SavePasskeyCeremony сохраняет данные начатой церемонии WebAuthn и заодно удаляет истёкшие.
+// userID = 0 — церемония входа, пользователь ещё неизвестен
+func (r *UserRepository) SavePasskeyCeremony(ctx context.Context, id string, kind string, userID int, data []byte, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM passkey_ceremonies WHERE expires_at <= now()")
+	if err != nil {
+		r.log.Warn("failed to delete expired passkey ceremonies",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "SavePasskeyCeremony"))
+	}
+
+	var owner sql.NullInt64
+	if userID != 0 {
+		owner = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+
+	query := `INSERT INTO passkey_ceremonies (id, kind, user_id, data, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	_, err = r.db.ExecContext(ctx, query, id, kind, owner, string(data), expiresAt)
+	if err != nil {
+		r.log.Error("failed to insert passkey ceremony",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "SavePasskeyCeremony"))
+
+		return fmt.Errorf("repository/SavePasskeyCeremony: %w", err)
+	}
+	return nil
+}
+
+// TakePasskeyCeremony забирает (и удаляет) данные церемонии.
+// false — церемонии нет, она истекла, уже завершена или начата другим пользователем
+func (r *UserRepository) TakePasskeyCeremony(ctx context.Context, id string, kind string, userID int) ([]byte, bool, error) {
+	query := `
+	DELETE FROM passkey_ceremonies
+	WHERE id = $1 AND kind = $2 AND COALESCE(user_id, 0) = $3 AND expires_at > now()
+	RETURNING data
+`
+	var data string
+	err := r.db.QueryRowContext(ctx, query, id, kind, userID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		r.log.Error("failed to take passkey ceremony",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "TakePasskeyCeremony"))
+
+		return nil, false, fmt.Errorf("repository/TakePasskeyCeremony: %w", err)
+	}
+	return []byte(data), true, nil
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/config"
+	"pet/internal/model"
+	"sync"
+	"time"
+)
+
+// RateLimitStore - лимиты запросов в Postgres (GCRA, как middleware.MemoryLimiterStore):
+// квоты общие для всех экземпляров сервера. Реализует middleware.LimiterStore
+type RateLimitStore struct {
+	db  *sql.DB
+	log *zap.Logger
+
+	cleanupInterval time.Duration
+	stop            chan struct{}
+	done            chan struct{}
+	startOnce       sync.Once
+	stopOnce        sync.Once
+	started         chan struct{}
+}
+
+// NewRateLimitStore создаёт хранилище лимитов в Postgres. Очистка запускается через Start
+func NewRateLimitStore(db *sql.DB, logger *zap.Logger) *RateLimitStore {
+	return &RateLimitStore{
+		db:              db,
+		log:             logger,
+		cleanupInterval: time.Minute,
+		stop:            make(chan struct{}),
+		done:            make(chan struct{}),
+		started:         make(chan struct{}),
+	}
+}
+
+// rateLimitQuery - один атомарный шаг GCRA. $2 — интервал между запросами, $3 — интервал × burst (в секундах).
+// Новая строка или tat, сдвинутый не дальше допустимого, — запрос пропущен (upsert вернул строку).
+// Иначе строка не меняется (но блокируется до конца оператора), и возвращается текущий tat
+const rateLimitQuery = `
+WITH upsert AS (
+	INSERT INTO rate_limits AS rl (key, tat)
+	VALUES ($1, now() + make_interval(secs => $2))
+	ON CONFLICT (key) DO UPDATE
+	SET tat = GREATEST(rl.tat, now()) + make_interval(secs => $2)
+	WHERE GREATEST(rl.tat, now()) + make_interval(secs => $2) - make_interval(secs => $3) <= now()
+	RETURNING tat
+)
+SELECT true, tat, now() FROM upsert
+UNION ALL
+SELECT false, tat, now() FROM rate_limits WHERE key = $1 AND NOT EXISTS (SELECT 1 FROM upsert)
+`
+
+// Allow учитывает запрос клиента key
+func (s *RateLimitStore) Allow(ctx context.Context, key string, limit config.RateLimit) (model.RateLimitResult, error) {
+	interval := time.Duration(float64(time.Second) / limit.Rate)
+	tolerance := interval * time.Duration(limit.Burst)
+
+	var allowed bool
+	var tat, now time.Time
+	err := s.db.QueryRowContext(ctx, rateLimitQuery, key, interval.Seconds(), tolerance.Seconds()).Scan(&allowed, &tat, &now)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// строку вставил параллельный запрос, которого ещё нет в снимке этого оператора:
+			// лимит только что израсходован, повторить можно через один интервал
+			return model.RateLimitResult{Limit: limit.Burst, RetryAfter: interval}, nil
+		}
+
+		s.log.Error("failed to apply rate limit",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "RateLimitAllow"))
+
+		return model.RateLimitResult{}, fmt.Errorf("repository/RateLimitStore.Allow: %w", err)
+	}
+
+	if !allowed {
+		// следующий запрос будет пропущен, когда tat + interval - tolerance наступит
+		return model.RateLimitResult{
+			Limit:      limit.Burst,
+			RetryAfter: tat.Add(interval - tolerance).Sub(now),
+		}, nil
+	}
+
+	return model.RateLimitResult{
+		Allowed:   true,
+		Limit:     limit.Burst,
+		Remaining: int(now.Sub(tat.Add(-tolerance)) / interval),
+	}, nil
+}
+
+// Start запускает фоновое удаление клиентов, которые уже восстановили весь лимит
+func (s *RateLimitStore) Start() {
+	s.startOnce.Do(func() {
+		close(s.started)
+
+		go func() {
+			defer close(s.done)
+
+			ticker := time.NewTicker(s.cleanupInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-s.stop:
+					return
+				case <-ticker.C:
+					s.deleteExpired()
+				}
+			}
+		}()
+	})
+}
+
+// Stop останавливает очистку и дожидается её завершения. Повторный вызов ничего не делает
+func (s *RateLimitStore) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+
+		select {
+		case <-s.started:
+			<-s.done
+		default:
+		}
+	})
+}
+
+// deleteExpired удаляет строки с tat в прошлом: они не отличаются от отсутствующих
+func (s *RateLimitStore) deleteExpired() {
+	_, err := s.db.Exec("DELETE FROM rate_limits WHERE tat < now()")
+	if err != nil {
+		s.log.Error("failed to delete expired rate limits",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "RateLimitCleanup"))
+	}
+}
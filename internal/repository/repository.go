@@ -2,14 +2,28 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/zap"
 	"pet/internal/model"
 	"strings"
 )
 
+// ErrInsufficientFunds — на счёте отправителя недостаточно средств для перевода
+var ErrInsufficientFunds = errors.New("user has no enough founds")
+
+// ErrRequiredFields — не заполнены обязательные поля пользователя
+var ErrRequiredFields = errors.New("required user fields are empty")
+
+// isEmailConflict - нарушен уникальный индекс e-mail: пользователь с таким e-mail уже есть
+func isEmailConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "users_email_key"
+}
+
 // UserRepository — это уровень доступа к данным (Data Access Layer).
 // Он знает, как общаться с базой, выполнять CRUD операции, но не знает бизнес-правил
 type UserRepository struct {
@@ -25,12 +39,12 @@ func NewUserRepository(db *sql.DB, logger *zap.Logger) *UserRepository {
 }
 
 // GetAllUsers - получает весь список пользователей
-func (r *UserRepository) GetAllUsers() ([]model.User, error) {
+func (r *UserRepository) GetAllUsers(ctx context.Context) ([]model.User, error) {
 	query := `
-SELECT id, name, age, email 
+SELECT id, public_id, handle, name, age, email, role, status
 FROM users
 `
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		r.log.Error("failed to execute SELECT users",
 			zap.Error(err),
@@ -53,7 +67,7 @@ FROM users
 	for rows.Next() {
 		var user model.User
 
-		err = rows.Scan(&user.ID, &user.Name, &user.Age, &user.Email)
+		err = rows.Scan(&user.ID, &user.PublicID, &user.Handle, &user.Name, &user.Age, &user.Email, &user.Role, &user.Status)
 		if err != nil {
 			r.log.Error("failed to scan user row",
 				zap.Error(err),
@@ -79,16 +93,16 @@ FROM users
 }
 
 // GetUserByID получает пользователя по его ID
-func (r *UserRepository) GetUserByID(id int) (model.User, error) {
+func (r *UserRepository) GetUserByID(ctx context.Context, id int) (model.User, error) {
 	query := `
-	SELECT id, name, age, email, password
+	SELECT id, public_id, handle, name, age, email, role, status, password
 	FROM users
 	WHERE id = $1
 `
-	row := r.db.QueryRow(query, id)
+	row := r.db.QueryRowContext(ctx, query, id)
 
 	var user model.User
-	err := row.Scan(&user.ID, &user.Name, &user.Age, &user.Email, &user.HashedPassword)
+	err := row.Scan(&user.ID, &user.PublicID, &user.Handle, &user.Name, &user.Age, &user.Email, &user.Role, &user.Status, &user.HashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) { // если польз. не найден в БД
 			r.log.Info("user not found",
@@ -110,25 +124,44 @@ func (r *UserRepository) GetUserByID(id int) (model.User, error) {
 	return user, nil
 }
 
+// ResolvePublicID возвращает внутренний ID пользователя по публичному.
+// Если пользователя нет — ошибка с sql.ErrNoRows. Формат publicID проверяет вызывающий
+func (r *UserRepository) ResolvePublicID(ctx context.Context, publicID string) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, "SELECT id FROM users WHERE public_id = $1", publicID).Scan(&id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to resolve public ID",
+				zap.Error(err),
+				zap.String("user.public_id", publicID),
+				zap.String("component", "repository"),
+				zap.String("event", "ResolvePublicID"))
+		}
+
+		return 0, fmt.Errorf("repository/ResolvePublicID: %w", err)
+	}
+	return id, nil
+}
+
 // GetUserByEmail получает пользователя по e-mail
-func (r *UserRepository) GetUserByEmail(email string) (model.User, error) {
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
 	query := `
-	SELECT id, name, age, email, password
+	SELECT id, public_id, handle, name, age, email, role, status, password
 	FROM users
 	WHERE email = $1
 `
-	row := r.db.QueryRow(query, email)
+	row := r.db.QueryRowContext(ctx, query, email)
 
 	var loginUser model.User
-	err := row.Scan(&loginUser.ID, &loginUser.Name, &loginUser.Age, &loginUser.Email, &loginUser.HashedPassword)
+	err := row.Scan(&loginUser.ID, &loginUser.PublicID, &loginUser.Handle, &loginUser.Name, &loginUser.Age, &loginUser.Email, &loginUser.Role, &loginUser.Status, &loginUser.HashedPassword)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) { // если польз. не найден в БД
 			r.log.Info("user not found",
-				zap.String("email", loginUser.Email),
+				zap.String("email", email),
 				zap.String("component", "repository"),
 				zap.String("event", "GetUserByEmail"))
 
-			return model.User{}, fmt.Errorf("user with email %s not found", loginUser.Email)
+			return model.User{}, fmt.Errorf("user with email %s not found: %w", email, err)
 		}
 
 		r.log.Error("failed to scan user email", // если ошибка по другой причине
@@ -141,15 +174,16 @@ func (r *UserRepository) GetUserByEmail(email string) (model.User, error) {
 	return loginUser, nil
 }
 
-// PostUser добавляет пользователя в БД
-func (r *UserRepository) PostUser(createUser model.User) (model.User, error) {
+// PostUser добавляет пользователя в БД. Без Handle имя выдаётся по умолчанию (user_xxx),
+// занятое имя — ErrHandleTaken
+func (r *UserRepository) PostUser(ctx context.Context, createUser model.User) (model.User, error) {
 
 	if createUser.Name == "" || createUser.Email == "" || createUser.Age <= 0 || createUser.HashedPassword == "" {
 		r.log.Info("not all fields filled",
 			zap.String("component", "repository"),
 			zap.String("event", "PostUser"))
 
-		return model.User{}, fmt.Errorf("not all fields fill")
+		return model.User{}, fmt.Errorf("repository/PostUser: %w", ErrRequiredFields)
 	}
 
 	query := `
@@ -157,15 +191,27 @@ func (r *UserRepository) PostUser(createUser model.User) (model.User, error) {
 	VALUES ($1, $2, $3, $4)
 	RETURNING id
 `
-	row := r.db.QueryRow(query,
-		createUser.Name,
-		createUser.Age,
-		createUser.Email,
-		createUser.HashedPassword)
+	args := []any{createUser.Name, createUser.Age, createUser.Email, createUser.HashedPassword}
+	if createUser.Handle != "" {
+		query = `
+	INSERT INTO users (name, age, email, password, handle)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id
+`
+		args = append(args, createUser.Handle)
+	}
+	row := r.db.QueryRowContext(ctx, query, args...)
 
 	var id int
 	err := row.Scan(&id)
 	if err != nil {
+		if isHandleConflict(err) {
+			return model.User{}, fmt.Errorf("repository/PostUser: %w", ErrHandleTaken)
+		}
+		if isEmailConflict(err) {
+			return model.User{}, fmt.Errorf("repository/PostUser: %w", ErrEmailTaken)
+		}
+
 		r.log.Error("failed to insert user",
 			zap.Error(err),
 			zap.String("component", "repository"),
@@ -174,15 +220,15 @@ func (r *UserRepository) PostUser(createUser model.User) (model.User, error) {
 		return model.User{}, fmt.Errorf("repository/PostUser: %w", err)
 	}
 
-	backUser, _ := r.GetUserByID(id)
+	backUser, _ := r.GetUserByID(ctx, id)
 	backUser.HashedPassword = ""
 
 	return backUser, nil
 }
 
 // PutUser полностью обновляет пользователя в БД
-func (r *UserRepository) PutUser(updateUser model.User) (model.User, error) {
-	_, err := r.GetUserByID(updateUser.ID)
+func (r *UserRepository) PutUser(ctx context.Context, updateUser model.User) (model.User, error) {
+	_, err := r.GetUserByID(ctx, updateUser.ID)
 	if err != nil {
 		r.log.Error("user not found by ID", // если ошибка по другой причине
 			zap.Error(err),
@@ -196,12 +242,12 @@ func (r *UserRepository) PutUser(updateUser model.User) (model.User, error) {
 	UPDATE users
 	SET name = $1, age = $2, email = $3
 	WHERE id = $4
-	RETURNING id, name, age, email
+	RETURNING id, public_id, handle, name, age, email, role, status
 `
 	var user model.User
 
-	err = r.db.QueryRow(query, updateUser.Name, updateUser.Age, updateUser.Email, updateUser.ID).
-		Scan(&user.ID, &user.Name, &user.Age, &user.Email)
+	err = r.db.QueryRowContext(ctx, query, updateUser.Name, updateUser.Age, updateUser.Email, updateUser.ID).
+		Scan(&user.ID, &user.PublicID, &user.Handle, &user.Name, &user.Age, &user.Email, &user.Role, &user.Status)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			r.log.Info("user not found",
@@ -210,6 +256,9 @@ func (r *UserRepository) PutUser(updateUser model.User) (model.User, error) {
 
 			return model.User{}, fmt.Errorf("repository/PutUser: %w", err)
 		}
+		if isEmailConflict(err) {
+			return model.User{}, fmt.Errorf("repository/PutUser: %w", ErrEmailTaken)
+		}
 
 		r.log.Error("failed to update user",
 			zap.Error(err),
@@ -223,7 +272,7 @@ func (r *UserRepository) PutUser(updateUser model.User) (model.User, error) {
 }
 
 // PatchUser частично обновляет пользователя в БД
-func (r *UserRepository) PatchUser(updateUser model.PartialUser) (model.User, error) {
+func (r *UserRepository) PatchUser(ctx context.Context, updateUser model.PartialUser) (model.User, error) {
 	setParts := []string{} // фрагменты SQL типа name = $1
 	args := []any{}        // значения на место $1, $2
 	argIdx := 1            // индекс для SQL-плейсхолдеров ($1, $2, ...)
@@ -249,7 +298,7 @@ func (r *UserRepository) PatchUser(updateUser model.PartialUser) (model.User, er
 	// Если PATCH не содержит новых полей, логичнее не падать с ошибкой,
 	// а просто вернуть текущую версию пользователя (ничего ведь не изменилось).
 	if len(setParts) == 0 {
-		return r.GetUserByID(updateUser.ID)
+		return r.GetUserByID(ctx, updateUser.ID)
 	}
 
 	//// вернул проверку
@@ -265,8 +314,12 @@ func (r *UserRepository) PatchUser(updateUser model.PartialUser) (model.User, er
 
 	args = append(args, updateUser.ID)
 
-	_, err := r.db.Exec(query, args...)
+	_, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
+		if isEmailConflict(err) {
+			return model.User{}, fmt.Errorf("repository/PatchUser: %w", ErrEmailTaken)
+		}
+
 		r.log.Error("failed to update user",
 			zap.Error(err),
 			zap.String("component", "repository"),
@@ -275,7 +328,7 @@ func (r *UserRepository) PatchUser(updateUser model.PartialUser) (model.User, er
 		return model.User{}, fmt.Errorf("repository/PatchUser: %w", err)
 	}
 
-	updatedUser, err := r.GetUserByID(updateUser.ID)
+	updatedUser, err := r.GetUserByID(ctx, updateUser.ID)
 	if err != nil {
 		r.log.Error("user not found by ID",
 			zap.Error(err),
@@ -288,12 +341,12 @@ func (r *UserRepository) PatchUser(updateUser model.PartialUser) (model.User, er
 }
 
 // DeleteUser удаляет пользователя в БД
-func (r *UserRepository) DeleteUser(id int) error {
+func (r *UserRepository) DeleteUser(ctx context.Context, id int) error {
 	query := `
 	DELETE FROM users
 	WHERE id = $1
 `
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		r.log.Error("user not found",
 			zap.Error(err),
@@ -316,17 +369,17 @@ func (r *UserRepository) DeleteUser(id int) error {
 	return nil
 }
 
-func (r *UserRepository) BeginTx() (*sql.Tx, error) {
-	return r.db.Begin()
+func (r *UserRepository) BeginTx(ctx context.Context) (*sql.Tx, error) {
+	return r.db.BeginTx(ctx, nil)
 }
 
 // WithdrawBalance списывает средства со счета
-func (r *UserRepository) WithdrawBalance(tx *sql.Tx, senderID int, amount float64) error {
+func (r *UserRepository) WithdrawBalance(ctx context.Context, tx *sql.Tx, senderID int, amount float64) error {
 	// tx типа *sql.Tx — специальный объект, через который нужно делать SQL-запросы внутри транзакции
 
 	var currentBalance float64
 
-	row := tx.QueryRow("SELECT balance FROM users WHERE id = $1", senderID)
+	row := tx.QueryRowContext(ctx, "SELECT balance FROM users WHERE id = $1", senderID)
 
 	err := row.Scan(&currentBalance)
 	if err != nil {
@@ -345,10 +398,10 @@ func (r *UserRepository) WithdrawBalance(tx *sql.Tx, senderID int, amount float6
 			zap.String("component", "repository"),
 			zap.String("event", "WithdrawBalance"))
 
-		return fmt.Errorf("repository/WithdrawBalance: user has no enough founds: %w", err)
+		return fmt.Errorf("repository/WithdrawBalance: %w", ErrInsufficientFunds)
 	}
 
-	_, err = tx.Exec("UPDATE users SET balance = balance - $1 WHERE id = $2", amount, senderID)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET balance = balance - $1 WHERE id = $2", amount, senderID)
 	if err != nil {
 		r.log.Error("withdraw error",
 			zap.Error(err),
@@ -363,11 +416,11 @@ func (r *UserRepository) WithdrawBalance(tx *sql.Tx, senderID int, amount float6
 }
 
 // DepositBalance зачисляет средства на счет
-func (r *UserRepository) DepositBalance(tx *sql.Tx, receiverID int, amount float64) error {
+func (r *UserRepository) DepositBalance(ctx context.Context, tx *sql.Tx, receiverID int, amount float64) error {
 
 	var currentBalance float64
 
-	row := tx.QueryRow("SELECT balance FROM users WHERE id = $1", receiverID)
+	row := tx.QueryRowContext(ctx, "SELECT balance FROM users WHERE id = $1", receiverID)
 
 	err := row.Scan(&currentBalance)
 	if err != nil {
@@ -380,7 +433,7 @@ func (r *UserRepository) DepositBalance(tx *sql.Tx, receiverID int, amount float
 		return fmt.Errorf("repository/DepositBalance: %w", err)
 	}
 
-	_, err = tx.Exec("UPDATE users SET balance = balance + $1 WHERE id = $2", amount, receiverID)
+	_, err = tx.ExecContext(ctx, "UPDATE users SET balance = balance + $1 WHERE id = $2", amount, receiverID)
 	if err != nil {
 		r.log.Error("deposit error",
 			zap.Error(err),
@@ -393,3 +446,23 @@ func (r *UserRepository) DepositBalance(tx *sql.Tx, receiverID int, amount float
 
 	return nil
 }
+
+// UpdatePassword заменяет хеш пароля пользователя
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, hashedPassword string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET password = $1 WHERE id = $2", hashedPassword, id)
+	if err != nil {
+		r.log.Error("failed to update password",
+			zap.Error(err),
+			zap.Int("id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "UpdatePassword"))
+
+		return fmt.Errorf("repository/UpdatePassword: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("repository/UpdatePassword: %w", sql.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/internal/model"
+)
+
+// SetUserRole назначает пользователю роль и возвращает обновлённого пользователя.
+// Если пользователь не найден, возвращает ошибку, оборачивающую sql.ErrNoRows.
+func (r *UserRepository) SetUserRole(ctx context.Context, id int, role string) (model.User, error) {
+	query := `
+	UPDATE users
+	SET role = $1
+	WHERE id = $2
+	RETURNING id, public_id, handle, name, age, email, role, status
+`
+	var user model.User
+	err := r.db.QueryRowContext(ctx, query, role, id).Scan(&user.ID, &user.PublicID, &user.Handle, &user.Name, &user.Age, &user.Email, &user.Role, &user.Status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			r.log.Info("user not found",
+				zap.Int("id", id),
+				zap.String("component", "repository"),
+				zap.String("event", "SetUserRole"))
+
+			return model.User{}, fmt.Errorf("repository/SetUserRole: %w", err)
+		}
+
+		r.log.Error("failed to update user role",
+			zap.Error(err),
+			zap.Int("id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "SetUserRole"))
+
+		return model.User{}, fmt.Errorf("repository/SetUserRole: %w", err)
+	}
+
+	return user, nil
+}
+
+// SetUserRoleByEmail назначает роль пользователю с указанным e-mail (используется при старте для первого администратора)
+func (r *UserRepository) SetUserRoleByEmail(ctx context.Context, email string, role string) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET role = $1 WHERE email = $2", role, email)
+	if err != nil {
+		r.log.Error("failed to update user role",
+			zap.Error(err),
+			zap.String("component", "repository"),
+			zap.String("event", "SetUserRoleByEmail"))
+
+		return fmt.Errorf("repository/SetUserRoleByEmail: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("repository/SetUserRoleByEmail: %w", sql.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/internal/model"
+)
+
+// sessionColumns - общий список колонок для выборки сессий
+const sessionColumns = `id, user_id, refresh_jti, device, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at`
+
+// scanSession - сканирует строку с колонками sessionColumns в model.Session
+func scanSession(row interface{ Scan(dest ...any) error }) (model.Session, error) {
+	var session model.Session
+	var revokedAt sql.NullTime
+
+	err := row.Scan(&session.ID, &session.UserID, &session.RefreshJTI, &session.Device, &session.UserAgent,
+		&session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &revokedAt)
+	if err != nil {
+		return model.Session{}, err
+	}
+
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+
+	return session, nil
+}
+
+// CreateSession сохраняет новую сессию и возвращает её из БД
+func (r *UserRepository) CreateSession(ctx context.Context, session model.Session) (model.Session, error) {
+	query := `
+	INSERT INTO sessions (user_id, refresh_jti, device, user_agent, ip, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING ` + sessionColumns
+
+	created, err := scanSession(r.db.QueryRowContext(ctx, query,
+		session.UserID,
+		session.RefreshJTI,
+		session.Device,
+		session.UserAgent,
+		session.IP,
+		session.ExpiresAt))
+	if err != nil {
+		r.log.Error("failed to insert session",
+			zap.Error(err),
+			zap.Int("user.id", session.UserID),
+			zap.String("component", "repository"),
+			zap.String("event", "CreateSession"))
+
+		return model.Session{}, fmt.Errorf("repository/CreateSession: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetSession возвращает сессию по ID, в том числе отозванную
+func (r *UserRepository) GetSession(ctx context.Context, id int) (model.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+
+	session, err := scanSession(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to scan session",
+				zap.Error(err),
+				zap.Int("session.id", id),
+				zap.String("component", "repository"),
+				zap.String("event", "GetSession"))
+		}
+
+		return model.Session{}, fmt.Errorf("repository/GetSession: %w", err)
+	}
+
+	return session, nil
+}
+
+// ListSessions возвращает действующие (не отозванные и не истёкшие) сессии пользователя
+func (r *UserRepository) ListSessions(ctx context.Context, userID int) ([]model.Session, error) {
+	query := `
+	SELECT ` + sessionColumns + `
+	FROM sessions
+	WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+	ORDER BY last_seen_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		r.log.Error("failed to execute SELECT sessions",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "ListSessions"))
+
+		return nil, fmt.Errorf("repository/ListSessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []model.Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("repository/ListSessions: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("repository/ListSessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// IsSessionActive проверяет, что сессия не отозвана и не истекла
+func (r *UserRepository) IsSessionActive(ctx context.Context, id int) (bool, error) {
+	var active bool
+	query := `SELECT EXISTS (SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NULL AND expires_at > now())`
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&active)
+	if err != nil {
+		r.log.Error("failed to check session",
+			zap.Error(err),
+			zap.Int("session.id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "IsSessionActive"))
+
+		return false, fmt.Errorf("repository/IsSessionActive: %w", err)
+	}
+
+	return active, nil
+}
+
+// RotateSessionRefresh заменяет ID refresh-токена сессии, только если предъявлен последний выданный токен.
+// false означает, что сессия отозвана, истекла или токен уже был использован.
+func (r *UserRepository) RotateSessionRefresh(ctx context.Context, id int, oldJTI string, newJTI string, ip string) (bool, error) {
+	query := `
+	UPDATE sessions
+	SET refresh_jti = $3, ip = $4, last_seen_at = now()
+	WHERE id = $1 AND refresh_jti = $2 AND revoked_at IS NULL AND expires_at > now()
+`
+	result, err := r.db.ExecContext(ctx, query, id, oldJTI, newJTI, ip)
+	if err != nil {
+		r.log.Error("failed to rotate session refresh token",
+			zap.Error(err),
+			zap.Int("session.id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "RotateSessionRefresh"))
+
+		return false, fmt.Errorf("repository/RotateSessionRefresh: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected == 1, nil
+}
+
+// RevokeSession отзывает сессию пользователя. Если сессии нет или она уже отозвана — ошибка с sql.ErrNoRows
+func (r *UserRepository) RevokeSession(ctx context.Context, userID int, id int) error {
+	result, err := r.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = now() WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL", id, userID)
+	if err != nil {
+		r.log.Error("failed to revoke session",
+			zap.Error(err),
+			zap.Int("session.id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "RevokeSession"))
+
+		return fmt.Errorf("repository/RevokeSession: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("repository/RevokeSession: %w", sql.ErrNoRows)
+	}
+	return nil
+}
+
+// RevokeOtherSessions отзывает все сессии пользователя, кроме exceptID (0 — отозвать все).
+// Возвращает число отозванных сессий
+func (r *UserRepository) RevokeOtherSessions(ctx context.Context, userID int, exceptID int) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL", userID, exceptID)
+	if err != nil {
+		r.log.Error("failed to revoke sessions",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("component", "repository"),
+			zap.String("event", "RevokeOtherSessions"))
+
+		return 0, fmt.Errorf("repository/RevokeOtherSessions: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+)
+
+// ErrStatusChanged — статус пользователя изменился между чтением и записью
+var ErrStatusChanged = errors.New("user status changed concurrently")
+
+// GetUserStatus возвращает внутренний ID и статус аккаунта по публичному ID из токена (для middleware Auth)
+func (r *UserRepository) GetUserStatus(ctx context.Context, publicID string) (int, string, error) {
+	var id int
+	var status string
+	err := r.db.QueryRowContext(ctx, "SELECT id, status FROM users WHERE public_id = $1", publicID).Scan(&id, &status)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to get user status",
+				zap.Error(err),
+				zap.String("user.public_id", publicID),
+				zap.String("component", "repository"),
+				zap.String("event", "GetUserStatus"))
+		}
+
+		return 0, "", fmt.Errorf("repository/GetUserStatus: %w", err)
+	}
+	return id, status, nil
+}
+
+// GetUserStatusTx читает статус внутри транзакции и блокирует строку до её конца (FOR UPDATE).
+// Блокировка сразу эксклюзивная: та же транзакция затем меняет баланс, и две FOR SHARE
+// на одной строке привели бы к взаимоблокировке при обновлении
+func (r *UserRepository) GetUserStatusTx(ctx context.Context, tx *sql.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, "SELECT status FROM users WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			r.log.Error("failed to get user status",
+				zap.Error(err),
+				zap.Int("user.id", id),
+				zap.String("component", "repository"),
+				zap.String("event", "GetUserStatusTx"))
+		}
+
+		return "", fmt.Errorf("repository/GetUserStatusTx: %w", err)
+	}
+	return status, nil
+}
+
+// SetUserStatus меняет статус, только если текущий статус равен from (иначе ErrStatusChanged).
+// Допустимость перехода проверяет сервис, здесь — только запись
+func (r *UserRepository) SetUserStatus(ctx context.Context, id int, from string, to string, reason string, actorID int) error {
+	query := `
+	UPDATE users
+	SET status = $3, status_reason = $4, status_changed_at = now(), status_changed_by = NULLIF($5, 0)
+	WHERE id = $1 AND status = $2
+`
+	result, err := r.db.ExecContext(ctx, query, id, from, to, reason, actorID)
+	if err != nil {
+		r.log.Error("failed to update user status",
+			zap.Error(err),
+			zap.Int("user.id", id),
+			zap.String("component", "repository"),
+			zap.String("event", "SetUserStatus"))
+
+		return fmt.Errorf("repository/SetUserStatus: %w", err)
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return fmt.Errorf("repository/SetUserStatus: %w", ErrStatusChanged)
+	}
+	return nil
+}
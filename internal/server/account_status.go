@@ -0,0 +1,126 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"pet/internal/service"
+)
+
+// SuspendUserHandler приостанавливает аккаунт пользователя.
+// @Summary Приостановить аккаунт
+// @Description Переводит аккаунт в статус suspended и отзывает все его сессии. Причина попадает в журнал аудита
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Публичный ID пользователя (UUID)"
+// @Param reason body model.ChangeStatusRequest true "Причина"
+// @Success 200 {object} model.User
+// @Failure 400 {string} string "Неверный ID, JSON или попытка приостановить себя"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Пользователь не найден"
+// @Failure 409 {string} string "Переход из текущего статуса запрещён"
+// @Router /admin/users/{id}/suspend [post]
+func SuspendUserHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return changeUserStatusHandler(repo, model.UserStatusSuspended)
+}
+
+// LockUserHandler блокирует аккаунт пользователя по соображениям безопасности.
+// @Summary Заблокировать аккаунт
+// @Description Переводит аккаунт в статус locked (например, при подозрении на взлом) и отзывает все его сессии.
+// @Description Снять блокировку можно через POST /admin/users/{id}/reactivate
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Публичный ID пользователя (UUID)"
+// @Param reason body model.ChangeStatusRequest true "Причина"
+// @Success 200 {object} model.User
+// @Failure 400 {string} string "Неверный ID, JSON или попытка заблокировать себя"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Пользователь не найден"
+// @Failure 409 {string} string "Переход из текущего статуса запрещён"
+// @Router /admin/users/{id}/lock [post]
+func LockUserHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return changeUserStatusHandler(repo, model.UserStatusLocked)
+}
+
+// ReactivateUserHandler возвращает аккаунт в статус active.
+// @Summary Восстановить аккаунт
+// @Description Переводит приостановленный, заблокированный или неподтверждённый аккаунт в статус active
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Публичный ID пользователя (UUID)"
+// @Param reason body model.ChangeStatusRequest true "Причина"
+// @Success 200 {object} model.User
+// @Failure 400 {string} string "Неверный ID или JSON"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Пользователь не найден"
+// @Failure 409 {string} string "Переход из текущего статуса запрещён"
+// @Router /admin/users/{id}/reactivate [post]
+func ReactivateUserHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return changeUserStatusHandler(repo, model.UserStatusActive)
+}
+
+// changeUserStatusHandler — общий обработчик смены статуса администратором
+func changeUserStatusHandler(repo *repository.UserRepository, to string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		adminID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		userID, err := parseIDFromRequest(r, repo)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid user ID", idErrorStatus(err))
+			return
+		}
+
+		if userID == adminID {
+			ErrorHandler(w, r, fmt.Errorf("admin %d tried to change own status", adminID), "cannot change own status", http.StatusBadRequest)
+			return
+		}
+
+		var req model.ChangeStatusRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err = validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		_, err = repo.GetUserByID(r.Context(), userID)
+		if err != nil {
+			ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
+			return
+		}
+
+		user, err := service.NewAccountStatusService(repo, log).ChangeStatus(r.Context(), userID, to, req.Reason, adminID)
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidStatusTransition) || errors.Is(err, repository.ErrStatusChanged) {
+				ErrorHandler(w, r, err, "status transition not allowed", http.StatusConflict)
+				return
+			}
+			ErrorHandler(w, r, err, "change status error", http.StatusInternalServerError)
+			return
+		}
+
+		// на этом экземпляре смена статуса действует сразу, на остальных — через config.UserStatusCacheTTL
+		middleware.InvalidateUserStatus(user.PublicID)
+
+		writeResponse(w, r, http.StatusOK, user)
+	}
+}
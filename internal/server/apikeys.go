@@ -0,0 +1,143 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"time"
+)
+
+// CreateAPIKeyHandler выпускает новый API-ключ.
+// @Summary Выпустить API-ключ
+// @Description Создаёт именованный ключ со скоупами и необязательным сроком действия. Полный ключ возвращается только в этом ответе, в БД хранится его хеш
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param key body model.CreateAPIKeyRequest true "Название, скоупы и срок действия"
+// @Success 201 {object} model.CreateAPIKeyResponse
+// @Failure 400 {string} string "Неверный JSON или ошибка валидации"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /admin/api-keys [post]
+func CreateAPIKeyHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		var req model.CreateAPIKeyRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+			ErrorHandler(w, r, fmt.Errorf("expires_at is in the past"), "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		rawKey, prefix, hash, err := middleware.GenerateAPIKey()
+		if err != nil {
+			ErrorHandler(w, r, err, "generate api key error", http.StatusInternalServerError)
+			return
+		}
+
+		newKey := model.APIKey{
+			Name:      req.Name,
+			Prefix:    prefix,
+			KeyHash:   hash,
+			Scopes:    req.Scopes,
+			ExpiresAt: req.ExpiresAt,
+		}
+
+		adminID, ok := middleware.GetUserIDFromContext(r)
+		if ok {
+			newKey.CreatedBy = &adminID
+		}
+
+		created, err := repo.CreateAPIKey(r.Context(), newKey)
+		if err != nil {
+			ErrorHandler(w, r, err, "create api key error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusCreated, model.CreateAPIKeyResponse{Key: rawKey, APIKey: created})
+
+		log.Info("api key created",
+			zap.String("event", "APIKeyCreated"),
+			zap.Int("api_key.id", created.ID),
+			zap.String("api_key.prefix", created.Prefix),
+			zap.Strings("api_key.scopes", created.Scopes),
+			zap.Int("admin.id", adminID),
+		)
+	}
+}
+
+// ListAPIKeysHandler возвращает список API-ключей.
+// @Summary Список API-ключей
+// @Description Возвращает все ключи (без самих секретов), включая отозванные и просроченные
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.APIKey
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /admin/api-keys [get]
+func ListAPIKeysHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		keys, err := repo.ListAPIKeys(r.Context())
+		if err != nil {
+			ErrorHandler(w, r, err, "list api keys error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, keys)
+	}
+}
+
+// RevokeAPIKeyHandler отзывает API-ключ.
+// @Summary Отозвать API-ключ
+// @Tags api-keys
+// @Security BearerAuth
+// @Param id path int true "ID ключа"
+// @Success 204 "Ключ отозван"
+// @Failure 400 {string} string "Неверный ID"
+// @Failure 404 {string} string "Ключ не найден или уже отозван"
+// @Router /admin/api-keys/{id} [delete]
+func RevokeAPIKeyHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		id, err := parseResourceIDFromRequest(r)
+		if err != nil {
+			ErrorHandler(w, r, err, "failed to get ID from URL", http.StatusBadRequest)
+			return
+		}
+
+		err = repo.RevokeAPIKey(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ErrorHandler(w, r, err, "api key not found", http.StatusNotFound)
+				return
+			}
+			ErrorHandler(w, r, err, "revoke api key error", http.StatusInternalServerError)
+			return
+		}
+
+		middleware.LoggerFromContext(r.Context()).Info("api key revoked",
+			zap.String("event", "APIKeyRevoked"),
+			zap.Int("api_key.id", id),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"pet/config"
+	"strconv"
+	"strings"
+)
+
+// readJSON разбирает тело запроса в dst. Если тело не подходит, сам отвечает клиенту и возвращает false:
+// 415 — Content-Type не application/json, 413 — тело больше config.MaxRequestBodySize,
+// 400 — неверный JSON, неизвестное поле (его имя в ответе) или несколько JSON-значений подряд
+func readJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	defer r.Body.Close()
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+		writeError(w, r, err, http.StatusUnsupportedMediaType, "unsupported media type", map[string]any{
+			"expected": "application/json",
+		})
+		return false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, config.MaxRequestBodySize))
+	decoder.DisallowUnknownFields()
+
+	err = decoder.Decode(dst)
+	if err != nil {
+		writeDecodeError(w, r, err)
+		return false
+	}
+
+	// после первого значения допускаются только пробелы
+	err = decoder.Decode(&json.RawMessage{})
+	if !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeDecodeError(w, r, err)
+			return false
+		}
+		writeError(w, r, err, http.StatusBadRequest, "request body must contain a single JSON value", nil)
+		return false
+	}
+
+	return true
+}
+
+// writeDecodeError отвечает клиенту на ошибку разбора JSON
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		writeError(w, r, err, http.StatusRequestEntityTooLarge, "request body too large", map[string]any{
+			"limit": maxBytesErr.Limit,
+		})
+	case errors.Is(err, io.EOF):
+		writeError(w, r, err, http.StatusBadRequest, "request body is empty", nil)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		writeError(w, r, err, http.StatusBadRequest, "malformed JSON", nil)
+	case errors.As(err, &syntaxErr):
+		writeError(w, r, err, http.StatusBadRequest, "malformed JSON", map[string]any{"offset": syntaxErr.Offset})
+	case errors.As(err, &typeErr):
+		writeError(w, r, err, http.StatusBadRequest, "invalid field type", map[string]any{
+			"field":    typeErr.Field,
+			"expected": typeErr.Type.String(),
+		})
+	default:
+		// у encoding/json нет отдельного типа для неизвестного поля, только текст ошибки
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			if unquoted, unquoteErr := strconv.Unquote(field); unquoteErr == nil {
+				field = unquoted
+			}
+			writeError(w, r, err, http.StatusBadRequest, "unknown field", map[string]any{"field": field})
+			return
+		}
+		writeError(w, r, err, http.StatusBadRequest, "failed to decode JSON", nil)
+	}
+}
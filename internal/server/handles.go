@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"pet/internal/service"
+	"strings"
+)
+
+// writeHandleError отвечает 422 с причиной для неверного формата имени и 409 для занятого.
+// Возвращает false, если это другая ошибка и ответ ещё не отправлен
+func writeHandleError(w http.ResponseWriter, r *http.Request, err error, handle string) bool {
+	var handleErr *service.HandleError
+	switch {
+	case errors.As(err, &handleErr):
+		writeError(w, r, err, http.StatusUnprocessableEntity, "invalid handle", map[string]any{
+			"handle": handle,
+			"reason": handleErr.Reason,
+		})
+		return true
+	case errors.Is(err, repository.ErrHandleTaken):
+		writeError(w, r, err, http.StatusConflict, "handle already taken", map[string]any{"handle": handle})
+		return true
+	}
+	return false
+}
+
+// checkNewHandle проверяет имя, выбранное при регистрации. Возвращает нормализованное имя
+// или false, если ответ уже отправлен
+func checkNewHandle(w http.ResponseWriter, r *http.Request, repo *repository.UserRepository, handle string) (string, bool) {
+	handle = service.NormalizeHandle(handle)
+	err := service.ValidateHandle(handle)
+	if err != nil {
+		writeHandleError(w, r, err, handle)
+		return "", false
+	}
+
+	available, err := repo.HandleAvailable(r.Context(), handle, 0)
+	if err != nil {
+		ErrorHandler(w, r, err, "check handle error", http.StatusInternalServerError)
+		return "", false
+	}
+	if !available {
+		writeHandleError(w, r, repository.ErrHandleTaken, handle)
+		return "", false
+	}
+	return handle, true
+}
+
+// isEmailRef - ссылка на пользователя похожа на e-mail, а не на @имя
+func isEmailRef(ref string) bool {
+	return strings.Contains(ref, "@") && !strings.HasPrefix(ref, "@")
+}
+
+// resolveUserRef находит пользователя по ссылке: публичному ID (UUID), e-mail или имени (с "@" или без).
+// Если пользователя нет — ошибка с sql.ErrNoRows
+func resolveUserRef(ctx context.Context, repo *repository.UserRepository, ref string) (model.User, error) {
+	ref = strings.TrimSpace(ref)
+
+	if parsed, err := uuid.Parse(ref); err == nil {
+		id, err := repo.ResolvePublicID(ctx, parsed.String())
+		if err != nil {
+			return model.User{}, err
+		}
+		return repo.GetUserByID(ctx, id)
+	}
+
+	if isEmailRef(ref) {
+		return repo.GetUserByEmail(ctx, ref)
+	}
+
+	return repo.GetUserByHandle(ctx, service.NormalizeHandle(ref))
+}
+
+// loginEmail возвращает e-mail, по которому ведётся вход и счётчики блокировки.
+// Для входа по имени это e-mail аккаунта, чтобы чередование имени и e-mail не удваивало число попыток.
+// Имена публичны, поэтому поиск по имени до проверки блокировки ничего не раскрывает
+func loginEmail(ctx context.Context, repo *repository.UserRepository, req model.LoginRequest) (string, error) {
+	login := strings.TrimSpace(req.Login)
+	if login == "" {
+		return req.Email, nil
+	}
+	if isEmailRef(login) {
+		return login, nil
+	}
+
+	handle := service.NormalizeHandle(login)
+	user, err := repo.GetUserByHandle(ctx, handle)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// несуществующее имя получает свой счётчик и тот же ответ 401, что и неверный пароль
+			return "@" + strings.ToLower(handle), nil
+		}
+		return "", err
+	}
+	return user.Email, nil
+}
+
+// ChangeHandleHandler меняет имя текущего пользователя.
+// @Summary Сменить имя пользователя
+// @Description Имя уникально без учёта регистра. Старое имя закрепляется за пользователем на HANDLE_RESERVATION_TTL: другие занять его не могут, сам пользователь может вернуть
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param handle body model.ChangeHandleRequest true "Новое имя"
+// @Success 200 {object} model.User
+// @Failure 400 {string} string "Неверный JSON или ошибка валидации"
+// @Failure 401 {string} string "Нет access-токена"
+// @Failure 409 {object} map[string]any "Имя занято или закреплено за другим пользователем"
+// @Failure 422 {object} map[string]any "Имя не соответствует формату"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /me/handle [put]
+func ChangeHandleHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		id, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		var req model.ChangeHandleRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		user, err := service.NewHandleService(repo, log).Change(r.Context(), id, req.Handle)
+		if err != nil {
+			if writeHandleError(w, r, err, service.NormalizeHandle(req.Handle)) {
+				return
+			}
+			ErrorHandler(w, r, err, "change handle error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, user)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/config"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"time"
+)
+
+// ImpersonateHandler выдаёт администратору access-токен от имени пользователя.
+// @Summary Войти от имени пользователя
+// @Description Короткоживущий access-токен с claim "act" (настоящий автор). Refresh-токен не выдаётся. Переводы, смена пароля и администрирование с таким токеном запрещены
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Публичный ID пользователя (UUID)"
+// @Param reason body model.ImpersonateRequest true "Причина (для журнала аудита)"
+// @Success 200 {object} model.ImpersonationResponse
+// @Failure 400 {string} string "Неверный ID или не указана причина"
+// @Failure 403 {string} string "Нет права users:impersonate, попытка войти от имени себя или другого администратора"
+// @Failure 404 {string} string "Пользователь не найден"
+// @Router /users/{id}/impersonate [post]
+func ImpersonateHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		actorID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+		actorPublicID, _ := middleware.GetPublicIDFromContext(r)
+		sessionID, _ := middleware.GetSessionIDFromContext(r)
+
+		targetID, err := parseIDFromRequest(r, repo)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid user ID", idErrorStatus(err))
+			return
+		}
+
+		var req model.ImpersonateRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err = validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		if targetID == actorID {
+			ErrorHandler(w, r, fmt.Errorf("cannot impersonate yourself"), "access denied", http.StatusForbidden)
+			return
+		}
+
+		target, err := repo.GetUserByID(r.Context(), targetID)
+		if err != nil {
+			ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
+			return
+		}
+
+		// от имени другого администратора действовать нельзя: это обход его прав и аудита
+		if middleware.RoleHasPermission(target.Role, middleware.PermUsersImpersonate) {
+			ErrorHandler(w, r, fmt.Errorf("cannot impersonate user with role %s", target.Role), "access denied", http.StatusForbidden)
+			return
+		}
+
+		expiresAt := time.Now().Add(config.ImpersonationTokenTTL)
+		tokenString, err := getImpersonationToken(target, sessionID, actorPublicID, expiresAt)
+		if err != nil {
+			ErrorHandler(w, r, err, "create token error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, model.ImpersonationResponse{
+			AccessToken: tokenString,
+			ExpiresAt:   expiresAt,
+			UserID:      target.PublicID,
+			ActorID:     actorPublicID,
+		})
+
+		log.Warn("impersonation started",
+			zap.String("event", "ImpersonationStarted"),
+			zap.Int("user.id", target.ID),
+			zap.Int("actor.id", actorID),
+			zap.String("reason", req.Reason),
+			zap.Time("expires_at", expiresAt),
+		)
+	}
+}
+
+// getImpersonationToken создает access-токен от имени пользователя с claim "act" (RFC 8693).
+// Токен привязан к сессии администратора: её отзыв сразу прекращает имперсонацию
+func getImpersonationToken(user model.User, sessionID int, actorPublicID string, expiresAt time.Time) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   user.PublicID,
+		"sid":   sessionID,
+		"email": user.Email,
+		"role":  user.Role,
+		"act":   map[string]any{"sub": actorPublicID},
+		"typ":   middleware.TokenTypeAccess,
+		"exp":   expiresAt.Unix(),
+	})
+
+	tokenString, err := token.SignedString(config.JWTSecret)
+	if err != nil {
+		return "", err
+	}
+	return tokenString, nil
+}
@@ -0,0 +1,199 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/password"
+	"pet/internal/repository"
+	"pet/internal/service"
+)
+
+// CreateInvitationHandler приглашает пользователя по e-mail с заранее назначенной ролью.
+// @Summary Пригласить пользователя
+// @Description Отправляет на e-mail ссылку с одноразовым токеном. Прежние действующие приглашения на этот e-mail отзываются
+// @Tags invitations
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param invitation body model.CreateInvitationRequest true "E-mail и роль"
+// @Success 201 {object} model.Invitation
+// @Failure 400 {string} string "Неверный JSON или ошибка валидации"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 409 {string} string "E-mail уже зарегистрирован"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /admin/invitations [post]
+func CreateInvitationHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		if mail == nil {
+			ErrorHandler(w, r, fmt.Errorf("mailer is not initialized"), "mailer is not configured", http.StatusInternalServerError)
+			return
+		}
+
+		adminID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		var req model.CreateInvitationRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		invitation, err := service.NewInvitationService(repo, mail, log).Invite(r.Context(), req.Email, req.Role, adminID)
+		if err != nil {
+			if errors.Is(err, repository.ErrEmailTaken) {
+				ErrorHandler(w, r, err, "email already registered", http.StatusConflict)
+				return
+			}
+			ErrorHandler(w, r, err, "create invitation error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusCreated, invitation)
+	}
+}
+
+// ListInvitationsHandler возвращает все приглашения со статусами.
+// @Summary Список приглашений
+// @Description Возвращает приглашения в статусах pending, accepted, revoked и expired
+// @Tags invitations
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.Invitation
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /admin/invitations [get]
+func ListInvitationsHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invitations, err := repo.ListInvitations(r.Context())
+		if err != nil {
+			ErrorHandler(w, r, err, "list invitations error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, invitations)
+	}
+}
+
+// RevokeInvitationHandler отзывает действующее приглашение.
+// @Summary Отозвать приглашение
+// @Tags invitations
+// @Security BearerAuth
+// @Param id path int true "ID приглашения"
+// @Success 204 "Приглашение отозвано"
+// @Failure 400 {string} string "Неверный ID"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Действующее приглашение не найдено"
+// @Router /admin/invitations/{id} [delete]
+func RevokeInvitationHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		id, err := parseResourceIDFromRequest(r)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid invitation ID", http.StatusBadRequest)
+			return
+		}
+
+		err = repo.RevokeInvitation(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ErrorHandler(w, r, err, "invitation not found", http.StatusNotFound)
+				return
+			}
+			ErrorHandler(w, r, err, "revoke invitation error", http.StatusInternalServerError)
+			return
+		}
+
+		adminID, _ := middleware.GetUserIDFromContext(r)
+		middleware.LoggerFromContext(r.Context()).Info("invitation revoked",
+			zap.String("event", "InvitationRevoked"),
+			zap.Int("invitation.id", id),
+			zap.Int("admin.id", adminID),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// AcceptInvitationHandler регистрирует пользователя по приглашению.
+// @Summary Принять приглашение
+// @Description Создаёт аккаунт с e-mail и ролью из приглашения и паролем, который задаёт сам приглашённый. Работает и при закрытой регистрации
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param invitation body model.AcceptInvitationRequest true "Токен из ссылки, имя, возраст и пароль"
+// @Success 201 {object} model.User
+// @Failure 400 {string} string "Неверный JSON или ошибка валидации"
+// @Failure 404 {string} string "Приглашение не найдено, истекло, отозвано или уже принято"
+// @Failure 409 {string} string "E-mail уже зарегистрирован"
+// @Failure 422 {string} string "Пароль не соответствует политике"
+// @Router /invitations/accept [post]
+func AcceptInvitationHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		var req model.AcceptInvitationRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		invitations := service.NewInvitationService(repo, mail, log)
+
+		invitation, err := invitations.Lookup(r.Context(), req.Token)
+		if err != nil {
+			if errors.Is(err, service.ErrInvitationInvalid) {
+				ErrorHandler(w, r, err, "invitation not found", http.StatusNotFound)
+				return
+			}
+			ErrorHandler(w, r, err, "lookup invitation error", http.StatusInternalServerError)
+			return
+		}
+
+		if !checkPasswordPolicy(w, r, req.Password, invitation.Email, req.Name) {
+			return
+		}
+
+		hash, err := password.Hash(req.Password)
+		if err != nil {
+			ErrorHandler(w, r, err, "hash password error", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := invitations.Accept(r.Context(), req.Token, model.User{Name: req.Name, Age: req.Age, HashedPassword: hash})
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvitationInvalid):
+				ErrorHandler(w, r, err, "invitation not found", http.StatusNotFound)
+			case errors.Is(err, repository.ErrEmailTaken):
+				ErrorHandler(w, r, err, "email already registered", http.StatusConflict)
+			default:
+				ErrorHandler(w, r, err, "accept invitation error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		writeResponse(w, r, http.StatusCreated, user)
+	}
+}
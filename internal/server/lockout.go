@@ -0,0 +1,103 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"math"
+	"net/http"
+	"pet/internal/metrics"
+	"pet/internal/middleware"
+	"pet/internal/repository"
+	"pet/internal/service"
+	"strconv"
+)
+
+// checkLoginAllowed проверяет, не заблокированы ли попытки входа для e-mail и IP.
+// Если заблокированы, сам отвечает 429 с Retry-After и возвращает false.
+// Ответ одинаковый для существующих и несуществующих e-mail.
+func checkLoginAllowed(w http.ResponseWriter, r *http.Request, guard *service.LoginGuard, email string) bool {
+	err := guard.Check(r.Context(), email, middleware.GetClientIP(r))
+	if err == nil {
+		return true
+	}
+
+	if writeThrottled(w, r, err) {
+		return false
+	}
+
+	ErrorHandler(w, r, err, "check login throttle error", http.StatusInternalServerError)
+	return false
+}
+
+// writeThrottled отвечает 429 с Retry-After, если err — *service.LoginThrottledError.
+// Возвращает false, если это другая ошибка и ответ ещё не отправлен
+func writeThrottled(w http.ResponseWriter, r *http.Request, err error) bool {
+	var throttled *service.LoginThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	ErrorHandler(w, r, err, "too many attempts", http.StatusTooManyRequests)
+	return true
+}
+
+// registerLoginFailure учитывает неудачную попытку входа. Ошибка хранилища только логируется:
+// клиент в любом случае получает 401
+func registerLoginFailure(r *http.Request, guard *service.LoginGuard, email string) {
+	metrics.LoginFailures.Inc()
+
+	err := guard.RegisterFailure(r.Context(), email, middleware.GetClientIP(r))
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).Error("register login failure error",
+			zap.Error(err),
+			zap.String("event", "UserLoginFailed"),
+		)
+	}
+}
+
+// UnlockUserLoginHandler снимает блокировку входа с пользователя.
+// @Summary Снять блокировку входа
+// @Description Сбрасывает счётчик неудачных попыток входа и блокировку для e-mail пользователя,
+// @Description а также счётчики IP, с которых в аккаунт пытались войти
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Публичный ID пользователя (UUID)"
+// @Success 204 "Блокировка снята"
+// @Failure 400 {string} string "Неверный ID"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Пользователь не найден"
+// @Router /admin/users/{id}/lockout [delete]
+func UnlockUserLoginHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		id, err := parseIDFromRequest(r, repo)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid user ID", idErrorStatus(err))
+			return
+		}
+
+		adminID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		user, err := repo.GetUserByID(r.Context(), id)
+		if err != nil {
+			ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
+			return
+		}
+
+		err = service.NewLoginGuard(repo, log).Unlock(r.Context(), user.Email, adminID)
+		if err != nil {
+			ErrorHandler(w, r, err, "unlock login error", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
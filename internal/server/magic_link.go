@@ -0,0 +1,125 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/config"
+	"pet/internal/mailer"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"pet/internal/service"
+)
+
+var mail mailer.Mailer
+
+// InitMailer задаёт отправителя писем. Пока он не задан, вход по ссылке недоступен
+func InitMailer(m mailer.Mailer) {
+	mail = m
+}
+
+// MagicLinkHandler отправляет на e-mail одноразовую ссылку для входа.
+// @Summary Запросить ссылку для входа
+// @Description Ответ одинаковый для зарегистрированных и незарегистрированных e-mail. Число писем на один e-mail ограничено
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param email body model.MagicLinkRequest true "E-mail"
+// @Success 202 {object} map[string]string
+// @Failure 400 {string} string "Неверный JSON или e-mail"
+// @Failure 404 {string} string "Вход по ссылке выключен"
+// @Failure 429 {string} string "Слишком много запросов, см. Retry-After"
+// @Router /login/magic [post]
+func MagicLinkHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		if !config.MagicLinkEnabled || mail == nil {
+			ErrorHandler(w, r, fmt.Errorf("magic link login is disabled"), "magic link login is disabled", http.StatusNotFound)
+			return
+		}
+
+		var req model.MagicLinkRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		err = service.NewMagicLinkService(repo, mail, log).Send(r.Context(), req.Email)
+		if err != nil {
+			if writeThrottled(w, r, err) {
+				return
+			}
+			ErrorHandler(w, r, err, "send magic link error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusAccepted, map[string]string{
+			"message": "Если e-mail зарегистрирован, на него отправлена ссылка для входа",
+		})
+	}
+}
+
+// RedeemMagicLinkHandler выдаёт токены по одноразовой ссылке из письма.
+// @Summary Войти по ссылке
+// @Description Принимает токен из ссылки. Выдаёт те же токены, что и /login (или mfa-token, если подключён TOTP)
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body model.RedeemMagicLinkRequest true "Токен из ссылки"
+// @Success 200 {object} map[string]string "access-token, refresh-токен — в cookie"
+// @Failure 400 {string} string "Неверный JSON"
+// @Failure 401 {string} string "Ссылка недействительна, истекла или уже использована"
+// @Failure 404 {string} string "Вход по ссылке выключен"
+// @Router /login/magic/redeem [post]
+func RedeemMagicLinkHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		if !config.MagicLinkEnabled {
+			ErrorHandler(w, r, fmt.Errorf("magic link login is disabled"), "magic link login is disabled", http.StatusNotFound)
+			return
+		}
+
+		var req model.RedeemMagicLinkRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		user, err := service.NewMagicLinkService(repo, mail, log).Redeem(r.Context(), req.Token)
+		if err != nil {
+			if errors.Is(err, service.ErrMagicLinkInvalid) {
+				ErrorHandler(w, r, err, "invalid magic link", http.StatusUnauthorized)
+				return
+			}
+			ErrorHandler(w, r, err, "redeem magic link error", http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("magic link redeemed",
+			zap.String("event", "UserLoginMagicLink"),
+			zap.Int("user.id", user.ID),
+		)
+
+		if challengeMFA(w, r, repo, user) {
+			return
+		}
+
+		issueTokens(w, r, repo, user, "magic_link")
+	}
+}
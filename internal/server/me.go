@@ -0,0 +1,245 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/password"
+	"pet/internal/repository"
+	"pet/internal/service"
+)
+
+// currentUser - достаёт ID текущего пользователя из контекста и загружает его из БД.
+// При ошибке сам отвечает клиенту и возвращает false.
+func currentUser(w http.ResponseWriter, r *http.Request, repo *repository.UserRepository) (model.User, bool) {
+	id, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+		return model.User{}, false
+	}
+
+	user, err := repo.GetUserByID(r.Context(), id)
+	if err != nil {
+		ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
+		return model.User{}, false
+	}
+
+	return user, true
+}
+
+// checkCurrentPassword повторно проверяет пароль текущего пользователя. Неудачи учитываются LoginGuard
+// в тех же счётчиках аккаунта и IP, что и при входе, поэтому украденный access-токен не даёт подбирать пароль.
+// При ошибке сам отвечает клиенту (401 или 429) и возвращает false
+func checkCurrentPassword(w http.ResponseWriter, r *http.Request, repo *repository.UserRepository, user model.User, currentPassword string) bool {
+	log := middleware.LoggerFromContext(r.Context())
+
+	guard := service.NewLoginGuard(repo, log)
+	if !checkLoginAllowed(w, r, guard, user.Email) {
+		return false
+	}
+
+	ok, _, err := password.Verify(currentPassword, user.HashedPassword)
+	if err != nil || !ok {
+		if err == nil {
+			err = fmt.Errorf("password mismatch")
+		}
+		registerLoginFailure(r, guard, user.Email)
+		ErrorHandler(w, r, err, "current password mismatch", http.StatusUnauthorized)
+		return false
+	}
+
+	err = guard.RegisterSuccess(r.Context(), user.Email)
+	if err != nil {
+		log.Error("reset login throttle error",
+			zap.Error(err),
+			zap.String("event", "PasswordConfirmed"),
+		)
+	}
+	return true
+}
+
+// UpdateMeHandler частично обновляет профиль текущего пользователя.
+// @Summary Обновить свой профиль
+// @Description Меняет только имя, возраст и e-mail текущего пользователя. Роль и баланс так изменить нельзя
+// @Tags me
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user body model.UpdateProfileRequest true "Изменяемые поля"
+// @Success 200 {object} model.User
+// @Failure 400 {string} string "Неверный JSON или ошибка валидации"
+// @Failure 401 {string} string "Нет access-токена"
+// @Failure 409 {string} string "E-mail уже занят"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /me [patch]
+func UpdateMeHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		user, ok := currentUser(w, r, repo)
+		if !ok {
+			return
+		}
+
+		var req model.UpdateProfileRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		patchUser, err := repo.PatchUser(r.Context(), model.PartialUser{
+			ID:    user.ID,
+			Name:  req.Name,
+			Age:   req.Age,
+			Email: req.Email,
+		})
+		if err != nil {
+			if errors.Is(err, repository.ErrEmailTaken) {
+				ErrorHandler(w, r, err, "email already registered", http.StatusConflict)
+				return
+			}
+			ErrorHandler(w, r, err, "update user error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, patchUser)
+
+		log.Info("profile updated",
+			zap.String("event", "ProfileUpdated"),
+			zap.Int("user.id", patchUser.ID),
+		)
+	}
+}
+
+// ChangePasswordHandler меняет пароль текущего пользователя.
+// @Summary Сменить пароль
+// @Description Требует текущий пароль, даже если access-токен действителен. Все сессии, кроме текущей, отзываются
+// @Tags me
+// @Accept json
+// @Security BearerAuth
+// @Param password body model.ChangePasswordRequest true "Текущий и новый пароль"
+// @Success 204 "Пароль изменён"
+// @Failure 400 {string} string "Неверный JSON или ошибка валидации"
+// @Failure 401 {string} string "Неверный текущий пароль"
+// @Failure 429 {string} string "Слишком много неверных паролей, см. Retry-After"
+// @Failure 422 {object} map[string]any "Новый пароль не соответствует политике"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /me/password [post]
+func ChangePasswordHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, ok := currentUser(w, r, repo)
+		if !ok {
+			return
+		}
+
+		var req model.ChangePasswordRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		if !checkCurrentPassword(w, r, repo, user, req.CurrentPassword) {
+			return
+		}
+
+		if !checkPasswordPolicy(w, r, req.NewPassword, user.Email, user.Name) {
+			return
+		}
+
+		hash, err := password.Hash(req.NewPassword)
+		if err != nil {
+			ErrorHandler(w, r, err, "hash password error", http.StatusInternalServerError)
+			return
+		}
+
+		err = repo.UpdatePassword(r.Context(), user.ID, hash)
+		if err != nil {
+			ErrorHandler(w, r, err, "update password error", http.StatusInternalServerError)
+			return
+		}
+
+		// после смены пароля остальные устройства должны войти заново
+		currentSessionID, _ := middleware.GetSessionIDFromContext(r)
+		revoked, err := repo.RevokeOtherSessions(r.Context(), user.ID, currentSessionID)
+		if err != nil {
+			ErrorHandler(w, r, err, "revoke sessions error", http.StatusInternalServerError)
+			return
+		}
+
+		middleware.LoggerFromContext(r.Context()).Info("password changed",
+			zap.String("event", "PasswordChanged"),
+			zap.Int("user.id", user.ID),
+			zap.Int64("sessions_revoked", revoked),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteMeHandler удаляет аккаунт текущего пользователя.
+// @Summary Удалить свой аккаунт
+// @Description Требует подтверждения паролем. Удаляет refresh-токен из cookie
+// @Tags me
+// @Accept json
+// @Security BearerAuth
+// @Param password body model.DeleteAccountRequest true "Текущий пароль"
+// @Success 204 "Аккаунт удалён"
+// @Failure 400 {string} string "Неверный JSON или ошибка валидации"
+// @Failure 401 {string} string "Неверный пароль"
+// @Failure 429 {string} string "Слишком много неверных паролей, см. Retry-After"
+// @Router /me [delete]
+func DeleteMeHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user, ok := currentUser(w, r, repo)
+		if !ok {
+			return
+		}
+
+		var req model.DeleteAccountRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		if !checkCurrentPassword(w, r, repo, user, req.Password) {
+			return
+		}
+
+		err = repo.DeleteUser(r.Context(), user.ID)
+		if err != nil {
+			ErrorHandler(w, r, err, "delete user error", http.StatusInternalServerError)
+			return
+		}
+
+		// refresh-токен удалённого пользователя больше не нужен
+		clearRefreshCookie(w)
+
+		middleware.LoggerFromContext(r.Context()).Info("account deleted by owner",
+			zap.String("event", "AccountDeleted"),
+			zap.Int("user.id", user.ID),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"crypto/subtle"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/config"
+	"pet/internal/metrics"
+	"strings"
+)
+
+// metricsAuth пропускает к метрикам только запросы с "Authorization: Bearer <token>"
+func metricsAuth(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, "access denied", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// startMetricsServer отдаёт /metrics отдельным сервером на addr, недоступным снаружи
+func startMetricsServer(addr string, log *zap.Logger) {
+	adminMux := http.NewServeMux()
+	adminMux.Handle("/metrics", metrics.Handler())
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           adminMux,
+		ReadHeaderTimeout: config.HTTPReadHeaderTimeout,
+		ReadTimeout:       config.HTTPReadTimeout,
+		WriteTimeout:      config.HTTPWriteTimeout,
+		IdleTimeout:       config.HTTPIdleTimeout,
+	}
+
+	log.Info("Starting metrics server",
+		zap.String("addr", addr),
+		zap.String("component", "server"),
+		zap.String("event", "metrics"),
+	)
+
+	err := srv.ListenAndServe()
+	if err != nil {
+		log.Error("metrics server stopped",
+			zap.Error(err),
+			zap.String("component", "server"),
+			zap.String("event", "metrics"),
+		)
+	}
+}
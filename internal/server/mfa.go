@@ -0,0 +1,353 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"go.uber.org/zap"
+	"image/png"
+	"net/http"
+	"pet/config"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"pet/internal/service"
+	"strings"
+	"time"
+)
+
+// количество кодов восстановления, выдаваемых при подключении TOTP
+const recoveryCodesCount = 10
+
+// totpPeriod — длительность шага TOTP в секундах (как у totp.Generate и приложений-аутентификаторов)
+const totpPeriod = 30
+
+// validateTOTP проверяет код так же, как totp.Validate (текущий шаг и по одному соседнему),
+// и возвращает номер шага, которому код соответствует: принятый шаг запоминается (UseTOTPStep),
+// чтобы тот же код нельзя было использовать повторно
+func validateTOTP(code string, secret string) (int64, bool) {
+	now := time.Now()
+	for _, skew := range []int64{0, -1, 1} {
+		at := now.Add(time.Duration(skew*totpPeriod) * time.Second)
+		ok, err := totp.ValidateCustom(code, secret, at, totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err == nil && ok {
+			return at.Unix() / totpPeriod, true
+		}
+	}
+	return 0, false
+}
+
+// EnrollTOTPHandler начинает подключение TOTP-аутентификатора.
+// @Summary Подключить TOTP-аутентификатор
+// @Description Генерирует секрет и возвращает otpauth URI и QR-код (PNG в base64). TOTP включается только после подтверждения кодом
+// @Tags mfa
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.TOTPEnrollResponse
+// @Failure 401 {string} string "Нет access-токена"
+// @Failure 409 {string} string "TOTP уже подключён"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /me/mfa/totp [post]
+func EnrollTOTPHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		id, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusInternalServerError)
+			return
+		}
+
+		user, err := repo.GetUserByID(r.Context(), id)
+		if err != nil {
+			ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
+			return
+		}
+
+		key, err := totp.Generate(totp.GenerateOpts{
+			Issuer:      config.TOTPIssuer,
+			AccountName: user.Email,
+		})
+		if err != nil {
+			ErrorHandler(w, r, err, "generate totp secret error", http.StatusInternalServerError)
+			return
+		}
+
+		err = repo.SaveTOTPSecret(r.Context(), id, key.Secret())
+		if err != nil {
+			if errors.Is(err, repository.ErrTOTPAlreadyEnabled) {
+				ErrorHandler(w, r, err, "totp already enabled", http.StatusConflict)
+				return
+			}
+			ErrorHandler(w, r, err, "save totp secret error", http.StatusInternalServerError)
+			return
+		}
+
+		// QR-код для сканирования приложением-аутентификатором
+		img, err := key.Image(200, 200)
+		if err != nil {
+			ErrorHandler(w, r, err, "generate qr code error", http.StatusInternalServerError)
+			return
+		}
+
+		var qr bytes.Buffer
+		err = png.Encode(&qr, img)
+		if err != nil {
+			ErrorHandler(w, r, err, "encode qr code error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, model.TOTPEnrollResponse{
+			Secret: key.Secret(),
+			URI:    key.URL(),
+			QRCode: base64.StdEncoding.EncodeToString(qr.Bytes()),
+		})
+
+		log.Info("totp enrollment started",
+			zap.String("event", "TOTPEnroll"),
+			zap.Int("user.id", id),
+		)
+	}
+}
+
+// ConfirmTOTPHandler подтверждает подключение TOTP первым кодом из приложения.
+// @Summary Подтвердить TOTP-аутентификатор
+// @Description Проверяет код, включает TOTP и возвращает коды восстановления. Коды показываются один раз, в БД хранятся только их хеши
+// @Tags mfa
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param code body model.MFACodeRequest true "Код из приложения-аутентификатора"
+// @Success 200 {object} model.RecoveryCodesResponse
+// @Failure 400 {string} string "Неверный JSON или код"
+// @Failure 404 {string} string "Подключение TOTP не начато"
+// @Failure 409 {string} string "TOTP уже подключён"
+// @Router /me/mfa/totp/confirm [post]
+func ConfirmTOTPHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		id, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusInternalServerError)
+			return
+		}
+
+		var req model.MFACodeRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		userTOTP, err := repo.GetTOTP(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ErrorHandler(w, r, err, "totp enrollment not started", http.StatusNotFound)
+				return
+			}
+			ErrorHandler(w, r, err, "get totp error", http.StatusInternalServerError)
+			return
+		}
+
+		if userTOTP.Enabled {
+			ErrorHandler(w, r, repository.ErrTOTPAlreadyEnabled, "totp already enabled", http.StatusConflict)
+			return
+		}
+
+		step, valid := validateTOTP(req.Code, userTOTP.Secret)
+		if !valid {
+			ErrorHandler(w, r, fmt.Errorf("invalid totp code"), "invalid totp code", http.StatusBadRequest)
+			return
+		}
+
+		// код подтверждения не должен подойти и для входа сразу после него
+		used, err := repo.UseTOTPStep(r.Context(), id, step)
+		if err != nil {
+			ErrorHandler(w, r, err, "use totp code error", http.StatusInternalServerError)
+			return
+		}
+		if !used {
+			ErrorHandler(w, r, fmt.Errorf("totp code already used"), "invalid totp code", http.StatusBadRequest)
+			return
+		}
+
+		codes, hashes, err := generateRecoveryCodes()
+		if err != nil {
+			ErrorHandler(w, r, err, "generate recovery codes error", http.StatusInternalServerError)
+			return
+		}
+
+		err = repo.EnableTOTP(r.Context(), id, hashes)
+		if err != nil {
+			if errors.Is(err, repository.ErrTOTPAlreadyEnabled) {
+				ErrorHandler(w, r, err, "totp already enabled", http.StatusConflict)
+				return
+			}
+			ErrorHandler(w, r, err, "enable totp error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, model.RecoveryCodesResponse{RecoveryCodes: codes})
+
+		log.Info("totp enabled",
+			zap.String("event", "TOTPConfirm"),
+			zap.Int("user.id", id),
+		)
+	}
+}
+
+// LoginMFAHandler завершает вход пользователя с подключённым TOTP.
+// @Summary Второй шаг авторизации: TOTP-код или код восстановления
+// @Description Принимает mfa-token из /login и код. При успехе выдаёт access и refresh токены, как LoginHandler
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param login body model.MFALoginRequest true "MFA-токен и код"
+// @Success 200 {object} map[string]string "access-token и сообщение об успешной авторизации"
+// @Failure 400 {string} string "Неверный JSON или ошибка валидации"
+// @Failure 401 {string} string "Неверный токен или код"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /login/mfa [post]
+func LoginMFAHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var req model.MFALoginRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		claims, err := parseToken(req.MFAToken, middleware.TokenTypeMFA)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid mfa token", http.StatusUnauthorized)
+			return
+		}
+
+		sub, ok := claims["sub"].(string)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("invalid sub-field"), "invalid mfa token", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := resolvePublicID(r.Context(), repo, sub)
+		if err != nil {
+			ErrorHandler(w, r, err, "user not found", http.StatusUnauthorized)
+			return
+		}
+
+		loginUser, err := repo.GetUserByID(r.Context(), id)
+		if err != nil {
+			ErrorHandler(w, r, err, "user not found", http.StatusUnauthorized)
+			return
+		}
+
+		// перебор TOTP-кодов ограничивается тем же счётчиком, что и перебор паролей
+		guard := service.NewLoginGuard(repo, middleware.LoggerFromContext(r.Context()))
+		if !checkLoginAllowed(w, r, guard, loginUser.Email) {
+			return
+		}
+
+		userTOTP, err := repo.GetTOTP(r.Context(), id)
+		if err != nil || !userTOTP.Enabled {
+			ErrorHandler(w, r, err, "totp is not enabled", http.StatusUnauthorized)
+			return
+		}
+
+		step, valid := validateTOTP(req.Code, userTOTP.Secret)
+		if valid {
+			// код уже принимался — повтор перехваченного кода считается неудачной попыткой
+			used, err := repo.UseTOTPStep(r.Context(), id, step)
+			if err != nil {
+				ErrorHandler(w, r, err, "use totp code error", http.StatusInternalServerError)
+				return
+			}
+			if !used {
+				registerLoginFailure(r, guard, loginUser.Email)
+				ErrorHandler(w, r, fmt.Errorf("totp code already used"), "invalid mfa code", http.StatusUnauthorized)
+				return
+			}
+		} else {
+			// код из приложения не подошёл — пробуем как код восстановления
+			used, err := repo.UseRecoveryCode(r.Context(), id, hashRecoveryCode(req.Code))
+			if err != nil {
+				ErrorHandler(w, r, err, "check recovery code error", http.StatusInternalServerError)
+				return
+			}
+			if !used {
+				registerLoginFailure(r, guard, loginUser.Email)
+				ErrorHandler(w, r, fmt.Errorf("invalid mfa code"), "invalid mfa code", http.StatusUnauthorized)
+				return
+			}
+
+			middleware.LoggerFromContext(r.Context()).Warn("recovery code used",
+				zap.String("event", "UserLoginRecoveryCode"),
+				zap.Int("user.id", id),
+			)
+		}
+
+		err = guard.RegisterSuccess(r.Context(), loginUser.Email)
+		if err != nil {
+			middleware.LoggerFromContext(r.Context()).Error("reset login throttle error",
+				zap.Error(err),
+				zap.String("event", "UserLogin"),
+			)
+		}
+
+		issueTokens(w, r, repo, loginUser, "mfa")
+	}
+}
+
+// generateRecoveryCodes генерирует коды восстановления вида "abcde-fghij"
+// и возвращает их вместе с хешами для хранения в БД
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+
+	for i := 0; i < recoveryCodesCount; i++ {
+		buf := make([]byte, 10) // в код идут первые 10 символов base32 — 50 бит случайности
+		_, err := rand.Read(buf)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf))
+		code := raw[:5] + "-" + raw[5:10]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode нормализует код (регистр, дефисы, пробелы) и возвращает его SHA-256.
+// Коды случайные и длинные, поэтому медленный хеш (bcrypt) здесь не нужен.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
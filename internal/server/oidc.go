@@ -0,0 +1,210 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"net/http"
+	"pet/config"
+	"pet/internal/middleware"
+	"pet/internal/repository"
+	"pet/internal/service"
+	"time"
+)
+
+// oidcStateTTL — сколько у пользователя есть времени на вход у провайдера
+const oidcStateTTL = 10 * time.Minute
+
+// oidcStateCookie — cookie с подписанными state, nonce и PKCE-verifier незавершённого входа
+const oidcStateCookie = "oidc-state"
+
+var oidcProviders = map[string]*service.OIDCProvider{}
+
+// InitOIDCProviders загружает discovery-документы настроенных провайдеров.
+// Провайдер, который не удалось загрузить, пропускается; ошибки возвращаются вместе.
+func InitOIDCProviders(ctx context.Context, providers []config.OIDCProviderConfig, log *zap.Logger) error {
+	oidcProviders = map[string]*service.OIDCProvider{}
+
+	var errs []error
+	for _, cfg := range providers {
+		provider, err := service.NewOIDCProvider(ctx, cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		oidcProviders[cfg.Name] = provider
+
+		log.Info("oidc provider configured",
+			zap.String("identity.provider", cfg.Name),
+			zap.String("issuer", cfg.IssuerURL),
+			zap.String("component", "server"),
+			zap.String("event", "oidc_init"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// oidcProviderFromRequest находит провайдера по {provider} в URL. Если его нет — отвечает 404
+func oidcProviderFromRequest(w http.ResponseWriter, r *http.Request) (*service.OIDCProvider, bool) {
+	name := mux.Vars(r)["provider"]
+	provider, ok := oidcProviders[name]
+	if !ok {
+		ErrorHandler(w, r, fmt.Errorf("oidc provider %q is not configured", name), "provider not found", http.StatusNotFound)
+		return nil, false
+	}
+	return provider, true
+}
+
+// OIDCLoginHandler начинает вход через внешнего провайдера.
+// @Summary Вход через OIDC-провайдера
+// @Description Перенаправляет на страницу входа провайдера (authorization code + PKCE). state, nonce и verifier сохраняются в подписанной cookie
+// @Tags auth
+// @Param provider path string true "Имя провайдера"
+// @Success 302 "Редирект к провайдеру"
+// @Failure 404 {string} string "Провайдер не настроен"
+// @Router /auth/oidc/{provider}/login [get]
+func OIDCLoginHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		provider, ok := oidcProviderFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		state, err := newTokenID()
+		if err != nil {
+			ErrorHandler(w, r, err, "generate state error", http.StatusInternalServerError)
+			return
+		}
+		nonce, err := newTokenID()
+		if err != nil {
+			ErrorHandler(w, r, err, "generate nonce error", http.StatusInternalServerError)
+			return
+		}
+		verifier := oauth2.GenerateVerifier()
+
+		stateToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"typ":      middleware.TokenTypeOIDC,
+			"provider": provider.Name,
+			"state":    state,
+			"nonce":    nonce,
+			"verifier": verifier,
+			"exp":      time.Now().Add(oidcStateTTL).Unix(),
+		}).SignedString(config.JWTSecret)
+		if err != nil {
+			ErrorHandler(w, r, err, "create state token error", http.StatusInternalServerError)
+			return
+		}
+
+		// SameSite=Lax: cookie должна прийти в callback после редиректа со стороннего сайта провайдера
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    stateToken,
+			Path:     "/auth/oidc/" + provider.Name,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   int(oidcStateTTL.Seconds()),
+		})
+
+		http.Redirect(w, r, provider.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+	}
+}
+
+// OIDCCallbackHandler завершает вход через внешнего провайдера.
+// @Summary Callback OIDC-провайдера
+// @Description Проверяет state, обменивает код на токены, проверяет ID-токен и nonce, находит, привязывает или создаёт пользователя и выдаёт токены API
+// @Tags auth
+// @Produce json
+// @Param provider path string true "Имя провайдера"
+// @Param code query string true "Код авторизации"
+// @Param state query string true "state из запроса авторизации"
+// @Success 200 {object} map[string]string "Access-токен, refresh-токен — в cookie"
+// @Failure 401 {string} string "Неверный state, код или ID-токен"
+// @Failure 403 {string} string "Учётная запись не привязана, а автосоздание выключено"
+// @Failure 409 {string} string "E-mail занят и не подтверждён провайдером"
+// @Router /auth/oidc/{provider}/callback [get]
+func OIDCCallbackHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		provider, ok := oidcProviderFromRequest(w, r)
+		if !ok {
+			return
+		}
+
+		// state-cookie одноразовая
+		http.SetCookie(w, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    "",
+			Path:     "/auth/oidc/" + provider.Name,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			MaxAge:   -1,
+		})
+
+		if idpErr := r.URL.Query().Get("error"); idpErr != "" {
+			ErrorHandler(w, r, fmt.Errorf("provider error: %s", idpErr), "provider rejected login", http.StatusUnauthorized)
+			return
+		}
+
+		cookie, err := r.Cookie(oidcStateCookie)
+		if err != nil {
+			ErrorHandler(w, r, err, "no oidc state", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := parseToken(cookie.Value, middleware.TokenTypeOIDC)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid oidc state", http.StatusUnauthorized)
+			return
+		}
+
+		state, _ := claims["state"].(string)
+		nonce, _ := claims["nonce"].(string)
+		verifier, _ := claims["verifier"].(string)
+		if claims["provider"] != provider.Name || state == "" ||
+			subtle.ConstantTimeCompare([]byte(state), []byte(r.URL.Query().Get("state"))) != 1 {
+			ErrorHandler(w, r, fmt.Errorf("state mismatch"), "invalid oidc state", http.StatusUnauthorized)
+			return
+		}
+
+		identity, err := provider.Exchange(r.Context(), r.URL.Query().Get("code"), verifier, nonce)
+		if err != nil {
+			ErrorHandler(w, r, err, "oidc exchange error", http.StatusUnauthorized)
+			return
+		}
+
+		// при закрытой регистрации новые аккаунты через OIDC тоже не создаются, только привязываются существующие
+		user, err := service.NewIdentityService(repo, log).Resolve(r.Context(), identity, provider.AutoProvision && config.RegistrationOpen)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrIdentityNotLinked):
+				ErrorHandler(w, r, err, "external identity is not linked", http.StatusForbidden)
+			case errors.Is(err, service.ErrIdentityEmailTaken):
+				ErrorHandler(w, r, err, "email is already registered", http.StatusConflict)
+			default:
+				ErrorHandler(w, r, err, "resolve identity error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Info("user authenticated by oidc provider",
+			zap.String("event", "UserLoginOIDC"),
+			zap.String("identity.provider", provider.Name),
+			zap.Int("user.id", user.ID),
+		)
+
+		// второй фактор провайдер не заменяет: с включённым TOTP вход завершается через /login/mfa
+		if challengeMFA(w, r, repo, user) {
+			return
+		}
+
+		issueTokens(w, r, repo, user, "oidc")
+	}
+}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"pet/internal/service"
+)
+
+// ListMyPasskeysHandler возвращает ключи доступа текущего пользователя.
+// @Summary Мои ключи доступа
+// @Tags passkeys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.Passkey
+// @Failure 401 {string} string "Нет access-токена"
+// @Router /me/passkeys [get]
+func ListMyPasskeysHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		userID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		passkeys, err := repo.ListPasskeys(r.Context(), userID)
+		if err != nil {
+			ErrorHandler(w, r, err, "list passkeys error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, passkeys)
+	}
+}
+
+// BeginPasskeyRegistrationHandler начинает регистрацию ключа доступа.
+// @Summary Начать регистрацию ключа доступа
+// @Description Возвращает параметры для navigator.credentials.create и ceremony_id. Ответ аутентификатора нужно отправить в /me/passkeys/register/finish
+// @Tags passkeys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.PasskeyBeginResponse
+// @Failure 401 {string} string "Нет access-токена"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /me/passkeys/register/begin [post]
+func BeginPasskeyRegistrationHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		userID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		begin, err := service.NewPasskeyService(repo, middleware.LoggerFromContext(r.Context())).BeginRegistration(r.Context(), userID)
+		if err != nil {
+			ErrorHandler(w, r, err, "begin passkey registration error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, begin)
+	}
+}
+
+// FinishPasskeyRegistrationHandler проверяет ответ аутентификатора и сохраняет ключ.
+// @Summary Завершить регистрацию ключа доступа
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param credential body model.PasskeyRegisterFinishRequest true "ceremony_id, название и ответ navigator.credentials.create"
+// @Success 201 {object} model.Passkey
+// @Failure 400 {string} string "Неверный JSON, церемония истекла или ответ аутентификатора не прошёл проверку"
+// @Failure 401 {string} string "Нет access-токена"
+// @Failure 409 {string} string "Ключ уже зарегистрирован"
+// @Router /me/passkeys/register/finish [post]
+func FinishPasskeyRegistrationHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		userID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		var req model.PasskeyRegisterFinishRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		passkey, err := service.NewPasskeyService(repo, middleware.LoggerFromContext(r.Context())).
+			FinishRegistration(r.Context(), userID, req.CeremonyID, req.Name, req.Credential)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrPasskeyCeremonyInvalid), errors.Is(err, service.ErrPasskeyInvalid):
+				ErrorHandler(w, r, err, "invalid passkey registration", http.StatusBadRequest)
+			case errors.Is(err, repository.ErrPasskeyExists):
+				ErrorHandler(w, r, err, "passkey already registered", http.StatusConflict)
+			default:
+				ErrorHandler(w, r, err, "finish passkey registration error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		writeResponse(w, r, http.StatusCreated, passkey)
+	}
+}
+
+// RenamePasskeyHandler меняет название ключа доступа.
+// @Summary Переименовать ключ доступа
+// @Tags passkeys
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "ID ключа"
+// @Param name body model.RenamePasskeyRequest true "Новое название"
+// @Success 200 {object} model.Passkey
+// @Failure 400 {string} string "Неверный ID или JSON"
+// @Failure 404 {string} string "Ключ не найден"
+// @Router /me/passkeys/{id} [patch]
+func RenamePasskeyHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		userID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		passkeyID, err := parseResourceIDFromRequest(r)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid passkey ID", http.StatusBadRequest)
+			return
+		}
+
+		var req model.RenamePasskeyRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err = validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		passkey, err := repo.RenamePasskey(r.Context(), userID, passkeyID, req.Name)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ErrorHandler(w, r, err, "passkey not found", http.StatusNotFound)
+				return
+			}
+			ErrorHandler(w, r, err, "rename passkey error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, passkey)
+	}
+}
+
+// DeletePasskeyHandler удаляет ключ доступа.
+// @Summary Удалить ключ доступа
+// @Tags passkeys
+// @Security BearerAuth
+// @Param id path int true "ID ключа"
+// @Success 204 "Ключ удалён"
+// @Failure 400 {string} string "Неверный ID"
+// @Failure 404 {string} string "Ключ не найден"
+// @Router /me/passkeys/{id} [delete]
+func DeletePasskeyHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		userID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		passkeyID, err := parseResourceIDFromRequest(r)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid passkey ID", http.StatusBadRequest)
+			return
+		}
+
+		err = repo.DeletePasskey(r.Context(), userID, passkeyID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ErrorHandler(w, r, err, "passkey not found", http.StatusNotFound)
+				return
+			}
+			ErrorHandler(w, r, err, "delete passkey error", http.StatusInternalServerError)
+			return
+		}
+
+		middleware.LoggerFromContext(r.Context()).Info("passkey deleted",
+			zap.String("event", "PasskeyDeleted"),
+			zap.Int("user.id", userID),
+			zap.Int("passkey.id", passkeyID),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// BeginPasskeyLoginHandler начинает вход по ключу доступа.
+// @Summary Начать вход по ключу доступа
+// @Description Возвращает параметры для navigator.credentials.get и ceremony_id. E-mail не нужен: аутентификатор сам предложит ключ
+// @Tags auth
+// @Produce json
+// @Success 200 {object} model.PasskeyBeginResponse
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /login/passkey/begin [post]
+func BeginPasskeyLoginHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		begin, err := service.NewPasskeyService(repo, middleware.LoggerFromContext(r.Context())).BeginLogin(r.Context())
+		if err != nil {
+			ErrorHandler(w, r, err, "begin passkey login error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, begin)
+	}
+}
+
+// FinishPasskeyLoginHandler проверяет подпись ключа и выдаёт токены.
+// @Summary Войти по ключу доступа
+// @Description Ключ проверяет пользователя сам (user verification), поэтому TOTP-код после него не запрашивается
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credential body model.PasskeyLoginFinishRequest true "ceremony_id и ответ navigator.credentials.get"
+// @Success 200 {object} map[string]string "access-token, refresh-токен — в cookie"
+// @Failure 400 {string} string "Неверный JSON или церемония истекла"
+// @Failure 401 {string} string "Ключ не прошёл проверку"
+// @Router /login/passkey/finish [post]
+func FinishPasskeyLoginHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		var req model.PasskeyLoginFinishRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		user, err := service.NewPasskeyService(repo, log).FinishLogin(r.Context(), req.CeremonyID, req.Credential)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrPasskeyCeremonyInvalid):
+				ErrorHandler(w, r, err, "passkey ceremony expired", http.StatusBadRequest)
+			case errors.Is(err, service.ErrPasskeyInvalid), errors.Is(err, service.ErrPasskeyCloned):
+				ErrorHandler(w, r, err, "invalid passkey", http.StatusUnauthorized)
+			default:
+				ErrorHandler(w, r, err, "finish passkey login error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		log.Info("user logged in with passkey",
+			zap.String("event", "UserLoginPasskey"),
+			zap.Int("user.id", user.ID),
+		)
+
+		issueTokens(w, r, repo, user, "passkey")
+	}
+}
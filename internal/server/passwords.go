@@ -0,0 +1,63 @@
+package server
+
+import (
+	"errors"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/password"
+	"pet/internal/repository"
+)
+
+// checkPasswordPolicy проверяет новый пароль по политике. Если пароль не подходит,
+// сам отвечает 422 со списком нарушений и возвращает false
+func checkPasswordPolicy(w http.ResponseWriter, r *http.Request, newPassword string, userInputs ...string) bool {
+	err := password.Validate(newPassword, userInputs...)
+	if err == nil {
+		return true
+	}
+
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		ErrorHandler(w, r, err, "password policy check error", http.StatusInternalServerError)
+		return false
+	}
+
+	log := middleware.LoggerFromContext(r.Context())
+	log.Info("password rejected by policy",
+		zap.Strings("violations", policyErr.Violations),
+		zap.String("component", "server"),
+		zap.String("event", "PasswordPolicyViolation"),
+	)
+
+	writeResponse(w, r, http.StatusUnprocessableEntity, map[string]any{
+		"error":      "password does not meet policy",
+		"violations": policyErr.Violations,
+	})
+	return false
+}
+
+// rehashPassword пересчитывает устаревший хеш текущим алгоритмом после успешного входа.
+// Ошибка не мешает входу: хеш обновится при следующем входе
+func rehashPassword(r *http.Request, repo *repository.UserRepository, userID int, plain string) {
+	log := middleware.LoggerFromContext(r.Context())
+
+	hash, err := password.Hash(plain)
+	if err == nil {
+		err = repo.UpdatePassword(r.Context(), userID, hash)
+	}
+	if err != nil {
+		log.Warn("password rehash error",
+			zap.Error(err),
+			zap.Int("user.id", userID),
+			zap.String("event", "PasswordRehashed"),
+		)
+		return
+	}
+
+	log.Info("password hash upgraded",
+		zap.Int("user.id", userID),
+		zap.String("algorithm", password.Algorithm()),
+		zap.String("event", "PasswordRehashed"),
+	)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/render"
+)
+
+// writeResponse отправляет v со статусом status в формате, который клиент запросил в Accept
+// (JSON, XML, MessagePack или CSV для списков, см. render.Write)
+func writeResponse(w http.ResponseWriter, r *http.Request, status int, v any) {
+	err := render.Write(w, r, status, v)
+	if err != nil {
+		middleware.LoggerFromContext(r.Context()).Error("encoding error",
+			zap.Error(err),
+			zap.String("component", "server"),
+			zap.String("event", "response"),
+		)
+	}
+}
+
+// writeError отвечает статусом status и телом {"error": msg, ...fields}
+func writeError(w http.ResponseWriter, r *http.Request, err error, status int, msg string, fields map[string]any) {
+	log := middleware.LoggerFromContext(r.Context())
+	log.Info(msg,
+		zap.Error(err),
+		zap.String("component", "server"),
+		zap.String("event", "http_error"),
+	)
+
+	body := map[string]any{"error": msg}
+	for k, v := range fields {
+		body[k] = v
+	}
+
+	writeResponse(w, r, status, body)
+}
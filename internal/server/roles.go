@@ -0,0 +1,100 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+)
+
+// ListRolesHandler возвращает роли и их права.
+// @Summary Список ролей и прав
+// @Description Возвращает все роли с правами с учётом наследования (admin ⊃ editor ⊃ guest)
+// @Tags roles
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.RoleInfo
+// @Failure 403 {string} string "Недостаточно прав"
+// @Router /admin/roles [get]
+func ListRolesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roles := make([]model.RoleInfo, 0, len(middleware.Roles))
+		for _, role := range middleware.Roles {
+			roles = append(roles, model.RoleInfo{
+				Name:        role,
+				Inherits:    middleware.RoleParent(role),
+				Permissions: middleware.RolePermissions(role),
+			})
+		}
+
+		writeResponse(w, r, http.StatusOK, roles)
+	}
+}
+
+// SetUserRoleHandler назначает пользователю роль.
+// @Summary Назначить роль пользователю
+// @Description Новая роль попадает в токены при следующем входе пользователя. Менять собственную роль нельзя
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Публичный ID пользователя (UUID)"
+// @Param role body model.SetRoleRequest true "Новая роль"
+// @Success 200 {object} model.User
+// @Failure 400 {string} string "Неверный JSON или неизвестная роль"
+// @Failure 403 {string} string "Недостаточно прав или попытка сменить свою роль"
+// @Failure 404 {string} string "Пользователь не найден"
+// @Router /admin/users/{id}/role [put]
+func SetUserRoleHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		id, err := parseIDFromRequest(r, repo)
+		if err != nil {
+			ErrorHandler(w, r, err, "failed to get ID from URL", idErrorStatus(err))
+			return
+		}
+
+		var req model.SetRoleRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err = validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		// защита от случайной потери доступа: администратор не может понизить сам себя
+		adminID, _ := middleware.GetUserIDFromContext(r)
+		if adminID == id {
+			ErrorHandler(w, r, fmt.Errorf("admin %d tried to change own role", id), "cannot change own role", http.StatusForbidden)
+			return
+		}
+
+		user, err := repo.SetUserRole(r.Context(), id, req.Role)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
+				return
+			}
+			ErrorHandler(w, r, err, "set user role error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, user)
+
+		log.Info("user role changed",
+			zap.String("event", "UserRoleChanged"),
+			zap.Int("user.id", user.ID),
+			zap.String("user.role", user.Role),
+			zap.Int("admin.id", adminID),
+		)
+	}
+}
@@ -1,25 +1,31 @@
 package server
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/go-playground/validator/v10"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"go.uber.org/zap"
-	"golang.org/x/crypto/bcrypt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"pet/config"
+	"pet/internal/metrics"
 	"pet/internal/middleware"
 	"pet/internal/model"
+	"pet/internal/password"
 	"pet/internal/repository"
+	"pet/internal/service"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	_ "pet/docs" // важно для инициализации swagger-доков
@@ -47,20 +53,50 @@ func InitValidator() {
 // Запускает и настраивает роутер для страниц, где происходят CRUD-операции с пользователями и БД.
 func StartServer(repo *repository.UserRepository, log *zap.Logger) {
 	InitValidator()
+	password.Init()
+
+	// IP клиента определяется с учётом доверенных прокси (TRUSTED_PROXIES)
+	ipResolver, err := middleware.NewClientIPResolver(config.TrustedProxies)
+	if err != nil {
+		log.Error("invalid trusted proxies",
+			zap.Error(err),
+			zap.String("component", "server"),
+			zap.String("event", "client_ip"),
+		)
+		return
+	}
+
+	// политика CORS из конфигурации; по SIGHUP перечитывается без перезапуска
+	corsHandler, err := middleware.NewCors(config.Cors)
+	if err != nil {
+		log.Error("invalid cors policy",
+			zap.Error(err),
+			zap.String("component", "server"),
+			zap.String("event", "cors"),
+		)
+		return
+	}
+	go reloadCorsOnSignal(corsHandler, log)
+
+	if config.MetricsAddr != "" {
+		go startMetricsServer(config.MetricsAddr, log)
+	}
 
 	var handler http.Handler = SetupRoutes(repo) // явно указываю тип
 
-	handler = middleware.WithLogger(log)(handler)         // кладем логгер в контекст для исп. в ручках
-	handler = middleware.Recoverer()(handler)             // сначала обработка panic()
-	handler = middleware.MidLog()(handler)                // логирует метод, путь, статус-код и время выполнения запроса
-	handler = middleware.Cors()(handler)                  // обработка предзапросов браузера
-	handler = middleware.DefaultHeaders()(handler)        // системные заголовки по умолчанию
-	handler = middleware.RateLimiterMiddleware()(handler) // добавил лимит запросов 5-10 в секунду
+	handler = middleware.WithLogger(log)(handler)      // кладем логгер в контекст для исп. в ручках
+	handler = middleware.Tracing(handler)              // спан запроса, продолжает трассу из traceparent
+	handler = middleware.ClientIP(ipResolver)(handler) // IP клиента в контекст: для логгера, лимитов и сессий
+	handler = middleware.Recoverer()(handler)          // сначала обработка panic()
+	handler = middleware.MidLog()(handler)             // логирует метод, путь, статус-код и время выполнения запроса
+	handler = corsHandler.Handler(handler)             // обработка предзапросов браузера
+	handler = middleware.DefaultHeaders()(handler)     // системные заголовки по умолчанию
+	// лимит запросов ставится в SetupRoutes: по группам маршрутов и после Auth, чтобы учитывать пользователя
 	//handler = auth.AuthMiddleware(handler)              // проверка токена и передачи ID через контекст
 
 	log.Info("Starting HTTP-server on :8080")
 
-	err := runServer(handler) // запуск сервера с проверкой
+	err = runServer(handler) // запуск сервера с проверкой
 	if err != nil {
 		// Если сервер остановился с ошибкой, отличной от http.ErrServerClosed — это реально ошибка
 		if errors.Is(err, http.ErrServerClosed) {
@@ -80,6 +116,35 @@ func StartServer(repo *repository.UserRepository, log *zap.Logger) {
 	log.Info("Server stopped gracefully")
 }
 
+// reloadCorsOnSignal перечитывает политику CORS из файла CORS_CONFIG_FILE по сигналу SIGHUP.
+// Переменные CORS_* действуют только при старте: окружение запущенного процесса не меняется.
+// Неверная новая политика не применяется, продолжает действовать прежняя
+func reloadCorsOnSignal(corsHandler *middleware.CorsHandler, log *zap.Logger) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	for range signals {
+		policy, err := config.ReloadCorsPolicy(os.Getenv("APP_ENV"))
+		if err == nil {
+			err = corsHandler.Reload(policy)
+		}
+		if err != nil {
+			log.Error("cannot reload cors policy",
+				zap.Error(err),
+				zap.String("component", "server"),
+				zap.String("event", "cors_reload"),
+			)
+			continue
+		}
+
+		log.Info("cors policy reloaded",
+			zap.Strings("cors.allowed_origins", policy.AllowedOrigins),
+			zap.String("component", "server"),
+			zap.String("event", "cors_reload"),
+		)
+	}
+}
+
 // SetupRoutes - настройки роутера и хендлеров
 func SetupRoutes(repo *repository.UserRepository) *mux.Router {
 	fmt.Println("[DEBUG] SetupRoutes: начало")
@@ -89,28 +154,114 @@ func SetupRoutes(repo *repository.UserRepository) *mux.Router {
 	// Handle(...) используется, потому что ты передаёшь http.Handler, а не просто функцию
 	// router.Handle("/users", middleware.Logging(http.HandlerFunc(handlePostUsers(repo)))).Methods(http.MethodPost)
 
-	protected := router.Methods(http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete).Subrouter()
-	protected.Use(middleware.Auth)
-	protected.Use(middleware.AdminOnly)
-	protected.Use(middleware.EditorOnly)
+	// Auth ищет API-ключи в том же репозитории
+	middleware.InitAPIKeyStore(repo)
+	middleware.InitSessionStore(repo)
+	middleware.InitUserStatusStore(repo)
+	middleware.InitIdempotencyStore(repo)
 
-	// Публичные маршруты или эндпоинты
-	router.HandleFunc("/ready", ReadyHandler).Methods(http.MethodGet)
-	router.HandleFunc("/users", GetUsersHandler(repo)).Methods(http.MethodGet)
-	router.HandleFunc("/users/{id}", GetUserByIDFromURLHandler(repo)).Methods(http.MethodGet)
-	router.HandleFunc("/me", GetUserByIDFromContextHandler(repo)).Methods(http.MethodGet)
+	// метрики, имя спана по маршруту и срок обработки каждого запроса (config.RequestTimeout или свой для маршрута)
+	router.Use(middleware.Metrics, middleware.TraceRoute, middleware.Deadline)
+
+	// метрики на основном порту — только по токену; с отдельным портом их отдаёт startMetricsServer
+	if config.MetricsAddr == "" && config.MetricsToken != "" {
+		router.Handle("/metrics", metricsAuth(config.MetricsToken, metrics.Handler())).Methods(http.MethodGet)
+	}
 
-	router.HandleFunc("/register", RegisterHandler(repo)).Methods(http.MethodPost)
-	router.HandleFunc("/login", LoginHandler(repo)).Methods(http.MethodPost) // вместо GET !!!
+	// проверка готовности не ограничивается: её часто опрашивают балансировщики
+	router.HandleFunc("/ready", ReadyHandler).Methods(http.MethodGet)
 
-	// Маршруты / эндпоинты, защищенные авторизацией и правами доступа
-	protected.HandleFunc("/users", PostUserHandler(repo)).Methods(http.MethodPost)
-	protected.HandleFunc("/users/{id}", PutUserHandler(repo)).Methods(http.MethodPut)
-	protected.HandleFunc("/users/{id}", PatchUserHandler(repo)).Methods(http.MethodPatch)
-	protected.HandleFunc("/users/{id}", DeleteUserHandler(repo)).Methods(http.MethodDelete)
+	// Публичные маршруты или эндпоинты (лимит по IP). Во всех группах ответ отдаётся в формате из Accept,
+	// а клиент, не принимающий ни одного из форматов render, сразу получает 406 (Negotiate)
+	public := router.NewRoute().Subrouter()
+	public.Use(middleware.Negotiate, middleware.RateLimit(config.RateLimitGroupDefault))
+	public.HandleFunc("/users", GetUsersHandler(repo)).Methods(http.MethodGet)
+	public.HandleFunc("/users/{id}", GetUserByIDFromURLHandler(repo)).Methods(http.MethodGet)
+	public.HandleFunc("/refresh", RefreshHandler(repo)).Methods(http.MethodPost)
+	public.HandleFunc("/auth/oidc/{provider}/login", OIDCLoginHandler()).Methods(http.MethodGet)
+	public.HandleFunc("/auth/oidc/{provider}/callback", OIDCCallbackHandler(repo)).Methods(http.MethodGet)
+
+	// Вход и регистрация — отдельная, более строгая группа лимитов.
+	// Повтор регистрации с тем же Idempotency-Key и e-mail получает первый ответ, а не 409
+	auth := router.NewRoute().Subrouter()
+	auth.Use(middleware.Negotiate, middleware.RateLimit(config.RateLimitGroupAuth))
+	auth.Handle("/register", middleware.IdempotencyByEmail(RegisterHandler(repo))).Methods(http.MethodPost)
+	auth.HandleFunc("/invitations/accept", AcceptInvitationHandler(repo)).Methods(http.MethodPost)
+	auth.HandleFunc("/login", LoginHandler(repo)).Methods(http.MethodPost) // вместо GET !!!
+	auth.HandleFunc("/login/mfa", LoginMFAHandler(repo)).Methods(http.MethodPost)
+	auth.HandleFunc("/login/magic", MagicLinkHandler(repo)).Methods(http.MethodPost)
+	auth.HandleFunc("/login/magic/redeem", RedeemMagicLinkHandler(repo)).Methods(http.MethodPost)
+	auth.HandleFunc("/login/passkey/begin", BeginPasskeyLoginHandler(repo)).Methods(http.MethodPost)
+	auth.HandleFunc("/login/passkey/finish", FinishPasskeyLoginHandler(repo)).Methods(http.MethodPost)
+
+	// Маршруты / эндпоинты, защищенные авторизацией. Право доступа объявляется для каждого маршрута.
+	// Лимит считается дважды: до Auth — по IP (перебор токенов и API-ключей), после Auth — по пользователю,
+	// его роли или API-ключу, как и ключи Idempotency-Key.
+	// Idempotency подключается к маршрутам по одному: ответы, в которых есть токены, ключи или секреты
+	// (API-ключи, имперсонация, TOTP), в БД не сохраняются
+	protected := router.NewRoute().Subrouter()
+	protected.Use(middleware.Negotiate, middleware.RateLimitByIP(config.RateLimitGroupCredentials), middleware.Auth,
+		middleware.RateLimit(config.RateLimitGroupDefault))
+
+	// Самообслуживание: любой аутентифицированный пользователь управляет своим аккаунтом
+	me := protected.PathPrefix("/me").Subrouter()
+	me.Use(middleware.RejectAPIKeys)
+	me.HandleFunc("", GetUserByIDFromContextHandler(repo)).Methods(http.MethodGet)
+	me.HandleFunc("/sessions", ListMySessionsHandler(repo)).Methods(http.MethodGet)
+	me.HandleFunc("/passkeys", ListMyPasskeysHandler(repo)).Methods(http.MethodGet)
+
+	// чувствительные операции с аккаунтом недоступны администратору, действующему от имени пользователя
+	me.Handle("", middleware.ForbidImpersonation(middleware.Idempotency(UpdateMeHandler(repo)))).Methods(http.MethodPatch)
+	me.Handle("", middleware.ForbidImpersonation(DeleteMeHandler(repo))).Methods(http.MethodDelete)
+	me.Handle("/password", middleware.ForbidImpersonation(ChangePasswordHandler(repo))).Methods(http.MethodPost)
+	me.Handle("/handle", middleware.ForbidImpersonation(ChangeHandleHandler(repo))).Methods(http.MethodPut)
+	me.Handle("/mfa/totp", middleware.ForbidImpersonation(EnrollTOTPHandler(repo))).Methods(http.MethodPost)
+	me.Handle("/mfa/totp/confirm", middleware.ForbidImpersonation(ConfirmTOTPHandler(repo))).Methods(http.MethodPost)
+	me.Handle("/passkeys/register/begin", middleware.ForbidImpersonation(BeginPasskeyRegistrationHandler(repo))).Methods(http.MethodPost)
+	me.Handle("/passkeys/register/finish", middleware.ForbidImpersonation(FinishPasskeyRegistrationHandler(repo))).Methods(http.MethodPost)
+	me.Handle("/passkeys/{id}", middleware.ForbidImpersonation(RenamePasskeyHandler(repo))).Methods(http.MethodPatch)
+	me.Handle("/passkeys/{id}", middleware.ForbidImpersonation(DeletePasskeyHandler(repo))).Methods(http.MethodDelete)
+	me.Handle("/sessions/revoke-others", middleware.ForbidImpersonation(RevokeOtherSessionsHandler(repo))).Methods(http.MethodPost)
+	me.Handle("/sessions/{id}", middleware.ForbidImpersonation(RevokeMySessionHandler(repo))).Methods(http.MethodDelete)
+
+	// свой собственный /users/{id} пользователь может менять и без роли editor/admin.
+	// Удаление — только по праву: свой аккаунт удаляется через DELETE /me с подтверждением паролем.
+	// Изменение и удаление аккаунта (в том числе e-mail) недоступны администратору от имени пользователя
+	protected.Handle("/users", middleware.Require(middleware.PermUsersWrite)(middleware.Idempotency(PostUserHandler(repo)))).Methods(http.MethodPost)
+	protected.Handle("/users/{id}", middleware.ForbidImpersonation(
+		middleware.RequireSelfOr(middleware.PermUsersWrite)(PutUserHandler(repo)))).Methods(http.MethodPut)
+	protected.Handle("/users/{id}", middleware.ForbidImpersonation(
+		middleware.RequireSelfOr(middleware.PermUsersWrite)(middleware.Idempotency(PatchUserHandler(repo))))).Methods(http.MethodPatch)
+	protected.Handle("/users/{id}", middleware.ForbidImpersonation(
+		middleware.Require(middleware.PermUsersDelete)(DeleteUserHandler(repo)))).Methods(http.MethodDelete)
+
+	protected.Handle("/users/{id}/impersonate", middleware.RejectAPIKeys(middleware.ForbidImpersonation(
+		middleware.Require(middleware.PermUsersImpersonate)(ImpersonateHandler(repo))))).Methods(http.MethodPost)
+
+	protected.Handle("/transfers", middleware.ForbidImpersonation(
+		middleware.Require(middleware.PermTransfersWrite)(middleware.Idempotency(TransferHandler(repo))))).Methods(http.MethodPost)
+
+	// Администрирование: роли, сессии и API-ключи (только для пользователей с JWT)
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.RejectAPIKeys, middleware.ForbidImpersonation)
+	admin.Handle("/roles", middleware.Require(middleware.PermRolesManage)(ListRolesHandler())).Methods(http.MethodGet)
+	admin.Handle("/users/{id}/role", middleware.Require(middleware.PermRolesManage)(SetUserRoleHandler(repo))).Methods(http.MethodPut)
+	admin.Handle("/users/{id}/suspend", middleware.Require(middleware.PermUsersSuspend)(middleware.Idempotency(SuspendUserHandler(repo)))).Methods(http.MethodPost)
+	admin.Handle("/users/{id}/lock", middleware.Require(middleware.PermUsersSuspend)(middleware.Idempotency(LockUserHandler(repo)))).Methods(http.MethodPost)
+	admin.Handle("/users/{id}/reactivate", middleware.Require(middleware.PermUsersSuspend)(middleware.Idempotency(ReactivateUserHandler(repo)))).Methods(http.MethodPost)
+	admin.Handle("/users/{id}/lockout", middleware.Require(middleware.PermLoginsUnlock)(UnlockUserLoginHandler(repo))).Methods(http.MethodDelete)
+	admin.Handle("/users/{id}/sessions", middleware.Require(middleware.PermSessionsManage)(ListUserSessionsHandler(repo))).Methods(http.MethodGet)
+	admin.Handle("/users/{id}/sessions", middleware.Require(middleware.PermSessionsManage)(RevokeUserSessionsHandler(repo))).Methods(http.MethodDelete)
+	admin.Handle("/users/{id}/sessions/{session_id}", middleware.Require(middleware.PermSessionsManage)(RevokeUserSessionHandler(repo))).Methods(http.MethodDelete)
+	admin.Handle("/invitations", middleware.Require(middleware.PermUsersInvite)(middleware.Idempotency(CreateInvitationHandler(repo)))).Methods(http.MethodPost)
+	admin.Handle("/invitations", middleware.Require(middleware.PermUsersInvite)(ListInvitationsHandler(repo))).Methods(http.MethodGet)
+	admin.Handle("/invitations/{id}", middleware.Require(middleware.PermUsersInvite)(RevokeInvitationHandler(repo))).Methods(http.MethodDelete)
+	admin.Handle("/api-keys", middleware.Require(middleware.PermAPIKeysManage)(CreateAPIKeyHandler(repo))).Methods(http.MethodPost)
+	admin.Handle("/api-keys", middleware.Require(middleware.PermAPIKeysManage)(ListAPIKeysHandler(repo))).Methods(http.MethodGet)
+	admin.Handle("/api-keys/{id}", middleware.Require(middleware.PermAPIKeysManage)(RevokeAPIKeyHandler(repo))).Methods(http.MethodDelete)
 
 	//Маршруты / эндпоинты для документации:
-	router.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
+	public.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
 	fmt.Println("[DEBUG] SetupRoutes: конец, router =", router)
 	if router == nil {
@@ -121,8 +272,18 @@ func SetupRoutes(repo *repository.UserRepository) *mux.Router {
 
 // runServer - запускает сервер на :8080
 func runServer(handler http.Handler) error {
+	// без таймаутов медленный клиент может держать соединение сколько угодно
+	srv := &http.Server{
+		Addr:              ":8081",
+		Handler:           handler,
+		ReadHeaderTimeout: config.HTTPReadHeaderTimeout,
+		ReadTimeout:       config.HTTPReadTimeout,
+		WriteTimeout:      config.HTTPWriteTimeout,
+		IdleTimeout:       config.HTTPIdleTimeout,
+	}
+
 	//go func() {
-	err := http.ListenAndServe(":8081", handler)
+	err := srv.ListenAndServe()
 	if err != nil {
 		return err
 	}
@@ -141,20 +302,23 @@ func runServer(handler http.Handler) error {
 // @Success 200 {object} map[string]string
 // @Router /ready [get]
 func ReadyHandler(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
 	//_, _ = w.Write([]byte(`{"status":"ok"}`))
-	_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	writeResponse(w, r, http.StatusOK, map[string]string{"status": "ok"})
 }
 
 // PostUserHandler добавляет нового пользователя.
 // @Summary Создать пользователя
+// @Description Пароль проверяется по той же политике, что и при регистрации, и хранится только в виде хеша
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param user body model.User true "Информация о пользователе"
+// @Security BearerAuth
+// @Param user body model.CreateUserRequest true "Информация о пользователе"
 // @Success 201 {object} model.User
 // @Failure 400 {string} string "Неверный JSON или ошибка валидации"
-// @Failure 422 {string} string "Ошибка бизнес-валидации (например, обязательные поля)"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 409 {string} string "E-mail или имя пользователя заняты"
+// @Failure 422 {string} string "Ошибка бизнес-валидации (например, обязательные поля или пароль не по политике)"
 // @Failure 500 {string} string "Внутренняя ошибка сервера"
 // @Router /users [post]
 func PostUserHandler(repo *repository.UserRepository) http.HandlerFunc {
@@ -162,48 +326,66 @@ func PostUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 
 		log := middleware.LoggerFromContext(r.Context())
 
-		var newUser model.User
+		var req model.CreateUserRequest
 
-		defer r.Body.Close()
-		err := json.NewDecoder(r.Body).Decode(&newUser)
-		if err != nil {
-			ErrorHandler(w, r, err, "failed to decode JSON", http.StatusBadRequest)
+		if !readJSON(w, r, &req) {
 			return
 		}
 
-		err = validate.Struct(newUser)
+		err := validate.Struct(req)
 		if err != nil {
 			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
 			return
 		}
 
-		postUser, err := repo.PostUser(newUser)
-		if err != nil {
-			// Проверим, ошибка ли это валидации (ошибка пользователя)
-			if strings.Contains(err.Error(), "обязательны для заполнения") {
-				ErrorHandler(w, r, err, "unprocessable entity", http.StatusUnprocessableEntity)
+		if !checkPasswordPolicy(w, r, req.Password, req.Email, req.Name) {
+			return
+		}
+
+		var handle string
+		if req.Handle != "" {
+			var ok bool
+			handle, ok = checkNewHandle(w, r, repo, req.Handle)
+			if !ok {
 				return
 			}
-			// Иначе — это внутренняя ошибка сервера
-			ErrorHandler(w, r, err, "add new user error", http.StatusInternalServerError)
+		}
+
+		hash, err := password.Hash(req.Password)
+		if err != nil {
+			ErrorHandler(w, r, err, "hash password error", http.StatusInternalServerError)
 			return
 		}
 
-		// вернуть статус и заголовки, удалим как будет готов ErrorHandler
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
+		newUser := model.User{
+			Name:           req.Name,
+			Age:            req.Age,
+			Email:          req.Email,
+			Handle:         handle,
+			HashedPassword: hash,
+		}
 
-		// вернуть добавленного пользователя
-		err = json.NewEncoder(w).Encode(postUser)
+		postUser, err := repo.PostUser(r.Context(), newUser)
 		if err != nil {
-			log.Error(
-				"encoding error",
-				zap.Error(err),
-				zap.String("event", "UserCreated"),
-			)
+			if writeHandleError(w, r, err, handle) {
+				return
+			}
+			switch {
+			case errors.Is(err, repository.ErrEmailTaken):
+				ErrorHandler(w, r, err, "email already registered", http.StatusConflict)
+			case errors.Is(err, repository.ErrRequiredFields):
+				ErrorHandler(w, r, err, "unprocessable entity", http.StatusUnprocessableEntity)
+			default:
+				ErrorHandler(w, r, err, "add new user error", http.StatusInternalServerError)
+			}
 			return
 		}
 
+		metrics.Registrations.WithLabelValues("admin").Inc()
+
+		// вернуть добавленного пользователя
+		writeResponse(w, r, http.StatusCreated, postUser)
+
 		log.Info("user added successfully",
 			zap.String("event", "UserCreated"),
 			zap.Int("user.id", postUser.ID),
@@ -221,43 +403,68 @@ func PostUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 // @Param user body model.RegisterRequest true "Информация о пользователе"
 // @Success 201 {object} model.User
 // @Failure 400 {string} string "Неверный JSON или ошибка валидации"
-// @Failure 422 {string} string "Ошибка бизнес-валидации (например, обязательные поля)"
+// @Failure 403 {string} string "Регистрация только по приглашению (REGISTRATION_OPEN=false)"
+// @Failure 409 {object} map[string]any "Имя пользователя или e-mail заняты"
+// @Failure 422 {string} string "Ошибка бизнес-валидации (например, обязательные поля или формат имени)"
 // @Failure 500 {string} string "Внутренняя ошибка сервера"
 // @Router /register [post]
 func RegisterHandler(repo *repository.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var registerUser model.RegisterRequest
+		if !config.RegistrationOpen {
+			ErrorHandler(w, r, fmt.Errorf("open registration is disabled"), "registration is by invitation only", http.StatusForbidden)
+			return
+		}
 
-		defer r.Body.Close()
+		var registerUser model.RegisterRequest
 
-		err := json.NewDecoder(r.Body).Decode(&registerUser)
-		if err != nil {
-			ErrorHandler(w, r, err, "invalid request format", http.StatusBadRequest)
+		if !readJSON(w, r, &registerUser) {
 			return
 		}
 
-		err = validate.Struct(registerUser)
+		err := validate.Struct(registerUser)
 		if err != nil {
 			ErrorHandler(w, r, err, "validation failed ", http.StatusBadRequest)
 			return
 		}
 
+		if !checkPasswordPolicy(w, r, registerUser.Password, registerUser.Email, registerUser.Name) {
+			return
+		}
+
+		var handle string
+		if registerUser.Handle != "" {
+			var ok bool
+			handle, ok = checkNewHandle(w, r, repo, registerUser.Handle)
+			if !ok {
+				return
+			}
+		}
+
 		// хеширование пароля
-		hash, err := bcrypt.GenerateFromPassword([]byte(registerUser.Password), bcrypt.DefaultCost)
+		hash, err := password.Hash(registerUser.Password)
 		if err != nil {
 			ErrorHandler(w, r, err, "hash password error:", http.StatusInternalServerError)
+			return
 		}
 
 		var newUser model.User
 		newUser.Name = registerUser.Name
 		newUser.Age = registerUser.Age
 		newUser.Email = registerUser.Email
-		newUser.HashedPassword = string(hash)
+		newUser.Handle = handle
+		newUser.HashedPassword = hash
 
-		postUser, err := repo.PostUser(newUser)
+		postUser, err := repo.PostUser(r.Context(), newUser)
 		if err != nil {
+			if writeHandleError(w, r, err, handle) {
+				return
+			}
+			if errors.Is(err, repository.ErrEmailTaken) {
+				ErrorHandler(w, r, err, "email already registered", http.StatusConflict)
+				return
+			}
 			// Проверим, ошибка ли это валидации (ошибка пользователя)
-			if strings.Contains(err.Error(), "обязательны для заполнения") {
+			if errors.Is(err, repository.ErrRequiredFields) {
 				ErrorHandler(w, r, err, "unprocessable entity", http.StatusUnprocessableEntity)
 				return
 			}
@@ -268,20 +475,11 @@ func RegisterHandler(repo *repository.UserRepository) http.HandlerFunc {
 
 		log := middleware.LoggerFromContext(r.Context())
 
-		// вернуть статус и заголовки. Нужны ли еще какие-либо заголовки?
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
+		metrics.Registrations.WithLabelValues("register").Inc()
 
+		// вернуть статус и заголовки. Нужны ли еще какие-либо заголовки?
 		// вернуть добавленного пользователя
-		err = json.NewEncoder(w).Encode(postUser)
-		if err != nil {
-			log.Error(
-				"encoding error",
-				zap.Error(err),
-				zap.String("event", "UserRegistered"),
-			)
-			return
-		}
+		writeResponse(w, r, http.StatusCreated, postUser)
 
 		log.Info("user added successfully",
 			zap.String("event", "UserCreated"),
@@ -301,7 +499,7 @@ func RegisterHandler(repo *repository.UserRepository) http.HandlerFunc {
 // @Router /users [get]
 func GetUsersHandler(repo *repository.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		getUsers, err := repo.GetAllUsers()
+		getUsers, err := repo.GetAllUsers(r.Context())
 		if err != nil {
 			ErrorHandler(w, r, err, "get all users error", http.StatusInternalServerError)
 			return
@@ -309,18 +507,7 @@ func GetUsersHandler(repo *repository.UserRepository) http.HandlerFunc {
 
 		log := middleware.LoggerFromContext(r.Context())
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-
-		err = json.NewEncoder(w).Encode(getUsers)
-		if err != nil {
-			log.Error(
-				"encoding error",
-				zap.Error(err),
-				zap.String("event", "GetUsers"),
-			)
-			return
-		}
+		writeResponse(w, r, http.StatusOK, getUsers)
 
 		log.Info("users got successfully",
 			zap.String("event", "GetUsers"),
@@ -334,11 +521,12 @@ func GetUsersHandler(repo *repository.UserRepository) http.HandlerFunc {
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param id path int true "ID пользователя"
+// @Param id path string true "Публичный ID пользователя (UUID)"
 // @Param user body model.User true "Информация о пользователе"
 // @Success 200 {object} model.User
 // @Failure 400 {string} string "Неверный JSON или ошибка валидации"
 // @Failure 404 {string} string "Пользователь не найден в БД"
+// @Failure 409 {string} string "E-mail уже занят"
 // @Failure 500 {string} string "Внутренняя ошибка сервера"
 // @Router /users/{id} [put]
 func PutUserHandler(repo *repository.UserRepository) http.HandlerFunc {
@@ -346,23 +534,22 @@ func PutUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 
 		log := middleware.LoggerFromContext(r.Context())
 
-		id, err := parseIDFromRequest(r)
+		id, err := parseIDFromRequest(r, repo)
 		if err != nil {
-			ErrorHandler(w, r, err, "failed to get ID from URL", http.StatusBadRequest)
+			ErrorHandler(w, r, err, "failed to get ID from URL", idErrorStatus(err))
+			return
 		}
 
 		var updatedUser model.User
-		err = json.NewDecoder(r.Body).Decode(&updatedUser)
-		if err != nil {
-			ErrorHandler(w, r, err, "failed to decode JSON", http.StatusBadRequest)
+		if !readJSON(w, r, &updatedUser) {
 			return
 		}
-		defer r.Body.Close()
 
-		if updatedUser.ID != id {
+		if !strings.EqualFold(updatedUser.PublicID, mux.Vars(r)["id"]) {
 			ErrorHandler(w, r, err, "ID from URL and ID from body do not match", http.StatusBadRequest)
 			return
 		}
+		updatedUser.ID = id
 
 		// Простая проверка на пустые поля
 		if updatedUser.Name == "" || updatedUser.Email == "" || updatedUser.Age == 0 {
@@ -376,31 +563,22 @@ func PutUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 			return
 		}
 
-		// TODO: Разобрать ошибку и возвращать 409 только при нарушении уникальности
-		// http.Error(w, "[SERVER] ошибка при PUT-обновлении пользователя в БД", http.StatusConflict)
-
-		putUser, err := repo.PutUser(updatedUser)
+		putUser, err := repo.PutUser(r.Context(), updatedUser)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
 				return
 			}
+			if errors.Is(err, repository.ErrEmailTaken) {
+				ErrorHandler(w, r, err, "email already registered", http.StatusConflict)
+				return
+			}
 
 			ErrorHandler(w, r, err, "internal server error", http.StatusInternalServerError)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-
-		err = json.NewEncoder(w).Encode(putUser)
-		if err != nil {
-			log.Error("encoding error",
-				zap.Error(err),
-				zap.String("event", "UserPut"),
-			)
-			return
-		}
+		writeResponse(w, r, http.StatusOK, putUser)
 
 		log.Info("user added successfully",
 			zap.String("event", "UserPut"),
@@ -416,34 +594,34 @@ func PutUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param id path int true "ID пользователя"
+// @Param id path string true "Публичный ID пользователя (UUID)"
 // @Param user body model.PartialUser true "Информация о пользователе"
 // @Success 200 {object} model.User
 // @Failure 400 {string} string "Неверный JSON или ошибка валидации"
 // @Failure 404 {string} string "Пользователь не найден в БД"
+// @Failure 409 {string} string "E-mail уже занят"
 // @Failure 500 {string} string "Внутренняя ошибка сервера"
 // @Router /users/{id} [patch]
 func PatchUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log := middleware.LoggerFromContext(r.Context())
 
-		id, err := parseIDFromRequest(r)
+		id, err := parseIDFromRequest(r, repo)
 		if err != nil {
-			ErrorHandler(w, r, err, "failed to get ID from URL", http.StatusBadRequest)
+			ErrorHandler(w, r, err, "failed to get ID from URL", idErrorStatus(err))
 			return
 		}
 
 		var updatedUser model.PartialUser
-		err = json.NewDecoder(r.Body).Decode(&updatedUser)
-		if err != nil {
-			ErrorHandler(w, r, err, "failed to decode JSON", http.StatusBadRequest)
+		if !readJSON(w, r, &updatedUser) {
 			return
 		}
 
-		if updatedUser.ID != id {
+		if !strings.EqualFold(updatedUser.PublicID, mux.Vars(r)["id"]) {
 			ErrorHandler(w, r, err, "ID from URL and ID from body do not match", http.StatusBadRequest)
 			return
 		}
+		updatedUser.ID = id
 
 		//// убрал эту логику
 		//if updatedUser.Name == nil && updatedUser.Age == nil && updatedUser.Email == nil {
@@ -457,7 +635,7 @@ func PatchUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 			return
 		}
 
-		patchUser, err := repo.PatchUser(updatedUser)
+		patchUser, err := repo.PatchUser(r.Context(), updatedUser)
 		if err != nil {
 			switch {
 			case err.Error() == "нет полей для обновления":
@@ -466,23 +644,16 @@ func PatchUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 			case errors.Is(err, sql.ErrNoRows):
 				ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
 				return
+			case errors.Is(err, repository.ErrEmailTaken):
+				ErrorHandler(w, r, err, "email already registered", http.StatusConflict)
+				return
 			default:
 				ErrorHandler(w, r, err, "update user error", http.StatusInternalServerError)
 				return
 			}
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-
-		err = json.NewEncoder(w).Encode(patchUser)
-		if err != nil {
-			log.Error("encoding error",
-				zap.Error(err),
-				zap.String("event", "UserPatch"),
-			)
-			return
-		}
+		writeResponse(w, r, http.StatusOK, patchUser)
 
 		log.Info("user added successfully",
 			zap.String("event", "UserPatch"),
@@ -496,7 +667,7 @@ func PatchUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 // @Summary Удалить пользователя
 // @Description Извлекает ID из URL, удаляет данные о пользователе из БД
 // @Tags users
-// @Param id path int true "ID пользователя"
+// @Param id path string true "Публичный ID пользователя (UUID)"
 // @Success 204 "Пользователь успешно удалён"
 // @Failure 400 {string} string "Неверный ID"
 // @Failure 404 {string} string "Пользователь не найден в БД"
@@ -504,13 +675,13 @@ func PatchUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 func DeleteUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		id, err := parseIDFromRequest(r)
+		id, err := parseIDFromRequest(r, repo)
 		if err != nil {
-			ErrorHandler(w, r, err, "failed to get ID from URL", http.StatusBadRequest)
+			ErrorHandler(w, r, err, "failed to get ID from URL", idErrorStatus(err))
 			return
 		}
 
-		err = repo.DeleteUser(id)
+		err = repo.DeleteUser(r.Context(), id)
 		if err != nil {
 			ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
 			return
@@ -524,7 +695,7 @@ func DeleteUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 // @Description делает запрос в БД, получает пользователя, инкодирует в JSON и возвращает ответ
 // @Tags users
 // @Produce json
-// @Param id path int true "ID пользователя"
+// @Param id path string true "Публичный ID пользователя (UUID)"
 // @Success 200 {object} model.User
 // @Failure 400 {string} string "ошибка при получении ID"
 // @Failure 404 {string} string "Пользователь не найден в БД"
@@ -532,22 +703,19 @@ func DeleteUserHandler(repo *repository.UserRepository) http.HandlerFunc {
 func GetUserByIDFromURLHandler(repo *repository.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		id, err := parseIDFromRequest(r)
+		id, err := parseIDFromRequest(r, repo)
 		if err != nil {
-			ErrorHandler(w, r, err, "failed to get ID from URL", http.StatusBadRequest)
+			ErrorHandler(w, r, err, "failed to get ID from URL", idErrorStatus(err))
 			return
 		}
 
-		getUser, err := repo.GetUserByID(id)
+		getUser, err := repo.GetUserByID(r.Context(), id)
 		if err != nil {
 			ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-
-		_ = json.NewEncoder(w).Encode(getUser)
+		writeResponse(w, r, http.StatusOK, getUser)
 	}
 }
 
@@ -558,37 +726,30 @@ func GetUserByIDFromURLHandler(repo *repository.UserRepository) http.HandlerFunc
 // @Produce json
 // @Success 200 {object} model.User
 // @Failure 404 {string} string "Пользователь не найден"
-// @Failure 500 {string} string "Ошибка сервера при извлечении ID из контекста"
+// @Security BearerAuth
+// @Failure 401 {string} string "Нет access-токена"
 // @Router /me [get]
 func GetUserByIDFromContextHandler(repo *repository.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		id, ok := middleware.GetUserIDFromContext(r)
+		getUser, ok := currentUser(w, r, repo)
 		if !ok {
-			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusInternalServerError)
-			return
-		}
-
-		getUser, err := repo.GetUserByID(id)
-		if err != nil {
-			ErrorHandler(w, r, err, "user not found", http.StatusNotFound)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(getUser)
+		writeResponse(w, r, http.StatusOK, getUser)
 	}
 }
 
 // LoginHandler авторизует пользователя.
 // @Summary Авторизовать пользователя и получить JWT токены
-// @Description Принимает email и пароль, валидирует, проверяет в БД, создает JWT access и refresh токены, возвращает access токен в JSON и refresh токен в HTTP-only cookie
+// @Description Принимает e-mail или имя пользователя (поле login) и пароль, валидирует, проверяет в БД, создает JWT access и refresh токены, возвращает access токен в JSON и refresh токен в HTTP-only cookie.
+// @Description Если у пользователя подключён TOTP, вместо токенов возвращает короткоживущий mfa-token для POST /login/mfa
 // @Tags users
 // @Accept json
 // @Produce json
-// @Param user body model.LoginRequest true "Данные для авторизации (email и пароль)"
-// @Success 200 {object} map[string]string "access-token и сообщение об успешной авторизации"
+// @Param user body model.LoginRequest true "Данные для авторизации (email или login и пароль)"
+// @Success 200 {object} map[string]string "access-token и сообщение об успешной авторизации (или mfa-token, если нужен TOTP-код)"
 // @Failure 400 {string} string "Неверный JSON или ошибка валидации"
 // @Failure 401 {string} string "Неверный email или пароль"
 // @Failure 500 {string} string "Внутренняя ошибка сервера"
@@ -599,85 +760,240 @@ func LoginHandler(repo *repository.UserRepository) http.HandlerFunc {
 		log := middleware.LoggerFromContext(r.Context())
 
 		var user model.LoginRequest
-		err := json.NewDecoder(r.Body).Decode(&user)
-		if err != nil {
-			ErrorHandler(w, r, err, "failed to decode JSON", http.StatusBadRequest) // не StatusUnauthorized
+		if !readJSON(w, r, &user) {
 			return
 		}
 
-		err = validate.Struct(user)
+		err := validate.Struct(user)
 		if err != nil {
 			ErrorHandler(w, r, err, "incorrect login or password", http.StatusBadRequest)
 			return
 		}
 
-		loginUser, err := repo.GetUserByEmail(user.Email)
+		email, err := loginEmail(r.Context(), repo, user)
 		if err != nil {
-			ErrorHandler(w, r, err, "user not found by e-mail", http.StatusUnauthorized)
+			ErrorHandler(w, r, err, "find user by handle error", http.StatusInternalServerError)
 			return
 		}
 
-		err = bcrypt.CompareHashAndPassword([]byte(loginUser.HashedPassword), []byte(user.Password)) // сначала пароль из БД
+		// блокировка проверяется до поиска пользователя: ответ не зависит от того, есть ли такой e-mail
+		guard := service.NewLoginGuard(repo, log)
+		if !checkLoginAllowed(w, r, guard, email) {
+			return
+		}
+
+		loginUser, err := repo.GetUserByEmail(r.Context(), email)
 		if err != nil {
+			password.VerifyDummy(user.Password)
+			registerLoginFailure(r, guard, email)
 			ErrorHandler(w, r, err, "user not found by e-mail", http.StatusUnauthorized)
 			return
 		}
 
-		// Создать JWT access-токен
-		accessTokenString, err := getAccessToken(loginUser)
-		if err != nil {
-			ErrorHandler(w, r, err, "create token error", http.StatusInternalServerError)
+		ok, needsRehash, err := password.Verify(user.Password, loginUser.HashedPassword)
+		if err != nil || !ok {
+			if err == nil {
+				err = fmt.Errorf("password mismatch")
+			}
+			registerLoginFailure(r, guard, email)
+			ErrorHandler(w, r, err, "user not found by e-mail", http.StatusUnauthorized)
 			return
 		}
 
-		// Создать JWT refresh-токен
-		refreshTokenString, err := getRefreshToken(loginUser)
-		if err != nil {
-			ErrorHandler(w, r, err, "create token error", http.StatusInternalServerError)
+		// статус проверяется только после верного пароля, чтобы не раскрывать его подбирающим
+		if !checkAccountActive(w, r, loginUser) {
 			return
 		}
 
-		// здесь (в ручке) передаю только refresh-токен в cookie
-		http.SetCookie(w, &http.Cookie{
-			Name:     "refresh-token",
-			Value:    refreshTokenString,
-			Path:     "/",
-			Secure:   false,
-			HttpOnly: true,
-			SameSite: http.SameSiteStrictMode,
-			MaxAge:   int(config.RefreshTokenTTL.Seconds()),
-		})
+		// хеш старого алгоритма или со слабыми параметрами обновляется, пока известен пароль
+		if needsRehash {
+			rehashPassword(r, repo, loginUser.ID, user.Password)
+		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
+		// если подключён TOTP, токены выдаются только после ввода кода в /login/mfa
+		if challengeMFA(w, r, repo, loginUser) {
+			return
+		}
 
-		err = json.NewEncoder(w).Encode(map[string]string{
-			"message":      "Успешная авторизация",
-			"access-token": accessTokenString,
-		})
+		// при включённом TOTP счётчик сбрасывается только после ввода кода
+		err = guard.RegisterSuccess(r.Context(), email)
 		if err != nil {
-			log.Error(
-				"encoding error",
+			log.Error("reset login throttle error",
 				zap.Error(err),
 				zap.String("event", "UserLogin"),
 			)
-			return
 		}
 
-		log.Info("user added successfully",
-			zap.String("event", "UserLogin"),
-			zap.Int("user.id", loginUser.ID),
-			zap.String("user.email", loginUser.Email),
-		)
+		issueTokens(w, r, repo, loginUser, "password")
+	}
+}
+
+// checkAccountActive отвечает 403, если аккаунт не в статусе active. Возвращает false, если ответ уже отправлен
+func checkAccountActive(w http.ResponseWriter, r *http.Request, user model.User) bool {
+	err := service.CheckActive(user)
+	if err != nil {
+		ErrorHandler(w, r, err, "account is not active", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// challengeMFA отвечает mfa-token, если у пользователя подключён TOTP: токены выдаст /login/mfa.
+// Возвращает true, если ответ уже отправлен (запрошен код или произошла ошибка)
+func challengeMFA(w http.ResponseWriter, r *http.Request, repo *repository.UserRepository, loginUser model.User) bool {
+	log := middleware.LoggerFromContext(r.Context())
+
+	totp, err := repo.GetTOTP(r.Context(), loginUser.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		ErrorHandler(w, r, err, "get totp error", http.StatusInternalServerError)
+		return true
+	}
+
+	if err != nil || !totp.Enabled {
+		return false
+	}
+
+	mfaTokenString, err := getMFAToken(loginUser)
+	if err != nil {
+		ErrorHandler(w, r, err, "create token error", http.StatusInternalServerError)
+		return true
+	}
+
+	writeResponse(w, r, http.StatusOK, map[string]any{
+		"message":      "Требуется код подтверждения",
+		"mfa_required": true,
+		"mfa-token":    mfaTokenString,
+	})
+
+	log.Info("mfa challenge issued",
+		zap.String("event", "UserLoginMFARequired"),
+		zap.Int("user.id", loginUser.ID),
+	)
+	return true
+}
+
+// issueTokens создаёт сессию для нового входа и выдаёт её токены.
+// Общий последний шаг для всех способов входа; method — способ входа для метрик.
+func issueTokens(w http.ResponseWriter, r *http.Request, repo *repository.UserRepository, loginUser model.User, method string) {
+	log := middleware.LoggerFromContext(r.Context())
+
+	// все способы входа сходятся здесь, поэтому неактивный аккаунт не получит токенов ни одним из них
+	if !checkAccountActive(w, r, loginUser) {
+		return
+	}
+
+	jti, err := newTokenID()
+	if err != nil {
+		ErrorHandler(w, r, err, "create token error", http.StatusInternalServerError)
+		return
+	}
+
+	userAgent := r.UserAgent()
+	session, err := repo.CreateSession(r.Context(), model.Session{
+		UserID:     loginUser.ID,
+		RefreshJTI: jti,
+		Device:     deviceFromUserAgent(userAgent),
+		UserAgent:  userAgent,
+		IP:         middleware.GetClientIP(r),
+		ExpiresAt:  time.Now().Add(config.RefreshTokenTTL),
+	})
+	if err != nil {
+		ErrorHandler(w, r, err, "create session error", http.StatusInternalServerError)
+		return
 	}
+
+	if !writeTokens(w, r, loginUser, session.ID, jti) {
+		return
+	}
+
+	metrics.Logins.WithLabelValues(method).Inc()
+
+	log.Info("user logged in successfully",
+		zap.String("event", "UserLogin"),
+		zap.Int("user.id", loginUser.ID),
+		zap.String("user.email", loginUser.Email),
+		zap.Int("session.id", session.ID),
+	)
 }
 
-func parseIDFromRequest(r *http.Request) (int, error) {
+// writeTokens создает JWT access и refresh токены сессии, передает refresh-токен в cookie,
+// а access-токен — в теле ответа. Возвращает false, если ответить не удалось.
+func writeTokens(w http.ResponseWriter, r *http.Request, user model.User, sessionID int, jti string) bool {
+	// Создать JWT access-токен
+	accessTokenString, err := getAccessToken(user, sessionID)
+	if err != nil {
+		ErrorHandler(w, r, err, "create token error", http.StatusInternalServerError)
+		return false
+	}
+
+	// Создать JWT refresh-токен
+	refreshTokenString, err := getRefreshToken(user, sessionID, jti)
+	if err != nil {
+		ErrorHandler(w, r, err, "create token error", http.StatusInternalServerError)
+		return false
+	}
+
+	// здесь (в ручке) передаю только refresh-токен в cookie
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh-token",
+		Value:    refreshTokenString,
+		Path:     "/",
+		Secure:   false,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(config.RefreshTokenTTL.Seconds()),
+	})
+
+	writeResponse(w, r, http.StatusOK, map[string]string{
+		"message":      "Успешная авторизация",
+		"access-token": accessTokenString,
+	})
+	return true
+}
+
+// ErrInvalidPublicID — в URL или теле запроса не публичный ID пользователя (UUID)
+var ErrInvalidPublicID = errors.New("invalid user ID")
+
+// parseIDFromRequest извлекает из URL публичный ID пользователя и возвращает его внутренний ID.
+// Неверный формат — ErrInvalidPublicID, неизвестный пользователь — ошибка с sql.ErrNoRows (см. idErrorStatus)
+func parseIDFromRequest(r *http.Request, repo *repository.UserRepository) (int, error) {
 	vars := mux.Vars(r)
 	idStr, ok := vars["id"]
 	if !ok {
-		return 0, fmt.Errorf("ID пользователя не указан в ссылке")
+		return 0, fmt.Errorf("ID пользователя не указан в ссылке: %w", ErrInvalidPublicID)
+	}
 
+	return resolvePublicID(r.Context(), repo, idStr)
+}
+
+// resolvePublicID проверяет формат публичного ID и находит внутренний ID пользователя
+func resolvePublicID(ctx context.Context, repo *repository.UserRepository, publicID string) (int, error) {
+	parsed, err := uuid.Parse(publicID)
+	if err != nil {
+		return 0, fmt.Errorf("%q: %w", publicID, ErrInvalidPublicID)
+	}
+
+	return repo.ResolvePublicID(ctx, parsed.String())
+}
+
+// idErrorStatus — HTTP-статус для ошибки parseIDFromRequest: 404 для неизвестного пользователя, иначе 400
+func idErrorStatus(err error) int {
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, ErrInvalidPublicID) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// parseResourceIDFromRequest извлекает из URL числовой {id} ресурса, который не является пользователем:
+// API-ключа, сессии, ключа доступа или приглашения
+func parseResourceIDFromRequest(r *http.Request) (int, error) {
+	vars := mux.Vars(r)
+	idStr, ok := vars["id"]
+	if !ok {
+		return 0, fmt.Errorf("ID не указан в ссылке")
 	}
 
 	id, err := strconv.Atoi(idStr)
@@ -687,11 +1003,13 @@ func parseIDFromRequest(r *http.Request) (int, error) {
 	return id, nil
 }
 
-func getAccessToken(user model.User) (string, error) {
+func getAccessToken(user model.User, sessionID int) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{ // только HEADER.PAYLOAD
-		"sub":   user.ID,
+		"sub":   user.PublicID,
+		"sid":   sessionID,
 		"email": user.Email,
 		"role":  user.Role,
+		"typ":   middleware.TokenTypeAccess,
 		"exp":   time.Now().Add(config.AccessTokenTTL).Unix(),
 	})
 
@@ -702,11 +1020,51 @@ func getAccessToken(user model.User) (string, error) {
 	return tokenString, nil
 }
 
-func getRefreshToken(user model.User) (string, error) {
+// getMFAToken создает короткоживущий токен, подтверждающий, что пароль уже проверен
+func getMFAToken(user model.User) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": user.PublicID,
+		"typ": middleware.TokenTypeMFA,
+		"exp": time.Now().Add(config.MFATokenTTL).Unix(),
+	})
+
+	tokenString, err := token.SignedString(config.JWTSecret)
+	if err != nil {
+		return "", err
+	}
+	return tokenString, nil
+}
+
+// parseToken проверяет подпись и срок действия токена и что его тип совпадает с ожидаемым
+func parseToken(tokenString string, typ string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		_, ok := token.Method.(*jwt.SigningMethodHMAC)
+		if !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return config.JWTSecret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid token: %w", err)
+	}
+
+	if claims["typ"] != typ {
+		return nil, fmt.Errorf("unexpected token type: %v", claims["typ"])
+	}
+	return claims, nil
+}
+
+// getRefreshToken создает refresh-токен сессии. jti меняется при каждом обновлении,
+// так сервер отличает последний токен семейства от уже использованных
+func getRefreshToken(user model.User, sessionID int, jti string) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"sub":   user.ID,
+		"sub":   user.PublicID,
+		"sid":   sessionID,
+		"jti":   jti,
 		"email": user.Email,
 		"role":  user.Role,
+		"typ":   middleware.TokenTypeRefresh,
 		"exp":   time.Now().Add(config.RefreshTokenTTL).Unix(),
 	})
 
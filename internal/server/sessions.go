@@ -0,0 +1,435 @@
+package server
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"strconv"
+	"strings"
+)
+
+// newTokenID генерирует случайный идентификатор refresh-токена (claim "jti")
+func newTokenID() (string, error) {
+	buf := make([]byte, 16)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// deviceFromUserAgent возвращает короткое описание устройства вида "Chrome on Windows".
+// Разбор грубый: нужен только для того, чтобы пользователь узнал свою сессию в списке
+func deviceFromUserAgent(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	browser := ""
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	case strings.Contains(ua, "go-http-client"):
+		browser = "Go client"
+	}
+
+	platform := ""
+	switch {
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		platform = "iOS"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os"):
+		platform = "macOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	default:
+		return "Unknown device"
+	}
+}
+
+// clearRefreshCookie удаляет refresh-токен из cookie клиента
+func clearRefreshCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "refresh-token",
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   -1,
+	})
+}
+
+// parseSessionIDFromRequest достаёт ID сессии из {session_id} в URL
+func parseSessionIDFromRequest(r *http.Request) (int, error) {
+	idStr, ok := mux.Vars(r)["session_id"]
+	if !ok {
+		return 0, fmt.Errorf("ID сессии не указан в ссылке")
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return 0, fmt.Errorf("ID сессии не является числом")
+	}
+	return id, nil
+}
+
+// RefreshHandler выдаёт новую пару токенов по refresh-токену из cookie.
+// @Summary Обновить токены
+// @Description Refresh-токен одноразовый: каждый раз выдаётся новый. Повторное использование старого токена отзывает всю сессию
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]string "Новый access-токен, refresh-токен — в cookie"
+// @Failure 401 {string} string "Нет refresh-токена, токен недействителен или сессия отозвана"
+// @Router /refresh [post]
+func RefreshHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		cookie, err := r.Cookie("refresh-token")
+		if err != nil {
+			ErrorHandler(w, r, err, "no refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := parseToken(cookie.Value, middleware.TokenTypeRefresh)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		sub, okSub := claims["sub"].(string)
+		sid, okSid := claims["sid"].(float64)
+		jti, okJti := claims["jti"].(string)
+		if !okSub || !okSid || !okJti {
+			ErrorHandler(w, r, fmt.Errorf("refresh token without session"), "invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		sessionID := int(sid)
+
+		userID, err := resolvePublicID(r.Context(), repo, sub)
+		if err != nil {
+			ErrorHandler(w, r, err, "user not found", http.StatusUnauthorized)
+			return
+		}
+
+		newJTI, err := newTokenID()
+		if err != nil {
+			ErrorHandler(w, r, err, "create token error", http.StatusInternalServerError)
+			return
+		}
+
+		rotated, err := repo.RotateSessionRefresh(r.Context(), sessionID, jti, newJTI, middleware.GetClientIP(r))
+		if err != nil {
+			ErrorHandler(w, r, err, "rotate refresh token error", http.StatusInternalServerError)
+			return
+		}
+
+		if !rotated {
+			// сессия жива, но токен уже был использован — его, вероятно, украли. Отзываем всё семейство
+			session, err := repo.GetSession(r.Context(), sessionID)
+			if err == nil && session.RevokedAt == nil && session.UserID == userID {
+				_ = repo.RevokeSession(r.Context(), session.UserID, session.ID)
+
+				log.Warn("refresh token reuse detected, session revoked",
+					zap.String("event", "RefreshTokenReuse"),
+					zap.Int("user.id", session.UserID),
+					zap.Int("session.id", session.ID),
+				)
+			}
+
+			clearRefreshCookie(w)
+			ErrorHandler(w, r, fmt.Errorf("session %d is not active or refresh token reused", sessionID), "invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+
+		// пользователь перечитывается из БД, чтобы новый access-токен содержал актуальную роль
+		user, err := repo.GetUserByID(r.Context(), userID)
+		if err != nil {
+			ErrorHandler(w, r, err, "user not found", http.StatusUnauthorized)
+			return
+		}
+
+		if !checkAccountActive(w, r, user) {
+			return
+		}
+
+		if !writeTokens(w, r, user, sessionID, newJTI) {
+			return
+		}
+
+		log.Info("tokens refreshed",
+			zap.String("event", "TokenRefreshed"),
+			zap.Int("user.id", user.ID),
+			zap.Int("session.id", sessionID),
+		)
+	}
+}
+
+// writeSessions отдаёт список сессий, отмечая текущую
+func writeSessions(w http.ResponseWriter, r *http.Request, sessions []model.Session) {
+	currentID, _ := middleware.GetSessionIDFromContext(r)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	writeResponse(w, r, http.StatusOK, sessions)
+}
+
+// ListMySessionsHandler возвращает активные сессии текущего пользователя.
+// @Summary Мои сессии
+// @Description Список устройств, на которых выполнен вход. Текущая сессия отмечена полем current
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} model.Session
+// @Failure 401 {string} string "Нет access-токена"
+// @Router /me/sessions [get]
+func ListMySessionsHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		userID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		sessions, err := repo.ListSessions(r.Context(), userID)
+		if err != nil {
+			ErrorHandler(w, r, err, "list sessions error", http.StatusInternalServerError)
+			return
+		}
+
+		writeSessions(w, r, sessions)
+	}
+}
+
+// RevokeMySessionHandler завершает одну из сессий текущего пользователя.
+// @Summary Выйти на устройстве
+// @Description Отзывает сессию: её access- и refresh-токены перестают действовать
+// @Tags me
+// @Security BearerAuth
+// @Param id path int true "ID сессии"
+// @Success 204 "Сессия отозвана"
+// @Failure 400 {string} string "Неверный ID"
+// @Failure 404 {string} string "Сессия не найдена"
+// @Router /me/sessions/{id} [delete]
+func RevokeMySessionHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		userID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+
+		sessionID, err := parseResourceIDFromRequest(r)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid session ID", http.StatusBadRequest)
+			return
+		}
+
+		err = repo.RevokeSession(r.Context(), userID, sessionID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ErrorHandler(w, r, err, "session not found", http.StatusNotFound)
+				return
+			}
+			ErrorHandler(w, r, err, "revoke session error", http.StatusInternalServerError)
+			return
+		}
+
+		currentID, _ := middleware.GetSessionIDFromContext(r)
+		if sessionID == currentID {
+			clearRefreshCookie(w)
+		}
+
+		middleware.LoggerFromContext(r.Context()).Info("session revoked by owner",
+			zap.String("event", "SessionRevoked"),
+			zap.Int("user.id", userID),
+			zap.Int("session.id", sessionID),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RevokeOtherSessionsHandler завершает все сессии текущего пользователя, кроме текущей.
+// @Summary Выйти на всех других устройствах
+// @Tags me
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.RevokeSessionsResponse
+// @Failure 401 {string} string "Нет access-токена"
+// @Router /me/sessions/revoke-others [post]
+func RevokeOtherSessionsHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		userID, ok := middleware.GetUserIDFromContext(r)
+		if !ok {
+			ErrorHandler(w, r, fmt.Errorf("ID did not send with context from middleware"), "no ID with context", http.StatusUnauthorized)
+			return
+		}
+		currentID, _ := middleware.GetSessionIDFromContext(r)
+
+		revoked, err := repo.RevokeOtherSessions(r.Context(), userID, currentID)
+		if err != nil {
+			ErrorHandler(w, r, err, "revoke sessions error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, model.RevokeSessionsResponse{Revoked: revoked})
+
+		log.Info("other sessions revoked by owner",
+			zap.String("event", "OtherSessionsRevoked"),
+			zap.Int("user.id", userID),
+			zap.Int64("revoked", revoked),
+		)
+	}
+}
+
+// ListUserSessionsHandler возвращает активные сессии любого пользователя.
+// @Summary Сессии пользователя
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Публичный ID пользователя (UUID)"
+// @Success 200 {array} model.Session
+// @Failure 400 {string} string "Неверный ID"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Router /admin/users/{id}/sessions [get]
+func ListUserSessionsHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		userID, err := parseIDFromRequest(r, repo)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid user ID", idErrorStatus(err))
+			return
+		}
+
+		sessions, err := repo.ListSessions(r.Context(), userID)
+		if err != nil {
+			ErrorHandler(w, r, err, "list sessions error", http.StatusInternalServerError)
+			return
+		}
+
+		writeSessions(w, r, sessions)
+	}
+}
+
+// RevokeUserSessionHandler завершает сессию любого пользователя.
+// @Summary Отозвать сессию пользователя
+// @Tags admin
+// @Security BearerAuth
+// @Param id path string true "Публичный ID пользователя (UUID)"
+// @Param session_id path int true "ID сессии"
+// @Success 204 "Сессия отозвана"
+// @Failure 400 {string} string "Неверный ID"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Failure 404 {string} string "Сессия не найдена"
+// @Router /admin/users/{id}/sessions/{session_id} [delete]
+func RevokeUserSessionHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		userID, err := parseIDFromRequest(r, repo)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid user ID", idErrorStatus(err))
+			return
+		}
+
+		sessionID, err := parseSessionIDFromRequest(r)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid session ID", http.StatusBadRequest)
+			return
+		}
+
+		err = repo.RevokeSession(r.Context(), userID, sessionID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				ErrorHandler(w, r, err, "session not found", http.StatusNotFound)
+				return
+			}
+			ErrorHandler(w, r, err, "revoke session error", http.StatusInternalServerError)
+			return
+		}
+
+		adminID, _ := middleware.GetUserIDFromContext(r)
+		middleware.LoggerFromContext(r.Context()).Warn("session revoked by admin",
+			zap.String("event", "SessionRevoked"),
+			zap.Int("user.id", userID),
+			zap.Int("session.id", sessionID),
+			zap.Int("admin.id", adminID),
+		)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RevokeUserSessionsHandler завершает все сессии пользователя.
+// @Summary Отозвать все сессии пользователя
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Публичный ID пользователя (UUID)"
+// @Success 200 {object} model.RevokeSessionsResponse
+// @Failure 400 {string} string "Неверный ID"
+// @Failure 403 {string} string "Недостаточно прав"
+// @Router /admin/users/{id}/sessions [delete]
+func RevokeUserSessionsHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		userID, err := parseIDFromRequest(r, repo)
+		if err != nil {
+			ErrorHandler(w, r, err, "invalid user ID", idErrorStatus(err))
+			return
+		}
+
+		revoked, err := repo.RevokeOtherSessions(r.Context(), userID, 0)
+		if err != nil {
+			ErrorHandler(w, r, err, "revoke sessions error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, model.RevokeSessionsResponse{Revoked: revoked})
+
+		adminID, _ := middleware.GetUserIDFromContext(r)
+		log.Warn("all sessions revoked by admin",
+			zap.String("event", "AllSessionsRevoked"),
+			zap.Int("user.id", userID),
+			zap.Int64("revoked", revoked),
+			zap.Int("admin.id", adminID),
+		)
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"pet/internal/service"
+	"slices"
+	"strings"
+)
+
+// TransferHandler переводит средства между пользователями.
+// @Summary Перевести средства
+// @Description Для пользователя с JWT отправитель — он сам. API-ключ указывает отправителя в from_id (публичный ID);
+// @Description для этого кроме transfers:write нужен скоуп transfers:any, который может выдать только администратор.
+// @Description Получатель задаётся в to — именем пользователя (@alice), e-mail или публичным ID — либо публичным ID в to_id
+// @Tags transfers
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param transfer body model.TransferRequest true "Получатель и сумма"
+// @Success 200 {object} map[string]string
+// @Failure 400 {string} string "Неверный JSON или ошибка валидации"
+// @Failure 403 {string} string "Нет скоупа transfers:write (или transfers:any для API-ключа) или аккаунт отправителя не активен"
+// @Failure 404 {object} map[string]any "Получатель не найден (или отправитель для API-ключа)"
+// @Failure 422 {string} string "Недостаточно средств или аккаунт получателя не активен"
+// @Failure 500 {string} string "Внутренняя ошибка сервера"
+// @Router /transfers [post]
+func TransferHandler(repo *repository.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		log := middleware.LoggerFromContext(r.Context())
+
+		var req model.TransferRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+
+		err := validate.Struct(req)
+		if err != nil {
+			ErrorHandler(w, r, err, "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		senderID, ok := middleware.GetUserIDFromContext(r)
+		if ok {
+			publicID, _ := middleware.GetPublicIDFromContext(r)
+			if req.FromID != "" && !strings.EqualFold(req.FromID, publicID) {
+				ErrorHandler(w, r, fmt.Errorf("from_id %s is not the current user", req.FromID), "access denied", http.StatusForbidden)
+				return
+			}
+		} else {
+			// запрос с API-ключом: отправитель задаётся явно, и списывать с чужого счёта можно только по отдельному скоупу
+			key, _ := middleware.GetAPIKeyFromContext(r)
+			if !slices.Contains(key.Scopes, middleware.PermTransfersAny) {
+				ErrorHandler(w, r, fmt.Errorf("api key %d has no %s scope", key.ID, middleware.PermTransfersAny), "access denied", http.StatusForbidden)
+				return
+			}
+			if req.FromID == "" {
+				ErrorHandler(w, r, fmt.Errorf("from_id is required for api keys"), "validation failed", http.StatusBadRequest)
+				return
+			}
+			senderID, err = resolvePublicID(r.Context(), repo, req.FromID)
+			if err != nil {
+				ErrorHandler(w, r, err, "sender not found", idErrorStatus(err))
+				return
+			}
+		}
+
+		recipient := req.To
+		if recipient == "" {
+			recipient = req.ToID
+		}
+		receiver, err := resolveUserRef(r.Context(), repo, recipient)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeError(w, r, err, http.StatusNotFound, "recipient not found", map[string]any{"recipient": recipient})
+				return
+			}
+			ErrorHandler(w, r, err, "resolve recipient error", http.StatusInternalServerError)
+			return
+		}
+		receiverID := receiver.ID
+
+		if senderID == receiverID {
+			ErrorHandler(w, r, fmt.Errorf("sender and receiver are the same"), "validation failed", http.StatusBadRequest)
+			return
+		}
+
+		srv := service.NewUserService(repo, log)
+		err = srv.TransferFunds(r.Context(), senderID, receiverID, req.Amount)
+		if err != nil {
+			if errors.Is(err, repository.ErrInsufficientFunds) {
+				ErrorHandler(w, r, err, "insufficient funds", http.StatusUnprocessableEntity)
+				return
+			}
+			var inactive *service.AccountInactiveError
+			if errors.As(err, &inactive) {
+				if inactive.UserID == senderID {
+					ErrorHandler(w, r, err, "account is not active", http.StatusForbidden)
+					return
+				}
+				ErrorHandler(w, r, err, "receiver account is not active", http.StatusUnprocessableEntity)
+				return
+			}
+			ErrorHandler(w, r, err, "transfer error", http.StatusInternalServerError)
+			return
+		}
+
+		writeResponse(w, r, http.StatusOK, map[string]string{"status": "ok"})
+
+		log.Info("transfer completed",
+			zap.String("event", "Transfer"),
+			zap.Int("sender.id", senderID),
+			zap.Int("receiver.id", receiverID),
+			zap.Float64("amount", req.Amount),
+		)
+	}
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/internal/model"
+	"pet/internal/tracing"
+	"slices"
+)
+
+// ErrInvalidStatusTransition — из текущего статуса нельзя перейти в запрошенный
+var ErrInvalidStatusTransition = errors.New("invalid account status transition")
+
+// statusTransitions — разрешённые переходы статуса аккаунта. closed — конечное состояние
+var statusTransitions = map[string][]string{
+	model.UserStatusPending:   {model.UserStatusActive, model.UserStatusClosed},
+	model.UserStatusActive:    {model.UserStatusSuspended, model.UserStatusLocked, model.UserStatusClosed},
+	model.UserStatusSuspended: {model.UserStatusActive, model.UserStatusClosed},
+	model.UserStatusLocked:    {model.UserStatusActive, model.UserStatusClosed},
+	model.UserStatusClosed:    {},
+}
+
+// CanTransition проверяет, разрешён ли переход статуса from → to
+func CanTransition(from string, to string) bool {
+	return slices.Contains(statusTransitions[from], to)
+}
+
+// AccountInactiveError — аккаунт не в статусе active, поэтому действие запрещено
+type AccountInactiveError struct {
+	UserID int
+	Status string
+}
+
+func (e *AccountInactiveError) Error() string {
+	return fmt.Sprintf("account %d is %s", e.UserID, e.Status)
+}
+
+// CheckActive возвращает *AccountInactiveError, если аккаунт не активен
+func CheckActive(user model.User) error {
+	if user.Status != model.UserStatusActive {
+		return &AccountInactiveError{UserID: user.ID, Status: user.Status}
+	}
+	return nil
+}
+
+// AccountStatusRepository определяет хранилище, нужное для смены статуса
+type AccountStatusRepository interface {
+	GetUserByID(ctx context.Context, id int) (model.User, error)
+	SetUserStatus(ctx context.Context, id int, from string, to string, reason string, actorID int) error
+	RevokeOtherSessions(ctx context.Context, userID int, exceptID int) (int64, error)
+}
+
+// AccountStatusService меняет статус аккаунта по правилам statusTransitions
+type AccountStatusService struct {
+	repo AccountStatusRepository
+	log  *zap.Logger
+}
+
+// NewAccountStatusService создаёт AccountStatusService
+func NewAccountStatusService(repo AccountStatusRepository, logger *zap.Logger) *AccountStatusService {
+	return &AccountStatusService{
+		repo: repo,
+		log:  logger,
+	}
+}
+
+// ChangeStatus переводит аккаунт в статус to. Недопустимый переход — ErrInvalidStatusTransition.
+// При уходе из active все сессии пользователя отзываются, чтобы выданные токены перестали действовать сразу
+func (s *AccountStatusService) ChangeStatus(ctx context.Context, userID int, to string, reason string, actorID int) (_ model.User, err error) {
+	ctx, span := tracing.Start(ctx, "AccountStatusService.ChangeStatus")
+	defer tracing.End(span, &err)
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.AccountStatusService.ChangeStatus: %w", op, err)
+	}
+
+	if !CanTransition(user.Status, to) {
+		return model.User{}, fmt.Errorf("%s.AccountStatusService.ChangeStatus: %s -> %s: %w",
+			op, user.Status, to, ErrInvalidStatusTransition)
+	}
+
+	err = s.repo.SetUserStatus(ctx, userID, user.Status, to, reason, actorID)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.AccountStatusService.ChangeStatus: %w", op, err)
+	}
+
+	if to != model.UserStatusActive {
+		revoked, err := s.repo.RevokeOtherSessions(ctx, userID, 0)
+		if err != nil {
+			return model.User{}, fmt.Errorf("%s.AccountStatusService.ChangeStatus: %w", op, err)
+		}
+
+		s.log.Info("sessions revoked on status change",
+			zap.Int("user.id", userID),
+			zap.Int64("sessions.revoked", revoked),
+			zap.String("component", "service"),
+			zap.String("event", "UserStatusChanged"))
+	}
+
+	s.log.Info("user status changed",
+		zap.Int("user.id", userID),
+		zap.Int("actor.id", actorID),
+		zap.String("status.from", user.Status),
+		zap.String("status.to", to),
+		zap.String("reason", reason),
+		zap.String("component", "service"),
+		zap.String("event", "UserStatusChanged"))
+
+	user.Status = to
+	user.HashedPassword = ""
+	return user, nil
+}
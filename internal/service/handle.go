@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/config"
+	"pet/internal/model"
+	"pet/internal/tracing"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrHandleInvalid — имя пользователя не соответствует формату
+var ErrHandleInvalid = errors.New("invalid handle")
+
+// HandleError — имя не прошло проверку. Reason показывается клиенту
+type HandleError struct {
+	Reason string
+}
+
+func (e *HandleError) Error() string {
+	return ErrHandleInvalid.Error() + ": " + e.Reason
+}
+
+func (e *HandleError) Unwrap() error {
+	return ErrHandleInvalid
+}
+
+// handlePattern — латиница, цифры и подчёркивание, начинается с буквы, от 3 до 30 символов
+var handlePattern = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{2,29}$`)
+
+// reservedHandles — имена, которые легко спутать с системными адресами и службой поддержки
+var reservedHandles = []string{
+	"admin", "administrator", "root", "system", "support", "help", "api", "me",
+	"login", "logout", "register", "users", "null", "undefined", "security",
+}
+
+// generatedHandlePrefix — префикс имён, которые выдаются автоматически; вручную его занять нельзя
+const generatedHandlePrefix = "user_"
+
+// NormalizeHandle убирает пробелы по краям и ведущий "@", регистр сохраняется
+func NormalizeHandle(handle string) string {
+	return strings.TrimPrefix(strings.TrimSpace(handle), "@")
+}
+
+// ValidateHandle проверяет формат имени. Возвращает *HandleError с причиной
+func ValidateHandle(handle string) error {
+	if !handlePattern.MatchString(handle) {
+		return &HandleError{Reason: "must be 3-30 latin letters, digits or underscores, starting with a letter"}
+	}
+
+	lower := strings.ToLower(handle)
+	if slices.Contains(reservedHandles, lower) || strings.HasPrefix(lower, generatedHandlePrefix) {
+		return &HandleError{Reason: "handle is reserved"}
+	}
+	return nil
+}
+
+// HandleRepository определяет хранилище, нужное для смены имени
+type HandleRepository interface {
+	ChangeHandle(ctx context.Context, userID int, handle string, reservedUntil time.Time) (model.User, error)
+}
+
+// HandleService меняет имя пользователя, закрепляя старое за ним на config.HandleReservationTTL
+type HandleService struct {
+	repo HandleRepository
+	log  *zap.Logger
+}
+
+// NewHandleService создаёт HandleService
+func NewHandleService(repo HandleRepository, logger *zap.Logger) *HandleService {
+	return &HandleService{
+		repo: repo,
+		log:  logger,
+	}
+}
+
+// Change проверяет и устанавливает новое имя. Неверный формат — *HandleError,
+// занятое имя — repository.ErrHandleTaken
+func (s *HandleService) Change(ctx context.Context, userID int, handle string) (_ model.User, err error) {
+	ctx, span := tracing.Start(ctx, "HandleService.Change")
+	defer tracing.End(span, &err)
+
+	handle = NormalizeHandle(handle)
+	err = ValidateHandle(handle)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.HandleService.Change: %w", op, err)
+	}
+
+	user, err := s.repo.ChangeHandle(ctx, userID, handle, time.Now().Add(config.HandleReservationTTL))
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.HandleService.Change: %w", op, err)
+	}
+
+	s.log.Info("user handle changed",
+		zap.Int("user.id", userID),
+		zap.String("user.handle", user.Handle),
+		zap.String("component", "service"),
+		zap.String("event", "HandleChanged"))
+
+	return user, nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"net/url"
+	"pet/config"
+	"pet/internal/mailer"
+	"pet/internal/metrics"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"pet/internal/tracing"
+	"time"
+)
+
+// ErrInvitationInvalid — приглашения нет, оно принято, отозвано или истекло
+var ErrInvitationInvalid = errors.New("invitation is invalid, expired or already used")
+
+// InvitationRepository определяет хранилище, нужное для приглашений
+type InvitationRepository interface {
+	GetUserByEmail(ctx context.Context, email string) (model.User, error)
+	CreateInvitation(ctx context.Context, invitation model.Invitation) (model.Invitation, error)
+	GetPendingInvitation(ctx context.Context, tokenHash string) (model.Invitation, error)
+	AcceptInvitation(ctx context.Context, tokenHash string, user model.User) (model.User, error)
+}
+
+// InvitationService выдаёт приглашения и регистрирует по ним пользователей
+type InvitationService struct {
+	repo   InvitationRepository
+	mailer mailer.Mailer
+	log    *zap.Logger
+}
+
+// NewInvitationService создаёт InvitationService
+func NewInvitationService(repo InvitationRepository, m mailer.Mailer, logger *zap.Logger) *InvitationService {
+	return &InvitationService{
+		repo:   repo,
+		mailer: m,
+		log:    logger,
+	}
+}
+
+// hashInvitationToken — в БД хранится только SHA-256 токена
+func hashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Invite создаёт приглашение с ролью role и отправляет ссылку на e-mail.
+// Если e-mail уже зарегистрирован — repository.ErrEmailTaken
+func (s *InvitationService) Invite(ctx context.Context, email string, role string, invitedBy int) (_ model.Invitation, err error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.Invite")
+	defer tracing.End(span, &err)
+
+	_, err = s.repo.GetUserByEmail(ctx, email)
+	if err == nil {
+		return model.Invitation{}, fmt.Errorf("%s.InvitationService.Invite: %w", op, repository.ErrEmailTaken)
+	}
+
+	tokenBytes := make([]byte, 32)
+	_, err = rand.Read(tokenBytes)
+	if err != nil {
+		return model.Invitation{}, fmt.Errorf("%s.InvitationService.Invite: %w", op, err)
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	invitation, err := s.repo.CreateInvitation(ctx, model.Invitation{
+		Email:     email,
+		Role:      role,
+		InvitedBy: &invitedBy,
+		ExpiresAt: time.Now().Add(config.InvitationTTL),
+		TokenHash: hashInvitationToken(token),
+	})
+	if err != nil {
+		return model.Invitation{}, fmt.Errorf("%s.InvitationService.Invite: %w", op, err)
+	}
+
+	link := config.InvitationURL + "?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Приглашение в Users API",
+		Body: "Вас пригласили в Users API. Чтобы создать аккаунт и задать пароль, откройте ссылку:\n\n" + link + "\n\n" +
+			"Ссылка действует до " + invitation.ExpiresAt.Format("02.01.2006 15:04 MST") + ".\n" +
+			"Если вы не ждали приглашения, просто проигнорируйте это письмо.\n",
+	})
+	if err != nil {
+		return model.Invitation{}, fmt.Errorf("%s.InvitationService.Invite: %w", op, err)
+	}
+
+	s.log.Info("invitation sent",
+		zap.Int("invitation.id", invitation.ID),
+		zap.String("invitation.role", role),
+		zap.Int("actor.id", invitedBy),
+		zap.Time("expires_at", invitation.ExpiresAt),
+		zap.String("component", "service"),
+		zap.String("event", "InvitationSent"))
+
+	return invitation, nil
+}
+
+// Lookup возвращает действующее приглашение по токену из ссылки
+func (s *InvitationService) Lookup(ctx context.Context, token string) (_ model.Invitation, err error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.Lookup")
+	defer tracing.End(span, &err)
+
+	invitation, err := s.repo.GetPendingInvitation(ctx, hashInvitationToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.Invitation{}, fmt.Errorf("%s.InvitationService.Lookup: %w", op, ErrInvitationInvalid)
+		}
+		return model.Invitation{}, fmt.Errorf("%s.InvitationService.Lookup: %w", op, err)
+	}
+	return invitation, nil
+}
+
+// Accept создаёт пользователя по приглашению. Пароль уже должен быть проверен политикой и захеширован
+func (s *InvitationService) Accept(ctx context.Context, token string, user model.User) (_ model.User, err error) {
+	ctx, span := tracing.Start(ctx, "InvitationService.Accept")
+	defer tracing.End(span, &err)
+
+	created, err := s.repo.AcceptInvitation(ctx, hashInvitationToken(token), user)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.User{}, fmt.Errorf("%s.InvitationService.Accept: %w", op, ErrInvitationInvalid)
+		}
+		return model.User{}, fmt.Errorf("%s.InvitationService.Accept: %w", op, err)
+	}
+
+	metrics.Registrations.WithLabelValues("invitation").Inc()
+
+	s.log.Info("invitation accepted",
+		zap.Int("user.id", created.ID),
+		zap.String("user.role", created.Role),
+		zap.String("component", "service"),
+		zap.String("event", "InvitationAccepted"))
+
+	return created, nil
+}
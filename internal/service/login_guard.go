@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"pet/config"
+	"pet/internal/model"
+	"pet/internal/tracing"
+	"strings"
+	"time"
+)
+
+// LoginThrottleRepository определяет хранилище счётчиков неудачных входов
+type LoginThrottleRepository interface {
+	GetLoginThrottle(ctx context.Context, key string) (model.LoginThrottle, error)
+	IncrementLoginFailures(ctx context.Context, key string, window time.Duration) (int, error)
+	BlockLogin(ctx context.Context, key string, blockedUntil time.Time, locked bool) error
+	ResetLoginThrottle(ctx context.Context, key string) (bool, error)
+	RecordLoginFailureIP(ctx context.Context, emailKey string, ipKey string, window time.Duration) error
+	TakeLoginFailureIPs(ctx context.Context, emailKey string, window time.Duration) ([]string, error)
+}
+
+// LoginThrottledError — попытки входа временно запрещены. RetryAfter — сколько осталось ждать
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("login throttled, retry after %s", e.RetryAfter)
+}
+
+// throttlePolicy — после backoffAfter неудач включается экспоненциальная задержка,
+// после lockAfter неудач — блокировка на config.LoginLockoutDuration
+type throttlePolicy struct {
+	backoffAfter int
+	lockAfter    int
+}
+
+// LoginGuard защищает вход от перебора паролей.
+// Неудачи считаются отдельно по e-mail (аккаунту) и по IP. Для несуществующих e-mail
+// счётчики ведутся так же, поэтому ответы не позволяют узнать, зарегистрирован ли e-mail.
+type LoginGuard struct {
+	repo LoginThrottleRepository
+	log  *zap.Logger
+}
+
+// NewLoginGuard создаёт LoginGuard поверх хранилища счётчиков
+func NewLoginGuard(repo LoginThrottleRepository, logger *zap.Logger) *LoginGuard {
+	return &LoginGuard{
+		repo: repo,
+		log:  logger,
+	}
+}
+
+// EmailThrottleKey возвращает ключ счётчика для e-mail (регистр и пробелы не учитываются)
+func EmailThrottleKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (g *LoginGuard) policy(key string) throttlePolicy {
+	if strings.HasPrefix(key, "ip:") {
+		return throttlePolicy{backoffAfter: config.LoginMaxFailuresPerIP / 2, lockAfter: config.LoginMaxFailuresPerIP}
+	}
+	return throttlePolicy{backoffAfter: config.LoginBackoffAfter, lockAfter: config.LoginMaxFailures}
+}
+
+// Check проверяет, можно ли сейчас пытаться войти. Возвращает *LoginThrottledError, если нельзя
+func (g *LoginGuard) Check(ctx context.Context, email string, ip string) (err error) {
+	ctx, span := tracing.Start(ctx, "LoginGuard.Check")
+	defer tracing.End(span, &err)
+
+	var retryAfter time.Duration
+
+	for _, key := range []string{EmailThrottleKey(email), ipThrottleKey(ip)} {
+		throttle, err := g.repo.GetLoginThrottle(ctx, key)
+		if err != nil {
+			return fmt.Errorf("%s.LoginGuard.Check: %w", op, err)
+		}
+
+		if throttle.BlockedUntil != nil {
+			wait := time.Until(*throttle.BlockedUntil)
+			if wait > retryAfter {
+				retryAfter = wait
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LoginThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RegisterFailure учитывает неудачную попытку входа и при необходимости включает задержку или блокировку
+func (g *LoginGuard) RegisterFailure(ctx context.Context, email string, ip string) (err error) {
+	ctx, span := tracing.Start(ctx, "LoginGuard.RegisterFailure")
+	defer tracing.End(span, &err)
+
+	// IP запоминается за аккаунтом, чтобы Unlock мог сбросить и его счётчик
+	err = g.repo.RecordLoginFailureIP(ctx, EmailThrottleKey(email), ipThrottleKey(ip), config.LoginLockoutDuration)
+	if err != nil {
+		return fmt.Errorf("%s.LoginGuard.RegisterFailure: %w", op, err)
+	}
+
+	for _, key := range []string{EmailThrottleKey(email), ipThrottleKey(ip)} {
+		failures, err := g.repo.IncrementLoginFailures(ctx, key, config.LoginLockoutDuration)
+		if err != nil {
+			return fmt.Errorf("%s.LoginGuard.RegisterFailure: %w", op, err)
+		}
+
+		policy := g.policy(key)
+
+		switch {
+		case failures >= policy.lockAfter:
+			until := time.Now().Add(config.LoginLockoutDuration)
+			err = g.repo.BlockLogin(ctx, key, until, true)
+			if err != nil {
+				return fmt.Errorf("%s.LoginGuard.RegisterFailure: %w", op, err)
+			}
+
+			// пишем событие только в момент блокировки, а не на каждую следующую попытку
+			if failures == policy.lockAfter {
+				g.log.Warn("login locked",
+					zap.String("key", key),
+					zap.Int("failures", failures),
+					zap.Time("locked_until", until),
+					zap.String("component", "service"),
+					zap.String("event", "LoginLocked"))
+			}
+
+		case failures >= policy.backoffAfter:
+			err = g.repo.BlockLogin(ctx, key, time.Now().Add(backoffDelay(failures-policy.backoffAfter)), false)
+			if err != nil {
+				return fmt.Errorf("%s.LoginGuard.RegisterFailure: %w", op, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RegisterSuccess сбрасывает счётчик аккаунта после успешного входа.
+// Счётчик IP не сбрасывается: иначе перебор чужих паролей можно чередовать со входом в свой аккаунт.
+func (g *LoginGuard) RegisterSuccess(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "LoginGuard.RegisterSuccess")
+	defer tracing.End(span, &err)
+
+	_, err = g.repo.ResetLoginThrottle(ctx, EmailThrottleKey(email))
+	if err != nil {
+		return fmt.Errorf("%s.LoginGuard.RegisterSuccess: %w", op, err)
+	}
+	return nil
+}
+
+// Unlock снимает блокировку входа для e-mail по решению администратора. Сбрасываются счётчик аккаунта
+// и счётчики IP, с которых в него пытались войти за config.LoginLockoutDuration: иначе пользователь
+// остался бы заблокирован в своей сети
+func (g *LoginGuard) Unlock(ctx context.Context, email string, adminID int) (err error) {
+	ctx, span := tracing.Start(ctx, "LoginGuard.Unlock")
+	defer tracing.End(span, &err)
+
+	key := EmailThrottleKey(email)
+
+	wasLocked, err := g.repo.ResetLoginThrottle(ctx, key)
+	if err != nil {
+		return fmt.Errorf("%s.LoginGuard.Unlock: %w", op, err)
+	}
+
+	ipKeys, err := g.repo.TakeLoginFailureIPs(ctx, key, config.LoginLockoutDuration)
+	if err != nil {
+		return fmt.Errorf("%s.LoginGuard.Unlock: %w", op, err)
+	}
+
+	for _, ipKey := range ipKeys {
+		_, err = g.repo.ResetLoginThrottle(ctx, ipKey)
+		if err != nil {
+			return fmt.Errorf("%s.LoginGuard.Unlock: %w", op, err)
+		}
+	}
+
+	g.log.Warn("login unlocked",
+		zap.String("key", key),
+		zap.Bool("was_locked", wasLocked),
+		zap.Strings("ip_keys", ipKeys),
+		zap.Int("admin.id", adminID),
+		zap.String("component", "service"),
+		zap.String("event", "LoginUnlocked"))
+
+	return nil
+}
+
+// backoffDelay — экспоненциальная задержка: base, 2*base, 4*base ... но не больше config.LoginBackoffMax
+func backoffDelay(step int) time.Duration {
+	delay := config.LoginBackoffBase
+	for i := 0; i < step && delay < config.LoginBackoffMax; i++ {
+		delay *= 2
+	}
+	return min(delay, config.LoginBackoffMax)
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+	"net/url"
+	"pet/config"
+	"pet/internal/mailer"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/tracing"
+	"strings"
+	"time"
+)
+
+// ErrMagicLinkInvalid — ссылка подделана, истекла или уже использована
+var ErrMagicLinkInvalid = errors.New("magic link is invalid, expired or already used")
+
+// MagicLinkRepository определяет хранилище, нужное для входа по ссылке
+type MagicLinkRepository interface {
+	RecordMagicLinkRequest(ctx context.Context, email string, limit int, window time.Duration) (bool, time.Duration, error)
+	GetUserByID(ctx context.Context, id int) (model.User, error)
+	GetUserByEmail(ctx context.Context, email string) (model.User, error)
+	ResolvePublicID(ctx context.Context, publicID string) (int, error)
+	CreateMagicLink(ctx context.Context, jti string, userID int, expiresAt time.Time) error
+	UseMagicLink(ctx context.Context, jti string, userID int) (bool, error)
+}
+
+// MagicLinkService выдаёт и гасит одноразовые ссылки для входа без пароля
+type MagicLinkService struct {
+	repo   MagicLinkRepository
+	mailer mailer.Mailer
+	log    *zap.Logger
+}
+
+// NewMagicLinkService создаёт MagicLinkService
+func NewMagicLinkService(repo MagicLinkRepository, m mailer.Mailer, logger *zap.Logger) *MagicLinkService {
+	return &MagicLinkService{
+		repo:   repo,
+		mailer: m,
+		log:    logger,
+	}
+}
+
+// magicLinkSendTimeout ограничивает отправку письма, которая идёт уже после ответа клиенту
+const magicLinkSendTimeout = 30 * time.Second
+
+// Send принимает запрос ссылки для входа на e-mail. Число запросов на один e-mail ограничено
+// (config.MagicLinkMaxPerWindow за config.MagicLinkWindow): при превышении возвращается *LoginThrottledError.
+// Сама ссылка создаётся и отправляется в фоне, в том числе поиск пользователя: ни ответ, ни время ответа
+// не выдают, есть ли аккаунт. Для незарегистрированного e-mail письмо не отправляется
+func (s *MagicLinkService) Send(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "MagicLinkService.Send")
+	defer tracing.End(span, &err)
+
+	key := strings.ToLower(strings.TrimSpace(email))
+
+	allowed, retryAfter, err := s.repo.RecordMagicLinkRequest(ctx, key, config.MagicLinkMaxPerWindow, config.MagicLinkWindow)
+	if err != nil {
+		return fmt.Errorf("%s.MagicLinkService.Send: %w", op, err)
+	}
+	if !allowed {
+		return &LoginThrottledError{RetryAfter: retryAfter}
+	}
+
+	// запрос клиента к этому моменту уже завершён, поэтому контекст без отмены, но со своим сроком
+	sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), magicLinkSendTimeout)
+	go func() {
+		defer cancel()
+
+		err := s.deliver(sendCtx, email)
+		if err != nil {
+			s.log.Error("failed to send magic link",
+				zap.Error(err),
+				zap.String("component", "service"),
+				zap.String("event", "MagicLinkSent"))
+		}
+	}()
+	return nil
+}
+
+// deliver создаёт ссылку для пользователя с e-mail email и отправляет её письмом
+func (s *MagicLinkService) deliver(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "MagicLinkService.deliver")
+	defer tracing.End(span, &err)
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("%s.MagicLinkService.deliver: %w", op, err)
+		}
+		s.log.Info("magic link requested for unknown email",
+			zap.String("component", "service"),
+			zap.String("event", "MagicLinkRequested"))
+		return nil
+	}
+
+	jtiBytes := make([]byte, 16)
+	_, err = rand.Read(jtiBytes)
+	if err != nil {
+		return fmt.Errorf("%s.MagicLinkService.deliver: %w", op, err)
+	}
+	jti := hex.EncodeToString(jtiBytes)
+	expiresAt := time.Now().Add(config.MagicLinkTTL)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": user.PublicID,
+		"jti": jti,
+		"typ": middleware.TokenTypeMagic,
+		"exp": expiresAt.Unix(),
+	}).SignedString(config.JWTSecret)
+	if err != nil {
+		return fmt.Errorf("%s.MagicLinkService.deliver: %w", op, err)
+	}
+
+	err = s.repo.CreateMagicLink(ctx, jti, user.ID, expiresAt)
+	if err != nil {
+		return fmt.Errorf("%s.MagicLinkService.deliver: %w", op, err)
+	}
+
+	link := config.MagicLinkURL + "?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Вход в Users API",
+		Body: "Чтобы войти, откройте ссылку:\n\n" + link + "\n\n" +
+			"Ссылка действует " + config.MagicLinkTTL.String() + " и сработает только один раз.\n" +
+			"Если вы не запрашивали вход, просто проигнорируйте это письмо.\n",
+	})
+	if err != nil {
+		return fmt.Errorf("%s.MagicLinkService.deliver: %w", op, err)
+	}
+
+	s.log.Info("magic link sent",
+		zap.Int("user.id", user.ID),
+		zap.Time("expires_at", expiresAt),
+		zap.String("component", "service"),
+		zap.String("event", "MagicLinkSent"))
+
+	return nil
+}
+
+// Redeem проверяет подпись и срок токена из ссылки, гасит ссылку и возвращает пользователя
+func (s *MagicLinkService) Redeem(ctx context.Context, tokenString string) (_ model.User, err error) {
+	ctx, span := tracing.Start(ctx, "MagicLinkService.Redeem")
+	defer tracing.End(span, &err)
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		_, ok := token.Method.(*jwt.SigningMethodHMAC)
+		if !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return config.JWTSecret, nil
+	})
+	if err != nil || !token.Valid || claims["typ"] != middleware.TokenTypeMagic {
+		return model.User{}, fmt.Errorf("%s.MagicLinkService.Redeem: %w", op, ErrMagicLinkInvalid)
+	}
+
+	sub, okSub := claims["sub"].(string)
+	jti, okJti := claims["jti"].(string)
+	if !okSub || !okJti {
+		return model.User{}, fmt.Errorf("%s.MagicLinkService.Redeem: %w", op, ErrMagicLinkInvalid)
+	}
+
+	userID, err := s.repo.ResolvePublicID(ctx, sub)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return model.User{}, fmt.Errorf("%s.MagicLinkService.Redeem: %w", op, ErrMagicLinkInvalid)
+		}
+		return model.User{}, fmt.Errorf("%s.MagicLinkService.Redeem: %w", op, err)
+	}
+
+	used, err := s.repo.UseMagicLink(ctx, jti, userID)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.MagicLinkService.Redeem: %w", op, err)
+	}
+	if !used {
+		return model.User{}, fmt.Errorf("%s.MagicLinkService.Redeem: %w", op, ErrMagicLinkInvalid)
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.MagicLinkService.Redeem: %w", op, err)
+	}
+	return user, nil
+}
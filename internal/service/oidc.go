@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"pet/config"
+	"pet/internal/metrics"
+	"pet/internal/model"
+	"pet/internal/tracing"
+	"strings"
+)
+
+var (
+	// ErrIdentityNotLinked — внешняя учётная запись ни к кому не привязана, а автосоздание выключено
+	ErrIdentityNotLinked = errors.New("external identity is not linked to any user")
+	// ErrIdentityEmailTaken — e-mail уже занят, но провайдер не подтвердил его, поэтому привязать нельзя
+	ErrIdentityEmailTaken = errors.New("email is taken and not verified by the provider")
+)
+
+// OIDCProvider — внешний провайдер входа по OpenID Connect (authorization code + PKCE)
+type OIDCProvider struct {
+	Name          string
+	AutoProvision bool
+	oauth         oauth2.Config
+	verifier      *oidc.IDTokenVerifier
+}
+
+// NewOIDCProvider загружает discovery-документ провайдера и готовит проверку его ID-токенов
+func NewOIDCProvider(ctx context.Context, cfg config.OIDCProviderConfig) (*OIDCProvider, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("%s.NewOIDCProvider: discovery %s: %w", op, cfg.IssuerURL, err)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	return &OIDCProvider{
+		Name:          cfg.Name,
+		AutoProvision: cfg.AutoProvision,
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+	}, nil
+}
+
+// AuthCodeURL возвращает адрес страницы входа провайдера
+func (p *OIDCProvider) AuthCodeURL(state string, nonce string, verifier string) string {
+	return p.oauth.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// Exchange обменивает код авторизации на токены, проверяет ID-токен (подпись, issuer, audience, срок, nonce)
+// и возвращает данные пользователя из него
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, verifier string, nonce string) (model.ExternalIdentity, error) {
+	token, err := p.oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return model.ExternalIdentity{}, fmt.Errorf("%s.OIDCProvider.Exchange: %w", op, err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return model.ExternalIdentity{}, fmt.Errorf("%s.OIDCProvider.Exchange: no id_token in token response", op)
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return model.ExternalIdentity{}, fmt.Errorf("%s.OIDCProvider.Exchange: %w", op, err)
+	}
+
+	if idToken.Nonce != nonce {
+		return model.ExternalIdentity{}, fmt.Errorf("%s.OIDCProvider.Exchange: nonce mismatch", op)
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	err = idToken.Claims(&claims)
+	if err != nil {
+		return model.ExternalIdentity{}, fmt.Errorf("%s.OIDCProvider.Exchange: %w", op, err)
+	}
+
+	return model.ExternalIdentity{
+		Provider:      p.Name,
+		Subject:       idToken.Subject,
+		Email:         strings.ToLower(claims.Email),
+		EmailVerified: claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
+
+// IdentityRepository определяет хранилище привязок внешних учётных записей
+type IdentityRepository interface {
+	GetUserByIdentity(ctx context.Context, provider string, subject string) (model.User, error)
+	GetUserByEmail(ctx context.Context, email string) (model.User, error)
+	LinkIdentity(ctx context.Context, userID int, identity model.ExternalIdentity) error
+	CreateUserWithIdentity(ctx context.Context, user model.User, identity model.ExternalIdentity) (model.User, error)
+}
+
+// IdentityService находит или создаёт пользователя для внешней учётной записи
+type IdentityService struct {
+	repo IdentityRepository
+	log  *zap.Logger
+}
+
+// NewIdentityService создаёт IdentityService
+func NewIdentityService(repo IdentityRepository, logger *zap.Logger) *IdentityService {
+	return &IdentityService{
+		repo: repo,
+		log:  logger,
+	}
+}
+
+// Resolve возвращает пользователя для внешней учётной записи:
+//   - уже привязанную запись — её пользователя;
+//   - подтверждённый провайдером e-mail существующего пользователя — привязывает к нему;
+//   - иначе, если autoProvision, создаёт нового пользователя (just-in-time provisioning).
+func (s *IdentityService) Resolve(ctx context.Context, identity model.ExternalIdentity, autoProvision bool) (_ model.User, err error) {
+	ctx, span := tracing.Start(ctx, "IdentityService.Resolve")
+	defer tracing.End(span, &err)
+
+	user, err := s.repo.GetUserByIdentity(ctx, identity.Provider, identity.Subject)
+	if err == nil {
+		err = s.repo.LinkIdentity(ctx, user.ID, identity) // обновляет last_login_at
+		if err != nil {
+			return model.User{}, fmt.Errorf("%s.IdentityService.Resolve: %w", op, err)
+		}
+		return user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return model.User{}, fmt.Errorf("%s.IdentityService.Resolve: %w", op, err)
+	}
+
+	if identity.Email != "" {
+		existing, err := s.repo.GetUserByEmail(ctx, identity.Email)
+		if err == nil {
+			if !identity.EmailVerified {
+				return model.User{}, fmt.Errorf("%s.IdentityService.Resolve: %w", op, ErrIdentityEmailTaken)
+			}
+
+			err = s.repo.LinkIdentity(ctx, existing.ID, identity)
+			if err != nil {
+				return model.User{}, fmt.Errorf("%s.IdentityService.Resolve: %w", op, err)
+			}
+
+			s.log.Info("external identity linked",
+				zap.String("identity.provider", identity.Provider),
+				zap.Int("user.id", existing.ID),
+				zap.String("component", "service"),
+				zap.String("event", "IdentityLinked"))
+
+			return existing, nil
+		}
+		// любая ошибка, кроме «не найден», — не повод создавать ещё одного пользователя с тем же e-mail
+		if !errors.Is(err, sql.ErrNoRows) {
+			return model.User{}, fmt.Errorf("%s.IdentityService.Resolve: %w", op, err)
+		}
+	}
+
+	if !autoProvision || identity.Email == "" {
+		return model.User{}, fmt.Errorf("%s.IdentityService.Resolve: %w", op, ErrIdentityNotLinked)
+	}
+
+	name := identity.Name
+	if name == "" {
+		name, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	user, err = s.repo.CreateUserWithIdentity(ctx, model.User{Name: name, Email: identity.Email}, identity)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.IdentityService.Resolve: %w", op, err)
+	}
+
+	metrics.Registrations.WithLabelValues("oidc").Inc()
+
+	s.log.Info("user provisioned from external identity",
+		zap.String("identity.provider", identity.Provider),
+		zap.Int("user.id", user.ID),
+		zap.String("component", "service"),
+		zap.String("event", "UserProvisioned"))
+
+	return user, nil
+}
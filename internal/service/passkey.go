@@ -0,0 +1,370 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"pet/config"
+	"pet/internal/model"
+	"pet/internal/tracing"
+	"strconv"
+	"time"
+)
+
+// виды церемоний WebAuthn
+const (
+	passkeyCeremonyRegister = "register"
+	passkeyCeremonyLogin    = "login"
+)
+
+var (
+	// ErrPasskeyCeremonyInvalid — церемонии нет, она истекла или уже завершена
+	ErrPasskeyCeremonyInvalid = errors.New("passkey ceremony not found or expired")
+	// ErrPasskeyInvalid — ответ аутентификатора не прошёл проверку
+	ErrPasskeyInvalid = errors.New("passkey verification failed")
+	// ErrPasskeyCloned — счётчик подписей не вырос: похоже, ключ скопирован
+	ErrPasskeyCloned = errors.New("passkey sign counter did not increase")
+)
+
+// PasskeyRepository определяет хранилище, нужное для ключей доступа
+type PasskeyRepository interface {
+	GetUserByID(ctx context.Context, id int) (model.User, error)
+	ResolvePublicID(ctx context.Context, publicID string) (int, error)
+	CreatePasskey(ctx context.Context, passkey model.Passkey) (model.Passkey, error)
+	ListPasskeys(ctx context.Context, userID int) ([]model.Passkey, error)
+	UpdatePasskeyUsage(ctx context.Context, id int, signCount uint32, backupState bool) error
+	SavePasskeyCeremony(ctx context.Context, id string, kind string, userID int, data []byte, expiresAt time.Time) error
+	TakePasskeyCeremony(ctx context.Context, id string, kind string, userID int) ([]byte, bool, error)
+}
+
+// PasskeyService проводит церемонии WebAuthn: регистрацию ключа и вход по нему
+type PasskeyService struct {
+	repo PasskeyRepository
+	log  *zap.Logger
+}
+
+// NewPasskeyService создаёт PasskeyService
+func NewPasskeyService(repo PasskeyRepository, logger *zap.Logger) *PasskeyService {
+	return &PasskeyService{
+		repo: repo,
+		log:  logger,
+	}
+}
+
+// passkeyUser — пользователь вместе с ключами в виде, который ожидает библиотека webauthn
+type passkeyUser struct {
+	user     model.User
+	passkeys []model.Passkey
+}
+
+// WebAuthnID — user handle. Это публичный ID пользователя: он не раскрывает e-mail и не меняется
+func (u passkeyUser) WebAuthnID() []byte {
+	return []byte(u.user.PublicID)
+}
+
+func (u passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u passkeyUser) WebAuthnDisplayName() string {
+	return u.user.Name
+}
+
+func (u passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.passkeys))
+	for _, passkey := range u.passkeys {
+		transports := make([]protocol.AuthenticatorTransport, 0, len(passkey.Transports))
+		for _, transport := range passkey.Transports {
+			transports = append(transports, protocol.AuthenticatorTransport(transport))
+		}
+
+		credentials = append(credentials, webauthn.Credential{
+			ID:              passkey.CredentialID,
+			PublicKey:       passkey.PublicKey,
+			AttestationType: passkey.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: passkey.BackupEligible,
+				BackupState:    passkey.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    passkey.AAGUID,
+				SignCount: passkey.SignCount,
+			},
+		})
+	}
+	return credentials
+}
+
+// webAuthn собирает настройки проверяющей стороны из config
+func webAuthn() (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:                  config.WebAuthnRPID,
+		RPDisplayName:         config.WebAuthnRPName,
+		RPOrigins:             config.WebAuthnOrigins,
+		AttestationPreference: protocol.PreferNoAttestation,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		},
+	})
+}
+
+// loadPasskeyUser загружает пользователя и его ключи
+func (s *PasskeyService) loadPasskeyUser(ctx context.Context, userID int) (passkeyUser, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return passkeyUser{}, err
+	}
+
+	passkeys, err := s.repo.ListPasskeys(ctx, userID)
+	if err != nil {
+		return passkeyUser{}, err
+	}
+
+	return passkeyUser{user: user, passkeys: passkeys}, nil
+}
+
+// saveCeremony сохраняет данные церемонии и возвращает её ID для запроса finish
+func (s *PasskeyService) saveCeremony(ctx context.Context, kind string, userID int, session *webauthn.SessionData) (string, error) {
+	idBytes := make([]byte, 16)
+	_, err := rand.Read(idBytes)
+	if err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(idBytes)
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.repo.SavePasskeyCeremony(ctx, id, kind, userID, data, time.Now().Add(config.PasskeyCeremonyTTL))
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// takeCeremony забирает данные церемонии. Повторный finish с тем же ID вернёт ErrPasskeyCeremonyInvalid
+func (s *PasskeyService) takeCeremony(ctx context.Context, id string, kind string, userID int) (webauthn.SessionData, error) {
+	data, found, err := s.repo.TakePasskeyCeremony(ctx, id, kind, userID)
+	if err != nil {
+		return webauthn.SessionData{}, err
+	}
+	if !found {
+		return webauthn.SessionData{}, ErrPasskeyCeremonyInvalid
+	}
+
+	var session webauthn.SessionData
+	err = json.Unmarshal(data, &session)
+	if err != nil {
+		return webauthn.SessionData{}, err
+	}
+	return session, nil
+}
+
+// BeginRegistration начинает регистрацию нового ключа. Уже зарегистрированные ключи пользователя
+// передаются в excludeCredentials, чтобы аутентификатор не создал второй ключ для того же аккаунта
+func (s *PasskeyService) BeginRegistration(ctx context.Context, userID int) (_ model.PasskeyBeginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "PasskeyService.BeginRegistration")
+	defer tracing.End(span, &err)
+
+	wa, err := webAuthn()
+	if err != nil {
+		return model.PasskeyBeginResponse{}, fmt.Errorf("%s.PasskeyService.BeginRegistration: %w", op, err)
+	}
+
+	user, err := s.loadPasskeyUser(ctx, userID)
+	if err != nil {
+		return model.PasskeyBeginResponse{}, fmt.Errorf("%s.PasskeyService.BeginRegistration: %w", op, err)
+	}
+
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(user.passkeys))
+	for _, credential := range user.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+
+	creation, session, err := wa.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return model.PasskeyBeginResponse{}, fmt.Errorf("%s.PasskeyService.BeginRegistration: %w", op, err)
+	}
+
+	ceremonyID, err := s.saveCeremony(ctx, passkeyCeremonyRegister, userID, session)
+	if err != nil {
+		return model.PasskeyBeginResponse{}, fmt.Errorf("%s.PasskeyService.BeginRegistration: %w", op, err)
+	}
+
+	return model.PasskeyBeginResponse{CeremonyID: ceremonyID, Options: creation.Response}, nil
+}
+
+// FinishRegistration проверяет ответ аутентификатора и сохраняет ключ под названием name
+func (s *PasskeyService) FinishRegistration(ctx context.Context, userID int, ceremonyID string, name string, credential []byte) (_ model.Passkey, err error) {
+	ctx, span := tracing.Start(ctx, "PasskeyService.FinishRegistration")
+	defer tracing.End(span, &err)
+
+	wa, err := webAuthn()
+	if err != nil {
+		return model.Passkey{}, fmt.Errorf("%s.PasskeyService.FinishRegistration: %w", op, err)
+	}
+
+	session, err := s.takeCeremony(ctx, ceremonyID, passkeyCeremonyRegister, userID)
+	if err != nil {
+		return model.Passkey{}, fmt.Errorf("%s.PasskeyService.FinishRegistration: %w", op, err)
+	}
+
+	user, err := s.loadPasskeyUser(ctx, userID)
+	if err != nil {
+		return model.Passkey{}, fmt.Errorf("%s.PasskeyService.FinishRegistration: %w", op, err)
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(credential)
+	if err != nil {
+		return model.Passkey{}, fmt.Errorf("%s.PasskeyService.FinishRegistration: %w: %w", op, ErrPasskeyInvalid, err)
+	}
+
+	created, err := wa.CreateCredential(user, session, parsed)
+	if err != nil {
+		return model.Passkey{}, fmt.Errorf("%s.PasskeyService.FinishRegistration: %w: %w", op, ErrPasskeyInvalid, err)
+	}
+
+	if name == "" {
+		name = "Passkey " + strconv.Itoa(len(user.passkeys)+1)
+	}
+
+	transports := make([]string, 0, len(created.Transport))
+	for _, transport := range created.Transport {
+		transports = append(transports, string(transport))
+	}
+
+	passkey, err := s.repo.CreatePasskey(ctx, model.Passkey{
+		UserID:          userID,
+		Name:            name,
+		CredentialID:    created.ID,
+		PublicKey:       created.PublicKey,
+		AttestationType: created.AttestationType,
+		AAGUID:          created.Authenticator.AAGUID,
+		SignCount:       created.Authenticator.SignCount,
+		Transports:      transports,
+		BackupEligible:  created.Flags.BackupEligible,
+		BackupState:     created.Flags.BackupState,
+	})
+	if err != nil {
+		return model.Passkey{}, fmt.Errorf("%s.PasskeyService.FinishRegistration: %w", op, err)
+	}
+
+	s.log.Info("passkey registered",
+		zap.Int("user.id", userID),
+		zap.Int("passkey.id", passkey.ID),
+		zap.String("component", "service"),
+		zap.String("event", "PasskeyRegistered"))
+
+	return passkey, nil
+}
+
+// BeginLogin начинает вход по ключу. Пользователь не указывается: аутентификатор сам предложит
+// подходящий ключ (discoverable credential) и вернёт user handle
+func (s *PasskeyService) BeginLogin(ctx context.Context) (_ model.PasskeyBeginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "PasskeyService.BeginLogin")
+	defer tracing.End(span, &err)
+
+	wa, err := webAuthn()
+	if err != nil {
+		return model.PasskeyBeginResponse{}, fmt.Errorf("%s.PasskeyService.BeginLogin: %w", op, err)
+	}
+
+	assertion, session, err := wa.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+	if err != nil {
+		return model.PasskeyBeginResponse{}, fmt.Errorf("%s.PasskeyService.BeginLogin: %w", op, err)
+	}
+
+	ceremonyID, err := s.saveCeremony(ctx, passkeyCeremonyLogin, 0, session)
+	if err != nil {
+		return model.PasskeyBeginResponse{}, fmt.Errorf("%s.PasskeyService.BeginLogin: %w", op, err)
+	}
+
+	return model.PasskeyBeginResponse{CeremonyID: ceremonyID, Options: assertion.Response}, nil
+}
+
+// FinishLogin проверяет подпись аутентификатора и возвращает владельца ключа.
+// Если счётчик подписей не вырос, вход отклоняется с ErrPasskeyCloned
+func (s *PasskeyService) FinishLogin(ctx context.Context, ceremonyID string, credential []byte) (_ model.User, err error) {
+	ctx, span := tracing.Start(ctx, "PasskeyService.FinishLogin")
+	defer tracing.End(span, &err)
+
+	wa, err := webAuthn()
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.PasskeyService.FinishLogin: %w", op, err)
+	}
+
+	session, err := s.takeCeremony(ctx, ceremonyID, passkeyCeremonyLogin, 0)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.PasskeyService.FinishLogin: %w", op, err)
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(credential)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.PasskeyService.FinishLogin: %w: %w", op, ErrPasskeyInvalid, err)
+	}
+
+	var owner passkeyUser
+	// user handle — всегда публичный ID; библиотека сама сверяет его с WebAuthnID найденного пользователя
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		_, err := uuid.ParseBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		userID, err := s.repo.ResolvePublicID(ctx, string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+
+		owner, err = s.loadPasskeyUser(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		return owner, nil
+	}
+
+	validated, err := wa.ValidateDiscoverableLogin(findUser, session, parsed)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.PasskeyService.FinishLogin: %w: %w", op, ErrPasskeyInvalid, err)
+	}
+
+	var passkey model.Passkey
+	for _, p := range owner.passkeys {
+		if bytes.Equal(p.CredentialID, validated.ID) {
+			passkey = p
+			break
+		}
+	}
+
+	if validated.Authenticator.CloneWarning {
+		s.log.Warn("passkey sign counter did not increase",
+			zap.Int("user.id", owner.user.ID),
+			zap.Int("passkey.id", passkey.ID),
+			zap.Uint32("stored_sign_count", passkey.SignCount),
+			zap.Uint32("received_sign_count", parsed.Response.AuthenticatorData.Counter),
+			zap.String("component", "service"),
+			zap.String("event", "PasskeyCloneWarning"))
+
+		return model.User{}, fmt.Errorf("%s.PasskeyService.FinishLogin: %w", op, ErrPasskeyCloned)
+	}
+
+	err = s.repo.UpdatePasskeyUsage(ctx, passkey.ID, validated.Authenticator.SignCount, validated.Flags.BackupState)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%s.PasskeyService.FinishLogin: %w", op, err)
+	}
+
+	return owner.user, nil
+}
@@ -1,10 +1,13 @@
 package service
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"go.uber.org/zap"
+	"pet/internal/metrics"
 	"pet/internal/model"
+	"pet/internal/tracing"
 )
 
 const op = "users.service"
@@ -12,15 +15,16 @@ const op = "users.service"
 // UserRepository определяет контракт для взаимодействия с хранилищем пользователей.
 // Он абстрагирует слой сервиса от конкретной реализации репозитория - PostgreSQL.
 type UserRepository interface {
-	GetAllUsers() ([]model.User, error)
-	GetUserByID(id int) (model.User, error)
-	PostUser(createUser model.User) (model.User, error)
-	PutUser(updateUser model.User) (model.User, error)
-	PatchUser(updateUser model.PartialUser) (model.User, error)
-	DeleteUser(id int) error
-	BeginTx() (*sql.Tx, error)
-	WithdrawBalance(tx *sql.Tx, senderID int, amount float64) error
-	DepositBalance(tx *sql.Tx, receiverID int, amount float64) error
+	GetAllUsers(ctx context.Context) ([]model.User, error)
+	GetUserByID(ctx context.Context, id int) (model.User, error)
+	PostUser(ctx context.Context, createUser model.User) (model.User, error)
+	PutUser(ctx context.Context, updateUser model.User) (model.User, error)
+	PatchUser(ctx context.Context, updateUser model.PartialUser) (model.User, error)
+	DeleteUser(ctx context.Context, id int) error
+	BeginTx(ctx context.Context) (*sql.Tx, error)
+	GetUserStatusTx(ctx context.Context, tx *sql.Tx, id int) (string, error)
+	WithdrawBalance(ctx context.Context, tx *sql.Tx, senderID int, amount float64) error
+	DepositBalance(ctx context.Context, tx *sql.Tx, receiverID int, amount float64) error
 	// другие методы...
 }
 
@@ -44,15 +48,20 @@ func NewUserService(repo UserRepository, logger *zap.Logger) *UserService {
 
 // TransferFunds переводит указанную сумму со счёта отправителя на счёт получателя.
 // Операция выполняется в транзакции и либо полностью завершается, либо полностью откатывается.
+// Оба аккаунта должны быть активны, иначе возвращается *AccountInactiveError.
 // Возвращает ошибку в случае проблем с началом транзакции, списанием, зачислением или коммитом.
-func (s *UserService) TransferFunds(senderID int, receiverID int, amount float64) (err error) {
-	tx, err := s.repo.BeginTx()
+func (s *UserService) TransferFunds(ctx context.Context, senderID int, receiverID int, amount float64) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.TransferFunds")
+	defer tracing.End(span, &err)
+
+	tx, err := s.repo.BeginTx(ctx)
 	if err != nil {
 		return fmt.Errorf("%s.TransferFunds: begin transaction error: %w", op, err)
 	}
 
 	defer func() {
 		if err != nil {
+			metrics.Transfers.WithLabelValues("failure").Inc()
 
 			rbErr := tx.Rollback()
 			if rbErr != nil {
@@ -64,12 +73,30 @@ func (s *UserService) TransferFunds(senderID int, receiverID int, amount float64
 		}
 	}()
 
-	err = s.repo.WithdrawBalance(tx, senderID, amount)
+	// строки блокируются до конца транзакции: приостановить аккаунт посреди перевода нельзя.
+	// Порядок блокировки — по возрастанию ID, чтобы встречные переводы A→B и B→A не взаимоблокировались
+	ids := []int{senderID, receiverID}
+	if receiverID < senderID {
+		ids = []int{receiverID, senderID}
+	}
+	for _, id := range ids {
+		var status string
+		status, err = s.repo.GetUserStatusTx(ctx, tx, id)
+		if err != nil {
+			return fmt.Errorf("%s.TransferFunds: status error: %w", op, err)
+		}
+		if status != model.UserStatusActive {
+			err = &AccountInactiveError{UserID: id, Status: status}
+			return fmt.Errorf("%s.TransferFunds: %w", op, err)
+		}
+	}
+
+	err = s.repo.WithdrawBalance(ctx, tx, senderID, amount)
 	if err != nil {
 		return fmt.Errorf("%s.TransferFunds: withdraw error: %w", op, err)
 	}
 
-	err = s.repo.DepositBalance(tx, receiverID, amount)
+	err = s.repo.DepositBalance(ctx, tx, receiverID, amount)
 	if err != nil {
 		return fmt.Errorf("%s.TransferFunds: deposit error: %w", op, err)
 	}
@@ -79,5 +106,7 @@ func (s *UserService) TransferFunds(senderID int, receiverID int, amount float64
 		return fmt.Errorf("%s.TransferFunds: canceled, transaction error : %w", op, err)
 	}
 
+	metrics.Transfers.WithLabelValues("success").Inc()
+	metrics.TransferVolume.Add(amount)
 	return nil
 }
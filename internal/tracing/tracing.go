@@ -0,0 +1,117 @@
+// Package tracing — трассировка OpenTelemetry: спаны HTTP-запросов (middleware.Tracing),
+// вызовов сервисов и SQL-запросов (database.ConnectDB). Контекст трассы принимается и передаётся
+// в заголовке traceparent (W3C Trace Context)
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+	"path/filepath"
+)
+
+const (
+	serviceName = "users-api" // переопределяется стандартной OTEL_SERVICE_NAME
+	tracerName  = "pet"
+)
+
+// Init настраивает глобальный TracerProvider и распространение контекста W3C Trace Context.
+// exporter — none, otlp или file (см. config.TraceExporter), file — путь для экспортёра file.
+// Возвращает функцию, которая выгружает оставшиеся спаны и закрывает экспортёр
+func Init(ctx context.Context, exporter string, file string) (func(context.Context) error, error) {
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tracing/Init: resource: %w", err)
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		// решение о записи принимает вызывающий сервис, если он передал traceparent
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	}
+
+	var out *os.File
+	switch exporter {
+	case "none":
+		// спаны без экспортёра: ID трасс всё равно попадают в логи и передаются дальше
+	case "otlp":
+		exp, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("tracing/Init: otlp exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	case "file":
+		err = os.MkdirAll(filepath.Dir(file), 0o755)
+		if err != nil {
+			return nil, fmt.Errorf("tracing/Init: %w", err)
+		}
+		out, err = os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("tracing/Init: %w", err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(out))
+		if err != nil {
+			_ = out.Close()
+			return nil, fmt.Errorf("tracing/Init: file exporter: %w", err)
+		}
+		// синхронно: спан оказывается в файле сразу после End, что удобно в тестах
+		opts = append(opts, sdktrace.WithSyncer(exp))
+	default:
+		return nil, fmt.Errorf("tracing/Init: unknown exporter %q", exporter)
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if out != nil {
+			err = errors.Join(err, out.Close())
+		}
+		return err
+	}, nil
+}
+
+// Tracer возвращает трассировщик приложения. До Init спаны не записываются
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// Start начинает внутренний спан name (например, "UserService.TransferFunds") в трассе из ctx
+func Start(ctx context.Context, name string) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name)
+}
+
+// RecordError отмечает спан как завершившийся ошибкой err. nil ничего не меняет
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// End завершает спан и отмечает его ошибкой *err, если она есть.
+// Вызывается через defer с именованным результатом: defer tracing.End(span, &err)
+func End(span trace.Span, err *error) {
+	RecordError(span, *err)
+	span.End()
+}
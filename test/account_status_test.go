@@ -0,0 +1,141 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/service"
+	"testing"
+)
+
+func TestAccountStatusTransitions(t *testing.T) {
+	cases := []struct {
+		from     string
+		to       string
+		expected bool
+	}{
+		{model.UserStatusPending, model.UserStatusActive, true},
+		{model.UserStatusPending, model.UserStatusSuspended, false},
+		{model.UserStatusActive, model.UserStatusSuspended, true},
+		{model.UserStatusActive, model.UserStatusLocked, true},
+		{model.UserStatusActive, model.UserStatusClosed, true},
+		{model.UserStatusSuspended, model.UserStatusActive, true},
+		{model.UserStatusSuspended, model.UserStatusLocked, false},
+		{model.UserStatusSuspended, model.UserStatusPending, false},
+		{model.UserStatusLocked, model.UserStatusActive, true},
+		{model.UserStatusActive, model.UserStatusActive, false},
+		{model.UserStatusClosed, model.UserStatusActive, false},
+	}
+
+	for _, c := range cases {
+		got := service.CanTransition(c.from, c.to)
+		if got != c.expected {
+			t.Errorf("переход %s -> %s: ожидалось %v, получено %v", c.from, c.to, c.expected, got)
+		}
+	}
+}
+
+func TestSuspendAndReactivate(t *testing.T) {
+	deleteTestUsers(TestDB)
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	adminToken := loginAsAdmin(t, testServer.URL, "status-admin@example.com")
+
+	const email, pw = "suspended@example.com", "long-test-pass-1"
+	registerAndLogin(t, testServer.URL, email, pw)
+	userToken, _ := loginWithCookie(t, testServer.URL, email, pw)
+
+	const senderEmail = "sender@example.com"
+	registerAndLogin(t, testServer.URL, senderEmail, pw)
+	senderToken, _ := loginWithCookie(t, testServer.URL, senderEmail, pw)
+
+	var user model.User
+	resp := doJSON(t, http.MethodGet, testServer.URL+"/me", userToken, nil)
+	err := decodeJSON(resp, &user)
+	resp.Body.Close()
+	if err != nil || user.Status != model.UserStatusActive {
+		t.Fatalf("ожидался активный пользователь, а получено: %+v (%v)", user, err)
+	}
+
+	changeStatus := func(action string) (int, model.User) {
+		url := fmt.Sprintf("%s/admin/users/%s/%s", testServer.URL, user.PublicID, action)
+		resp := postJSON(t, url, adminToken, model.ChangeStatusRequest{Reason: "проверка статуса"})
+		defer resp.Body.Close()
+
+		var changed model.User
+		if resp.StatusCode == http.StatusOK {
+			_ = decodeJSON(resp, &changed)
+		}
+		return resp.StatusCode, changed
+	}
+
+	status, changed := changeStatus("suspend")
+	if status != http.StatusOK || changed.Status != model.UserStatusSuspended {
+		t.Fatalf("ожидался статус 200 и suspended, а получено: %d (%+v)", status, changed)
+	}
+
+	// выданные токены перестают действовать
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/me", userToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для токена приостановленного аккаунта, а получен: %d", resp.StatusCode)
+	}
+
+	// войти нельзя
+	resp = postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Email: email, Password: pw})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("ожидался статус 403 при входе в приостановленный аккаунт, а получен: %d", resp.StatusCode)
+	}
+
+	// перевести на приостановленный аккаунт нельзя
+	resp = postJSON(t, testServer.URL+"/transfers", senderToken, model.TransferRequest{ToID: user.PublicID, Amount: 1})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("ожидался статус 422 при переводе на приостановленный аккаунт, а получен: %d", resp.StatusCode)
+	}
+
+	// повторно приостановить нельзя: suspended -> suspended не разрешён
+	if status, _ := changeStatus("suspend"); status != http.StatusConflict {
+		t.Errorf("ожидался статус 409 для недопустимого перехода, а получен: %d", status)
+	}
+
+	status, changed = changeStatus("reactivate")
+	if status != http.StatusOK || changed.Status != model.UserStatusActive {
+		t.Fatalf("ожидался статус 200 и active, а получено: %d (%+v)", status, changed)
+	}
+	userToken, _ = loginWithCookie(t, testServer.URL, email, pw)
+
+	status, changed = changeStatus("lock")
+	if status != http.StatusOK || changed.Status != model.UserStatusLocked {
+		t.Fatalf("ожидался статус 200 и locked, а получено: %d (%+v)", status, changed)
+	}
+
+	resp = postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Email: email, Password: pw})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("ожидался статус 403 при входе в заблокированный аккаунт, а получен: %d", resp.StatusCode)
+	}
+
+	status, changed = changeStatus("reactivate")
+	if status != http.StatusOK || changed.Status != model.UserStatusActive {
+		t.Fatalf("ожидался статус 200 и active после блокировки, а получено: %d (%+v)", status, changed)
+	}
+	userToken, _ = loginWithCookie(t, testServer.URL, email, pw)
+
+	// статус, изменённый в обход API, проверяется в Auth при следующем чтении из БД
+	_, err = TestDB.Exec("UPDATE users SET status = $1 WHERE public_id = $2", model.UserStatusClosed, user.PublicID)
+	if err != nil {
+		t.Fatalf("ошибка при изменении статуса: %v", err)
+	}
+	middleware.InvalidateUserStatus(user.PublicID)
+
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/me", userToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("ожидался статус 403 для закрытого аккаунта, а получен: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,110 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"testing"
+	"time"
+)
+
+// createTestAPIKey - выпускает API-ключ напрямую через репозиторий и возвращает полный ключ
+func createTestAPIKey(t *testing.T, testRepo *repository.UserRepository, scopes []string, expiresAt *time.Time) (string, model.APIKey) {
+	t.Helper()
+
+	rawKey, prefix, hash, err := middleware.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("ошибка при генерации API-ключа: %v", err)
+	}
+
+	created, err := testRepo.CreateAPIKey(context.Background(), model.APIKey{
+		Name: "test-job", Prefix: prefix, KeyHash: hash, Scopes: scopes, ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		t.Fatalf("ошибка при сохранении API-ключа: %v", err)
+	}
+	return rawKey, created
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	deleteTestUsers(TestDB)
+	users, err := seedTestUsers(TestDB)
+	if err != nil {
+		t.Fatalf("ошибка при добавлении пользователей в таблицу тестовой БД: %v", err)
+	}
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	testRepo := repository.NewUserRepository(TestDB, logger)
+	rawKey, created := createTestAPIKey(t, testRepo, []string{middleware.PermUsersRead}, nil)
+
+	transfer := model.TransferRequest{
+		FromID: users["alice@example.com"].PublicID,
+		ToID:   users["bob@example.com"].PublicID,
+		Amount: 1,
+	}
+
+	// ключ без transfers:write — 403
+	resp := postJSON(t, testServer.URL+"/transfers", rawKey, transfer)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("ожидался статус 403 без скоупа transfers:write, а получен: %d", resp.StatusCode)
+	}
+
+	// одного transfers:write мало: списание с чужого счёта требует transfers:any
+	writeKey, _ := createTestAPIKey(t, testRepo, []string{middleware.PermTransfersWrite}, nil)
+	resp = postJSON(t, testServer.URL+"/transfers", writeKey, transfer)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("ожидался статус 403 без скоупа transfers:any, а получен: %d", resp.StatusCode)
+	}
+
+	_, err = TestDB.Exec("UPDATE users SET balance = 10 WHERE email = 'alice@example.com'")
+	if err != nil {
+		t.Fatalf("ошибка при пополнении баланса: %v", err)
+	}
+	anyKey, _ := createTestAPIKey(t, testRepo, []string{middleware.PermTransfersWrite, middleware.PermTransfersAny}, nil)
+	resp = postJSON(t, testServer.URL+"/transfers", anyKey, transfer)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("ожидался статус 200 для ключа со скоупом transfers:any, а получен: %d", resp.StatusCode)
+	}
+
+	key, err := testRepo.GetAPIKeyByPrefix(context.Background(), created.Prefix)
+	if err != nil {
+		t.Fatalf("ошибка при получении API-ключа: %v", err)
+	}
+	if key.LastUsedAt == nil {
+		t.Error("ожидалось, что last_used_at обновится после запроса с ключом")
+	}
+
+	// искажённый ключ — 401
+	resp = postJSON(t, testServer.URL+"/transfers", rawKey+"x", transfer)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для неверного ключа, а получен: %d", resp.StatusCode)
+	}
+
+	// отозванный ключ — 401
+	err = testRepo.RevokeAPIKey(context.Background(), created.ID)
+	if err != nil {
+		t.Fatalf("ошибка при отзыве API-ключа: %v", err)
+	}
+	resp = postJSON(t, testServer.URL+"/transfers", rawKey, transfer)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для отозванного ключа, а получен: %d", resp.StatusCode)
+	}
+
+	// просроченный ключ — 401
+	expired := time.Now().Add(-time.Minute)
+	expiredKey, _ := createTestAPIKey(t, testRepo, []string{middleware.PermTransfersWrite}, &expired)
+	resp = postJSON(t, testServer.URL+"/transfers", expiredKey, transfer)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для просроченного ключа, а получен: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,74 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"pet/internal/middleware"
+	"testing"
+)
+
+func TestClientIPResolver(t *testing.T) {
+	_, err := middleware.NewClientIPResolver([]string{"10.0.0.0/33"})
+	if err == nil {
+		t.Errorf("ожидалась ошибка для неверной подсети")
+	}
+
+	resolver, err := middleware.NewClientIPResolver([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("ошибка при создании ClientIPResolver: %v", err)
+	}
+	untrusting, _ := middleware.NewClientIPResolver(nil)
+
+	tests := []struct {
+		name     string
+		resolver *middleware.ClientIPResolver
+		remote   string
+		headers  map[string]string
+		expected string
+	}{
+		{"без доверенных прокси заголовки игнорируются", untrusting, "203.0.113.9:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.9"},
+		{"недоверенный отправитель не может подменить IP", resolver, "203.0.113.9:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"}, "203.0.113.9"},
+		{"цепочка доверенных прокси", resolver, "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"подделанное начало цепочки отбрасывается", resolver, "10.0.0.1:5000",
+			map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		{"Forwarded важнее X-Forwarded-For", resolver, "192.168.1.1:5000",
+			map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`, "X-Forwarded-For": "198.51.100.1"}, "2001:db8::1"},
+		{"Forwarded с портом IPv4", resolver, "10.0.0.1:5000",
+			map[string]string{"Forwarded": `For="198.51.100.7:8080"`}, "198.51.100.7"},
+		{"unknown обрывает цепочку на последнем доверенном прокси", resolver, "10.0.0.1:5000",
+			map[string]string{"Forwarded": "for=unknown, for=10.0.0.2"}, "10.0.0.2"},
+		{"X-Real-IP от доверенного прокси", resolver, "10.0.0.1:5000",
+			map[string]string{"X-Real-IP": "198.51.100.3"}, "198.51.100.3"},
+		{"IPv4 в IPv6-формате", resolver, "[::ffff:10.0.0.1]:5000",
+			map[string]string{"X-Forwarded-For": "198.51.100.4"}, "198.51.100.4"},
+		{"без заголовков", resolver, "10.0.0.1:5000", nil, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remote
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+
+		if got := tt.resolver.Resolve(req); got != tt.expected {
+			t.Errorf("%s: ожидался IP %s, а получен: %s", tt.name, tt.expected, got)
+		}
+	}
+
+	// middleware кладёт определённый IP в контекст
+	var got string
+	handler := middleware.ClientIP(resolver)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = middleware.GetClientIP(r)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.5")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "198.51.100.5" {
+		t.Errorf("ожидался IP 198.51.100.5 из контекста, а получен: %s", got)
+	}
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"pet/config"
+	"pet/internal/middleware"
+	"strings"
+	"testing"
+)
+
+func TestCorsPolicy(t *testing.T) {
+	corsHandler, err := middleware.NewCors(config.CorsPolicy{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{http.MethodGet, http.MethodPost},
+		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		ExposedHeaders:   []string{"ETag", "RateLimit-Remaining"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	})
+	if err != nil {
+		t.Fatalf("ошибка при создании политики CORS: %v", err)
+	}
+
+	handler := corsHandler.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	preflight := func(origin string) *http.Response {
+		req := httptest.NewRequest(http.MethodOptions, "/users", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},     // поддомены любого уровня
+		{"https://example.org", false},        // сам домен шаблоном не покрывается
+		{"https://evilexample.org", false},    // не поддомен
+		{"http://a.example.org", false},       // другая схема
+		{"https://a.example.org:8443", false}, // другой порт
+		{"https://other.example.com", false},
+	}
+	for _, tt := range tests {
+		resp := preflight(tt.origin)
+		got := resp.Header.Get("Access-Control-Allow-Origin")
+		if tt.allowed && got != tt.origin {
+			t.Errorf("%s: ожидалось разрешение источника, а получено: %q", tt.origin, got)
+		}
+		if !tt.allowed && got != "" {
+			t.Errorf("%s: источник не должен быть разрешён, а получено: %q", tt.origin, got)
+		}
+	}
+
+	resp := preflight("https://a.example.org")
+	if resp.Header.Get("Access-Control-Max-Age") != "600" || resp.Header.Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("ожидались Max-Age 600 и Allow-Credentials в ответе на предзапрос, а получено: %v", resp.Header)
+	}
+
+	// обычный запрос: ETag и заголовки лимитов доступны JavaScript
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	exposed := strings.ToLower(rec.Header().Get("Access-Control-Expose-Headers"))
+	if !strings.Contains(exposed, "etag") || !strings.Contains(exposed, "ratelimit-remaining") {
+		t.Errorf("ожидались ETag и RateLimit-Remaining в Access-Control-Expose-Headers, а получено: %q", exposed)
+	}
+
+	// перезагрузка без перезапуска
+	err = corsHandler.Reload(config.CorsPolicy{AllowedOrigins: []string{"https://new.example.com"}})
+	if err != nil {
+		t.Fatalf("ошибка при перезагрузке политики CORS: %v", err)
+	}
+	if got := preflight("https://app.example.com").Header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("после перезагрузки старый источник не должен быть разрешён, а получено: %q", got)
+	}
+	if got := preflight("https://new.example.com").Header.Get("Access-Control-Allow-Origin"); got != "https://new.example.com" {
+		t.Errorf("после перезагрузки ожидалось разрешение нового источника, а получено: %q", got)
+	}
+
+	// неверная политика не применяется
+	err = corsHandler.Reload(config.CorsPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	if err == nil {
+		t.Error("ожидалась ошибка для \"*\" вместе с credentials")
+	}
+	err = corsHandler.Reload(config.CorsPolicy{AllowedOrigins: []string{"https://app.*.com"}})
+	if err == nil {
+		t.Error("ожидалась ошибка для \"*\" не в начале имени хоста")
+	}
+	if got := preflight("https://new.example.com").Header.Get("Access-Control-Allow-Origin"); got != "https://new.example.com" {
+		t.Errorf("после неверной политики должна действовать прежняя, а получено: %q", got)
+	}
+}
+
+func TestLoadCorsPolicy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cors.json")
+	err := os.WriteFile(file, []byte(`{"prod": {"allowed_origins": ["https://*.example.com"], "allow_credentials": true}}`), 0o600)
+	if err != nil {
+		t.Fatalf("ошибка при записи файла политики: %v", err)
+	}
+	t.Setenv("CORS_CONFIG_FILE", file)
+	t.Setenv("CORS_MAX_AGE", "120")
+
+	policy, err := config.LoadCorsPolicy("prod")
+	if err != nil {
+		t.Fatalf("ошибка при загрузке политики CORS: %v", err)
+	}
+	if len(policy.AllowedOrigins) != 1 || policy.AllowedOrigins[0] != "https://*.example.com" || !policy.AllowCredentials {
+		t.Errorf("ожидался профиль prod из файла, а получено: %+v", policy)
+	}
+	if policy.MaxAge != 120 {
+		t.Errorf("ожидался MaxAge из CORS_MAX_AGE, а получено: %d", policy.MaxAge)
+	}
+	if len(policy.ExposedHeaders) == 0 || policy.ExposedHeaders[0] != "ETag" {
+		t.Errorf("ожидались заголовки по умолчанию в ExposedHeaders, а получено: %v", policy.ExposedHeaders)
+	}
+
+	// профиля нет в файле — используется встроенный
+	policy, err = config.LoadCorsPolicy("dev")
+	if err != nil || len(policy.AllowedOrigins) == 0 || policy.AllowedOrigins[0] != "http://localhost:3000" {
+		t.Errorf("ожидался встроенный профиль dev, а получено: %+v (%v)", policy, err)
+	}
+
+	t.Setenv("CORS_ALLOWED_ORIGINS", "https://a.example.com, https://b.example.com")
+	policy, err = config.LoadCorsPolicy("dev")
+	if err != nil || len(policy.AllowedOrigins) != 2 || policy.AllowedOrigins[1] != "https://b.example.com" {
+		t.Errorf("ожидались источники из CORS_ALLOWED_ORIGINS, а получено: %+v (%v)", policy, err)
+	}
+
+	// перезагрузка читает только файл: CORS_ALLOWED_ORIGINS не перекрывает его правки
+	err = os.WriteFile(file, []byte(`{"dev": {"allowed_origins": ["https://edited.example.com"]}}`), 0o600)
+	if err != nil {
+		t.Fatalf("ошибка при записи файла политики: %v", err)
+	}
+	policy, err = config.ReloadCorsPolicy("dev")
+	if err != nil || len(policy.AllowedOrigins) != 1 || policy.AllowedOrigins[0] != "https://edited.example.com" {
+		t.Errorf("ожидались источники из изменённого файла, а получено: %+v (%v)", policy, err)
+	}
+
+	t.Setenv("CORS_MAX_AGE", "soon")
+	_, err = config.LoadCorsPolicy("dev")
+	if err == nil {
+		t.Error("ожидалась ошибка для неверного CORS_MAX_AGE")
+	}
+}
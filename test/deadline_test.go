@@ -0,0 +1,69 @@
+package test
+
+import (
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"pet/config"
+	"pet/internal/middleware"
+	"testing"
+	"time"
+)
+
+func TestDeadline(t *testing.T) {
+	savedDefault, savedRoutes := config.RequestTimeout, config.RouteTimeouts
+	config.RequestTimeout = 50 * time.Millisecond
+	config.RouteTimeouts = map[string]time.Duration{"GET /slow/{id}": 300 * time.Millisecond}
+	defer func() {
+		config.RequestTimeout, config.RouteTimeouts = savedDefault, savedRoutes
+	}()
+
+	// обработчик ждёт 100 мс или отмены контекста, как запрос к БД
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok {
+			t.Error("ожидался срок в контексте запроса")
+		}
+		select {
+		case <-time.After(100 * time.Millisecond):
+			w.Header().Set("X-Handler", "done")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		case <-r.Context().Done():
+			http.Error(w, "database error", http.StatusInternalServerError)
+		}
+	}
+
+	router := mux.NewRouter()
+	router.Use(middleware.Deadline)
+	router.HandleFunc("/slow", slow).Methods(http.MethodGet)
+	router.HandleFunc("/slow/{id}", slow).Methods(http.MethodGet)
+
+	do := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	// срок по умолчанию меньше времени обработки
+	rec := do("/slow")
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("ожидался статус 504, а получен: %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != "application/json" || rec.Body.String() != `{"error":"request timed out","timeout":"50ms"}` {
+		t.Errorf("ожидалось JSON-тело с ошибкой, а получено: %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+
+	// у маршрута свой, больший срок — ответ обработчика доходит целиком
+	rec = do("/slow/1")
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-Handler") != "done" || rec.Body.String() != `{"ok":true}` {
+		t.Errorf("ожидался ответ обработчика 201, а получено: %d %v %q", rec.Code, rec.Header(), rec.Body.String())
+	}
+
+	// паника обработчика доходит до Recoverer
+	router.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) { panic("boom") })
+	rec = httptest.NewRecorder()
+	middleware.Recoverer()(router).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("ожидался статус 500 после паники, а получен: %d", rec.Code)
+	}
+}
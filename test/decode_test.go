@@ -0,0 +1,65 @@
+package test
+
+import (
+	"net/http"
+	"pet/config"
+	"strings"
+	"testing"
+)
+
+func TestStrictJSONDecoding(t *testing.T) {
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	saved := config.MaxRequestBodySize
+	config.MaxRequestBodySize = 256
+	defer func() { config.MaxRequestBodySize = saved }()
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		errMsg      string
+		field       string
+	}{
+		{"без Content-Type", "", `{"email":"a@example.com","password":"x"}`, http.StatusUnsupportedMediaType, "unsupported media type", ""},
+		{"не JSON", "text/plain", `{"email":"a@example.com","password":"x"}`, http.StatusUnsupportedMediaType, "unsupported media type", ""},
+		{"неизвестное поле", "application/json", `{"email":"a@example.com","password":"x","remember":true}`, http.StatusBadRequest, "unknown field", "remember"},
+		{"два значения", "application/json", `{"email":"a@example.com","password":"x"} {}`, http.StatusBadRequest, "request body must contain a single JSON value", ""},
+		{"мусор после значения", "application/json", `{"email":"a@example.com","password":"x"}xyz`, http.StatusBadRequest, "request body must contain a single JSON value", ""},
+		{"неверный тип", "application/json", `{"email":42,"password":"x"}`, http.StatusBadRequest, "invalid field type", "email"},
+		{"неверный JSON", "application/json", `{"email":`, http.StatusBadRequest, "malformed JSON", ""},
+		{"пустое тело", "application/json; charset=utf-8", ``, http.StatusBadRequest, "request body is empty", ""},
+		{"слишком большое тело", "application/json", `{"email":"` + strings.Repeat("a", 300) + `","password":"x"}`, http.StatusRequestEntityTooLarge, "request body too large", ""},
+	}
+
+	for _, tt := range tests {
+		req, err := http.NewRequest(http.MethodPost, testServer.URL+"/login", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("%s: ошибка при создании запроса: %v", tt.name, err)
+		}
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: ошибка при выполнении запроса: %v", tt.name, err)
+		}
+
+		var body map[string]any
+		err = decodeJSON(resp, &body)
+		resp.Body.Close()
+
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: ожидался статус %d, а получен: %d", tt.name, tt.status, resp.StatusCode)
+		}
+		if err != nil || body["error"] != tt.errMsg {
+			t.Errorf("%s: ожидалась ошибка %q, а получено: %v (%v)", tt.name, tt.errMsg, body, err)
+		}
+		if tt.field != "" && body["field"] != tt.field {
+			t.Errorf("%s: ожидалось поле %q в ответе, а получено: %v", tt.name, tt.field, body["field"])
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"pet/internal/model"
+	"pet/internal/service"
+	"testing"
+)
+
+func TestValidateHandle(t *testing.T) {
+	tests := []struct {
+		handle string
+		valid  bool
+	}{
+		{"alice", true},
+		{"Alice_99", true},
+		{"ab", false},       // короче 3 символов
+		{"9lives", false},   // начинается с цифры
+		{"al ice", false},   // пробел
+		{"алиса", false},    // не латиница
+		{"Admin", false},    // зарезервировано без учёта регистра
+		{"user_abc", false}, // префикс автоматических имён
+		{"a23456789012345678901234567890", true},
+		{"a234567890123456789012345678901", false}, // длиннее 30 символов
+	}
+
+	for _, tt := range tests {
+		err := service.ValidateHandle(tt.handle)
+		if tt.valid && err != nil {
+			t.Errorf("%q: ожидалось допустимое имя, а получена ошибка: %v", tt.handle, err)
+		}
+		if !tt.valid && !errors.Is(err, service.ErrHandleInvalid) {
+			t.Errorf("%q: ожидалась ошибка ErrHandleInvalid, а получено: %v", tt.handle, err)
+		}
+	}
+
+	if got := service.NormalizeHandle("  @Alice "); got != "Alice" {
+		t.Errorf("ожидалось имя Alice после нормализации, а получено: %q", got)
+	}
+}
+
+func TestHandlesLoginAndTransfers(t *testing.T) {
+	deleteTestUsers(TestDB)
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const pw = "long-test-pass-1"
+	register := func(email string, handle string) int {
+		resp := postJSON(t, testServer.URL+"/register", "", model.RegisterRequest{
+			Name: "Test", Age: 30, Email: email, Password: pw, Handle: handle,
+		})
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := register("alice@example.com", "@Alice"); status != http.StatusCreated {
+		t.Fatalf("ожидался статус 201 при регистрации с именем, а получен: %d", status)
+	}
+	// уникальность без учёта регистра
+	if status := register("alice2@example.com", "ALICE"); status != http.StatusConflict {
+		t.Errorf("ожидался статус 409 для занятого имени, а получен: %d", status)
+	}
+	if status := register("bad@example.com", "9lives"); status != http.StatusUnprocessableEntity {
+		t.Errorf("ожидался статус 422 для неверного имени, а получен: %d", status)
+	}
+	// без имени выдаётся автоматическое
+	if status := register("bob@example.com", ""); status != http.StatusCreated {
+		t.Fatalf("ожидался статус 201 при регистрации без имени, а получен: %d", status)
+	}
+
+	// вход по имени без учёта регистра и с "@"
+	resp := postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Login: "@aLiCe", Password: pw})
+	var body map[string]string
+	err := decodeJSON(resp, &body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || body["access-token"] == "" {
+		t.Fatalf("ожидался статус 200 и access-token при входе по имени, а получено: %d (%v)", resp.StatusCode, err)
+	}
+	aliceToken := body["access-token"]
+
+	// e-mail в поле login тоже принимается
+	resp = postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Login: "bob@example.com", Password: pw})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("ожидался статус 200 при входе по e-mail в login, а получен: %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Login: "nobody", Password: pw})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для неизвестного имени, а получен: %d", resp.StatusCode)
+	}
+
+	bobToken, _ := loginWithCookie(t, testServer.URL, "bob@example.com", pw)
+	var bob model.User
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/me", bobToken, nil)
+	err = decodeJSON(resp, &bob)
+	resp.Body.Close()
+	if err != nil || len(bob.Handle) < len("user_") || bob.Handle[:5] != "user_" {
+		t.Fatalf("ожидалось автоматическое имя user_xxx, а получено: %+v (%v)", bob, err)
+	}
+
+	// переводы: получатель по имени, e-mail и публичному ID
+	_, err = TestDB.Exec("UPDATE users SET balance = 100 WHERE email = 'alice@example.com'")
+	if err != nil {
+		t.Fatalf("ошибка при пополнении баланса: %v", err)
+	}
+	for _, to := range []string{"@" + bob.Handle, "bob@example.com", bob.PublicID} {
+		resp = postJSON(t, testServer.URL+"/transfers", aliceToken, model.TransferRequest{To: to, Amount: 1})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("ожидался статус 200 для перевода на %q, а получен: %d", to, resp.StatusCode)
+		}
+	}
+
+	resp = postJSON(t, testServer.URL+"/transfers", aliceToken, model.TransferRequest{To: "@nobody", Amount: 1})
+	var notFound map[string]string
+	err = decodeJSON(resp, &notFound)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || notFound["error"] != "recipient not found" || notFound["recipient"] != "@nobody" {
+		t.Errorf("ожидался статус 404 с понятной ошибкой, а получено: %d %v (%v)", resp.StatusCode, notFound, err)
+	}
+
+	// смена имени: старое закреплено за владельцем
+	changeHandle := func(token string, handle string) (int, model.User) {
+		resp := doJSON(t, http.MethodPut, testServer.URL+"/me/handle", token, model.ChangeHandleRequest{Handle: handle})
+		defer resp.Body.Close()
+
+		var user model.User
+		if resp.StatusCode == http.StatusOK {
+			_ = decodeJSON(resp, &user)
+		}
+		return resp.StatusCode, user
+	}
+
+	status, changed := changeHandle(aliceToken, "alice_new")
+	if status != http.StatusOK || changed.Handle != "alice_new" {
+		t.Fatalf("ожидался статус 200 и новое имя, а получено: %d (%+v)", status, changed)
+	}
+	if status, _ := changeHandle(bobToken, "alice"); status != http.StatusConflict {
+		t.Errorf("ожидался статус 409 для закреплённого старого имени, а получен: %d", status)
+	}
+	if status, _ := changeHandle(bobToken, "root"); status != http.StatusUnprocessableEntity {
+		t.Errorf("ожидался статус 422 для зарезервированного имени, а получен: %d", status)
+	}
+
+	// после истечения срока имя свободно
+	_, err = TestDB.Exec("UPDATE handle_reservations SET reserved_until = now() - interval '1 minute' WHERE handle = 'alice'")
+	if err != nil {
+		t.Fatalf("ошибка при изменении срока закрепления: %v", err)
+	}
+	if status, changed := changeHandle(bobToken, "Alice"); status != http.StatusOK || changed.Handle != "Alice" {
+		t.Errorf("ожидался статус 200 после истечения закрепления, а получено: %d (%+v)", status, changed)
+	}
+}
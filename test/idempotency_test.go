@@ -0,0 +1,137 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"pet/internal/model"
+	"sync"
+	"testing"
+)
+
+// postIdempotent - POST с заголовком Idempotency-Key; возвращает статус, тело и заголовки ответа
+func postIdempotent(t *testing.T, url string, token string, key string, body any) (int, []byte, http.Header) {
+	t.Helper()
+
+	bytesBody, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("ошибка при инкодировании тела запроса в JSON: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(bytesBody))
+	if err != nil {
+		t.Fatalf("ошибка при создании POST-запроса: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", key)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Errorf("ошибка при выполнении POST-запроса: %v", err)
+		return 0, nil, nil
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Errorf("ошибка при чтении ответа: %v", err)
+	}
+	return resp.StatusCode, respBody, resp.Header
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	deleteTestUsers(TestDB)
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const pw = "long-test-pass-1"
+	key := uuid.New().String()
+
+	// повтор регистрации с тем же ключом и e-mail получает первый ответ, а не ошибку о занятом e-mail
+	register := model.RegisterRequest{Name: "Test", Age: 30, Email: "retry@example.com", Password: pw}
+	status, first, _ := postIdempotent(t, testServer.URL+"/register", "", key, register)
+	if status != http.StatusCreated {
+		t.Fatalf("ожидался статус 201 при регистрации, а получен: %d", status)
+	}
+	status, replayed, header := postIdempotent(t, testServer.URL+"/register", "", key, register)
+	if status != http.StatusCreated || !bytes.Equal(first, replayed) || header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("ожидался сохранённый ответ 201 на повтор регистрации, а получено: %d %s (%v)", status, replayed, header)
+	}
+
+	// без ключа повтор — конфликт, а не внутренняя ошибка
+	resp := postJSON(t, testServer.URL+"/register", "", register)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("ожидался статус 409 для занятого e-mail, а получен: %d", resp.StatusCode)
+	}
+
+	// тот же ключ с другим e-mail — другой клиент: чужой ответ не отдаётся
+	register.Email = "retry-other@example.com"
+	status, _, header = postIdempotent(t, testServer.URL+"/register", "", key, register)
+	if status != http.StatusCreated || header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("ожидалась новая регистрация для другого e-mail, а получено: %d (%v)", status, header)
+	}
+
+	sender := registerAndLogin(t, testServer.URL, "sender@example.com", pw)
+	token, _ := sender["access-token"].(string)
+	registerAndLogin(t, testServer.URL, "receiver@example.com", pw)
+	_, err := TestDB.Exec("UPDATE users SET balance = 100 WHERE email = 'sender@example.com'")
+	if err != nil {
+		t.Fatalf("ошибка при пополнении баланса: %v", err)
+	}
+
+	// повтор получает тот же ответ, перевод не выполняется второй раз
+	transfer := model.TransferRequest{To: "receiver@example.com", Amount: 10}
+	status, first, _ = postIdempotent(t, testServer.URL+"/transfers", token, key, transfer)
+	if status != http.StatusOK {
+		t.Fatalf("ожидался статус 200 при переводе, а получен: %d", status)
+	}
+	status, replayed, header = postIdempotent(t, testServer.URL+"/transfers", token, key, transfer)
+	if status != http.StatusOK || !bytes.Equal(first, replayed) || header.Get("Idempotent-Replayed") != "true" {
+		t.Errorf("ожидался сохранённый ответ 200, а получено: %d %s (%v)", status, replayed, header)
+	}
+
+	// тот же ключ с другим телом
+	status, _, _ = postIdempotent(t, testServer.URL+"/transfers", token, key, model.TransferRequest{To: "receiver@example.com", Amount: 20})
+	if status != http.StatusUnprocessableEntity {
+		t.Errorf("ожидался статус 422 для ключа с другим запросом, а получен: %d", status)
+	}
+
+	// параллельные повторы перевода выполняются один раз
+	transferKey := uuid.New().String()
+	var wg sync.WaitGroup
+	statuses := make([]int, 5)
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i], _, _ = postIdempotent(t, testServer.URL+"/transfers", token, transferKey, transfer)
+		}()
+	}
+	wg.Wait()
+
+	for _, status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("ожидался статус 200 для всех повторов перевода, а получены: %v", statuses)
+			break
+		}
+	}
+	var balance float64
+	err = TestDB.QueryRow("SELECT balance FROM users WHERE email = 'sender@example.com'").Scan(&balance)
+	if err != nil || balance != 80 {
+		t.Errorf("ожидался баланс 80 после двух переводов, а получен: %v (%v)", balance, err)
+	}
+
+	// ключи разных пользователей не пересекаются
+	other := registerAndLogin(t, testServer.URL, "other-sender@example.com", pw)
+	otherToken, _ := other["access-token"].(string)
+	status, _, header = postIdempotent(t, testServer.URL+"/transfers", otherToken, transferKey, transfer)
+	if header.Get("Idempotent-Replayed") != "" {
+		t.Errorf("ключ другого пользователя не должен давать сохранённый ответ, а получен статус: %d", status)
+	}
+}
@@ -0,0 +1,92 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"testing"
+)
+
+// loginAsAdmin - регистрирует пользователя, назначает ему роль admin и возвращает его access-токен
+func loginAsAdmin(t *testing.T, baseURL string, email string) string {
+	t.Helper()
+
+	const adminPassword = "admin-test-pass-1"
+	registerAndLogin(t, baseURL, email, adminPassword)
+
+	testRepo := repository.NewUserRepository(TestDB, logger)
+	err := testRepo.SetUserRoleByEmail(context.Background(), email, middleware.RoleAdmin)
+	if err != nil {
+		t.Fatalf("ошибка при назначении роли admin: %v", err)
+	}
+
+	// роль попадает в токен при входе, поэтому логинимся заново
+	token, _ := loginWithCookie(t, baseURL, email, adminPassword)
+	return token
+}
+
+func TestImpersonation(t *testing.T) {
+	deleteTestUsers(TestDB)
+	users, err := seedTestUsers(TestDB)
+	if err != nil {
+		t.Fatalf("ошибка при добавлении пользователей в таблицу тестовой БД: %v", err)
+	}
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	adminToken := loginAsAdmin(t, testServer.URL, "support@example.com")
+	alice := users["alice@example.com"]
+	impersonatePath := "/users/" + alice.PublicID + "/impersonate"
+	url := testServer.URL + impersonatePath
+
+	// без причины нельзя
+	resp := postJSON(t, url, adminToken, model.ImpersonateRequest{})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("без причины: ожидался статус 400, а получен: %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, url, adminToken, model.ImpersonateRequest{Reason: "ticket 42: balance question"})
+	var imp model.ImpersonationResponse
+	err = decodeJSON(resp, &imp)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус 200 и токен, а получен: %d (%v)", resp.StatusCode, err)
+	}
+
+	// API видно так же, как его видит пользователь
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/me", imp.AccessToken, nil)
+	var me model.User
+	err = decodeJSON(resp, &me)
+	resp.Body.Close()
+	if err != nil || me.PublicID != alice.PublicID {
+		t.Fatalf("ожидался /me пользователя %s, а получен %s (%v)", alice.PublicID, me.PublicID, err)
+	}
+	if imp.UserID != alice.PublicID {
+		t.Errorf("ожидался user_id %s в ответе, а получен %s", alice.PublicID, imp.UserID)
+	}
+
+	// чувствительные операции запрещены
+	blocked := []struct {
+		method string
+		path   string
+		body   any
+	}{
+		{http.MethodPost, "/transfers", model.TransferRequest{ToID: users["bob@example.com"].PublicID, Amount: 1}},
+		{http.MethodPost, "/me/password", model.ChangePasswordRequest{CurrentPassword: "x", NewPassword: "new-long-test-pass-2"}},
+		{http.MethodPost, impersonatePath, model.ImpersonateRequest{Reason: "nested"}},
+		{http.MethodPatch, "/me", map[string]string{"email": "taken-over@example.com"}},
+		{http.MethodPatch, "/users/" + alice.PublicID, map[string]string{"email": "taken-over@example.com"}},
+		{http.MethodDelete, "/users/" + alice.PublicID, nil},
+	}
+	for _, b := range blocked {
+		resp = doJSON(t, b.method, testServer.URL+b.path, imp.AccessToken, b.body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s при имперсонации: ожидался статус 403, а получен: %d", b.method, b.path, resp.StatusCode)
+		}
+	}
+}
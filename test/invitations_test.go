@@ -0,0 +1,143 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"pet/config"
+	"pet/internal/mailer"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/server"
+	"testing"
+)
+
+func TestInvitationOnboarding(t *testing.T) {
+	deleteTestUsers(TestDB)
+	_, err := TestDB.Exec("DELETE FROM invitations")
+	if err != nil {
+		t.Fatalf("ошибка при очистке invitations: %v", err)
+	}
+
+	mailDir := t.TempDir()
+	server.InitMailer(&mailer.FileMailer{Dir: mailDir, From: "no-reply@example.com"})
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	adminToken := loginAsAdmin(t, testServer.URL, "invite-admin@example.com")
+
+	invite := func(email string, role string) (int, model.Invitation) {
+		resp := postJSON(t, testServer.URL+"/admin/invitations", adminToken, model.CreateInvitationRequest{Email: email, Role: role})
+		defer resp.Body.Close()
+
+		var invitation model.Invitation
+		if resp.StatusCode == http.StatusCreated {
+			err := decodeJSON(resp, &invitation)
+			if err != nil {
+				t.Fatalf("ошибка при декодировании приглашения: %v", err)
+			}
+		}
+		return resp.StatusCode, invitation
+	}
+	accept := func(token string, pw string) *http.Response {
+		return postJSON(t, testServer.URL+"/invitations/accept", "", model.AcceptInvitationRequest{
+			Token: token, Name: "Invited", Age: 25, Password: pw,
+		})
+	}
+
+	// открытая регистрация выключена — /register недоступен, приглашение работает
+	config.RegistrationOpen = false
+	defer func() { config.RegistrationOpen = true }()
+
+	resp := postJSON(t, testServer.URL+"/register", "", model.RegisterRequest{
+		Name: "Test", Age: 30, Email: "self@example.com", Password: "long-test-pass-1",
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("ожидался статус 403 при закрытой регистрации, а получен: %d", resp.StatusCode)
+	}
+
+	status, invitation := invite("invited@example.com", middleware.RoleEditor)
+	if status != http.StatusCreated || invitation.Status != model.InvitationPending {
+		t.Fatalf("ожидался статус 201 и приглашение pending, а получено: %d (%+v)", status, invitation)
+	}
+	token := lastMagicToken(t, mailDir)
+
+	// пароль проверяется политикой
+	resp = accept(token, "short")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("ожидался статус 422 для слабого пароля, а получен: %d", resp.StatusCode)
+	}
+
+	resp = accept(token, "long-test-pass-1")
+	var user model.User
+	err = decodeJSON(resp, &user)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || user.Email != "invited@example.com" || user.Role != middleware.RoleEditor {
+		t.Fatalf("ожидался статус 201 и пользователь с ролью editor, а получено: %d (%+v, %v)", resp.StatusCode, user, err)
+	}
+	loginWithCookie(t, testServer.URL, "invited@example.com", "long-test-pass-1")
+
+	// приглашение одноразовое
+	resp = accept(token, "long-test-pass-1")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("ожидался статус 404 при повторном принятии, а получен: %d", resp.StatusCode)
+	}
+
+	// зарегистрированный e-mail пригласить нельзя
+	if status, _ := invite("invited@example.com", middleware.RoleGuest); status != http.StatusConflict {
+		t.Errorf("ожидался статус 409 для занятого e-mail, а получен: %d", status)
+	}
+
+	// отозванное приглашение не принимается
+	_, revoked := invite("revoked@example.com", middleware.RoleGuest)
+	revokedToken := lastMagicToken(t, mailDir)
+	resp = doJSON(t, http.MethodDelete, fmt.Sprintf("%s/admin/invitations/%d", testServer.URL, revoked.ID), adminToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("ожидался статус 204 при отзыве, а получен: %d", resp.StatusCode)
+	}
+	resp = accept(revokedToken, "long-test-pass-1")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("ожидался статус 404 для отозванного приглашения, а получен: %d", resp.StatusCode)
+	}
+
+	// истёкшее приглашение не принимается
+	_, expired := invite("expired@example.com", middleware.RoleGuest)
+	expiredToken := lastMagicToken(t, mailDir)
+	_, err = TestDB.Exec("UPDATE invitations SET expires_at = now() - interval '1 minute' WHERE id = $1", expired.ID)
+	if err != nil {
+		t.Fatalf("ошибка при изменении срока приглашения: %v", err)
+	}
+	resp = accept(expiredToken, "long-test-pass-1")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("ожидался статус 404 для истёкшего приглашения, а получен: %d", resp.StatusCode)
+	}
+
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/admin/invitations", adminToken, nil)
+	var invitations []model.Invitation
+	err = decodeJSON(resp, &invitations)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("ошибка при декодировании списка приглашений: %v", err)
+	}
+
+	statuses := map[string]string{}
+	for _, inv := range invitations {
+		statuses[inv.Email] = inv.Status
+	}
+	expected := map[string]string{
+		"invited@example.com": model.InvitationAccepted,
+		"revoked@example.com": model.InvitationRevoked,
+		"expired@example.com": model.InvitationExpired,
+	}
+	for email, status := range expected {
+		if statuses[email] != status {
+			t.Errorf("приглашение %s: ожидался статус %s, а получен %q", email, status, statuses[email])
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"pet/config"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"pet/internal/service"
+	"testing"
+)
+
+func TestLoginBackoffAndUnlock(t *testing.T) {
+	deleteTestUsers(TestDB)
+	_, err := TestDB.Exec("DELETE FROM login_throttle")
+	if err != nil {
+		t.Fatalf("ошибка при очистке login_throttle: %v", err)
+	}
+
+	// задержка по IP включается после последней неудачной попытки ниже (2 * LoginBackoffAfter)
+	saved := config.LoginMaxFailuresPerIP
+	config.LoginMaxFailuresPerIP = 4 * config.LoginBackoffAfter
+	defer func() { config.LoginMaxFailuresPerIP = saved }()
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email, password = "lockout@example.com", "long-test-pass-1"
+	registerAndLogin(t, testServer.URL, email, password)
+
+	login := func(email string, password string) *http.Response {
+		return postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Email: email, Password: password})
+	}
+
+	// существующий и несуществующий e-mail должны вести себя одинаково
+	for _, target := range []string{email, "nobody@example.com"} {
+		for i := 0; i < config.LoginBackoffAfter; i++ {
+			resp := login(target, "wrong-password")
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("%s: попытка %d: ожидался статус 401, а получен: %d", target, i+1, resp.StatusCode)
+			}
+		}
+
+		// после порога включается задержка — даже верный пароль отклоняется
+		resp := login(target, password)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests {
+			t.Fatalf("%s: ожидался статус 429, а получен: %d", target, resp.StatusCode)
+		}
+		if resp.Header.Get("Retry-After") == "" {
+			t.Errorf("%s: ожидался заголовок Retry-After", target)
+		}
+	}
+
+	// после снятия блокировки администратором вход снова работает: сброшены и счётчик аккаунта, и счётчик IP
+	testRepo := repository.NewUserRepository(TestDB, logger)
+	err = service.NewLoginGuard(testRepo, logger).Unlock(context.Background(), email, 0)
+	if err != nil {
+		t.Fatalf("ошибка при снятии блокировки: %v", err)
+	}
+
+	resp := login(email, password)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус 200 после снятия блокировки, а получен: %d", resp.StatusCode)
+	}
+
+}
+
+func TestReauthThrottled(t *testing.T) {
+	deleteTestUsers(TestDB)
+	_, err := TestDB.Exec("DELETE FROM login_throttle")
+	if err != nil {
+		t.Fatalf("ошибка при очистке login_throttle: %v", err)
+	}
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email, password = "reauth@example.com", "long-test-pass-1"
+	registerAndLogin(t, testServer.URL, email, password)
+	token, _ := loginWithCookie(t, testServer.URL, email, password)
+
+	// с украденным access-токеном пароль нельзя подбирать без ограничений
+	for i := 0; i < config.LoginBackoffAfter; i++ {
+		resp := doJSON(t, http.MethodPost, testServer.URL+"/me/password", token,
+			model.ChangePasswordRequest{CurrentPassword: "wrong-password", NewPassword: "new-long-test-pass-2"})
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("попытка %d: ожидался статус 401, а получен: %d", i+1, resp.StatusCode)
+		}
+	}
+
+	// счётчик общий для смены пароля, удаления аккаунта и входа
+	resp := doJSON(t, http.MethodDelete, testServer.URL+"/me", token, model.DeleteAccountRequest{Password: password})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("ожидался статус 429 с Retry-After при удалении аккаунта, а получен: %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Email: email, Password: password})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("ожидался статус 429 при входе, а получен: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,115 @@
+package test
+
+import (
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"pet/config"
+	"pet/internal/mailer"
+	"pet/internal/model"
+	"pet/internal/server"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+// lastMagicToken - достаёт токен из последнего письма, записанного FileMailer.
+// Письмо отправляется в фоне после ответа, поэтому его приходится подождать
+func lastMagicToken(t *testing.T, dir string) string {
+	t.Helper()
+
+	var files []string
+	var err error
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		files, err = filepath.Glob(filepath.Join(dir, "*.eml"))
+		if err != nil || len(files) > 0 {
+			break
+		}
+	}
+	if err != nil || len(files) == 0 {
+		t.Fatalf("письмо не найдено в %s: %v", dir, err)
+	}
+	sort.Strings(files)
+
+	data, err := os.ReadFile(files[len(files)-1])
+	if err != nil {
+		t.Fatalf("ошибка при чтении письма: %v", err)
+	}
+
+	match := regexp.MustCompile(`token=(\S+)`).FindSubmatch(data)
+	if match == nil {
+		t.Fatalf("в письме нет ссылки с токеном:\n%s", data)
+	}
+
+	token, err := url.QueryUnescape(string(match[1]))
+	if err != nil {
+		t.Fatalf("ошибка при разборе токена: %v", err)
+	}
+	return token
+}
+
+func TestMagicLinkLogin(t *testing.T) {
+	deleteTestUsers(TestDB)
+	_, err := TestDB.Exec("DELETE FROM magic_link_requests")
+	if err != nil {
+		t.Fatalf("ошибка при очистке magic_link_requests: %v", err)
+	}
+
+	mailDir := t.TempDir()
+	server.InitMailer(&mailer.FileMailer{Dir: mailDir, From: "no-reply@example.com"})
+	config.MagicLinkEnabled = true
+	defer func() { config.MagicLinkEnabled = false }()
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email = "magic@example.com"
+	registerAndLogin(t, testServer.URL, email, "long-test-pass-1")
+
+	requestLink := func(email string) int {
+		resp := postJSON(t, testServer.URL+"/login/magic", "", model.MagicLinkRequest{Email: email})
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := requestLink(email); status != http.StatusAccepted {
+		t.Fatalf("ожидался статус 202, а получен: %d", status)
+	}
+	token := lastMagicToken(t, mailDir)
+
+	resp := postJSON(t, testServer.URL+"/login/magic/redeem", "", model.RedeemMagicLinkRequest{Token: token})
+	var body map[string]string
+	err = decodeJSON(resp, &body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || body["access-token"] == "" {
+		t.Fatalf("ожидался статус 200 и access-токен, а получен: %d", resp.StatusCode)
+	}
+
+	// ссылка одноразовая
+	resp = postJSON(t, testServer.URL+"/login/magic/redeem", "", model.RedeemMagicLinkRequest{Token: token})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("повторное использование: ожидался статус 401, а получен: %d", resp.StatusCode)
+	}
+
+	// для незарегистрированного e-mail ответ такой же, но письма нет
+	before, _ := filepath.Glob(filepath.Join(mailDir, "*.eml"))
+	if status := requestLink("nobody@example.com"); status != http.StatusAccepted {
+		t.Errorf("незарегистрированный e-mail: ожидался статус 202, а получен: %d", status)
+	}
+	time.Sleep(200 * time.Millisecond) // письмо, если бы оно было, отправилось бы в фоне
+	after, _ := filepath.Glob(filepath.Join(mailDir, "*.eml"))
+	if len(after) != len(before) {
+		t.Errorf("для незарегистрированного e-mail не должно быть письма")
+	}
+
+	// ограничение частоты на один e-mail
+	for i := 1; i < config.MagicLinkMaxPerWindow; i++ {
+		requestLink(email)
+	}
+	if status := requestLink(email); status != http.StatusTooManyRequests {
+		t.Errorf("превышение лимита: ожидался статус 429, а получен: %d", status)
+	}
+}
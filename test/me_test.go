@@ -0,0 +1,134 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"pet/internal/model"
+	"testing"
+)
+
+// doJSON - выполняет запрос произвольным методом с JSON-телом и access-токеном
+func doJSON(t *testing.T, method string, url string, token string, body any) *http.Response {
+	t.Helper()
+
+	reader := &bytes.Buffer{}
+	if body != nil {
+		bytesBody, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("ошибка при инкодировании тела запроса в JSON: %v", err)
+		}
+		reader = bytes.NewBuffer(bytesBody)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		t.Fatalf("ошибка при создании %s-запроса: %v", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("ошибка при выполнении %s-запроса: %v", method, err)
+	}
+	return resp
+}
+
+func TestMeSelfService(t *testing.T) {
+	deleteTestUsers(TestDB)
+	users, err := seedTestUsers(TestDB)
+	if err != nil {
+		t.Fatalf("ошибка при добавлении пользователей в таблицу тестовой БД: %v", err)
+	}
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email, password = "me@example.com", "long-test-pass-1"
+	loginBody := registerAndLogin(t, testServer.URL, email, password)
+	token, _ := loginBody["access-token"].(string)
+
+	// без токена /me недоступен
+	resp := doJSON(t, http.MethodGet, testServer.URL+"/me", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для /me без токена, а получен: %d", resp.StatusCode)
+	}
+
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/me", token, nil)
+	defer resp.Body.Close()
+	var me map[string]any
+	_ = decodeJSON(resp, &me)
+	if resp.StatusCode != http.StatusOK || me["email"] != email {
+		t.Fatalf("ожидался свой профиль, получено: %d %v", resp.StatusCode, me)
+	}
+	if _, leaked := me["HashedPassword"]; leaked {
+		t.Error("хеш пароля не должен попадать в ответ")
+	}
+	myID, _ := me["id"].(string)
+
+	// PATCH /me меняет имя
+	resp = doJSON(t, http.MethodPatch, testServer.URL+"/me", token, model.UpdateProfileRequest{Name: model.StrPtr("Renamed")})
+	defer resp.Body.Close()
+	var patched model.User
+	_ = decodeJSON(resp, &patched)
+	if resp.StatusCode != http.StatusOK || patched.Name != "Renamed" {
+		t.Errorf("ожидалось имя Renamed, получено: %d %+v", resp.StatusCode, patched)
+	}
+
+	// занятый e-mail — конфликт, а не внутренняя ошибка
+	resp = doJSON(t, http.MethodPatch, testServer.URL+"/me", token, model.UpdateProfileRequest{Email: model.StrPtr("bob@example.com")})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("ожидался статус 409 для занятого e-mail, а получен: %d", resp.StatusCode)
+	}
+
+	// guest может менять свой /users/{id}, но не чужой
+	resp = doJSON(t, http.MethodPatch, testServer.URL+"/users/"+myID, token, model.PartialUser{PublicID: myID, Age: new(int)})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("ожидался статус 200 при изменении своего /users/{id}, а получен: %d", resp.StatusCode)
+	}
+
+	otherID := users["bob@example.com"].PublicID
+	resp = doJSON(t, http.MethodPatch, testServer.URL+"/users/"+otherID, token, model.PartialUser{PublicID: otherID, Age: new(int)})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("ожидался статус 403 при изменении чужого /users/{id}, а получен: %d", resp.StatusCode)
+	}
+
+	// смена пароля требует текущий пароль
+	resp = doJSON(t, http.MethodPost, testServer.URL+"/me/password", token, model.ChangePasswordRequest{CurrentPassword: "wrong-password", NewPassword: "new-long-test-pass-2"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для неверного текущего пароля, а получен: %d", resp.StatusCode)
+	}
+
+	resp = doJSON(t, http.MethodPost, testServer.URL+"/me/password", token, model.ChangePasswordRequest{CurrentPassword: password, NewPassword: "new-long-test-pass-2"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("ожидался статус 204 при смене пароля, а получен: %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Email: email, Password: "new-long-test-pass-2"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("ожидался успешный вход с новым паролем, а получен статус: %d", resp.StatusCode)
+	}
+
+	// удаление своего аккаунта
+	resp = doJSON(t, http.MethodDelete, testServer.URL+"/me", token, model.DeleteAccountRequest{Password: "new-long-test-pass-2"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("ожидался статус 204 при удалении аккаунта, а получен: %d", resp.StatusCode)
+	}
+
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/me", token, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("ожидался статус 404 для удалённого аккаунта, а получен: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,57 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"pet/config"
+	"strings"
+	"testing"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	deleteTestUsers(TestDB)
+	users, err := seedTestUsers(TestDB)
+	if err != nil {
+		t.Fatalf("ошибка при добавлении тестовых пользователей: %v", err)
+	}
+
+	config.MetricsToken = "metrics-secret"
+	defer func() { config.MetricsToken = "" }()
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	registerAndLogin(t, testServer.URL, "metrics@example.com", "long-test-pass-1")
+
+	resp := doJSON(t, http.MethodGet, testServer.URL+"/users/"+users["alice@example.com"].PublicID, "", nil)
+	resp.Body.Close()
+
+	// без токена метрики недоступны
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/metrics", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 без токена, а получен: %d", resp.StatusCode)
+	}
+
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/metrics", "metrics-secret", nil)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус 200 с токеном, а получен: %d (%v)", resp.StatusCode, err)
+	}
+
+	for _, want := range []string{
+		// шаблон маршрута, а не путь с конкретным ID
+		`users_api_http_request_duration_seconds_count{method="GET",route="/users/{id}",status="200"}`,
+		`users_api_http_requests_in_flight{method="GET",route="/metrics"} 1`,
+		`users_api_registrations_total{source="register"}`,
+		`users_api_logins_total{method="password"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("в метриках нет строки %q", want)
+		}
+	}
+	if strings.Contains(string(body), users["alice@example.com"].PublicID) {
+		t.Error("в метриках не должно быть путей с ID")
+	}
+}
@@ -0,0 +1,170 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/pquerna/otp/totp"
+	"net/http"
+	"pet/internal/model"
+	"testing"
+	"time"
+)
+
+// postJSON - отправляет POST-запрос с JSON-телом и (необязательно) access-токеном
+func postJSON(t *testing.T, url string, token string, body any) *http.Response {
+	t.Helper()
+
+	bytesBody, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("ошибка при инкодировании тела запроса в JSON: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(bytesBody))
+	if err != nil {
+		t.Fatalf("ошибка при создании POST-запроса: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("ошибка при выполнении POST-запроса: %v", err)
+	}
+	return resp
+}
+
+// decodeJSON - декодирует JSON-тело ответа
+func decodeJSON(resp *http.Response, v any) error {
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// registerAndLogin - регистрирует пользователя через /register, логинится через /login
+// и возвращает тело ответа /login
+func registerAndLogin(t *testing.T, baseURL string, email string, password string) map[string]any {
+	t.Helper()
+
+	resp := postJSON(t, baseURL+"/register", "", model.RegisterRequest{
+		Name: "Test", Age: 30, Email: email, Password: password,
+	})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("ожидался статус регистрации 201, а получен: %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, baseURL+"/login", "", model.LoginRequest{Email: email, Password: password})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус логина 200, а получен: %d", resp.StatusCode)
+	}
+
+	var body map[string]any
+	err := json.NewDecoder(resp.Body).Decode(&body)
+	if err != nil {
+		t.Fatalf("ошибка при декодировании ответа /login: %v", err)
+	}
+	return body
+}
+
+func TestTOTPEnrollAndLogin(t *testing.T) {
+	deleteTestUsers(TestDB)
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email, password = "totp@example.com", "long-test-pass-1"
+	loginBody := registerAndLogin(t, testServer.URL, email, password)
+	accessToken, _ := loginBody["access-token"].(string)
+	if accessToken == "" {
+		t.Fatalf("ожидался access-token до подключения TOTP, получено: %v", loginBody)
+	}
+
+	// 1. начало подключения — получаем секрет
+	resp := postJSON(t, testServer.URL+"/me/mfa/totp", accessToken, struct{}{})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус 200 при подключении TOTP, а получен: %d", resp.StatusCode)
+	}
+
+	var enroll model.TOTPEnrollResponse
+	err := json.NewDecoder(resp.Body).Decode(&enroll)
+	if err != nil {
+		t.Fatalf("ошибка при декодировании ответа: %v", err)
+	}
+	if enroll.Secret == "" || enroll.URI == "" || enroll.QRCode == "" {
+		t.Fatalf("ожидались secret, uri и qr_code, получено: %+v", enroll)
+	}
+
+	// 2. подтверждение кодом — получаем коды восстановления
+	code, err := totp.GenerateCode(enroll.Secret, time.Now())
+	if err != nil {
+		t.Fatalf("ошибка при генерации TOTP-кода: %v", err)
+	}
+
+	resp = postJSON(t, testServer.URL+"/me/mfa/totp/confirm", accessToken, model.MFACodeRequest{Code: code})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус 200 при подтверждении TOTP, а получен: %d", resp.StatusCode)
+	}
+
+	var recovery model.RecoveryCodesResponse
+	err = json.NewDecoder(resp.Body).Decode(&recovery)
+	if err != nil {
+		t.Fatalf("ошибка при декодировании ответа: %v", err)
+	}
+	if len(recovery.RecoveryCodes) == 0 {
+		t.Fatal("ожидались коды восстановления")
+	}
+
+	// 3. теперь /login возвращает только mfa-token
+	resp = postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Email: email, Password: password})
+	defer resp.Body.Close()
+
+	var challenge map[string]any
+	err = json.NewDecoder(resp.Body).Decode(&challenge)
+	if err != nil {
+		t.Fatalf("ошибка при декодировании ответа /login: %v", err)
+	}
+	if _, ok := challenge["access-token"]; ok {
+		t.Fatalf("при подключённом TOTP /login не должен выдавать access-token: %v", challenge)
+	}
+	mfaToken, _ := challenge["mfa-token"].(string)
+	if mfaToken == "" {
+		t.Fatalf("ожидался mfa-token, получено: %v", challenge)
+	}
+
+	// mfa-token не даёт доступа к защищённым ручкам
+	resp = postJSON(t, testServer.URL+"/me/mfa/totp", mfaToken, struct{}{})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("mfa-token не должен проходить Auth, получен статус: %d", resp.StatusCode)
+	}
+
+	// 4. неверный код — 401
+	resp = postJSON(t, testServer.URL+"/login/mfa", "", model.MFALoginRequest{MFAToken: mfaToken, Code: "000000-bad"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для неверного кода, а получен: %d", resp.StatusCode)
+	}
+
+	// код, которым подтверждали TOTP, повторно не принимается, хотя ещё не истёк
+	resp = postJSON(t, testServer.URL+"/login/mfa", "", model.MFALoginRequest{MFAToken: mfaToken, Code: code})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для уже использованного кода, а получен: %d", resp.StatusCode)
+	}
+
+	// 5. код восстановления — одноразовый
+	resp = postJSON(t, testServer.URL+"/login/mfa", "", model.MFALoginRequest{MFAToken: mfaToken, Code: recovery.RecoveryCodes[0]})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус 200 для кода восстановления, а получен: %d", resp.StatusCode)
+	}
+
+	resp = postJSON(t, testServer.URL+"/login/mfa", "", model.MFALoginRequest{MFAToken: mfaToken, Code: recovery.RecoveryCodes[0]})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("повторное использование кода восстановления должно давать 401, получен: %d", resp.StatusCode)
+	}
+}
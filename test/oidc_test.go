@@ -0,0 +1,217 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"pet/config"
+	"pet/internal/model"
+	"pet/internal/server"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const mockClientID = "users-api-test"
+
+// mockOIDCProvider - минимальный OIDC-провайдер в памяти: discovery, JWKS, /authorize и /token с проверкой PKCE
+type mockOIDCProvider struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu      sync.Mutex
+	pending map[string]url.Values // код авторизации -> параметры запроса /authorize
+
+	// пользователь, который "входит" у провайдера
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("ошибка при генерации RSA-ключа: %v", err)
+	}
+
+	p := &mockOIDCProvider{key: key, pending: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                p.URL,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "test", "alg": "RS256", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		code := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+		p.mu.Lock()
+		p.pending[code] = query
+		p.mu.Unlock()
+
+		redirect := query.Get("redirect_uri") + "?" + url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect, http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		p.mu.Lock()
+		auth, ok := p.pending[r.Form.Get("code")]
+		delete(p.pending, r.Form.Get("code"))
+		p.mu.Unlock()
+
+		sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+		if !ok || auth.Get("code_challenge_method") != "S256" ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != auth.Get("code_challenge") {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            p.URL,
+			"aud":            mockClientID,
+			"sub":            p.Subject,
+			"email":          p.Email,
+			"email_verified": p.EmailVerified,
+			"name":           "OIDC User",
+			"nonce":          auth.Get("nonce"),
+			"iat":            time.Now().Unix(),
+			"exp":            time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "mock-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   60,
+			"id_token":     idToken,
+		})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+// oidcLogin - проходит весь вход через провайдера с cookie-jar и возвращает ответ callback
+func oidcLogin(t *testing.T, baseURL string) *http.Response {
+	t.Helper()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+
+	resp, err := client.Get(baseURL + "/auth/oidc/mock/login")
+	if err != nil {
+		t.Fatalf("ошибка при входе через OIDC: %v", err)
+	}
+	return resp
+}
+
+func TestOIDCLogin(t *testing.T) {
+	deleteTestUsers(TestDB)
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	provider := newMockOIDCProvider(t)
+	defer provider.Close()
+
+	err := server.InitOIDCProviders(context.Background(), []config.OIDCProviderConfig{{
+		Name:          "mock",
+		IssuerURL:     provider.URL,
+		ClientID:      mockClientID,
+		RedirectURL:   testServer.URL + "/auth/oidc/mock/callback",
+		AutoProvision: true,
+	}}, logger)
+	if err != nil {
+		t.Fatalf("ошибка при настройке OIDC-провайдера: %v", err)
+	}
+	defer server.InitOIDCProviders(context.Background(), nil, logger)
+
+	meID := func(resp *http.Response) string {
+		t.Helper()
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("ожидался статус 200 после входа через OIDC, а получен: %d", resp.StatusCode)
+		}
+
+		var body map[string]string
+		err := decodeJSON(resp, &body)
+		if err != nil {
+			t.Fatalf("ошибка при декодировании ответа callback: %v", err)
+		}
+
+		meResp := doJSON(t, http.MethodGet, testServer.URL+"/me", body["access-token"], nil)
+		defer meResp.Body.Close()
+		var me model.User
+		err = decodeJSON(meResp, &me)
+		if err != nil {
+			t.Fatalf("ошибка при декодировании /me: %v", err)
+		}
+		return me.PublicID
+	}
+
+	// just-in-time: пользователя ещё нет, он создаётся при первом входе
+	provider.Subject, provider.Email, provider.EmailVerified = "employee-1", "employee@corp.example", true
+	firstID := meID(oidcLogin(t, testServer.URL))
+
+	// повторный вход той же учётной записью попадает в того же пользователя
+	if secondID := meID(oidcLogin(t, testServer.URL)); secondID != firstID {
+		t.Errorf("повторный вход: ожидался пользователь %s, а получен %s", firstID, secondID)
+	}
+
+	// подтверждённый провайдером e-mail привязывается к уже зарегистрированному пользователю
+	registerAndLogin(t, testServer.URL, "linked@corp.example", "long-test-pass-1")
+	provider.Subject, provider.Email = "employee-2", "linked@corp.example"
+	linkedID := meID(oidcLogin(t, testServer.URL))
+	if linkedID == firstID {
+		t.Errorf("ожидалась привязка к зарегистрированному пользователю, а не к %s", firstID)
+	}
+
+	// неподтверждённый e-mail чужого аккаунта привязать нельзя
+	provider.Subject, provider.EmailVerified = "attacker", false
+	resp := oidcLogin(t, testServer.URL)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("неподтверждённый e-mail: ожидался статус 409, а получен: %d", resp.StatusCode)
+	}
+
+	// callback без state-cookie (подделанный запрос) отклоняется
+	resp, err = http.Get(testServer.URL + "/auth/oidc/mock/callback?code=x&state=y")
+	if err != nil {
+		t.Fatalf("ошибка при выполнении GET-запроса: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("callback без state: ожидался статус 401, а получен: %d", resp.StatusCode)
+	}
+}
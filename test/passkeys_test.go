@@ -0,0 +1,304 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"net/http"
+	"pet/config"
+	"pet/internal/model"
+	"testing"
+)
+
+// флаги authenticatorData: UP (пользователь присутствует), UV (пользователь проверен), AT (есть данные ключа)
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+// passkeyOptions - нужная тесту часть параметров из begin
+type passkeyOptions struct {
+	CeremonyID string `json:"ceremony_id"`
+	Options    struct {
+		Challenge string `json:"challenge"`
+		User      struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	} `json:"options"`
+}
+
+// softAuthenticator - программный аутентификатор: создаёт ключ P-256 и подписывает challenge,
+// как это сделал бы браузер с passkey, поэтому тестам не нужно устройство
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ошибка при генерации ключа аутентификатора: %v", err)
+	}
+
+	credentialID := make([]byte, 16)
+	_, err = rand.Read(credentialID)
+	if err != nil {
+		t.Fatalf("ошибка при генерации credential ID: %v", err)
+	}
+
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+// clientData - clientDataJSON, который браузер передаёт аутентификатору
+func (a *softAuthenticator) clientData(typ string, challenge string) []byte {
+	data, _ := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": challenge,
+		"origin":    config.WebAuthnOrigins[0],
+	})
+	return data
+}
+
+// authData - authenticatorData: хеш RP ID, флаги, счётчик и (при регистрации) данные ключа
+func (a *softAuthenticator) authData(flags byte, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(config.WebAuthnRPID))
+
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+// create - ответ на navigator.credentials.create с аттестацией "none"
+func (a *softAuthenticator) create(t *testing.T, options passkeyOptions) json.RawMessage {
+	t.Helper()
+
+	userHandle, err := base64.RawURLEncoding.DecodeString(options.Options.User.ID)
+	if err != nil {
+		t.Fatalf("ошибка при разборе user.id: %v", err)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  1, // P-256
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatalf("ошибка при кодировании открытого ключа: %v", err)
+	}
+
+	attested := make([]byte, 16) // AAGUID из нулей
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authData(flagUserPresent|flagUserVerified|flagAttestedData, attested),
+	})
+	if err != nil {
+		t.Fatalf("ошибка при кодировании attestationObject: %v", err)
+	}
+
+	return a.credential(map[string]any{
+		"clientDataJSON":    b64(a.clientData("webauthn.create", options.Options.Challenge)),
+		"attestationObject": b64(attestationObject),
+		"transports":        []string{"internal"},
+	})
+}
+
+// get - ответ на navigator.credentials.get, подписанный ключом signer
+func (a *softAuthenticator) get(t *testing.T, options passkeyOptions, signer *ecdsa.PrivateKey) json.RawMessage {
+	t.Helper()
+
+	a.signCount++
+	authData := a.authData(flagUserPresent|flagUserVerified, nil)
+	clientData := a.clientData("webauthn.get", options.Options.Challenge)
+
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, signer, digest[:])
+	if err != nil {
+		t.Fatalf("ошибка при подписи assertion: %v", err)
+	}
+
+	return a.credential(map[string]any{
+		"clientDataJSON":    b64(clientData),
+		"authenticatorData": b64(authData),
+		"signature":         b64(signature),
+		"userHandle":        b64(a.userHandle),
+	})
+}
+
+// credential - PublicKeyCredential в JSON, как его сериализует браузер
+func (a *softAuthenticator) credential(response map[string]any) json.RawMessage {
+	data, _ := json.Marshal(map[string]any{
+		"id":       b64(a.credentialID),
+		"rawId":    b64(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	return data
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// beginPasskey - вызывает begin-эндпоинт и возвращает параметры церемонии
+func beginPasskey(t *testing.T, url string, token string) passkeyOptions {
+	t.Helper()
+
+	resp := postJSON(t, url, token, nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус 200 от %s, а получен: %d", url, resp.StatusCode)
+	}
+
+	var options passkeyOptions
+	err := decodeJSON(resp, &options)
+	if err != nil || options.CeremonyID == "" || options.Options.Challenge == "" {
+		t.Fatalf("ошибка при декодировании параметров церемонии: %v", err)
+	}
+	return options
+}
+
+// loginWithPasskey - проходит begin/finish входа по ключу и возвращает ответ finish
+func loginWithPasskey(t *testing.T, baseURL string, auth *softAuthenticator, signer *ecdsa.PrivateKey) *http.Response {
+	t.Helper()
+
+	options := beginPasskey(t, baseURL+"/login/passkey/begin", "")
+	return postJSON(t, baseURL+"/login/passkey/finish", "", model.PasskeyLoginFinishRequest{
+		CeremonyID: options.CeremonyID,
+		Credential: auth.get(t, options, signer),
+	})
+}
+
+// listPasskeys - возвращает ключи текущего пользователя
+func listPasskeys(t *testing.T, baseURL string, token string) []model.Passkey {
+	t.Helper()
+
+	resp := doJSON(t, http.MethodGet, baseURL+"/me/passkeys", token, nil)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус 200 от /me/passkeys, а получен: %d", resp.StatusCode)
+	}
+
+	var passkeys []model.Passkey
+	err := decodeJSON(resp, &passkeys)
+	if err != nil {
+		t.Fatalf("ошибка при декодировании списка ключей: %v", err)
+	}
+	return passkeys
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	deleteTestUsers(TestDB)
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email = "passkey@example.com"
+	registerAndLogin(t, testServer.URL, email, "long-test-pass-1")
+	token, _ := loginWithCookie(t, testServer.URL, email, "long-test-pass-1")
+
+	auth := newSoftAuthenticator(t)
+
+	// регистрация ключа
+	options := beginPasskey(t, testServer.URL+"/me/passkeys/register/begin", token)
+	finish := model.PasskeyRegisterFinishRequest{
+		CeremonyID: options.CeremonyID,
+		Name:       "Ноутбук",
+		Credential: auth.create(t, options),
+	}
+
+	resp := postJSON(t, testServer.URL+"/me/passkeys/register/finish", token, finish)
+	var created model.Passkey
+	err := decodeJSON(resp, &created)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated || created.Name != "Ноутбук" {
+		t.Fatalf("ожидался статус 201 и ключ с названием, а получен: %d (%+v, %v)", resp.StatusCode, created, err)
+	}
+
+	// церемония одноразовая
+	resp = postJSON(t, testServer.URL+"/me/passkeys/register/finish", token, finish)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("ожидался статус 400 при повторном finish, а получен: %d", resp.StatusCode)
+	}
+
+	// вход по ключу без пароля
+	resp = loginWithPasskey(t, testServer.URL, auth, auth.key)
+	var body map[string]string
+	err = decodeJSON(resp, &body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || body["access-token"] == "" {
+		t.Fatalf("ожидался статус 200 и access-токен при входе по ключу, а получен: %d (%v)", resp.StatusCode, err)
+	}
+
+	passkeys := listPasskeys(t, testServer.URL, body["access-token"])
+	if len(passkeys) != 1 || passkeys[0].SignCount != auth.signCount || passkeys[0].LastUsedAt == nil {
+		t.Errorf("ожидался один ключ со счётчиком %d и last_used_at, а получено: %+v", auth.signCount, passkeys)
+	}
+
+	// подпись чужим ключом
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ошибка при генерации ключа: %v", err)
+	}
+	resp = loginWithPasskey(t, testServer.URL, auth, otherKey)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для неверной подписи, а получен: %d", resp.StatusCode)
+	}
+
+	// счётчик не вырос — похоже на клон ключа
+	auth.signCount = 0
+	resp = loginWithPasskey(t, testServer.URL, auth, auth.key)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для невыросшего счётчика, а получен: %d", resp.StatusCode)
+	}
+
+	// переименование и удаление
+	passkeyURL := fmt.Sprintf("%s/me/passkeys/%d", testServer.URL, created.ID)
+	resp = doJSON(t, http.MethodPatch, passkeyURL, token, model.RenamePasskeyRequest{Name: "Телефон"})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("ожидался статус 200 при переименовании, а получен: %d", resp.StatusCode)
+	}
+	if passkeys = listPasskeys(t, testServer.URL, token); len(passkeys) != 1 || passkeys[0].Name != "Телефон" {
+		t.Errorf("ожидалось новое название ключа, а получено: %+v", passkeys)
+	}
+
+	resp = doJSON(t, http.MethodDelete, passkeyURL, token, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("ожидался статус 204 при удалении, а получен: %d", resp.StatusCode)
+	}
+
+	auth.signCount = 100
+	resp = loginWithPasskey(t, testServer.URL, auth, auth.key)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("ожидался статус 401 для удалённого ключа, а получен: %d", resp.StatusCode)
+	}
+}
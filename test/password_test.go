@@ -0,0 +1,117 @@
+package test
+
+import (
+	"errors"
+	"golang.org/x/crypto/bcrypt"
+	"net/http"
+	"pet/config"
+	"pet/internal/model"
+	"pet/internal/password"
+	"strings"
+	"testing"
+)
+
+func TestPasswordHashAndRehash(t *testing.T) {
+	hash, err := password.Hash("long-test-pass-1")
+	if err != nil {
+		t.Fatalf("ошибка при хешировании пароля: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Fatalf("ожидался хеш argon2id, а получен: %s", hash)
+	}
+
+	ok, needsRehash, err := password.Verify("long-test-pass-1", hash)
+	if err != nil || !ok || needsRehash {
+		t.Errorf("верный пароль: ожидалось ok=true, needsRehash=false, а получено %v, %v (%v)", ok, needsRehash, err)
+	}
+
+	ok, _, err = password.Verify("wrong-password", hash)
+	if err != nil || ok {
+		t.Errorf("неверный пароль: ожидалось ok=false, а получено %v (%v)", ok, err)
+	}
+
+	// старый bcrypt-хеш проверяется, но требует пересчёта
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("long-test-pass-1"), bcrypt.MinCost)
+	ok, needsRehash, err = password.Verify("long-test-pass-1", string(legacy))
+	if err != nil || !ok || !needsRehash {
+		t.Errorf("bcrypt-хеш: ожидалось ok=true, needsRehash=true, а получено %v, %v (%v)", ok, needsRehash, err)
+	}
+
+	// у пользователя без пароля (вход только через OIDC) войти по паролю нельзя
+	_, _, err = password.Verify("", "")
+	if !errors.Is(err, password.ErrUnknownAlgorithm) {
+		t.Errorf("пустой хеш: ожидалась ошибка ErrUnknownAlgorithm, а получено: %v", err)
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	cases := []struct {
+		password string
+		valid    bool
+	}{
+		{"long-test-pass-1", true},
+		{"short1", false},                  // короче минимальной длины
+		{"no-digits-here", false},          // нет цифры
+		{"PASSWORD123", false},             // в списке распространённых паролей (без учёта регистра)
+		{"alice.smith1", false},            // совпадает с e-mail
+		{strings.Repeat("a1", 100), false}, // длиннее максимальной длины
+	}
+
+	for _, c := range cases {
+		err := password.Validate(c.password, "alice.smith1@example.com")
+
+		var policyErr *password.PolicyError
+		if c.valid && err != nil {
+			t.Errorf("пароль %q: ожидалось соответствие политике, а получено: %v", c.password, err)
+		}
+		if !c.valid && !errors.As(err, &policyErr) {
+			t.Errorf("пароль %q: ожидалось нарушение политики, а получено: %v", c.password, err)
+		}
+	}
+
+	// с bcrypt пароль ограничен 72 байтами, даже если политика разрешает больше символов
+	savedAlgorithm := config.PasswordAlgorithm
+	config.PasswordAlgorithm = "bcrypt"
+	password.Init()
+	defer func() {
+		config.PasswordAlgorithm = savedAlgorithm
+		password.Init()
+	}()
+
+	long := strings.Repeat("пароль1-", 6) // 48 символов, но 84 байта
+	var policyErr *password.PolicyError
+	if err := password.Validate(long); !errors.As(err, &policyErr) {
+		t.Errorf("bcrypt: ожидалось нарушение политики для пароля длиннее 72 байт, а получено: %v", err)
+	}
+}
+
+func TestLogin_RehashesLegacyHash(t *testing.T) {
+	deleteTestUsers(TestDB)
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email, plain = "legacy@example.com", "long-test-pass-1"
+	registerAndLogin(t, testServer.URL, email, plain)
+
+	legacy, _ := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.MinCost)
+	_, err := TestDB.Exec("UPDATE users SET password = $1 WHERE email = $2", string(legacy), email)
+	if err != nil {
+		t.Fatalf("ошибка при записи bcrypt-хеша: %v", err)
+	}
+
+	resp := postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Email: email, Password: plain})
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус логина 200, а получен: %d", resp.StatusCode)
+	}
+
+	var stored string
+	err = TestDB.QueryRow("SELECT password FROM users WHERE email = $1", email).Scan(&stored)
+	if err != nil {
+		t.Fatalf("ошибка при чтении хеша: %v", err)
+	}
+	if !strings.HasPrefix(stored, "$argon2id$") {
+		t.Errorf("ожидалось, что после входа хеш пересчитан в argon2id, а получен: %s", stored)
+	}
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"net/http"
+	"pet/config"
+	"pet/internal/middleware"
+	"pet/internal/model"
+	"pet/internal/repository"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	deleteTestUsers(TestDB)
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email, pw = "limited@example.com", "long-test-pass-1"
+	registerAndLogin(t, testServer.URL, email, pw)
+	token, _ := loginWithCookie(t, testServer.URL, email, pw)
+
+	store := middleware.NewMemoryLimiterStore()
+	store.Start()
+	middleware.InitLimiterStore(store)
+
+	config.RateLimitEnabled = true
+	saved := config.RateLimits
+	config.RateLimits = map[string]config.RateLimitPolicy{
+		config.RateLimitGroupDefault: {
+			Anonymous: config.RateLimit{Rate: 0.01, Burst: 2},
+			User:      config.RateLimit{Rate: 0.01, Burst: 3},
+		},
+		config.RateLimitGroupAuth: {
+			Anonymous: config.RateLimit{Rate: 0.01, Burst: 1},
+		},
+		config.RateLimitGroupCredentials: {
+			Anonymous: config.RateLimit{Rate: 0.01, Burst: 6},
+		},
+	}
+	defer func() {
+		config.RateLimitEnabled = false
+		config.RateLimits = saved
+		middleware.InitLimiterStore(nil)
+		store.Stop()
+	}()
+
+	get := func(url string, token string) *http.Response {
+		resp := doJSON(t, http.MethodGet, url, token, nil)
+		resp.Body.Close()
+		return resp
+	}
+
+	// анонимный клиент: лимит по IP
+	for i := 0; i < 2; i++ {
+		resp := get(testServer.URL+"/users", "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("запрос %d: ожидался статус 200, а получен: %d", i+1, resp.StatusCode)
+		}
+		if resp.Header.Get("RateLimit-Limit") != "2" || resp.Header.Get("RateLimit-Remaining") != strconv.Itoa(1-i) {
+			t.Errorf("запрос %d: неверные заголовки лимита: %v", i+1, resp.Header)
+		}
+	}
+	resp := get(testServer.URL+"/users", "")
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") == "" {
+		t.Errorf("ожидался статус 429 с Retry-After, а получено: %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// пользователь с JWT ограничивается по sub, а не по исчерпанному лимиту IP
+	for i := 0; i < 3; i++ {
+		resp := get(testServer.URL+"/me", token)
+		if resp.StatusCode != http.StatusOK || resp.Header.Get("RateLimit-Limit") != "3" {
+			t.Fatalf("запрос %d: ожидался статус 200 с лимитом пользователя, а получено: %d %v", i+1, resp.StatusCode, resp.Header)
+		}
+	}
+	if resp := get(testServer.URL+"/me", token); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("ожидался статус 429 после исчерпания лимита пользователя, а получен: %d", resp.StatusCode)
+	}
+
+	// до Auth запросы к защищённым маршрутам ограничиваются по IP: 4 запроса выше уже учтены,
+	// и перебор токенов упирается в 429, а не получает 401 без конца
+	for i := 0; i < 2; i++ {
+		if resp := get(testServer.URL+"/me", "invalid-token"); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("запрос %d с неверным токеном: ожидался статус 401, а получен: %d", i+1, resp.StatusCode)
+		}
+	}
+	if resp := get(testServer.URL+"/me", "invalid-token"); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("ожидался статус 429 для перебора токенов, а получен: %d", resp.StatusCode)
+	}
+
+	// вход — отдельная, более строгая группа
+	login := func() *http.Response {
+		resp := postJSON(t, testServer.URL+"/login", "", model.LoginRequest{Email: email, Password: pw})
+		resp.Body.Close()
+		return resp
+	}
+	if resp := login(); resp.StatusCode != http.StatusOK {
+		t.Errorf("ожидался статус 200 для первого входа, а получен: %d", resp.StatusCode)
+	}
+	if resp := login(); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("ожидался статус 429 для второго входа, а получен: %d", resp.StatusCode)
+	}
+}
+
+func TestLimiterStores(t *testing.T) {
+	_, err := TestDB.Exec("DELETE FROM rate_limits")
+	if err != nil {
+		t.Fatalf("ошибка при очистке rate_limits: %v", err)
+	}
+
+	limit := config.RateLimit{Rate: 1, Burst: 3}
+	ctx := context.Background()
+
+	// два экземпляра Postgres-хранилища делят одну квоту, как два сервера за балансировщиком
+	first := repository.NewRateLimitStore(TestDB, zap.NewNop())
+	second := repository.NewRateLimitStore(TestDB, zap.NewNop())
+
+	stores := map[string][]middleware.LimiterStore{
+		"memory":   {middleware.NewMemoryLimiterStore()},
+		"postgres": {first, second},
+	}
+
+	for name, instances := range stores {
+		for _, store := range instances {
+			store.Start()
+			defer store.Stop()
+		}
+
+		for i := 0; i < limit.Burst; i++ {
+			result, err := instances[i%len(instances)].Allow(ctx, "test|"+name, limit)
+			if err != nil || !result.Allowed || result.Remaining != limit.Burst-i-1 || result.Limit != limit.Burst {
+				t.Fatalf("%s, запрос %d: ожидался пропуск с остатком %d, а получено: %+v (%v)", name, i+1, limit.Burst-i-1, result, err)
+			}
+		}
+
+		result, err := instances[len(instances)-1].Allow(ctx, "test|"+name, limit)
+		if err != nil || result.Allowed || result.RetryAfter <= 0 || result.RetryAfter > time.Second {
+			t.Errorf("%s: ожидался отказ с Retry-After до 1 с, а получено: %+v (%v)", name, result, err)
+		}
+
+		// другой клиент не затронут
+		result, err = instances[0].Allow(ctx, "test|other-"+name, limit)
+		if err != nil || !result.Allowed {
+			t.Errorf("%s: ожидался пропуск для другого клиента, а получено: %+v (%v)", name, result, err)
+		}
+	}
+
+	// Stop без Start и повторный Stop не блокируются
+	idle := middleware.NewMemoryLimiterStore()
+	idle.Stop()
+	idle.Stop()
+}
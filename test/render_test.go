@@ -0,0 +1,138 @@
+package test
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"pet/internal/model"
+	"pet/internal/render"
+	"strings"
+	"testing"
+)
+
+func TestRenderNegotiation(t *testing.T) {
+	users := []model.User{
+		{PublicID: "u1", Handle: "alice", Name: "Alice", Age: 30, Email: "alice@example.com", Role: "guest", Status: "active"},
+		{PublicID: "u2", Handle: "bob", Name: "=HYPERLINK(1)", Age: 25, Email: "bob@example.com", Role: "admin", Status: "active"},
+	}
+	user := users[0]
+
+	write := func(accept string, status int, v any) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		err := render.Write(rec, req, status, v)
+		if err != nil {
+			t.Fatalf("ошибка при записи ответа: %v", err)
+		}
+		return rec
+	}
+
+	tests := []struct {
+		name        string
+		accept      string
+		status      int
+		value       any
+		wantStatus  int
+		contentType string
+	}{
+		{"без Accept", "", http.StatusOK, user, http.StatusOK, "application/json"},
+		{"любой тип", "*/*", http.StatusOK, user, http.StatusOK, "application/json"},
+		{"XML", "application/xml", http.StatusOK, user, http.StatusOK, "application/xml; charset=utf-8"},
+		{"text/xml", "text/xml", http.StatusOK, user, http.StatusOK, "application/xml; charset=utf-8"},
+		{"MessagePack", "application/x-msgpack", http.StatusOK, user, http.StatusOK, "application/msgpack"},
+		{"CSV для списка", "text/csv", http.StatusOK, users, http.StatusOK, "text/csv; charset=utf-8"},
+		{"CSV не для списка — JSON", "text/csv", http.StatusOK, user, http.StatusOK, "application/json"},
+		{"ошибка отдаётся в JSON", "text/csv", http.StatusBadRequest, map[string]string{"error": "bad"}, http.StatusBadRequest, "application/json"},
+		{"неизвестный тип", "image/png", http.StatusOK, user, http.StatusNotAcceptable, "application/json"},
+		{"выше q", "application/xml;q=0.5, application/json;q=0.9", http.StatusOK, user, http.StatusOK, "application/json"},
+		{"точный тип важнее */*", "*/*;q=0.1, application/msgpack", http.StatusOK, user, http.StatusOK, "application/msgpack"},
+		{"q=0 исключает тип", "*/*, application/json;q=0", http.StatusOK, user, http.StatusOK, "application/xml; charset=utf-8"},
+		{"text/* для списка", "text/*", http.StatusOK, users, http.StatusOK, "application/xml; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		rec := write(tt.accept, tt.status, tt.value)
+		if rec.Code != tt.wantStatus || rec.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s: ожидались %d и %q, а получено: %d и %q", tt.name, tt.wantStatus, tt.contentType,
+				rec.Code, rec.Header().Get("Content-Type"))
+		}
+	}
+
+	// поля во всех форматах называются по тегам json, скрытые поля не попадают в ответ
+	body := write("application/xml", http.StatusOK, user).Body.String()
+	if !strings.Contains(body, "<response>") || !strings.Contains(body, "<id>u1</id>") || !strings.Contains(body, "<email>alice@example.com</email>") {
+		t.Errorf("неожиданное XML-тело: %s", body)
+	}
+
+	var decoded map[string]any
+	err := msgpack.Unmarshal(write("application/msgpack", http.StatusOK, user).Body.Bytes(), &decoded)
+	if err != nil || decoded["id"] != "u1" || decoded["handle"] != "alice" {
+		t.Errorf("неожиданное тело MessagePack: %v (%v)", decoded, err)
+	}
+
+	// формула в ячейке экранируется апострофом
+	csvBody := write("text/csv", http.StatusOK, users).Body.String()
+	wantCSV := "id,handle,name,age,email,role,status,balance\n" +
+		"u1,alice,Alice,30,alice@example.com,guest,active,0\n" +
+		"u2,bob,'=HYPERLINK(1),25,bob@example.com,admin,active,0\n"
+	if csvBody != wantCSV {
+		t.Errorf("неожиданное CSV-тело:\n%s\nожидалось:\n%s", csvBody, wantCSV)
+	}
+}
+
+func TestContentNegotiationRoutes(t *testing.T) {
+	deleteTestUsers(TestDB)
+	users, err := seedTestUsers(TestDB)
+	if err != nil {
+		t.Fatalf("ошибка при добавлении тестовых пользователей: %v", err)
+	}
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	get := func(path string, accept string) (*http.Response, string) {
+		req, err := http.NewRequest(http.MethodGet, testServer.URL+path, nil)
+		if err != nil {
+			t.Fatalf("ошибка при создании запроса: %v", err)
+		}
+		req.Header.Set("Accept", accept)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("ошибка при запросе: %v", err)
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := get("/users", "text/csv")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(body, "id,handle,name,age,email,role,status,balance\n") ||
+		!strings.Contains(body, "alice@example.com") {
+		t.Errorf("ожидался список в CSV, а получено: %d %q", resp.StatusCode, body)
+	}
+
+	// запрос с Accept: text/csv к маршруту, который отвечает не списком, выполняется, и ответ отдаётся в JSON
+	resp, body = get("/users/"+users["alice@example.com"].PublicID, "text/csv")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/json" ||
+		!strings.Contains(body, "alice@example.com") {
+		t.Errorf("ожидался пользователь в JSON, а получено: %d %q %q", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+
+	// клиент не принимает ни одного формата — 406 ещё до обработчика
+	resp, body = get("/users", "image/png")
+	if resp.StatusCode != http.StatusNotAcceptable || !strings.Contains(body, "application/msgpack") {
+		t.Errorf("ожидался статус 406 со списком форматов, а получено: %d %q", resp.StatusCode, body)
+	}
+
+	// текстовые ошибки больше не помечаются как JSON
+	resp, _ = get("/me", "application/json")
+	if resp.StatusCode != http.StatusUnauthorized || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Errorf("ожидался статус 401 с text/plain, а получено: %d %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+}
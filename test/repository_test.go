@@ -1,17 +1,19 @@
 package test
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"log"
-	"os"
 	"pet/internal/model"
 	"pet/internal/repository"
 	"testing"
 )
 
-var logger = log.New(os.Stdout, "[TEST]", log.LstdFlags)
+var logger = zap.NewNop()
 
 // TestDatabaseConnection проверяет, что тестовый сервер работает и БД подключена
 func TestDatabaseConnection(t *testing.T) {
@@ -32,7 +34,7 @@ func seedTestUsers(testBD *sql.DB) (map[string]model.User, error) {
 	    ('Alice', 30, 'alice@example.com'),
 		('Bob', 25, 'bob@example.com')
 	ON CONFLICT (email) DO NOTHING
-	RETURNING id, name, age, email ;
+	RETURNING id, public_id, name, age, email ;
 `
 	rows, err := testBD.Query(query)
 	if err != nil {
@@ -44,17 +46,18 @@ func seedTestUsers(testBD *sql.DB) (map[string]model.User, error) {
 
 	for rows.Next() {
 		var id, age int
-		var name, email string
+		var publicID, name, email string
 
-		err = rows.Scan(&id, &name, &age, &email)
+		err = rows.Scan(&id, &publicID, &name, &age, &email)
 		if err != nil {
 			return nil, err
 		}
 		expected[email] = model.User{
-			ID:    id,
-			Name:  name,
-			Age:   age,
-			Email: email,
+			ID:       id,
+			PublicID: publicID,
+			Name:     name,
+			Age:      age,
+			Email:    email,
 			// Остальные поля можно не заполнять, и они будут с нулевыми значениями
 		}
 	}
@@ -90,7 +93,7 @@ func TestGetUsers(t *testing.T) {
 
 	testRepo := repository.NewUserRepository(TestDB, logger)
 
-	users, err := testRepo.GetAllUsers()
+	users, err := testRepo.GetAllUsers(context.Background())
 	if err != nil {
 		t.Fatalf("не удалось получить пользователей из таблицы тестовой БД: %v", err)
 	}
@@ -119,7 +122,7 @@ func TestGetUserByID(t *testing.T) {
 	testRepo := repository.NewUserRepository(TestDB, logger)
 
 	expectedUser := expected["alice@example.com"]
-	user, err := testRepo.GetUserByID(expectedUser.ID)
+	user, err := testRepo.GetUserByID(context.Background(), expectedUser.ID)
 	if err != nil {
 		t.Errorf("не удалось получить пользователя по ID: %v", err)
 		return
@@ -138,7 +141,7 @@ func TestPostUser(t *testing.T) {
 		Name: "Alice", Age: 30, Email: "alice@example.com",
 	}
 
-	newUser, err := testRepo.PostUser(user)
+	newUser, err := testRepo.PostUser(context.Background(), user)
 	if err != nil {
 		t.Fatalf("ошибка при добавлении пользователя в таблицу тестовой БД: %v", err)
 	}
@@ -147,6 +150,11 @@ func TestPostUser(t *testing.T) {
 		t.Error("ID не был установлен после добавления пользователя")
 	}
 
+	publicID, err := uuid.Parse(newUser.PublicID)
+	if err != nil || publicID.Version() != 7 {
+		t.Errorf("ожидался публичный ID в формате UUIDv7, а получен: %q (%v)", newUser.PublicID, err)
+	}
+
 	if newUser.Name != user.Name || newUser.Age != user.Age || newUser.Email != user.Email {
 		t.Errorf("полученные данные не совпадают. Ожидалось %+v, получено %+v", user, newUser)
 	}
@@ -167,7 +175,7 @@ func TestPutUser(t *testing.T) {
 
 	testRepo := repository.NewUserRepository(TestDB, logger)
 
-	putUser, err := testRepo.PutUser(updatedUser)
+	putUser, err := testRepo.PutUser(context.Background(), updatedUser)
 	if err != nil {
 		t.Fatalf("ошибка при получении пользователя из таблицы тестовой БД %v", err)
 	}
@@ -193,7 +201,7 @@ func TestPatchUser(t *testing.T) {
 		Email: model.StrPtr("new@gmail.com"),
 	}
 
-	patchUser, err := testRepo.PatchUser(updatedUser)
+	patchUser, err := testRepo.PatchUser(context.Background(), updatedUser)
 	if err != nil {
 		t.Fatalf("ошибка при обновлении пользователя в таблице тестовой БД: %v", err)
 	}
@@ -221,12 +229,12 @@ func TestDeleteUser(t *testing.T) {
 	deletedID := users["alice@example.com"].ID
 
 	testRepo := repository.NewUserRepository(TestDB, logger)
-	err = testRepo.DeleteUser(deletedID)
+	err = testRepo.DeleteUser(context.Background(), deletedID)
 	if err != nil {
 		t.Fatalf("ошибка при удалении пользователя из таблицы тестовой БД: %v", err)
 	}
 
-	deletedUser, err := testRepo.GetUserByID(deletedID)
+	deletedUser, err := testRepo.GetUserByID(context.Background(), deletedID)
 	if !errors.Is(err, sql.ErrNoRows) {
 		t.Errorf("ожидалась ошибка sql.ErrNoRows, но получили: %v", err)
 	}
@@ -238,17 +246,45 @@ func TestDeleteUser(t *testing.T) {
 
 	// Если хочешь ещё больше уверенности, можно добавить проверку, что 2-й пользователь остался:
 	otherID := users["bob@example.com"].ID
-	_, err = testRepo.GetUserByID(otherID)
+	_, err = testRepo.GetUserByID(context.Background(), otherID)
 	if err != nil {
 		t.Errorf("пользователь с ID %d (не удаляемый) должен остаться, но не найден: %v", otherID, err)
 	}
 }
 
+func TestResolvePublicID(t *testing.T) {
+	deleteTestUsers(TestDB)
+
+	users, err := seedTestUsers(TestDB)
+	if err != nil {
+		t.Fatalf("ошибка при добавлении пользователей в таблицу тестовой БД: %v", err)
+	}
+	alice := users["alice@example.com"]
+
+	testRepo := repository.NewUserRepository(TestDB, logger)
+
+	id, err := testRepo.ResolvePublicID(context.Background(), alice.PublicID)
+	if err != nil || id != alice.ID {
+		t.Errorf("ожидался ID %d для публичного ID %s, а получен: %d (%v)", alice.ID, alice.PublicID, id, err)
+	}
+
+	_, err = testRepo.ResolvePublicID(context.Background(), uuid.NewString())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ожидалась ошибка sql.ErrNoRows для неизвестного публичного ID, а получена: %v", err)
+	}
+
+	// публичный ID неизменяем
+	_, err = TestDB.Exec("UPDATE users SET public_id = $1 WHERE id = $2", uuid.NewString(), alice.ID)
+	if err == nil {
+		t.Error("ожидалась ошибка при изменении публичного ID")
+	}
+}
+
 func TestGetUser_Negative_IsEmpty(t *testing.T) {
 	deleteTestUsers(TestDB)
 
 	testRepo := repository.NewUserRepository(TestDB, logger)
-	users, err := testRepo.GetAllUsers()
+	users, err := testRepo.GetAllUsers(context.Background())
 	if err != nil {
 		t.Fatalf("ошибка при получении списка пользователей из таблицы тестовой БД: %v", err)
 	}
@@ -270,7 +306,7 @@ func TestGetUserByID_Negative_NotFound(t *testing.T) {
 	err = row.Scan(&nonExistID)
 
 	testRepo := repository.NewUserRepository(TestDB, logger)
-	_, err = testRepo.GetUserByID(nonExistID)
+	_, err = testRepo.GetUserByID(context.Background(), nonExistID)
 	if !errors.Is(err, sql.ErrNoRows) {
 		t.Errorf("Метод не возвращает ошибку, если пользователя не найдено в БД. Ожидалось: %v, вернулось %v", sql.ErrNoRows, err)
 	}
@@ -286,7 +322,7 @@ func TestPostUser_Negative_Unique(t *testing.T) {
 	userEmailChecking := model.User{Email: "alice@example.com"}
 
 	testRepo := repository.NewUserRepository(TestDB, logger)
-	_, err = testRepo.PostUser(userEmailChecking)
+	_, err = testRepo.PostUser(context.Background(), userEmailChecking)
 	if err == nil {
 		t.Fatal("ожидалась ошибка из-за дублирования email, но err == nil")
 	}
@@ -302,7 +338,7 @@ func TestPostUser_Negative_NotNull(t *testing.T) {
 	testRepo := repository.NewUserRepository(TestDB, logger)
 
 	userCheckNotNull := model.User{}
-	_, err = testRepo.PostUser(userCheckNotNull)
+	_, err = testRepo.PostUser(context.Background(), userCheckNotNull)
 	if err == nil {
 		t.Fatal("ожидалась ошибка из-за добавлений пустых значений в NOT NULL поля, но err == nil")
 	}
@@ -321,7 +357,7 @@ func TestPutUser_Negative_ID(t *testing.T) {
 	}
 
 	testRepo := repository.NewUserRepository(TestDB, logger)
-	_, err := testRepo.PutUser(updatedUser)
+	_, err := testRepo.PutUser(context.Background(), updatedUser)
 	if err == nil {
 		t.Fatalf("ожидалась ошибка при попытке обновить пользователя с несуществующим ID (%d), но err == nil", nonExistentID)
 	}
@@ -347,7 +383,7 @@ func TestPutUser_Negative_Email(t *testing.T) {
 	}
 
 	testRepo := repository.NewUserRepository(TestDB, logger)
-	_, err = testRepo.PutUser(conflictUser)
+	_, err = testRepo.PutUser(context.Background(), conflictUser)
 	if err == nil {
 		t.Fatalf("ожидалась ошибка дублирования e-mail, но err == nil")
 	}
@@ -368,7 +404,7 @@ func TestPatchUser_Negative_Unique(t *testing.T) {
 	}
 
 	testRepo := repository.NewUserRepository(TestDB, logger)
-	_, err = testRepo.PatchUser(userCheckID)
+	_, err = testRepo.PatchUser(context.Background(), userCheckID)
 	if err == nil {
 		t.Fatalf("ожидалась ошибка дублирования e-mail, но err == nil")
 	}
@@ -390,7 +426,7 @@ func TestPatchUser_Negative_Unique(t *testing.T) {
 //	}
 //
 //	testRepo := repository.NewUserRepository(TestDB, logger)
-//	_, err = testRepo.PatchUser(userCheckID)
+//	_, err = testRepo.PatchUser(context.Background(), userCheckID)
 //	if err != nil {
 //		t.Fatalf("не ожидалась ошибка, но получена: %v", err) // поправил, убрал старую логику
 //	}
@@ -416,7 +452,7 @@ func TestDeleteUser_Negative_ID(t *testing.T) {
 	}
 
 	testRepo := repository.NewUserRepository(TestDB, logger)
-	err = testRepo.DeleteUser(id)
+	err = testRepo.DeleteUser(context.Background(), id)
 
 	if err == nil {
 		t.Fatalf("ожидалась ошибка при удалении несуществующего ID, но err == nil")
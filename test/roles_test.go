@@ -0,0 +1,109 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"pet/internal/middleware"
+	"pet/internal/repository"
+	"testing"
+)
+
+func TestRolePermissionsInheritance(t *testing.T) {
+	cases := []struct {
+		role       string
+		permission string
+		expected   bool
+	}{
+		{middleware.RoleGuest, middleware.PermUsersRead, true},
+		{middleware.RoleGuest, middleware.PermUsersWrite, false},
+		{middleware.RoleEditor, middleware.PermUsersRead, true}, // унаследовано от guest
+		{middleware.RoleEditor, middleware.PermUsersWrite, true},
+		{middleware.RoleEditor, middleware.PermUsersDelete, false},
+		{middleware.RoleAdmin, middleware.PermUsersWrite, true}, // унаследовано от editor
+		{middleware.RoleAdmin, middleware.PermTransfersWrite, true},
+		{middleware.RoleAdmin, middleware.PermRolesManage, true},
+		{"", middleware.PermUsersRead, false},
+	}
+
+	for _, c := range cases {
+		got := middleware.RoleHasPermission(c.role, c.permission)
+		if got != c.expected {
+			t.Errorf("роль %q, право %q: ожидалось %v, получено %v", c.role, c.permission, c.expected, got)
+		}
+	}
+}
+
+func TestDeleteUser_RequiresAdmin(t *testing.T) {
+	deleteTestUsers(TestDB)
+	users, err := seedTestUsers(TestDB)
+	if err != nil {
+		t.Fatalf("ошибка при добавлении пользователей в таблицу тестовой БД: %v", err)
+	}
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email, password = "rbac@example.com", "long-test-pass-1"
+	loginBody := registerAndLogin(t, testServer.URL, email, password)
+	guestToken, _ := loginBody["access-token"].(string)
+
+	url := testServer.URL + "/users/" + users["bob@example.com"].PublicID
+
+	deleteWithToken := func(token string) int {
+		req, err := http.NewRequest(http.MethodDelete, url, nil)
+		if err != nil {
+			t.Fatalf("ошибка при создании DELETE-запроса: %v", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("ошибка при выполнении DELETE-запроса: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// новый пользователь — guest, удалять нельзя
+	status := deleteWithToken(guestToken)
+	if status != http.StatusForbidden {
+		t.Fatalf("ожидался статус 403 для guest, а получен: %d", status)
+	}
+
+	// и свой аккаунт через /users/{id} не удалить: для этого есть DELETE /me с паролем
+	var ownID string
+	err = TestDB.QueryRow("SELECT public_id FROM users WHERE email = $1", email).Scan(&ownID)
+	if err != nil {
+		t.Fatalf("ошибка при получении ID пользователя: %v", err)
+	}
+	ownReq, err := http.NewRequest(http.MethodDelete, testServer.URL+"/users/"+ownID, nil)
+	if err != nil {
+		t.Fatalf("ошибка при создании DELETE-запроса: %v", err)
+	}
+	ownReq.Header.Set("Authorization", "Bearer "+guestToken)
+	ownResp, err := http.DefaultClient.Do(ownReq)
+	if err != nil {
+		t.Fatalf("ошибка при выполнении DELETE-запроса: %v", err)
+	}
+	ownResp.Body.Close()
+	if ownResp.StatusCode != http.StatusForbidden {
+		t.Errorf("ожидался статус 403 при удалении своего аккаунта через /users/{id}, а получен: %d", ownResp.StatusCode)
+	}
+
+	// назначаем роль admin и логинимся заново, чтобы роль попала в токен
+	testRepo := repository.NewUserRepository(TestDB, logger)
+	err = testRepo.SetUserRoleByEmail(context.Background(), email, middleware.RoleAdmin)
+	if err != nil {
+		t.Fatalf("ошибка при назначении роли: %v", err)
+	}
+
+	resp := postJSON(t, testServer.URL+"/login", "", map[string]string{"email": email, "password": password})
+	defer resp.Body.Close()
+	adminBody := map[string]string{}
+	_ = decodeJSON(resp, &adminBody)
+
+	status = deleteWithToken(adminBody["access-token"])
+	if status != http.StatusNoContent {
+		t.Errorf("ожидался статус 204 для admin, а получен: %d", status)
+	}
+}
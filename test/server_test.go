@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"io"
 	"log"
 	"net/http"
@@ -12,7 +14,6 @@ import (
 	"pet/internal/model"
 	"pet/internal/repository"
 	"pet/internal/server"
-	"strconv"
 	"strings"
 	"testing"
 )
@@ -20,10 +21,11 @@ import (
 // setupTestServer - создаёт временный HTTP-сервер, который автоматически запускается в фоне
 func setupTestServer() *httptest.Server {
 	testLogger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
-	testRepo := repository.NewUserRepository(TestDB, testLogger)
+	testRepo := repository.NewUserRepository(TestDB, zap.NewNop())
 
 	// Инициализируем глобальный логгер в пакете server
 	server.InitLogger(testLogger)
+	server.InitValidator()
 
 	router := server.SetupRoutes(testRepo)
 
@@ -82,9 +84,9 @@ func TestGetUserByIDHandler(t *testing.T) {
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	id := users["alice@example.com"].ID
+	id := users["alice@example.com"].PublicID
 
-	resp, err := http.Get(testServer.URL + "/users/" + strconv.Itoa(id))
+	resp, err := http.Get(testServer.URL + "/users/" + id)
 	if err != nil {
 		t.Fatalf("ошибка при запросе: %v", err)
 	}
@@ -100,8 +102,8 @@ func TestGetUserByIDHandler(t *testing.T) {
 		t.Fatalf("ошибка при декодировании пользователя из JSON: %v", err)
 	}
 
-	if user.ID != id {
-		t.Errorf("ожидался ID %d, получен %d", id, user.ID)
+	if user.PublicID != id {
+		t.Errorf("ожидался ID %s, получен %s", id, user.PublicID)
 	}
 
 	expUser := users["alice@example.com"]
@@ -120,17 +122,10 @@ func TestPostUserHandler(t *testing.T) {
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	newUser := model.User{Name: "New", Age: 1, Email: "new@example.com"}
-	bytesNewUser, err := json.Marshal(newUser)
-	if err != nil {
-		t.Fatalf("ошибка при инкодировании пользователя JSON: %v", err)
-	}
+	adminToken := loginAsAdmin(t, testServer.URL, "post-admin@example.com")
 
-	url := testServer.URL + "/users"
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(bytesNewUser))
-	if err != nil {
-		t.Fatalf("ошибка при создании запроса: %v", err)
-	}
+	newUser := model.CreateUserRequest{Name: "New", Age: 1, Email: "new@example.com", Password: "new-user-pass-1"}
+	resp := postJSON(t, testServer.URL+"/users", adminToken, newUser)
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
@@ -147,8 +142,8 @@ func TestPostUserHandler(t *testing.T) {
 		t.Fatalf("ошибка при декодировании пользователя в JSON: %v", err)
 	}
 
-	if user.ID < 1 {
-		t.Errorf("ожидаемый ID нового пользователя >1, а вернулся: %d", user.ID)
+	if user.PublicID == "" {
+		t.Error("ожидался публичный ID нового пользователя, а вернулся пустой")
 	}
 
 	if user.Name != newUser.Name || user.Age != newUser.Age || user.Email != newUser.Email {
@@ -156,7 +151,7 @@ func TestPostUserHandler(t *testing.T) {
 	}
 
 	// проверка, что результат POST действительно записан в БД, а не просто вернулся "из воздуха"
-	getResp, err := http.Get(fmt.Sprintf("%s/users/%d", testServer.URL, user.ID))
+	getResp, err := http.Get(fmt.Sprintf("%s/users/%s", testServer.URL, user.PublicID))
 	if err != nil {
 		t.Fatalf("ошибка при GET-запросе созданного пользователя: %v", err)
 	}
@@ -184,18 +179,18 @@ func TestPutUserHandler(t *testing.T) {
 		t.Fatalf("ошибка при добавлении пользователей в таблицу тестовой БД: %v", err)
 	}
 
-	id := users["alice@example.com"].ID
+	id := users["alice@example.com"].PublicID
 
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	updatedUser := model.User{ID: id, Name: "New", Age: 1, Email: "new@example.com"}
+	updatedUser := model.User{PublicID: id, Name: "New", Age: 1, Email: "new@example.com"}
 	bytesNewUser, err := json.Marshal(updatedUser)
 	if err != nil {
 		t.Fatalf("ошибка при инкодировании пользователя JSON: %v", err)
 	}
 
-	url := testServer.URL + "/users/" + strconv.Itoa(id)
+	url := testServer.URL + "/users/" + id
 	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(bytesNewUser))
 	if err != nil {
 		t.Fatalf("ошибка при создании PUT-запроса: %v", err)
@@ -222,8 +217,8 @@ func TestPutUserHandler(t *testing.T) {
 		t.Fatalf("ошибка при декодировании пользователя в JSON: %v", err)
 	}
 
-	if user.ID != updatedUser.ID {
-		t.Errorf("ожидаемый ID пользователя: %d, а вернулся: %d", updatedUser.ID, user.ID)
+	if user.PublicID != updatedUser.PublicID {
+		t.Errorf("ожидаемый ID пользователя: %s, а вернулся: %s", updatedUser.PublicID, user.PublicID)
 	}
 
 	if user.Name != updatedUser.Name || user.Age != updatedUser.Age || user.Email != updatedUser.Email {
@@ -231,7 +226,7 @@ func TestPutUserHandler(t *testing.T) {
 	}
 
 	// проверка, что результат PUT действительно записан в БД, а не просто вернулся "из воздуха"
-	getResp, err := http.Get(fmt.Sprintf("%s/users/%d", testServer.URL, user.ID))
+	getResp, err := http.Get(fmt.Sprintf("%s/users/%s", testServer.URL, user.PublicID))
 	if err != nil {
 		t.Fatalf("ошибка при GET-запросе обновленного пользователя: %v", err)
 	}
@@ -259,18 +254,18 @@ func TestPatchUserHandler(t *testing.T) {
 		t.Fatalf("ошибка при добавлении пользователей в таблицу тестовой БД: %v", err)
 	}
 	originalUser := users["alice@example.com"]
-	id := originalUser.ID
+	id := originalUser.PublicID
 
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	updatedUser := model.PartialUser{ID: id, Email: model.StrPtr("new@example.com")}
+	updatedUser := model.PartialUser{PublicID: id, Email: model.StrPtr("new@example.com")}
 	bytesNewUser, err := json.Marshal(updatedUser)
 	if err != nil {
 		t.Fatalf("ошибка при инкодировании пользователя JSON: %v", err)
 	}
 
-	url := testServer.URL + "/users/" + strconv.Itoa(id)
+	url := testServer.URL + "/users/" + id
 	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(bytesNewUser))
 	if err != nil {
 		t.Fatalf("ошибка при создании PATCH-запроса: %v", err)
@@ -297,8 +292,8 @@ func TestPatchUserHandler(t *testing.T) {
 		t.Fatalf("ошибка при декодировании пользователя в JSON: %v", err)
 	}
 
-	if user.ID != updatedUser.ID {
-		t.Errorf("ожидаемый ID пользователя: %d, а вернулся: %d", updatedUser.ID, user.ID)
+	if user.PublicID != updatedUser.PublicID {
+		t.Errorf("ожидаемый ID пользователя: %s, а вернулся: %s", updatedUser.PublicID, user.PublicID)
 	}
 
 	if user.Name != originalUser.Name || user.Age != originalUser.Age || user.Email != *updatedUser.Email {
@@ -306,7 +301,7 @@ func TestPatchUserHandler(t *testing.T) {
 	}
 
 	// проверка, что результат PATCH действительно записан в БД, а не просто вернулся "из воздуха"
-	getResp, err := http.Get(fmt.Sprintf("%s/users/%d", testServer.URL, user.ID))
+	getResp, err := http.Get(fmt.Sprintf("%s/users/%s", testServer.URL, user.PublicID))
 	if err != nil {
 		t.Fatalf("ошибка при GET-запросе обновленного пользователя: %v", err)
 	}
@@ -337,10 +332,10 @@ func TestPatchUser_PartialUpdate_NoChange(t *testing.T) {
 	defer testServer.Close()
 
 	// PATCH-запрос: ничего не передаём кроме ID
-	patch := model.PartialUser{ID: original.ID}
+	patch := model.PartialUser{PublicID: original.PublicID}
 	body, _ := json.Marshal(patch)
 
-	req, err := http.NewRequest(http.MethodPatch, testServer.URL+"/users/"+strconv.Itoa(original.ID), bytes.NewBuffer(body))
+	req, err := http.NewRequest(http.MethodPatch, testServer.URL+"/users/"+original.PublicID, bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("ошибка при создании PATCH-запроса: %v", err)
 	}
@@ -377,12 +372,12 @@ func TestDeleteUserHandler(t *testing.T) {
 	deleteTestUsers(TestDB)
 	users, _ := seedTestUsers(TestDB)
 
-	deletedID := users["alice@example.com"].ID
+	deletedID := users["alice@example.com"].PublicID
 
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	url := testServer.URL + "/users/" + strconv.Itoa(deletedID)
+	url := testServer.URL + "/users/" + deletedID
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		t.Fatalf("ошибка при создании DELETE-запроса: %v", err)
@@ -406,7 +401,7 @@ func TestDeleteUserHandler(t *testing.T) {
 		}
 	}
 
-	getResp, err := http.Get(fmt.Sprintf("%s/users/%d", testServer.URL, deletedID))
+	getResp, err := http.Get(fmt.Sprintf("%s/users/%s", testServer.URL, deletedID))
 	if err != nil {
 		t.Fatalf("ошибка при GET-запросе удаленного пользователя: %v", err)
 	}
@@ -420,17 +415,13 @@ func TestDeleteUserHandler(t *testing.T) {
 func TestGetUserByID_Negative_IDNotFound(t *testing.T) {
 	deleteTestUsers(TestDB)
 
-	row := TestDB.QueryRow("SELECT COALESCE(max(id), 0) + 1000 FROM users")
-	var nonExistID int
-	err := row.Scan(&nonExistID)
-	if err != nil {
-		t.Fatalf("ошибка при считывании данных из БД %v", err)
-	}
+	// публичный ID в верном формате, но такого пользователя нет
+	nonExistID := uuid.NewString()
 
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	resp, err := http.Get(testServer.URL + "/users/" + strconv.Itoa(nonExistID))
+	resp, err := http.Get(testServer.URL + "/users/" + nonExistID)
 	if err != nil {
 		t.Fatalf("ошибка при запросе: %v", err)
 	}
@@ -468,17 +459,9 @@ func TestPostUser_Negative_MissingFields(t *testing.T) {
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	userCheckNotNull := model.User{}
-	bytesUserCheckNotNull, err := json.Marshal(userCheckNotNull)
-	if err != nil {
-		t.Fatalf("ошибка при конвертации в JSON: %v", err)
-	}
+	adminToken := loginAsAdmin(t, testServer.URL, "post-admin@example.com")
 
-	url := testServer.URL + "/users"
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(bytesUserCheckNotNull))
-	if err != nil {
-		t.Fatalf("ошибка при отправке запроса: %v", err)
-	}
+	resp := postJSON(t, testServer.URL+"/users", adminToken, model.CreateUserRequest{})
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnprocessableEntity {
@@ -496,33 +479,36 @@ func TestPostUser_Negative_UniqueFields(t *testing.T) {
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	userCheckEmail := model.User{Name: "New", Age: 1, Email: "alice@example.com"}
-	bytesUserCheckEmail, err := json.Marshal(userCheckEmail)
-	if err != nil {
-		t.Fatalf("ошибка при конвертации в JSON: %v", err)
-	}
+	adminToken := loginAsAdmin(t, testServer.URL, "post-admin@example.com")
 
-	url := testServer.URL + "/users"
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(bytesUserCheckEmail))
-	if err != nil {
-		t.Fatalf("ошибка при отправке запроса: %v", err)
-	}
+	userCheckEmail := model.CreateUserRequest{Name: "New", Age: 1, Email: "alice@example.com", Password: "new-user-pass-1"}
+	resp := postJSON(t, testServer.URL+"/users", adminToken, userCheckEmail)
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusConflict && resp.StatusCode != http.StatusInternalServerError {
-		t.Fatalf("ожидался статус 409 или 500, но получен: %v", resp.StatusCode)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("ожидался статус 409, но получен: %v", resp.StatusCode)
 	}
 }
 
 func TestPostUser_Negative_InvalidJSON(t *testing.T) {
+	deleteTestUsers(TestDB)
+
 	testServer := setupTestServer()
 	defer testServer.Close()
 
+	adminToken := loginAsAdmin(t, testServer.URL, "post-admin@example.com")
+
 	// Невалидный JSON — просто текст
 	invalidJSON := []byte(`{invalid json...`)
 
-	url := testServer.URL + "/users"
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(invalidJSON))
+	req, err := http.NewRequest(http.MethodPost, testServer.URL+"/users", bytes.NewBuffer(invalidJSON))
+	if err != nil {
+		t.Fatalf("ошибка при создании запроса: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		t.Fatalf("ошибка при отправке запроса: %v", err)
 	}
@@ -536,13 +522,9 @@ func TestPostUser_Negative_InvalidJSON(t *testing.T) {
 func TestPutUser_Negative_IDNotFound(t *testing.T) {
 	deleteTestUsers(TestDB)
 
-	row := TestDB.QueryRow("SELECT COALESCE(max(id), 0) + 1000 FROM users")
-	var nonExistID int
-	err := row.Scan(&nonExistID)
-	if err != nil {
-		t.Fatalf("ошибка при считывании данных из БД %v", err)
-	}
-	testUser := model.User{ID: nonExistID, Name: "New", Age: 1, Email: "new@example.com"}
+	// публичный ID в верном формате, но такого пользователя нет
+	nonExistID := uuid.NewString()
+	testUser := model.User{PublicID: nonExistID, Name: "New", Age: 1, Email: "new@example.com"}
 	bytesTestUser, err := json.Marshal(testUser)
 	if err != nil {
 		t.Fatalf("ошибка при конвертации в JSON: %v", err)
@@ -551,7 +533,7 @@ func TestPutUser_Negative_IDNotFound(t *testing.T) {
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	url := testServer.URL + "/users/" + strconv.Itoa(nonExistID)
+	url := testServer.URL + "/users/" + nonExistID
 	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(bytesTestUser))
 	if err != nil {
 		t.Fatalf("ошибка при создании запроса: %v", err)
@@ -564,7 +546,7 @@ func TestPutUser_Negative_IDNotFound(t *testing.T) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNotFound {
-		t.Fatalf("ожидался статус 404 (не найден ID %d), а получен %v", nonExistID, resp.StatusCode)
+		t.Fatalf("ожидался статус 404 (не найден ID %s), а получен %v", nonExistID, resp.StatusCode)
 	}
 }
 
@@ -574,19 +556,19 @@ func TestPutUser_Negative_EmptyFields(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ошибка при добавлении пользователей в таблицу тестовой БД: %v", err)
 	}
-	id := users["alice@example.com"].ID
+	id := users["alice@example.com"].PublicID
 
 	testServer := setupTestServer()
 	defer testServer.Close()
 
 	// PUT-запрос: ничего не передаём кроме ID
-	alice := model.User{ID: id}
+	alice := model.User{PublicID: id}
 	body, err := json.Marshal(alice)
 	if err != nil {
 		t.Fatalf("ошибка при конвертации в JSON: %v", err)
 	}
 
-	url := testServer.URL + "/users/" + strconv.Itoa(id)
+	url := testServer.URL + "/users/" + id
 	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("ошибка при создании запроса: %v", err)
@@ -611,12 +593,12 @@ func TestPutUser_Negative_UniqueFields(t *testing.T) {
 		t.Fatalf("ошибка при добавлении пользователей в таблицу тестовой БД: %v", err)
 	}
 
-	id := users["bob@example.com"].ID
+	id := users["bob@example.com"].PublicID
 	bob := model.User{
-		ID:    id,
-		Name:  "Bob", // добавь имя
-		Age:   30,    // добавь возраст
-		Email: "alice@example.com",
+		PublicID: id,
+		Name:     "Bob", // добавь имя
+		Age:      30,    // добавь возраст
+		Email:    "alice@example.com",
 	}
 	body, err := json.Marshal(bob)
 	if err != nil {
@@ -626,7 +608,7 @@ func TestPutUser_Negative_UniqueFields(t *testing.T) {
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	url := testServer.URL + "/users/" + strconv.Itoa(id)
+	url := testServer.URL + "/users/" + id
 	req, err := http.NewRequest(http.MethodPut, url, bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("ошибка при создании запроса: %v", err)
@@ -652,18 +634,18 @@ func TestPatchUser_Negative_NoUpdatableFields(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ошибка при добавлении пользователей в таблицу тестовой БД: %v", err)
 	}
-	id := users["alice@example.com"].ID
+	id := users["alice@example.com"].PublicID
 
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	alice := model.PartialUser{ID: id}
+	alice := model.PartialUser{PublicID: id}
 	body, err := json.Marshal(alice)
 	if err != nil {
 		t.Fatalf("ошибка при конвертации в JSON: %v", err)
 	}
 
-	url := testServer.URL + "/users/" + strconv.Itoa(id)
+	url := testServer.URL + "/users/" + id
 	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("ошибка при создании запроса: %v", err)
@@ -691,8 +673,8 @@ func TestPatchUser_Negative_UniqueFields(t *testing.T) {
 
 	checkEmail := "alice@example.com"
 
-	id := users["bob@example.com"].ID
-	bob := model.PartialUser{ID: id, Email: &checkEmail}
+	id := users["bob@example.com"].PublicID
+	bob := model.PartialUser{PublicID: id, Email: &checkEmail}
 	body, err := json.Marshal(bob)
 	if err != nil {
 		t.Fatalf("ошибка при конвертации в JSON: %v", err)
@@ -701,7 +683,7 @@ func TestPatchUser_Negative_UniqueFields(t *testing.T) {
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	url := testServer.URL + "/users/" + strconv.Itoa(id)
+	url := testServer.URL + "/users/" + id
 	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(body))
 	if err != nil {
 		t.Fatalf("ошибка при создании запроса: %v", err)
@@ -714,26 +696,22 @@ func TestPatchUser_Negative_UniqueFields(t *testing.T) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusConflict && resp.StatusCode != http.StatusInternalServerError {
-		t.Fatalf("ожидался статус 409 или 500, но получен: %v", resp.StatusCode)
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("ожидался статус 409, но получен: %v", resp.StatusCode)
 	}
 }
 
 func TestPatchUser_Negative_IDNotFound(t *testing.T) {
 	deleteTestUsers(TestDB)
 
-	row := TestDB.QueryRow("SELECT COALESCE(max(id), 0) + 1000 FROM users")
-	var nonExistID int
-	err := row.Scan(&nonExistID)
-	if err != nil {
-		t.Fatalf("ошибка при считывании данных из БД %v", err)
-	}
+	// публичный ID в верном формате, но такого пользователя нет
+	nonExistID := uuid.NewString()
 
 	name := "New"
 	age := 1
 	email := "new@example.com"
 
-	testUser := model.PartialUser{ID: nonExistID, Name: &name, Age: &age, Email: &email}
+	testUser := model.PartialUser{PublicID: nonExistID, Name: &name, Age: &age, Email: &email}
 	bytesTestUser, err := json.Marshal(testUser)
 	if err != nil {
 		t.Fatalf("ошибка при конвертации в JSON: %v", err)
@@ -742,7 +720,7 @@ func TestPatchUser_Negative_IDNotFound(t *testing.T) {
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	url := testServer.URL + "/users/" + strconv.Itoa(nonExistID)
+	url := testServer.URL + "/users/" + nonExistID
 	req, err := http.NewRequest(http.MethodPatch, url, bytes.NewBuffer(bytesTestUser))
 	if err != nil {
 		t.Fatalf("ошибка при создании запроса: %v", err)
@@ -755,24 +733,20 @@ func TestPatchUser_Negative_IDNotFound(t *testing.T) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNotFound {
-		t.Fatalf("ожидался статус 404 (не найден ID %d), а получен %v", nonExistID, resp.StatusCode)
+		t.Fatalf("ожидался статус 404 (не найден ID %s), а получен %v", nonExistID, resp.StatusCode)
 	}
 }
 
 func TestDeleteUser_Negative_IDNotFound(t *testing.T) {
 	deleteTestUsers(TestDB)
 
-	row := TestDB.QueryRow("SELECT COALESCE(max(id), 0) + 1000 FROM users")
-	var nonExistID int
-	err := row.Scan(&nonExistID)
-	if err != nil {
-		t.Fatalf("ошибка при считывании данных из БД %v", err)
-	}
+	// публичный ID в верном формате, но такого пользователя нет
+	nonExistID := uuid.NewString()
 
 	testServer := setupTestServer()
 	defer testServer.Close()
 
-	url := testServer.URL + "/users/" + strconv.Itoa(nonExistID)
+	url := testServer.URL + "/users/" + nonExistID
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		t.Fatalf("ошибка при создании запроса: %v", err)
@@ -785,7 +759,7 @@ func TestDeleteUser_Negative_IDNotFound(t *testing.T) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusNotFound {
-		t.Fatalf("ожидался статус 404 (не найден ID %d), а получен %v", nonExistID, resp.StatusCode)
+		t.Fatalf("ожидался статус 404 (не найден ID %s), а получен %v", nonExistID, resp.StatusCode)
 	}
 }
 
@@ -0,0 +1,143 @@
+package test
+
+import (
+	"net/http"
+	"pet/internal/model"
+	"testing"
+)
+
+// loginWithCookie - логинится через /login и возвращает access-токен и cookie с refresh-токеном
+func loginWithCookie(t *testing.T, baseURL string, email string, password string) (string, *http.Cookie) {
+	t.Helper()
+
+	resp := postJSON(t, baseURL+"/login", "", model.LoginRequest{Email: email, Password: password})
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус логина 200, а получен: %d", resp.StatusCode)
+	}
+
+	var body map[string]string
+	err := decodeJSON(resp, &body)
+	if err != nil {
+		t.Fatalf("ошибка при декодировании ответа /login: %v", err)
+	}
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "refresh-token" {
+			return body["access-token"], cookie
+		}
+	}
+	t.Fatalf("в ответе /login нет cookie refresh-token")
+	return "", nil
+}
+
+// refresh - вызывает /refresh с переданной cookie
+func refresh(t *testing.T, baseURL string, cookie *http.Cookie) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, baseURL+"/refresh", nil)
+	if err != nil {
+		t.Fatalf("ошибка при создании POST-запроса: %v", err)
+	}
+	req.AddCookie(cookie)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("ошибка при выполнении POST-запроса: %v", err)
+	}
+	return resp
+}
+
+func TestSessionsListAndRevoke(t *testing.T) {
+	deleteTestUsers(TestDB)
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email, password = "sessions@example.com", "long-test-pass-1"
+	registerAndLogin(t, testServer.URL, email, password) // первая сессия
+	laptopToken, _ := loginWithCookie(t, testServer.URL, email, password)
+	phoneToken, _ := loginWithCookie(t, testServer.URL, email, password)
+
+	resp := doJSON(t, http.MethodGet, testServer.URL+"/me/sessions", phoneToken, nil)
+	var sessions []model.Session
+	err := decodeJSON(resp, &sessions)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("ошибка при декодировании списка сессий: %v", err)
+	}
+	if len(sessions) != 3 {
+		t.Fatalf("ожидалось 3 сессии, а получено: %d", len(sessions))
+	}
+
+	current := 0
+	for _, s := range sessions {
+		if s.Current {
+			current++
+		}
+	}
+	if current != 1 {
+		t.Errorf("ожидалась ровно одна текущая сессия, а получено: %d", current)
+	}
+
+	// "выйти на всех других устройствах" — токен ноутбука перестаёт работать
+	resp = doJSON(t, http.MethodPost, testServer.URL+"/me/sessions/revoke-others", phoneToken, nil)
+	var revoked model.RevokeSessionsResponse
+	err = decodeJSON(resp, &revoked)
+	resp.Body.Close()
+	if err != nil || revoked.Revoked != 2 {
+		t.Fatalf("ожидалось 2 отозванные сессии, а получено: %d (%v)", revoked.Revoked, err)
+	}
+
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/me", laptopToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("токен отозванной сессии: ожидался статус 401, а получен: %d", resp.StatusCode)
+	}
+
+	resp = doJSON(t, http.MethodGet, testServer.URL+"/me", phoneToken, nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("токен текущей сессии: ожидался статус 200, а получен: %d", resp.StatusCode)
+	}
+}
+
+func TestRefreshRotationAndReuse(t *testing.T) {
+	deleteTestUsers(TestDB)
+
+	testServer := setupTestServer()
+	defer testServer.Close()
+
+	const email, password = "refresh@example.com", "long-test-pass-1"
+	registerAndLogin(t, testServer.URL, email, password)
+	_, firstCookie := loginWithCookie(t, testServer.URL, email, password)
+
+	resp := refresh(t, testServer.URL, firstCookie)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус 200 при обновлении, а получен: %d", resp.StatusCode)
+	}
+
+	var secondCookie *http.Cookie
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "refresh-token" {
+			secondCookie = cookie
+		}
+	}
+	if secondCookie == nil || secondCookie.Value == firstCookie.Value {
+		t.Fatalf("ожидался новый refresh-токен")
+	}
+
+	// повторное использование старого токена отзывает всю сессию, в том числе новый токен
+	resp = refresh(t, testServer.URL, firstCookie)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("повторное использование: ожидался статус 401, а получен: %d", resp.StatusCode)
+	}
+
+	resp = refresh(t, testServer.URL, secondCookie)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("токен отозванной сессии: ожидался статус 401, а получен: %d", resp.StatusCode)
+	}
+}
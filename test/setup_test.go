@@ -2,8 +2,10 @@ package test
 
 import (
 	"database/sql"
+	"go.uber.org/zap"
 	"log"
 	"os"
+	"pet/config"
 	"pet/internal/database"
 	"testing"
 )
@@ -15,29 +17,19 @@ var TestDB *sql.DB
 
 func TestMain(m *testing.M) { // m - менеджер тестов
 	var err error
-	TestDB, err = database.ConnectDB("test_users")
+	TestDB, err = database.ConnectDB("test_users", zap.NewNop())
 	if err != nil {
 		log.Fatalf("не удалось подключиться к тестовой БД: %v", err)
 	}
 
-	err = setupTestSchema(TestDB)
+	err = database.Migrate(TestDB, zap.NewNop())
 	if err != nil {
 		log.Fatalf("не удалось создать схему таблицы users в тестовой БД %v", err)
 	}
 
+	// тесты шлют много запросов с одного IP; лимиты проверяются отдельно в TestRateLimit
+	config.RateLimitEnabled = false
+
 	defer TestDB.Close()
 	os.Exit(m.Run())
 }
-
-func setupTestSchema(testDB *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-	       id SERIAL PRIMARY KEY,
-	       name TEXT NOT NULL,
-	       age INT NOT NULL,
-	       email TEXT UNIQUE NOT NULL
-	)
-`
-	_, err := testDB.Exec(query)
-	return err
-}
@@ -0,0 +1,171 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"go.opentelemetry.io/otel"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"pet/internal/middleware"
+	"pet/internal/repository"
+	"pet/internal/server"
+	"pet/internal/service"
+	"pet/internal/tracing"
+	"strings"
+	"testing"
+)
+
+// exportedSpan — поля спана в файле экспортёра file, нужные для проверки
+type exportedSpan struct {
+	Name        string
+	SpanContext struct {
+		TraceID string
+		SpanID  string
+	}
+	Parent struct {
+		TraceID string
+		SpanID  string
+	}
+	Status struct {
+		Code string
+	}
+}
+
+func TestTracing(t *testing.T) {
+	deleteTestUsers(TestDB)
+	users, err := seedTestUsers(TestDB)
+	if err != nil {
+		t.Fatalf("ошибка при добавлении тестовых пользователей: %v", err)
+	}
+
+	savedProvider := otel.GetTracerProvider()
+	defer otel.SetTracerProvider(savedProvider)
+
+	traceFile := filepath.Join(t.TempDir(), "traces.json")
+	shutdown, err := tracing.Init(context.Background(), "file", traceFile)
+	if err != nil {
+		t.Fatalf("ошибка при настройке трассировки: %v", err)
+	}
+
+	core, logs := observer.New(zap.InfoLevel)
+	server.InitValidator()
+	var handler http.Handler = server.SetupRoutes(repository.NewUserRepository(TestDB, zap.NewNop()))
+	handler = middleware.MidLog()(handler)
+	handler = middleware.WithLogger(zap.New(core))(handler)
+	handler = middleware.Tracing(handler)
+	testServer := httptest.NewServer(handler)
+	defer testServer.Close()
+
+	const (
+		upstreamTrace = "4bf92f3577b34da6a3ce929d0e0e4736"
+		upstreamSpan  = "00f067aa0ba902b7"
+	)
+	req, err := http.NewRequest(http.MethodGet, testServer.URL+"/users/"+users["alice@example.com"].PublicID, nil)
+	if err != nil {
+		t.Fatalf("ошибка при создании запроса: %v", err)
+	}
+	req.Header.Set("traceparent", "00-"+upstreamTrace+"-"+upstreamSpan+"-01")
+	req.Header.Set("X-Request-ID", "req-123")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("ошибка при запросе: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("ожидался статус 200, а получен: %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Request-ID"); got != "req-123" {
+		t.Errorf("ожидался X-Request-ID req-123 в ответе, а получен: %q", got)
+	}
+
+	// без X-Request-ID сервер выдаёт свой
+	resp, err = http.Get(testServer.URL + "/users")
+	if err != nil {
+		t.Fatalf("ошибка при запросе: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("X-Request-ID") == "" {
+		t.Error("ожидался сгенерированный X-Request-ID в ответе")
+	}
+
+	// неудачный вызов сервиса отмечает свой спан ошибкой
+	_, err = service.NewMagicLinkService(repository.NewUserRepository(TestDB, zap.NewNop()), nil, zap.NewNop()).
+		Redeem(context.Background(), "not-a-token")
+	if err == nil {
+		t.Fatal("ожидалась ошибка для неверного токена ссылки")
+	}
+
+	err = shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("ошибка при выгрузке спанов: %v", err)
+	}
+
+	spans := readSpans(t, traceFile)
+
+	if len(spans["GET /users/{id}"]) != 1 {
+		t.Fatalf("ожидался один спан HTTP-запроса, спаны: %v", spans)
+	}
+	httpSpan := spans["GET /users/{id}"][0]
+	if httpSpan.SpanContext.TraceID != upstreamTrace || httpSpan.Parent.SpanID != upstreamSpan {
+		t.Errorf("спан запроса должен продолжать трассу из traceparent, а получено: %+v", httpSpan)
+	}
+
+	sqlInTrace := false
+	for _, span := range spans["SQL SELECT"] {
+		sqlInTrace = sqlInTrace || span.SpanContext.TraceID == upstreamTrace
+	}
+	if !sqlInTrace {
+		t.Errorf("не найден спан SQL-запроса в трассе запроса, спаны: %v", spans)
+	}
+
+	if redeem := spans["MagicLinkService.Redeem"]; len(redeem) != 1 || redeem[0].Status.Code != "Error" {
+		t.Errorf("ожидался спан MagicLinkService.Redeem со статусом Error, а получено: %+v", redeem)
+	}
+
+	// ID трассы и спана в логах запроса, ID запроса — из заголовка
+	entries := logs.FilterMessage("HTTP request completed").FilterField(zap.String("request.id", "req-123")).All()
+	if len(entries) != 1 {
+		t.Fatalf("ожидалась одна запись лога запроса, а получено: %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["trace.id"] != upstreamTrace || fields["span.id"] != httpSpan.SpanContext.SpanID {
+		t.Errorf("ожидались trace.id %s и span.id %s в логе, а получено: %v", upstreamTrace, httpSpan.SpanContext.SpanID, fields)
+	}
+}
+
+// readSpans читает спаны из файла экспортёра file (по одному JSON на строку) и группирует по имени
+func readSpans(t *testing.T, path string) map[string][]exportedSpan {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("ошибка при открытии файла трасс: %v", err)
+	}
+	defer file.Close()
+
+	spans := make(map[string][]exportedSpan)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var span exportedSpan
+		err = json.Unmarshal([]byte(line), &span)
+		if err != nil {
+			t.Fatalf("ошибка при разборе спана: %v", err)
+		}
+		spans[span.Name] = append(spans[span.Name], span)
+	}
+	if err = scanner.Err(); err != nil {
+		t.Fatalf("ошибка при чтении файла трасс: %v", err)
+	}
+	return spans
+}